@@ -1,3 +1,5 @@
+//go:build !windows
+
 /*******************************************************************************
  * IBM Confidential
  * OCO Source Materials
@@ -11,20 +13,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/IBM/ibmcloud-object-storage-plugin/driver"
 	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/mounter"
 	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	cfg "github.com/IBM/ibmcloud-object-storage-plugin/utils/config"
 	optParser "github.com/IBM/ibmcloud-object-storage-plugin/utils/parser"
 	flags "github.com/jessevdk/go-flags"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"io/ioutil"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 	"log"
 	"os"
 	"strings"
+	"time"
 )
 
 const (
@@ -43,6 +53,31 @@ var Version string
 // Build holds the driver build string
 var Build string
 
+// driverFlags are the settings of the driver. The kubelet runs it without flags, they come from the
+// IBMC_S3FS_DRIVER_<SETTING_NAME> environment variables, then from the config file.
+var driverFlags = flag.NewFlagSet("driver", flag.ContinueOnError)
+
+var (
+	driverConfigFile = driverFlags.String("config", "/etc/ibmc-s3fs/driver.toml",
+		"Path of a TOML file setting the driver settings by name")
+	logFile       = driverFlags.String("logFile", logConfig, "Path of the log file of the driver")
+	logLevel      = driverFlags.String("logLevel", "info", "Level of the logs of the driver: debug, info, warn or error")
+	logMaxSizeMB  = driverFlags.Int("logMaxSizeMB", 100, "Size of the log file rotated")
+	logMaxBackups = driverFlags.Int("logMaxBackups", 10, "Number of rotated log files kept")
+	logMaxAgeDays = driverFlags.Int("logMaxAgeDays", 60, "Days the rotated log files are kept")
+)
+
+// driverConfigLoader completes the driver settings, LOGCONFIG is the variable read before the settings existed
+var driverConfigLoader = cfg.FlagLoader{
+	EnvPrefix:  "IBMC_S3FS_DRIVER_",
+	EnvAliases: map[string]string{"logFile": "LOGCONFIG"},
+	ConfigFlag: "config",
+}
+
+// driverConfigSources are the sources of the driver settings, and driverConfigErr the error loading them
+// which leaves the defaults to the settings not loaded yet. Both are logged by the init command.
+var driverConfigSources, driverConfigErr = loadDriverConfig()
+
 var filelogger = getZapLogger()
 
 // Save the io streams, before we divert them to File.
@@ -52,8 +87,9 @@ var stdout = os.Stdout
 // NewS3fsPlugin returns a new instance of the driver that supports mount & unmount operations of s3fs volumes
 func NewS3fsPlugin(logger *zap.Logger) *driver.S3fsPlugin {
 	return &driver.S3fsPlugin{
-		Backend: &backend.COSSessionFactory{},
-		Logger:  logger,
+		Backend:      &backend.COSSessionFactory{},
+		AccessPolicy: &backend.UpdateAPFactory{},
+		Logger:       logger,
 	}
 }
 
@@ -69,6 +105,10 @@ type initCommand struct{}
 func (i *initCommand) Execute(args []string) error {
 	response := NewS3fsPlugin(filelogger).Init()
 	filelogger.Info(":S3FS Driver info:", zap.String("Version", Version), zap.String("Build", Build))
+	if driverConfigErr != nil {
+		filelogger.Error(":Invalid driver configuration", zap.Error(driverConfigErr))
+	}
+	filelogger.Info(":Effective configuration", zap.Any("config", cfg.EffectiveConfig(driverFlags, driverConfigSources)))
 	return printResponse(response)
 }
 
@@ -92,6 +132,7 @@ func maskSecrets(m map[string]string) (map[string]string, []byte, error) {
 	mountOptsLogs["kubernetes.io/secret/service-instance-id"] = "MMM"
 	mountOptsLogs["kubernetes.io/secret/ca-bundle-crt"] = "ZZZ"
 	mountOptsLogs["kubernetes.io/secret/res-conf-apikey"] = "PPP"
+	mountOptsLogs["kubernetes.io/secret/encryption-key"] = "EEE"
 	newString, err := json.Marshal(mountOptsLogs)
 
 	return mountOptsLogs, newString, err
@@ -122,6 +163,7 @@ func (m *mountCommand) Execute(args []string) error {
 
 		return printResponse(interfaces.FlexVolumeResponse{
 			Status:  interfaces.StatusFailure,
+			Code:    interfaces.ErrorCodeBadRequest,
 			Message: fmt.Sprintf("Unexpected number of arguments to 'mount' command: %d", len(args)),
 		})
 	}
@@ -142,6 +184,7 @@ func (m *mountCommand) Execute(args []string) error {
 	if err != nil {
 		return printResponse(interfaces.FlexVolumeResponse{
 			Status:  interfaces.StatusFailure,
+			Code:    interfaces.ErrorCodeBadRequest,
 			Message: fmt.Sprintf("Failed to mount volume to %s due to: %#v", targetMountDir, err),
 		})
 	}
@@ -181,6 +224,7 @@ func (u *unmountCommand) Execute(args []string) error {
 	if len(args) != 1 {
 		return printResponse(interfaces.FlexVolumeResponse{
 			Status:  interfaces.StatusFailure,
+			Code:    interfaces.ErrorCodeBadRequest,
 			Message: fmt.Sprintf("Unexpected number of arguments to 'unmount' command: %d", len(args)),
 		})
 	}
@@ -194,6 +238,117 @@ func (u *unmountCommand) Execute(args []string) error {
 	return printResponse(response)
 }
 
+type getVolumeStatsCommand struct{}
+
+func (g *getVolumeStatsCommand) Execute(args []string) error {
+	filelogger.Info(":GetVolumeStatsCommand args", zap.Strings("input args", args))
+
+	if len(args) != 1 {
+		return printResponse(interfaces.FlexVolumeResponse{
+			Status:  interfaces.StatusFailure,
+			Code:    interfaces.ErrorCodeBadRequest,
+			Message: fmt.Sprintf("Unexpected number of arguments to 'getvolumestats' command: %d", len(args)),
+		})
+	}
+
+	response := NewS3fsPlugin(filelogger).GetVolumeStats(args[0])
+	filelogger.Info(":GetVolumeStatsCommand end", zap.Reflect("response", response))
+	return printResponse(response)
+}
+
+type statusCommand struct{}
+
+func (s *statusCommand) Execute(args []string) error {
+	mounts, err := NewS3fsPlugin(filelogger).ListMounts()
+	if err != nil {
+		return err
+	}
+	if mounts == nil {
+		mounts = []driver.MountStatus{}
+	}
+	output, err := json.MarshalIndent(mounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\n", output)
+	return nil
+}
+
+type mountTestCommand struct {
+	PV           string `long:"pv" description:"Name of the PV to mount, its options and secret are read from the cluster"`
+	Bucket       string `long:"bucket" description:"Bucket to mount instead of a PV"`
+	Endpoint     string `long:"endpoint" description:"Object storage endpoint of the bucket"`
+	StorageClass string `long:"storage-class" description:"Region and storage class of the bucket, as the ibm.io/object-store-storage-class storage class parameter"`
+	Secret       string `long:"secret" description:"Name of the secret holding the credentials of the bucket"`
+	Namespace    string `long:"namespace" default:"default" description:"Namespace of the secret of the bucket"`
+	Kubeconfig   string `long:"kubeconfig" description:"Kubeconfig reading the PV and the secret, the in-cluster configuration when empty"`
+	Dir          string `long:"dir" description:"Directory to mount the volume on, a temporary directory when empty"`
+}
+
+// Execute mounts the volume, probes it and unmounts it, printing the latency and result of each
+// step. It exits with 1 when a step failed.
+func (m *mountTestCommand) Execute(args []string) error {
+	if (m.PV == "") == (m.Bucket == "") || (m.Bucket != "" && (m.Endpoint == "" || m.StorageClass == "" || m.Secret == "")) {
+		return errors.New("mount-test needs either --pv, or --bucket with --endpoint, --storage-class and --secret")
+	}
+
+	dir := m.Dir
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "ibmc-s3fs-mount-test")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+
+	steps := NewS3fsPlugin(filelogger).MountTest(dir, func() (map[string]string, error) {
+		config, err := clientcmd.BuildConfigFromFlags("", m.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if m.PV != "" {
+			opts, data, err := mounter.PVFlexVolume(ctx, client, m.PV)
+			if err != nil {
+				return nil, err
+			}
+			return driver.WithSecretOpts(opts, data), nil
+		}
+		data, err := mounter.SecretData(ctx, client, m.Namespace, m.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return driver.WithSecretOpts(map[string]string{
+			"bucket":                     m.Bucket,
+			"object-store-endpoint":      m.Endpoint,
+			"object-store-storage-class": m.StorageClass,
+		}, data), nil
+	})
+
+	failed := false
+	for _, step := range steps {
+		switch {
+		case step.Skipped:
+			fmt.Fprintf(stdout, "SKIP  %-12s\n", step.Name)
+		case step.Err != nil:
+			fmt.Fprintf(stdout, "FAIL  %-12s %8s  %v\n", step.Name, step.Latency.Round(time.Millisecond), step.Err)
+			failed = true
+		default:
+			fmt.Fprintf(stdout, "PASS  %-12s %8s\n", step.Name, step.Latency.Round(time.Millisecond))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
+
 type flagsOptions struct{}
 
 func main() {
@@ -202,6 +357,9 @@ func main() {
 	var initCommand initCommand
 	var mountCommand mountCommand
 	var unmountCommand unmountCommand
+	var statusCommand statusCommand
+	var getVolumeStatsCommand getVolumeStatsCommand
+	var mountTestCommand mountTestCommand
 	var options flagsOptions
 	var parser = flags.NewParser(&options, flags.Default&^flags.PrintErrors)
 
@@ -234,32 +392,54 @@ func main() {
 		"Unmount Volume",
 		"UnMount given a mount dir",
 		&unmountCommand)
+	/* #nosec */
+	parser.AddCommand("getvolumestats",
+		"Get volume stats",
+		"Returns the capacity, used and available bytes of the volume mounted at a given dir, from the quota and usage of its bucket.",
+		&getVolumeStatsCommand)
+	/* #nosec */
+	parser.AddCommand("status",
+		"List mounted volumes",
+		"Lists the volumes mounted on the node with their bucket, endpoint, FUSE daemon and state.",
+		&statusCommand)
+	/* #nosec */
+	parser.AddCommand("mount-test",
+		"Test mounting a volume",
+		"Mounts a PV or a bucket, writes, reads and lists a probe file and unmounts it, reporting the latency and result of each step.",
+		&mountTestCommand)
 
 	_, err = parser.Parse()
 	if err != nil {
-		var status string
+		var status, code string
 		if strings.Contains(strings.ToLower(err.Error()), "unknown command") {
 			status = interfaces.StatusNotSupported
 		} else {
 			status = interfaces.StatusFailure
+			code = interfaces.ErrorCodeBadRequest
 		}
 		/* #nosec */
 		printResponse(interfaces.FlexVolumeResponse{
 			Status:  status,
+			Code:    code,
 			Message: fmt.Sprintf("Error parsing arguments: %v", err),
 		})
 	}
 }
 
-func getZapLogger() *zap.Logger {
-	logfilepath := getFromEnv("LOGCONFIG", logConfig)
+func loadDriverConfig() (map[string]string, error) {
+	if err := driverFlags.Parse(nil); err != nil {
+		return nil, err
+	}
+	return driverConfigLoader.Load(driverFlags)
+}
 
+func getZapLogger() *zap.Logger {
 	// Configure log rotate
 	lumberjackLogger := &lumberjack.Logger{
-		Filename:   logfilepath,
-		MaxSize:    100, //MB
-		MaxBackups: 10,  //Maximum number of backup
-		MaxAge:     60,  //Days
+		Filename:   *logFile,
+		MaxSize:    *logMaxSizeMB,
+		MaxBackups: *logMaxBackups,
+		MaxAge:     *logMaxAgeDays,
 	}
 	//defer lumberjackLogger.Close()
 
@@ -271,8 +451,10 @@ func getZapLogger() *zap.Logger {
 	//create sync, where zap writes the output
 	zapsync := zapcore.AddSync(lumberjackLogger)
 
-	//Default Log level
-	loglevel := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	//Log level, info when invalid
+	level := zapcore.InfoLevel
+	_ = level.UnmarshalText([]byte(*logLevel))
+	loglevel := zap.NewAtomicLevelAt(level)
 
 	//zapcore
 	loggercore := zapcore.NewCore(encoder, zapsync, loglevel)
@@ -285,6 +467,7 @@ func getZapLogger() *zap.Logger {
 }
 
 func printResponse(f interfaces.FlexVolumeResponse) error {
+	f.Version = interfaces.ResponseVersion
 	responseBytes, err := json.Marshal(f)
 	if err != nil {
 		return err
@@ -298,11 +481,3 @@ func printResponse(f interfaces.FlexVolumeResponse) error {
 	fmt.Fprintf(stdout, "%s", output)
 	return nil
 }
-
-func getFromEnv(key string, defaultVal string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		value = defaultVal
-	}
-	return value
-}
@@ -0,0 +1,39 @@
+//go:build windows
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"os"
+	"runtime"
+)
+
+// Volumes are mounted through FUSE, which Windows nodes do not provide. This build only
+// answers kubelet with a typed error, so that pods scheduled there despite the linux node
+// affinity of the PVs report why instead of an exec failure.
+func main() {
+	response := interfaces.FlexVolumeResponse{
+		Status:  interfaces.StatusNotSupported,
+		Version: interfaces.ResponseVersion,
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init", "mount", "unmount":
+			response.Status = interfaces.StatusFailure
+			response.Code = interfaces.ErrorCodeUnsupportedOS
+			response.Message = "ibmc-s3fs volumes are not supported on " + runtime.GOOS + " nodes"
+		}
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(&response)
+}
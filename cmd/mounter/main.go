@@ -0,0 +1,250 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/mounter"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	log "github.com/IBM/ibmcloud-object-storage-plugin/utils/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var socketPath = flag.String(
+	"socket",
+	mounter.DefaultSocketPath,
+	"Path of the unix socket the FlexVolume driver sends mount requests to",
+)
+
+var nodeName = flag.String(
+	"node-name",
+	os.Getenv("NODE_NAME"),
+	"Name of the node, labeled with the result of the preflight checks. Labeling is disabled when empty",
+)
+
+var forwardMountLogs = flag.Bool(
+	"forward-mount-logs",
+	false,
+	"set 'true' to copy the log files of the volumes to stdout",
+)
+
+var tokenAddress = flag.String(
+	"token-address",
+	mounter.DefaultTokenAddress,
+	"Local address serving IAM tokens of the node instance identity to instance-identity volumes. Disabled when empty",
+)
+
+var metadataEndpoint = flag.String(
+	"metadata-endpoint",
+	mounter.DefaultMetadataEndpoint,
+	"URL of the VPC instance metadata service",
+)
+
+var preflightInterval = flag.Duration(
+	"preflight-interval",
+	5*time.Minute,
+	"How often the node preflight checks are run",
+)
+
+var driverBinary = flag.String(
+	"driver-binary",
+	"",
+	"Path of the FlexVolume driver binary shipped in the image, reinstalled in the plugin directory when removed or changed. Disabled when empty",
+)
+
+var s3fsBinary = flag.String(
+	"s3fs-binary",
+	"",
+	"Path of the s3fs binary shipped in the image, reinstalled on the node when removed or changed. Disabled when empty",
+)
+
+var pluginDir = flag.String(
+	"plugin-dir",
+	mounter.DefaultPluginDir,
+	"FlexVolume plugin directory of kubelet",
+)
+
+var hostBinDir = flag.String(
+	"host-bin-dir",
+	"/host/usr/local/bin",
+	"Directory of the node where s3fs is installed",
+)
+
+var repairInterval = flag.Duration(
+	"repair-interval",
+	time.Minute,
+	"How often the driver installation is checked",
+)
+
+var metricsAddress = flag.String(
+	"metrics-address",
+	"",
+	"Address serving the Prometheus metrics of the health of the volumes mounted on the node on /metrics. Disabled when empty",
+)
+
+// serveMetrics exports the health of the mounts of the node until the server fails
+func serveMetrics(logger *zap.Logger) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&driver.MountMetrics{Plugin: &driver.S3fsPlugin{Logger: logger}})
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	if err := http.ListenAndServe(*metricsAddress, mux); err != nil {
+		logger.Error("Metrics server stopped", zap.Error(err))
+	}
+}
+
+// labelNode runs the preflight checks periodically and records their result on the node
+func labelNode(logger *zap.Logger, client kubernetes.Interface) {
+	for {
+		err := driver.Preflight()
+		if err != nil {
+			logger.Error("Node cannot mount volumes", zap.Error(err))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := mounter.LabelNode(ctx, client, *nodeName, driver.NodeReadyLabel, err == nil); err != nil {
+			logger.Error("Cannot label node", zap.Error(err))
+		}
+		cancel()
+		time.Sleep(*preflightInterval)
+	}
+}
+
+// remountAfterReboot mounts again the volumes the node had before it rebooted, once the mounter listens
+func remountAfterReboot(logger *zap.Logger, client kubernetes.Interface) {
+	for i := 0; i < 30; i++ {
+		if conn, err := net.Dial("unix", *socketPath); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	plugin := &driver.S3fsPlugin{Backend: &backend.COSSessionFactory{}, AccessPolicy: &backend.UpdateAPFactory{}, Logger: logger}
+	plugin.RemountAfterReboot(func(pvName string) (map[string][]byte, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return mounter.PVSecretData(ctx, client, pvName)
+	})
+}
+
+// repairDriver keeps the driver installed on the node, and records its repairs as node events
+func repairDriver(logger *zap.Logger, client kubernetes.Interface) {
+	installer := &mounter.Installer{Logger: logger}
+	if *driverBinary != "" {
+		installer.Files = append(installer.Files, mounter.InstalledFile{
+			Source: *driverBinary,
+			Target: path.Join(*pluginDir, mounter.DriverDirName, mounter.DriverBinaryName),
+		})
+	}
+	if *s3fsBinary != "" {
+		installer.Files = append(installer.Files, mounter.InstalledFile{
+			Source: *s3fsBinary,
+			Target: path.Join(*hostBinDir, "s3fs"),
+		})
+	}
+
+	event := func(eventType, reason, message string) {
+		if client == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mounter.NodeEvent(ctx, client, *nodeName, eventType, reason, message); err != nil {
+			logger.Error("Cannot record node event", zap.Error(err))
+		}
+	}
+
+	for {
+		repaired, err := installer.Repair()
+		if len(repaired) > 0 {
+			logger.Info("Driver installation repaired", zap.Strings("files", repaired))
+			event(v1.EventTypeNormal, "DriverRepaired", "Reinstalled "+strings.Join(repaired, ", "))
+		}
+		if err != nil {
+			logger.Error("Cannot repair driver installation", zap.Error(err))
+			event(v1.EventTypeWarning, "DriverRepairFailed", err.Error())
+		}
+		time.Sleep(*repairInterval)
+	}
+}
+
+// manageMountLogs rotates the log files of the volumes and optionally forwards them to stdout
+func manageMountLogs(logger *zap.Logger) {
+	forwarder := &driver.MountLogForwarder{Out: os.Stdout}
+	for {
+		if *forwardMountLogs {
+			if err := forwarder.Forward(); err != nil {
+				logger.Error("Cannot forward volume log files", zap.Error(err))
+			}
+		}
+		if err := driver.RotateMountLogs(); err != nil {
+			logger.Error("Cannot rotate volume log files", zap.Error(err))
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func main() {
+	logger, _ := log.GetZapLogger()
+	flag.Parse()
+
+	var client kubernetes.Interface
+	if *nodeName != "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			logger.Fatal("Failed to create config:", zap.Error(err))
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			logger.Fatal("Failed to create client:", zap.Error(err))
+		}
+		go labelNode(logger, clientset)
+		go remountAfterReboot(logger, clientset)
+		client = clientset
+	}
+
+	if *driverBinary != "" || *s3fsBinary != "" {
+		go repairDriver(logger, client)
+	}
+
+	go manageMountLogs(logger)
+
+	if *metricsAddress != "" {
+		go serveMetrics(logger)
+	}
+
+	if *tokenAddress != "" {
+		tokenServer := mounter.NewTokenServer(logger, *metadataEndpoint)
+		go func() {
+			if err := tokenServer.ListenAndServe(*tokenAddress); err != nil {
+				logger.Error("Token server stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	server := &mounter.Server{Logger: logger}
+	if err := server.ListenAndServe(*socketPath); err != nil {
+		logger.Fatal("Mounter stopped", zap.Error(err))
+	}
+}
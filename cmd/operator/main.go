@@ -0,0 +1,63 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/IBM/ibmcloud-object-storage-plugin/operator"
+	log "github.com/IBM/ibmcloud-object-storage-plugin/utils/logger"
+	"go.uber.org/zap"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"time"
+)
+
+var master = flag.String(
+	"master",
+	"",
+	"Master URL to build a client config from. Either this or kubeconfig needs to be set if the operator is being run out of cluster.",
+)
+
+var kubeconfig = flag.String(
+	"kubeconfig",
+	"",
+	"Absolute path to the kubeconfig file. Either this or master needs to be set if the operator is being run out of cluster.",
+)
+
+var resyncPeriod = flag.Duration(
+	"resyncPeriod",
+	30*time.Second,
+	"How often the ObjectStoragePlugin resources are reconciled",
+)
+
+func main() {
+	logger, _ := log.GetZapLogger()
+	flag.Parse()
+
+	config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+	if err != nil {
+		logger.Fatal("Failed to create config:", zap.Error(err))
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logger.Fatal("Failed to create client:", zap.Error(err))
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		logger.Fatal("Failed to create client:", zap.Error(err))
+	}
+
+	o := &operator.Operator{Client: clientset, Dynamic: dynamicClient, Logger: logger}
+	logger.Info("Reconciling the ObjectStoragePlugin resources", zap.Duration("resyncPeriod", *resyncPeriod))
+	o.Run(context.Background(), *resyncPeriod)
+}
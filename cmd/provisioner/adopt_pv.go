@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	"io"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// adoptPVCommand is the subcommand binding a released PV to a new PVC instead of running the provisioner
+const adoptPVCommand = "adopt-pv"
+
+// adoptPV runs the adopt-pv subcommand with its arguments and returns its exit code:
+// 0 when the PV is bound to the PVC, or could be with -dryRun, 1 when it cannot be, 2 on bad arguments
+func adoptPV(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(adoptPVCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	pv := flags.String("pv", "", "Name of the Released PV, with the Retain reclaim policy")
+	namespace := flags.String("namespace", "", "Namespace of the PVC to bind the PV to")
+	pvc := flags.String("pvc", "", "Name of the PVC to bind the PV to, created when it does not exist")
+	dryRun := flags.Bool("dryRun", false, "Check the PV, its secret and its bucket without changing anything")
+	master := flags.String("master", "", "Master URL of the cluster")
+	kubeconfig := flags.String("kubeconfig", "", "Absolute path to the kubeconfig of the cluster")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *pv == "" || *namespace == "" || *pvc == "" {
+		fmt.Fprintln(stderr, "adopt-pv needs -pv, -namespace and -pvc")
+		flags.Usage()
+		return 2
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+
+	p := &s3fsprovisioner.IBMS3fsProvisioner{
+		// the report tells about the first attempt, the provisioner retries the calls
+		Backend: &backend.COSSessionFactory{Retry: backend.RetryPolicy{MaxAttempts: 1}},
+		Client:  clientset,
+		Logger:  zap.NewNop(),
+	}
+	claim, err := p.Adopt(context.Background(), s3fsprovisioner.Adoption{
+		PV:        *pv,
+		Namespace: *namespace,
+		Claim:     *pvc,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot adopt PV %s: %v\n", *pv, err)
+		return 1
+	}
+	if *dryRun {
+		fmt.Fprintf(stdout, "PV %s can be bound to PVC %s/%s\n", *pv, claim.Namespace, claim.Name)
+	} else {
+		fmt.Fprintf(stdout, "PV %s bound to PVC %s/%s\n", *pv, claim.Namespace, claim.Name)
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	"os"
+	"strings"
+	"time"
+)
+
+// collectDebugCommand is the subcommand writing a debug bundle instead of running the provisioner
+const collectDebugCommand = "collect-debug"
+
+// collectDebug runs the collect-debug subcommand with its arguments and returns its exit code
+func collectDebug(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(collectDebugCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("output", "", "Archive to write, ibmc-s3fs-debug-<time>.tar.gz when empty")
+	namespace := flags.String("namespace", "kube-system", "Namespace of the provisioner and mounter pods")
+	provisionerName := flags.String("provisioner", "ibm.io/ibmc-s3fs", "Name of the provisioner of the storage classes to gather")
+	logTail := flags.Int64("logTail", 10000, "Number of lines gathered from the end of each log, 0 for whole logs")
+	driverBinary := flags.String("driverBinary", "/usr/local/bin/ibmc-s3fs", "Path of the driver binary in the mounter pods, run to list the mount states of their nodes")
+	timeout := flags.Duration("timeout", 5*time.Minute, "Time allowed to gather the bundle")
+	master := flags.String("master", "", "Master URL of the cluster")
+	kubeconfig := flags.String("kubeconfig", "", "Absolute path to the kubeconfig of the cluster")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output == "" {
+		*output = "ibmc-s3fs-debug-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+
+	file, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the archive: %v\n", err)
+		return 2
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	err = s3fsprovisioner.CollectDebugBundle(ctx, s3fsprovisioner.DebugBundle{
+		Client:      clientset,
+		Namespace:   *namespace,
+		Provisioner: *provisionerName,
+		LogTail:     *logTail,
+		MountStates: func(ctx context.Context, pod *v1.Pod) ([]byte, error) {
+			return execInPod(ctx, config, clientset, pod, *driverBinary, "status")
+		},
+		NodeLog: func(ctx context.Context, node, file string) ([]byte, error) {
+			// kubelet serves the log directory of its node
+			return clientset.CoreV1().RESTClient().Get().
+				AbsPath("/api/v1/nodes", node, "proxy", "logs", file).DoRaw(ctx)
+		},
+	}, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot write the archive: %v\n", err)
+		os.Remove(*output)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Debug bundle written to %s, secret values are redacted\n", *output)
+	return 0
+}
+
+// execInPod runs a command in the first container of a pod and returns its output
+func execInPod(ctx context.Context, config *rest.Config, clientset kubernetes.Interface, pod *v1.Pod, command ...string) ([]byte, error) {
+	request := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{Command: command, Stdout: true, Stderr: true}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
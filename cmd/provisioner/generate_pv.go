@@ -0,0 +1,194 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	log "github.com/IBM/ibmcloud-object-storage-plugin/utils/logger"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/uuid"
+	"go.uber.org/zap"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// generatePVCommand is the subcommand printing a static PV and its PVC instead of running the provisioner
+const generatePVCommand = "generate-pv"
+
+// keyValues is a repeatable key=value flag, the keys missing the ibm.io/ prefix get it
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for key, value := range kv {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(pair string) error {
+	parts := strings.SplitN(pair, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expects key=value, got %q", pair)
+	}
+	key := parts[0]
+	if !strings.Contains(key, "/") {
+		key = "ibm.io/" + key
+	}
+	kv[key] = parts[1]
+	return nil
+}
+
+// generatePV runs the generate-pv subcommand with its arguments and returns its exit code.
+// The PV is built by the provisioner from a storage class and a PVC made of the flags, so that
+// it holds the options and annotations a provisioned PV would, validated the same way.
+func generatePV(args []string, stdout, stderr io.Writer) int {
+	parameters, annotations := keyValues{}, keyValues{}
+	flags := flag.NewFlagSet(generatePVCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	name := flags.String("name", "", "Name of the PV and of its PVC")
+	namespace := flags.String("namespace", "default", "Namespace of the PVC")
+	bucket := flags.String("bucket", "", "Existing bucket of the volume")
+	objectPath := flags.String("objectPath", "", "Path in the bucket mounted by the volume")
+	endpoint := flags.String("endpoint", "", "Object storage endpoint of the bucket")
+	storageClass := flags.String("storageClass", "", "Region and storage class of the bucket, as the ibm.io/object-store-storage-class storage class parameter")
+	secret := flags.String("secret", "", "Name of the secret holding the credentials, in the namespace of the PVC")
+	iamEndpoint := flags.String("iamEndpoint", "https://iam.cloud.ibm.com", "IAM endpoint of the API key of the secret")
+	size := flags.String("size", "8Gi", "Capacity of the PV and request of the PVC")
+	accessMode := flags.String("accessMode", string(v1.ReadWriteMany), "Access mode of the PV and PVC")
+	reclaimPolicy := flags.String("reclaimPolicy", string(v1.PersistentVolumeReclaimRetain), "Reclaim policy of the PV")
+	mountOptions := flags.String("mountOptions", "", "Comma-separated s3fs options of the volume, as the mountOptions of a storage class")
+	check := flags.Bool("check", false, "Read the secret from the cluster and check that it can access the bucket")
+	master := flags.String("master", "", "Master URL to read the secret from, with -check")
+	kubeconfig := flags.String("kubeconfig", "", "Absolute path to the kubeconfig to read the secret with, with -check")
+	flags.Var(parameters, "p", "Storage class parameter of the volume as key=value, the ibm.io/ prefix may be left out; repeatable")
+	flags.Var(annotations, "a", "PVC annotation of the volume as key=value, the ibm.io/ prefix may be left out; repeatable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *name == "" || *bucket == "" || *endpoint == "" || *storageClass == "" || *secret == "" {
+		fmt.Fprintln(stderr, "generate-pv needs -name, -bucket, -endpoint, -storageClass and -secret")
+		flags.Usage()
+		return 2
+	}
+	quantity, err := resource.ParseQuantity(*size)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid -size: %v\n", err)
+		return 2
+	}
+
+	parameters["ibm.io/object-store-endpoint"] = *endpoint
+	parameters["ibm.io/object-store-storage-class"] = *storageClass
+	parameters["ibm.io/iam-endpoint"] = *iamEndpoint
+	annotations["ibm.io/bucket"] = *bucket
+	annotations["ibm.io/secret-name"] = *secret
+	annotations["ibm.io/auto-create-bucket"] = "false"
+	annotations["ibm.io/auto-delete-bucket"] = "false"
+	if *objectPath != "" {
+		annotations["ibm.io/object-path"] = *objectPath
+	}
+
+	// the volume is printed on stdout, the provisioner logs are left out
+	log.ZapLogger = zap.NewNop()
+	p := &s3fsprovisioner.IBMS3fsProvisioner{
+		Backend:       &backend.COSSessionFactory{},
+		Logger:        zap.NewNop(),
+		UUIDGenerator: uuid.NewCryptoGenerator(),
+	}
+	if *check {
+		config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+		if err == nil {
+			p.Client, err = kubernetes.NewForConfig(config)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+			return 2
+		}
+	} else {
+		// the secret is only looked up for its CA bundle, the bucket is not checked
+		annotations["ibm.io/validate-bucket"] = "no"
+		p.Backend = &fake.ObjectStorageSessionFactory{}
+		p.Client = fakeclient.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: *secret, Namespace: *namespace},
+			Type:       "ibm/ibmc-s3fs",
+		})
+	}
+	defaultProvisionerSettings()
+
+	policy := v1.PersistentVolumeReclaimPolicy(*reclaimPolicy)
+	noStorageClass := ""
+	class := &storagev1.StorageClass{ReclaimPolicy: &policy, Parameters: parameters}
+	if *mountOptions != "" {
+		class.MountOptions = strings.Split(*mountOptions, ",")
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: *name, Namespace: *namespace, Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.PersistentVolumeAccessMode(*accessMode)},
+			Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: quantity}},
+			StorageClassName: &noStorageClass,
+			VolumeName:       *name,
+		},
+	}
+	pv, _, err := p.Provision(context.Background(), controller.ProvisionOptions{
+		PVName:       *name,
+		PVC:          pvc,
+		StorageClass: class,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid volume: %v\n", err)
+		return 1
+	}
+	pv.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"}
+	pv.Spec.ClaimRef = &v1.ObjectReference{Namespace: *namespace, Name: *name}
+
+	// the PVC binds to the PV by name, the annotations are kept on the PV only
+	pvc.Annotations = nil
+	for _, object := range []interface{}{pv, pvc} {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot render the volume: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "---\n%s", data)
+	}
+	return 0
+}
+
+// defaultProvisionerSettings leaves the operator settings of the provisioner to their defaults,
+// for the subcommands provisioning volumes without the flags of the provisioner
+func defaultProvisionerSettings() {
+	disabled := false
+	for _, setting := range []**bool{&s3fsprovisioner.ConfigBucketAccessPolicy, &s3fsprovisioner.ConfigQuotaLimit,
+		&s3fsprovisioner.ConfigNodeReadinessAffinity, &s3fsprovisioner.ConfigForbidPublicBuckets} {
+		if *setting == nil {
+			*setting = &disabled
+		}
+	}
+	if s3fsprovisioner.ConfigExtraMountOptionsAllowlist == nil {
+		allowlist := ""
+		s3fsprovisioner.ConfigExtraMountOptionsAllowlist = &allowlist
+	}
+}
@@ -0,0 +1,117 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"text/tabwriter"
+	"time"
+)
+
+// listOrphanBucketsCommand is the subcommand listing the buckets no PV refers to instead of running the provisioner
+const listOrphanBucketsCommand = "list-orphan-buckets"
+
+// listOrphanBuckets runs the list-orphan-buckets subcommand with its arguments and returns its exit code.
+// Nothing is deleted, the buckets are printed and optionally written as a deletion plan.
+func listOrphanBuckets(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(listOrphanBucketsCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	secret := flags.String("secret", "", "Name of the secret of the service instance to audit")
+	namespace := flags.String("namespace", "default", "Namespace of the secret")
+	endpoint := flags.String("endpoint", "", "Object storage endpoint of the service instance, the ibm.io/object-store-endpoint of its storage classes")
+	region := flags.String("region", "us-standard", "Region signing the requests of HMAC credentials")
+	iamEndpoint := flags.String("iamEndpoint", "https://iam.cloud.ibm.com", "IAM endpoint of the API key of the secret")
+	prefix := flags.String("prefix", "", "Prefix of the buckets to audit, the prefix of the buckets created by the provisioner when empty")
+	minAge := flags.Duration("minAge", time.Hour, "Leave out the buckets created less than minAge ago, and those of unknown age")
+	size := flags.Bool("size", false, "List the objects of every orphan bucket to print its size")
+	plan := flags.String("plan", "", "File to write the JSON deletion plan of the orphan buckets to")
+	master := flags.String("master", "", "Master URL of the cluster")
+	kubeconfig := flags.String("kubeconfig", "", "Absolute path to the kubeconfig of the cluster")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *secret == "" || *endpoint == "" {
+		fmt.Fprintln(stderr, "list-orphan-buckets needs -secret and -endpoint")
+		flags.Usage()
+		return 2
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create the client: %v\n", err)
+		return 2
+	}
+
+	p := &s3fsprovisioner.IBMS3fsProvisioner{
+		Backend: &backend.COSSessionFactory{},
+		Client:  clientset,
+		Logger:  zap.NewNop(),
+	}
+	audit := s3fsprovisioner.OrphanAudit{
+		SecretName:      *secret,
+		SecretNamespace: *namespace,
+		Endpoint:        *endpoint,
+		Region:          *region,
+		IAMEndpoint:     *iamEndpoint,
+		Prefix:          *prefix,
+		MinAge:          *minAge,
+		WithSize:        *size,
+	}
+	orphans, err := p.ListOrphanBuckets(context.Background(), audit)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot list the orphan buckets: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BUCKET\tAGE\tSIZE\tOBJECTS")
+	for _, orphan := range orphans {
+		age, bytes, objects := "<unknown>", "<unknown>", "<unknown>"
+		if !orphan.Created.IsZero() {
+			age = duration.HumanDuration(time.Since(orphan.Created))
+		}
+		if orphan.Usage != nil {
+			bytes = resource.NewQuantity(orphan.Usage.BytesUsed, resource.BinarySI).String()
+			objects = fmt.Sprint(orphan.Usage.ObjectCount)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", orphan.Name, age, bytes, objects)
+	}
+	w.Flush()
+
+	if *plan != "" {
+		data, err := json.MarshalIndent(s3fsprovisioner.NewOrphanDeletionPlan(audit, orphans), "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(*plan, append(data, '\n'), 0600)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot write the deletion plan: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
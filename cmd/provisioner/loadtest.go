@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	log "github.com/IBM/ibmcloud-object-storage-plugin/utils/logger"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/uuid"
+	"go.uber.org/zap"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"os"
+	"runtime/pprof"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"strconv"
+)
+
+// loadTestCommand is the subcommand measuring Provision instead of running the provisioner
+const loadTestCommand = "load-test"
+
+// loadTest runs the load-test subcommand with its arguments and returns its exit code:
+// 0 when every volume was provisioned, 1 when one failed, 2 on bad arguments
+func loadTest(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(loadTestCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	volumes := flags.Int("volumes", 1000, "Number of volumes to provision")
+	concurrency := flags.Int("concurrency", 16, "Number of volumes provisioned at once")
+	fakeServer := flags.Bool("fakeServer", true, "Send the object storage calls to an in-memory object storage server, exercising the S3 client; they are stubbed out when false")
+	cpuProfile := flags.String("cpuprofile", "", "File to write the CPU profile of the load test to")
+	memProfile := flags.String("memprofile", "", "File to write the allocation profile of the load test to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *volumes < 1 || *concurrency < 1 {
+		fmt.Fprintln(stderr, "load-test needs -volumes and -concurrency of at least 1")
+		return 2
+	}
+
+	const namespace, secretName = "default", "load-test"
+	log.ZapLogger = zap.NewNop()
+	defaultProvisionerSettings()
+	p := &s3fsprovisioner.IBMS3fsProvisioner{
+		Backend:       &fake.ObjectStorageSessionFactory{},
+		Logger:        zap.NewNop(),
+		UUIDGenerator: uuid.NewCryptoGenerator(),
+		Client: fakeclient.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace},
+			Type:       "ibm/ibmc-s3fs",
+			Data:       map[string][]byte{"access-key": []byte("access-key"), "secret-key": []byte("secret-key")},
+		}),
+	}
+	endpoint := "https://s3.us-south.cloud-object-storage.appdomain.cloud"
+	if *fakeServer {
+		server := fake.NewCOSServer()
+		defer server.Close()
+		endpoint = server.URL
+		p.Backend = &backend.COSSessionFactory{}
+	}
+
+	policy := v1.PersistentVolumeReclaimDelete
+	class := &storagev1.StorageClass{
+		ReclaimPolicy: &policy,
+		Parameters: map[string]string{
+			"ibm.io/object-store-endpoint":      endpoint,
+			"ibm.io/object-store-storage-class": "us-south-standard",
+			"ibm.io/iam-endpoint":               "https://iam.cloud.ibm.com",
+			"ibm.io/chunk-size-mb":              "16",
+			"ibm.io/parallel-count":             "2",
+			"ibm.io/multireq-max":               "4",
+			"ibm.io/stat-cache-size":            "1000",
+			"ibm.io/tls-cipher-suite":           "AESGCM",
+			"ibm.io/debug-level":                "warn",
+		},
+	}
+	options := func(i int) controller.ProvisionOptions {
+		name := "load-test-" + strconv.Itoa(i)
+		return controller.ProvisionOptions{
+			PVName:       name,
+			StorageClass: class,
+			PVC: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Annotations: map[string]string{
+						"ibm.io/secret-name":        secretName,
+						"ibm.io/auto-create-bucket": "true",
+						"ibm.io/auto-delete-bucket": "true",
+					},
+				},
+				Spec: v1.PersistentVolumeClaimSpec{
+					AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteMany},
+					Resources:   v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}},
+				},
+			},
+		}
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err == nil {
+			defer f.Close()
+			err = pprof.StartCPUProfile(f)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot profile the CPU: %v\n", err)
+			return 2
+		}
+	}
+	report := p.LoadTest(context.Background(), s3fsprovisioner.LoadTest{Volumes: *volumes, Concurrency: *concurrency, Options: options})
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err == nil {
+			err = pprof.Lookup("allocs").WriteTo(f, 0)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot write the allocation profile: %v\n", err)
+			return 2
+		}
+	}
+
+	fmt.Fprintf(stdout, "volumes:     %d (%d failed)\n", report.Volumes, report.Failures)
+	fmt.Fprintf(stdout, "duration:    %s\n", report.Duration)
+	fmt.Fprintf(stdout, "throughput:  %.1f volumes/s\n", report.Throughput)
+	fmt.Fprintf(stdout, "latency:     p50 %s, p90 %s, p99 %s, max %s\n", report.P50, report.P90, report.P99, report.Max)
+	fmt.Fprintf(stdout, "allocations: %d bytes, %d objects per volume\n", report.AllocBytes, report.Allocs)
+	if report.Failures > 0 {
+		fmt.Fprintf(stdout, "first error: %v\n", report.FirstError)
+		return 1
+	}
+	return 0
+}
@@ -13,9 +13,11 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	ibmprovider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider"
 	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
 	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
 	cfg "github.com/IBM/ibmcloud-object-storage-plugin/utils/config"
 	grpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client"
 	log "github.com/IBM/ibmcloud-object-storage-plugin/utils/logger"
@@ -26,21 +28,38 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"net/http"
+	"os"
 	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	failedRetryThreshold = 1
 	resyncPeriod         = 30 * time.Second
+	migrationPeriod      = 10 * time.Minute
 )
 
+// configLoader completes the flags of the provisioner from the environment and the config file,
+// CLUSTER_ID and DEBUG_TRACE are the variables read before the flags existed
+var configLoader = cfg.FlagLoader{
+	EnvPrefix:  "IBMC_S3FS_",
+	EnvAliases: map[string]string{"clusterID": "CLUSTER_ID", "debugTrace": "DEBUG_TRACE", "provisioner-name": "IBMC_S3FS_PROVISIONER_NAME"},
+	ConfigFlag: "config",
+}
+
 var provisioner = flag.String(
 	"provisioner",
 	"ibm.io/ibmc-s3fs",
 	"Name of the provisioner. The provisioner will only provision volumes for claims that request a StorageClass with a provisioner field set equal to this name.")
 
+var provisionerNames = flag.String(
+	"provisioner-name",
+	"",
+	"Comma separated names of the provisioner, served side by side, e.g. to rename the StorageClasses without orphaning their claims. Replaces -provisioner when set.")
+
 var master = flag.String(
 	"master",
 	"",
@@ -76,7 +95,300 @@ var leaseTermLimit = flag.Duration(
 	"Maximum time that a provisioner can maintain a lease",
 )
 
+var cosMaxIdleConns = flag.Int(
+	"cosMaxIdleConns",
+	backend.DefaultTransportConfig.MaxIdleConns,
+	"Maximum idle connections kept open to the object storage endpoint",
+)
+
+var cosIdleConnTimeout = flag.Duration(
+	"cosIdleConnTimeout",
+	backend.DefaultTransportConfig.IdleConnTimeout,
+	"How long an idle connection to the object storage is kept open",
+)
+
+var cosTLSHandshakeTimeout = flag.Duration(
+	"cosTLSHandshakeTimeout",
+	backend.DefaultTransportConfig.TLSHandshakeTimeout,
+	"Timeout of the TLS handshake with the object storage",
+)
+
+var cosResponseHeaderTimeout = flag.Duration(
+	"cosResponseHeaderTimeout",
+	backend.DefaultTransportConfig.ResponseHeaderTimeout,
+	"How long to wait for the response headers of the object storage once a request is sent",
+)
+
+var cosKeepAlive = flag.Duration(
+	"cosKeepAlive",
+	backend.DefaultTransportConfig.KeepAlive,
+	"Interval of the TCP keep-alive probes of the object storage connections",
+)
+
+var cosRetryMaxAttempts = flag.Int(
+	"cosRetryMaxAttempts",
+	backend.DefaultRetryPolicy.MaxAttempts,
+	"How many times an object storage call is tried, retries included",
+)
+
+var cosRetryBaseDelay = flag.Duration(
+	"cosRetryBaseDelay",
+	backend.DefaultRetryPolicy.BaseDelay,
+	"Delay cap of the first retry of an object storage call, doubled at each retry",
+)
+
+var cosRetryMaxBackoff = flag.Duration(
+	"cosRetryMaxBackoff",
+	backend.DefaultRetryPolicy.MaxBackoff,
+	"Maximum delay between two attempts of an object storage call",
+)
+
+var cosRetryableErrors = flag.String(
+	"cosRetryableErrors",
+	strings.Join(backend.DefaultRetryPolicy.RetryableClasses, ","),
+	"Comma-separated classes of the object storage errors that are retried: throttling, server, connection, timeout",
+)
+
+var cosBreakerFailureThreshold = flag.Int(
+	"cosBreakerFailureThreshold",
+	backend.DefaultCircuitBreakerConfig.FailureThreshold,
+	"Consecutive failures to reach an object storage endpoint after which its calls fail fast, negative to disable",
+)
+
+var cosBreakerOpenDuration = flag.Duration(
+	"cosBreakerOpenDuration",
+	backend.DefaultCircuitBreakerConfig.OpenDuration,
+	"How long the calls to an unavailable object storage endpoint fail fast before it is tried again",
+)
+
+var cosRateLimit = flag.Float64(
+	"cosRateLimit",
+	0,
+	"Requests per second sent to the object storage by the provisioner, retries included, 0 for no limit",
+)
+
+var cosRateLimitBurst = flag.Int(
+	"cosRateLimitBurst",
+	10,
+	"Requests sent at once to the object storage after an idle period, when cosRateLimit is set",
+)
+
+var simulateCOS = flag.Bool(
+	"simulateCOS",
+	false,
+	"Serve the object storage calls from an in-memory object storage instead of the endpoints of the storage classes, for dry-runs",
+)
+
+var migrateAnnotations = flag.Bool(
+	"migrateAnnotations",
+	false,
+	"set 'true' to rewrite the deprecated ibm.io/endpoint and ibm.io/region annotations of the claims and volumes, recording an event for each",
+)
+
+var defaultChunkSizeMB = flag.Int(
+	"defaultChunkSizeMB",
+	0,
+	"Default ibm.io/chunk-size-mb of the storage classes leaving it empty, after the cluster-wide defaults, unset when 0",
+)
+
+var maxChunkSizeMB = flag.Int(
+	"maxChunkSizeMB",
+	0,
+	"Highest ibm.io/chunk-size-mb of the PVCs and storage classes, unlimited when 0",
+)
+
+var defaultParallelCount = flag.Int(
+	"defaultParallelCount",
+	0,
+	"Default ibm.io/parallel-count of the storage classes leaving it empty, after the cluster-wide defaults, unset when 0",
+)
+
+var maxParallelCount = flag.Int(
+	"maxParallelCount",
+	0,
+	"Highest ibm.io/parallel-count of the PVCs and storage classes, unlimited when 0",
+)
+
+var defaultMultiReqMax = flag.Int(
+	"defaultMultiReqMax",
+	0,
+	"Default ibm.io/multireq-max of the storage classes leaving it empty, after the cluster-wide defaults, unset when 0",
+)
+
+var maxMultiReqMax = flag.Int(
+	"maxMultiReqMax",
+	0,
+	"Highest ibm.io/multireq-max of the PVCs and storage classes, unlimited when 0",
+)
+
+var defaultStatCacheSize = flag.Int(
+	"defaultStatCacheSize",
+	0,
+	"Default ibm.io/stat-cache-size of the storage classes leaving it empty, after the cluster-wide defaults, unset when 0",
+)
+
+var maxStatCacheSize = flag.Int(
+	"maxStatCacheSize",
+	0,
+	"Highest ibm.io/stat-cache-size of the PVCs and storage classes, unlimited when 0",
+)
+
+var tuningCapPolicy = flag.String(
+	"tuningCapPolicy",
+	"reject",
+	"reject the PVCs exceeding a max flag, or clamp their values to it",
+)
+
+var namespaceMetadataMapping = flag.String(
+	"namespaceMetadataMapping",
+	"",
+	"Comma separated label:<key>[=<target>] or annotation:<key>[=<target>] of the namespaces copied to the labels of their PVs and the tags of the buckets created for them",
+)
+
+var requesterAnnotation = flag.String(
+	"requesterAnnotation",
+	s3fsprovisioner.AnnotationRequestedBy,
+	"Annotation of the PVCs naming the identity that created them, set by an admission webhook or an audit tool, recorded on their PVs and buckets, empty to disable",
+)
+
+var globalDefaultsConfigMap = flag.String(
+	"globalDefaultsConfigMap",
+	"",
+	"<namespace>/<name> of the ConfigMap holding the cluster-wide defaults, overrides and caps of the storage class parameters, watched for changes",
+)
+
+var namespaceQuotaConfigMap = flag.String(
+	"namespaceQuotaConfigMap",
+	"",
+	"<namespace>/<name> of the ConfigMap holding the highest number of volumes of each namespace, watched for changes",
+)
+
+var admissionWebhookAddress = flag.String(
+	"admissionWebhookAddress",
+	"",
+	"Address of the HTTPS validating admission webhook rejecting the PVCs the provisioner would refuse, served on /validate, empty to disable",
+)
+
+var admissionWebhookCertFile = flag.String(
+	"admissionWebhookCertFile",
+	"",
+	"Path of the TLS certificate of the admission webhook",
+)
+
+var admissionWebhookKeyFile = flag.String(
+	"admissionWebhookKeyFile",
+	"",
+	"Path of the TLS key of the admission webhook",
+)
+
+var progressEventInterval = flag.Duration(
+	"progressEventInterval",
+	30*time.Second,
+	"Interval of the progress events recorded on the PVCs and PVs while their buckets are created or deleted, 0 to disable",
+)
+
+var provisionRetryBackoff = flag.Duration(
+	"provisionRetryBackoff",
+	10*time.Second,
+	"Backoff after the first failed provisioning of a PVC, doubled on every failure and recorded on the PVC so that it survives restarts, 0 to disable",
+)
+
+var provisionRetryBackoffMax = flag.Duration(
+	"provisionRetryBackoffMax",
+	10*time.Minute,
+	"Highest backoff of the failed provisionings of a PVC",
+)
+
+var missingSecretPolicy = flag.String(
+	"missingSecretPolicy",
+	"fail",
+	"Deletion of the buckets of the PVs whose secret is gone: fail keeps the PV, skip deletes the PV and keeps the bucket, fallback deletes the bucket with -cleanupSecret",
+)
+
+var cleanupSecret = flag.String(
+	"cleanupSecret",
+	"",
+	"<namespace>/<name> of the secret deleting the buckets with -missingSecretPolicy=fallback",
+)
+
+var configFile = flag.String(
+	"config",
+	"",
+	"Path of a TOML file setting the flags by name, the command line and the IBMC_S3FS_<FLAG_NAME> environment variables take precedence",
+)
+
+var clusterID = flag.String(
+	"clusterID",
+	"",
+	"ID of the cluster logged with the requests, read from the cluster-info ConfigMap of kube-system when empty",
+)
+
+var debugTrace = flag.Bool(
+	"debugTrace",
+	false,
+	"set 'true' to log at debug level",
+)
+
+var metricsPort = flag.Int(
+	"metricsPort",
+	0,
+	"Port of the Prometheus metrics of the provisioner, 0 to disable",
+)
+
+var usageMeteringPeriod = flag.Duration(
+	"usageMeteringPeriod",
+	0,
+	"Period of the metering of the usage of the buckets of the volumes per namespace, exported as metrics, 0 to disable",
+)
+
+var usageReportPath = flag.String(
+	"usageReportPath",
+	"/usage",
+	"Path of the JSON, or CSV with ?format=csv, report of the last usage metering on the metrics port, empty to disable",
+)
+
+var backupCheckPeriod = flag.Duration(
+	"backupCheckPeriod",
+	time.Minute,
+	"Period of the check of the volumes due for a backup to their ibm.io/backup-bucket, 0 to disable",
+)
+
+// registerBackends returns the backends the storage classes may select with ibm.io/backend besides the default one,
+// the session factories of new backends are registered here. Their volumes must be mountable by the driver.
+func registerBackends(logger *zap.Logger, cosSessionFactory *backend.COSSessionFactory) *backend.Registry {
+	backends := &backend.Registry{}
+	// the S3 compatible object storages are called with the client of IBM Cloud Object Storage and HMAC keys
+	s3SessionFactory := &backend.COSSessionFactory{
+		Transport: cosSessionFactory.Transport,
+		Retry:     cosSessionFactory.Retry,
+		Breaker:   cosSessionFactory.Breaker,
+		Endpoint:  cosSessionFactory.Endpoint,
+		RateLimit: cosSessionFactory.RateLimit,
+	}
+	if err := backends.Register(backend.S3BackendName, s3SessionFactory); err != nil {
+		logger.Fatal("Cannot register the object storage backend", zap.String("backend", backend.S3BackendName), zap.Error(err))
+	}
+	return backends
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case validateSecretCommand:
+			os.Exit(validateSecret(os.Args[2:], os.Stdout, os.Stderr))
+		case generatePVCommand:
+			os.Exit(generatePV(os.Args[2:], os.Stdout, os.Stderr))
+		case listOrphanBucketsCommand:
+			os.Exit(listOrphanBuckets(os.Args[2:], os.Stdout, os.Stderr))
+		case collectDebugCommand:
+			os.Exit(collectDebug(os.Args[2:], os.Stdout, os.Stderr))
+		case loadTestCommand:
+			os.Exit(loadTest(os.Args[2:], os.Stdout, os.Stderr))
+		case adoptPVCommand:
+			os.Exit(adoptPV(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	var err error
 	logger, _ := log.GetZapLogger()
 	loggerLevel := zap.NewAtomicLevel()
@@ -103,22 +415,53 @@ func main() {
 		"set 'true' to configure bucket quota limit",
 	)
 
+	s3fsprovisioner.ConfigExtraMountOptionsAllowlist = flag.String(
+		"extraMountOptionsAllowlist",
+		"",
+		"Comma-separated s3fs options users may set with the extra-mount-options annotation, a built-in list is used when empty",
+	)
+
+	s3fsprovisioner.ConfigForbidPublicBuckets = flag.Bool(
+		"forbidPublicBuckets",
+		false,
+		"set 'true' to refuse the storage classes creating public-read buckets",
+	)
+
+	s3fsprovisioner.ConfigRejectDeprecatedAnnotations = flag.Bool(
+		"rejectDeprecatedAnnotations",
+		false,
+		"set 'true' to refuse the PVCs using the deprecated ibm.io/endpoint and ibm.io/region annotations",
+	)
+
+	s3fsprovisioner.ConfigAllowReadWriteOncePod = flag.Bool(
+		"allowReadWriteOncePod",
+		false,
+		"set 'true' to accept the ReadWriteOncePod PVCs, only when the cluster enforces the single pod access itself",
+	)
+
+	s3fsprovisioner.ConfigNodeReadinessAffinity = flag.Bool(
+		"nodeReadinessAffinity",
+		false,
+		"set 'true' to restrict volumes to nodes labeled ready by the mounter pod",
+	)
+
 	flag.Parse()
+	sources, err := configLoader.Load(flag.CommandLine)
+	if err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+	logger.Info("Effective configuration", zap.Any("config", cfg.EffectiveConfig(flag.CommandLine, sources)))
 
 	// Enable debug trace
-	debugTrace := cfg.GetConfigBool("DEBUG_TRACE", false, *logger)
-	if debugTrace {
+	if *debugTrace {
 		loggerLevel.SetLevel(zap.DebugLevel)
 	}
 
-	if errs := validateProvisioner(*provisioner, field.NewPath("provisioner")); len(errs) != 0 {
-		errMsgs := ""
-		for _, err := range errs {
-			errMsgs = errMsgs + err.Error() + "\n"
-		}
-		logger.Fatal("Invalid provisioner specified", zap.String("validation_errors", errMsgs))
+	names, err := parseProvisionerNames(*provisioner, *provisionerNames)
+	if err != nil {
+		logger.Fatal("Invalid provisioner specified", zap.Error(err))
 	}
-	logger.Info("Provisioner specified: ", zap.String("provisioner", *provisioner))
+	logger.Info("Provisioner specified: ", zap.Strings("provisioner", names))
 
 	var config *rest.Config
 	config, err = clientcmd.BuildConfigFromFlags(*master, *kubeconfig)
@@ -130,6 +473,12 @@ func main() {
 		logger.Fatal("Failed to create client:", zap.Error(err))
 	}
 
+	// the cluster-info ConfigMap is only read when the cluster ID is not configured
+	if *clusterID != "" {
+		if err := os.Setenv("CLUSTER_ID", *clusterID); err != nil {
+			logger.Fatal("Failed to set the cluster ID", zap.Error(err))
+		}
+	}
 	err = cfg.SetUpEvn(clientset, logger)
 	if err != nil {
 		logger.Fatal("Error while loading the ENV variables", zap.Error(err))
@@ -140,32 +489,227 @@ func main() {
 		logger.Fatal("Error getting server version:", zap.Error(err))
 	}
 
+	transport := backend.TransportConfig{
+		MaxIdleConns:          *cosMaxIdleConns,
+		IdleConnTimeout:       *cosIdleConnTimeout,
+		TLSHandshakeTimeout:   *cosTLSHandshakeTimeout,
+		ResponseHeaderTimeout: *cosResponseHeaderTimeout,
+		KeepAlive:             *cosKeepAlive,
+	}
+	if err := transport.Validate(); err != nil {
+		logger.Fatal("Invalid object storage transport settings", zap.Error(err))
+	}
+	retryableClasses, err := backend.ParseRetryableClasses(*cosRetryableErrors)
+	if err != nil {
+		logger.Fatal("Invalid object storage retry policy", zap.Error(err))
+	}
+	retryPolicy := backend.RetryPolicy{
+		MaxAttempts:      *cosRetryMaxAttempts,
+		BaseDelay:        *cosRetryBaseDelay,
+		MaxBackoff:       *cosRetryMaxBackoff,
+		RetryableClasses: retryableClasses,
+	}
+	if err := retryPolicy.Validate(); err != nil {
+		logger.Fatal("Invalid object storage retry policy", zap.Error(err))
+	}
+	breaker := backend.CircuitBreakerConfig{
+		FailureThreshold: *cosBreakerFailureThreshold,
+		OpenDuration:     *cosBreakerOpenDuration,
+	}
+	if err := breaker.Validate(); err != nil {
+		logger.Fatal("Invalid object storage circuit breaker settings", zap.Error(err))
+	}
+
+	rateLimit := backend.RateLimitConfig{
+		RequestsPerSecond: *cosRateLimit,
+		Burst:             *cosRateLimitBurst,
+	}
+	if err := rateLimit.Validate(); err != nil {
+		logger.Fatal("Invalid object storage rate limit", zap.Error(err))
+	}
+
+	cosSessionFactory := &backend.COSSessionFactory{Transport: transport, Retry: retryPolicy, Breaker: breaker, RateLimit: rateLimit}
+	if *simulateCOS {
+		server := fake.NewCOSServer()
+		defer server.Close()
+		cosSessionFactory.Endpoint = server.URL
+		logger.Warn("Simulating the object storage, no bucket is created on the endpoints of the storage classes",
+			zap.String("endpoint", server.URL))
+	}
+
 	s3fsProvisioner := &s3fsprovisioner.IBMS3fsProvisioner{
-		Backend:       &backend.COSSessionFactory{},
-		GRPCBackend:   &grpcClient.ConnObjFactory{},
-		AccessPolicy:  &backend.UpdateAPFactory{},
-		IBMProvider:   &ibmprovider.IBMProviderClntFactory{},
-		Logger:        logger,
-		Client:        clientset,
-		UUIDGenerator: uuid.NewCryptoGenerator(),
-	}
-
-	pc := controller.NewProvisionController(
-		clientset,
-		*provisioner,
-		s3fsProvisioner,
-		serverVersion.GitVersion,
-		controller.LeaderElection(false),
-		controller.ResyncPeriod(resyncPeriod),
-		controller.ExponentialBackOffOnError(true),
-		controller.FailedProvisionThreshold(failedRetryThreshold),
-		controller.LeaseDuration(*leaseDuration),
-		controller.RenewDeadline(*leaseRenewDeadline),
-		controller.RetryPeriod(*leaseRetryPeriod),
-		//controller.TermLimit(*leaseTermLimit),
-	)
+		Backend:             cosSessionFactory,
+		Backends:            registerBackends(logger, cosSessionFactory),
+		GRPCBackend:         &grpcClient.ConnObjFactory{},
+		AccessPolicy:        &backend.UpdateAPFactory{},
+		IBMProvider:         &ibmprovider.IBMProviderClntFactory{},
+		Logger:              logger,
+		Client:              clientset,
+		UUIDGenerator:       uuid.NewCryptoGenerator(),
+		RequesterAnnotation: *requesterAnnotation,
+		ProgressInterval:    *progressEventInterval,
+	}
+
+	if *provisionRetryBackoff > 0 {
+		s3fsProvisioner.RetryBackoff = &s3fsprovisioner.RetryBackoff{Base: *provisionRetryBackoff, Max: *provisionRetryBackoffMax}
+	}
+
+	switch *missingSecretPolicy {
+	case s3fsprovisioner.MissingSecretFail, s3fsprovisioner.MissingSecretSkip, s3fsprovisioner.MissingSecretFallback:
+		s3fsProvisioner.MissingSecretPolicy = *missingSecretPolicy
+	default:
+		logger.Fatal("Invalid missing secret policy, expects fail, skip or fallback",
+			zap.String("missingSecretPolicy", *missingSecretPolicy))
+	}
+	if *cleanupSecret != "" {
+		parts := strings.SplitN(*cleanupSecret, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			logger.Fatal("Invalid cleanup secret, expects <namespace>/<name>", zap.String("cleanupSecret", *cleanupSecret))
+		}
+		s3fsProvisioner.CleanupSecretNamespace, s3fsProvisioner.CleanupSecretName = parts[0], parts[1]
+	} else if *missingSecretPolicy == s3fsprovisioner.MissingSecretFallback {
+		logger.Fatal("The fallback missing secret policy needs -cleanupSecret")
+	}
 
-	pc.Run(context.Background())
+	if *tuningCapPolicy != "reject" && *tuningCapPolicy != "clamp" {
+		logger.Fatal("Invalid tuning cap policy, expects reject or clamp", zap.String("tuningCapPolicy", *tuningCapPolicy))
+	}
+	s3fsProvisioner.TuningLimits = &s3fsprovisioner.TuningLimits{
+		Defaults: map[string]int{
+			"ibm.io/chunk-size-mb":   *defaultChunkSizeMB,
+			"ibm.io/parallel-count":  *defaultParallelCount,
+			"ibm.io/multireq-max":    *defaultMultiReqMax,
+			"ibm.io/stat-cache-size": *defaultStatCacheSize,
+		},
+		Caps: map[string]int{
+			"ibm.io/chunk-size-mb":   *maxChunkSizeMB,
+			"ibm.io/parallel-count":  *maxParallelCount,
+			"ibm.io/multireq-max":    *maxMultiReqMax,
+			"ibm.io/stat-cache-size": *maxStatCacheSize,
+		},
+		Clamp: *tuningCapPolicy == "clamp",
+	}
+	if err := s3fsProvisioner.TuningLimits.Validate(); err != nil {
+		logger.Fatal("Invalid tuning defaults and caps", zap.Error(err))
+	}
+
+	if s3fsProvisioner.NamespaceMetadata, err = s3fsprovisioner.ParseMetadataMappings(*namespaceMetadataMapping); err != nil {
+		logger.Fatal("Invalid namespace metadata mapping", zap.Error(err))
+	}
+
+	if *globalDefaultsConfigMap != "" {
+		parts := strings.SplitN(*globalDefaultsConfigMap, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			logger.Fatal("Invalid global defaults ConfigMap, expects <namespace>/<name>",
+				zap.String("globalDefaultsConfigMap", *globalDefaultsConfigMap))
+		}
+		s3fsProvisioner.GlobalDefaults = &s3fsprovisioner.GlobalDefaultsWatcher{
+			Client: clientset, Namespace: parts[0], Name: parts[1], Logger: logger}
+		if err := s3fsProvisioner.GlobalDefaults.Start(context.Background(), resyncPeriod); err != nil {
+			logger.Fatal("Failed to watch the global defaults", zap.Error(err))
+		}
+	}
+
+	if *namespaceQuotaConfigMap != "" {
+		parts := strings.SplitN(*namespaceQuotaConfigMap, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			logger.Fatal("Invalid namespace quota ConfigMap, expects <namespace>/<name>",
+				zap.String("namespaceQuotaConfigMap", *namespaceQuotaConfigMap))
+		}
+		s3fsProvisioner.NamespaceQuota = &s3fsprovisioner.NamespaceQuotaWatcher{
+			Client: clientset, Namespace: parts[0], Name: parts[1], Logger: logger}
+		if err := s3fsProvisioner.NamespaceQuota.Start(context.Background(), resyncPeriod); err != nil {
+			logger.Fatal("Failed to watch the namespace quota", zap.Error(err))
+		}
+	}
+
+	if *admissionWebhookAddress != "" {
+		if *admissionWebhookCertFile == "" || *admissionWebhookKeyFile == "" {
+			logger.Fatal("The admission webhook needs -admissionWebhookCertFile and -admissionWebhookKeyFile")
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/validate", &s3fsprovisioner.AdmissionWebhook{
+			Provisioner: s3fsProvisioner, Provisioners: names, Logger: logger})
+		server := &http.Server{Addr: *admissionWebhookAddress, Handler: mux}
+		go func() {
+			err := server.ListenAndServeTLS(*admissionWebhookCertFile, *admissionWebhookKeyFile)
+			logger.Fatal("Admission webhook stopped", zap.Error(err))
+		}()
+	}
+
+	if *migrateAnnotations {
+		for _, name := range names {
+			migrator := &s3fsprovisioner.AnnotationMigrator{Client: clientset, Provisioner: name, Logger: logger}
+			go migrator.Run(context.Background(), migrationPeriod)
+		}
+	}
+
+	if *usageMeteringPeriod > 0 {
+		meter := &s3fsprovisioner.UsageMeter{Provisioner: s3fsProvisioner, Logger: logger}
+		if *usageReportPath != "" {
+			// served by the metrics server of the controller
+			http.Handle(*usageReportPath, meter)
+		}
+		go meter.Run(context.Background(), *usageMeteringPeriod)
+	}
+
+	if *backupCheckPeriod > 0 {
+		backups := &s3fsprovisioner.BackupController{Provisioner: s3fsProvisioner, Logger: logger}
+		go backups.Run(context.Background(), *backupCheckPeriod)
+	}
+
+	// a controller per name shares the provisioner, the metrics are global and served by the first one only
+	var wg sync.WaitGroup
+	for i, name := range names {
+		port := int32(*metricsPort)
+		if i > 0 {
+			port = 0
+		}
+		pc := controller.NewProvisionController(
+			clientset,
+			name,
+			s3fsProvisioner,
+			serverVersion.GitVersion,
+			controller.LeaderElection(false),
+			controller.ResyncPeriod(resyncPeriod),
+			controller.ExponentialBackOffOnError(true),
+			controller.FailedProvisionThreshold(failedRetryThreshold),
+			controller.LeaseDuration(*leaseDuration),
+			controller.RenewDeadline(*leaseRenewDeadline),
+			controller.RetryPeriod(*leaseRetryPeriod),
+			controller.MetricsPort(port),
+			//controller.TermLimit(*leaseTermLimit),
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pc.Run(context.Background())
+		}()
+	}
+	wg.Wait()
+}
+
+// parseProvisionerNames returns the names served by the provisioner, the comma separated names
+// when set or else the single provisioner name, and fails on a duplicate or an invalid name.
+func parseProvisionerNames(provisioner, names string) ([]string, error) {
+	list := []string{provisioner}
+	if strings.TrimSpace(names) != "" {
+		list = strings.Split(names, ",")
+	}
+	var parsed []string
+	seen := map[string]bool{}
+	for _, name := range list {
+		name = strings.TrimSpace(name)
+		if errs := validateProvisioner(name, field.NewPath("provisioner")); len(errs) != 0 {
+			return nil, errs.ToAggregate()
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("provisioner name %s is set twice", name)
+		}
+		seen[name] = true
+		parsed = append(parsed, name)
+	}
+	return parsed, nil
 }
 
 // validateProvisioner tests if provisioner is a valid qualified name.
@@ -0,0 +1,125 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	s3fsprovisioner "github.com/IBM/ibmcloud-object-storage-plugin/provisioner"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+)
+
+// validateSecretCommand is the subcommand checking a secret instead of running the provisioner
+const validateSecretCommand = "validate-secret"
+
+// validateSecret runs the validate-secret subcommand with its arguments and returns its exit code:
+// 0 when every check passed, 1 when one failed, 2 when the secret could not be read
+func validateSecret(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(validateSecretCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	name := flags.String("name", "", "Name of the secret to validate")
+	namespace := flags.String("namespace", "default", "Namespace of the secret to validate")
+	file := flags.String("file", "", "YAML or JSON file of the secret to validate, instead of reading it from the cluster")
+	master := flags.String("master", "", "Master URL to read the secret from")
+	kubeconfig := flags.String("kubeconfig", "", "Absolute path to the kubeconfig to read the secret with")
+	endpoint := flags.String("endpoint", "", "Object storage endpoint the secret is used with, the ibm.io/object-store-endpoint of its storage classes")
+	region := flags.String("region", "us-standard", "Region signing the requests of HMAC credentials")
+	iamEndpoint := flags.String("iamEndpoint", "https://iam.cloud.ibm.com", "IAM endpoint exchanging the API key of the secret for a token")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *endpoint == "" || (*name == "") == (*file == "") {
+		fmt.Fprintln(stderr, "validate-secret needs -endpoint, and either -name or -file")
+		flags.Usage()
+		return 2
+	}
+
+	var secret *v1.Secret
+	var err error
+	if *file != "" {
+		secret, err = readSecretFile(*file)
+	} else {
+		secret, err = getSecret(*master, *kubeconfig, *name, *namespace)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot read the secret: %v\n", err)
+		return 2
+	}
+
+	checks := s3fsprovisioner.ValidateSecret(context.Background(), secret, s3fsprovisioner.SecretValidation{
+		Endpoint:    *endpoint,
+		Region:      *region,
+		IAMEndpoint: *iamEndpoint,
+		// the report tells about the first attempt, the provisioner retries the calls
+		Backend: &backend.COSSessionFactory{Retry: backend.RetryPolicy{MaxAttempts: 1}},
+	}, zap.NewNop())
+
+	code := 0
+	for _, check := range checks {
+		switch {
+		case check.Skipped:
+			fmt.Fprintf(stdout, "SKIP  %s\n", check.Name)
+		case check.Err != nil:
+			fmt.Fprintf(stdout, "FAIL  %s: %v\n", check.Name, check.Err)
+			code = 1
+		default:
+			fmt.Fprintf(stdout, "PASS  %s\n", check.Name)
+		}
+	}
+	return code
+}
+
+// getSecret reads a secret from the cluster
+func getSecret(master, kubeconfig, name, namespace string) (*v1.Secret, error) {
+	config, err := clientcmd.BuildConfigFromFlags(master, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// readSecretFile reads a secret from a manifest, its stringData merged into its data as the API server does
+func readSecretFile(path string) (*v1.Secret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	secret := &v1.Secret{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(secret); err != nil {
+		return nil, err
+	}
+	if secret.Kind != "" && secret.Kind != "Secret" {
+		return nil, errors.New(path + " holds a " + secret.Kind + ", not a Secret")
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range secret.StringData {
+		secret.Data[key] = []byte(value)
+	}
+	return secret, nil
+}
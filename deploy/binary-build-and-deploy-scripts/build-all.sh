@@ -1,6 +1,7 @@
 #! /bin/bash
  
 VERSION_TAG="v001"
+ARCHS=${ARCHS:-"amd64 s390x ppc64le arm64"}
 
 echo -e "\nCloning repo..."
 git clone https://github.com/IBM/ibmcloud-object-storage-plugin.git
@@ -9,22 +10,36 @@ git clone https://github.com/s3fs-fuse/s3fs-fuse.git
 echo -e "\nSpinning builder image..."
 docker build -t s3fs-plugin-builder:${VERSION_TAG} -f ./Dockerfile.build .
 
-echo -e "\nCompiling s3fs fuse..."
-docker run --name s3fsbuild-${VERSION_TAG} \
-       -v `pwd`/s3fs-fuse:/root/s3fs-fuse s3fs-plugin-builder:${VERSION_TAG} /root/compile-s3fs.sh
+mkdir -p ./bin
+
+# s3fs is a C program, it is compiled natively in a builder container of each architecture
+# (emulated through binfmt/QEMU for the foreign ones)
+for arch in $ARCHS; do
+    echo -e "\nCompiling s3fs fuse for ${arch}..."
+    docker buildx build --load --platform linux/${arch} \
+           -t s3fs-plugin-builder:${VERSION_TAG}-${arch} -f ./Dockerfile.build .
+    docker run --platform linux/${arch} --name s3fsbuild-${VERSION_TAG}-${arch} \
+           -v `pwd`/s3fs-fuse:/root/s3fs-fuse s3fs-plugin-builder:${VERSION_TAG}-${arch} \
+           sh -c "git -C /root/s3fs-fuse clean -fdx && /root/compile-s3fs.sh"
+    if [[ $? -ne 0 ]]; then
+       exit 1
+    fi
+    mkdir -p ./bin/${arch}
+    cp s3fs-fuse/src/s3fs ./bin/${arch}/
+done
 
 echo -e "\nCompiling plugin..."
 TARGET_PATH="/go/src/github.com/IBM/ibmcloud-object-storage-plugin"
-docker run --name pluginbuild-${VERSION_TAG} \
+docker run --name pluginbuild-${VERSION_TAG} -e ARCHS="${ARCHS}" \
        -v `pwd`/ibmcloud-object-storage-plugin:${TARGET_PATH} s3fs-plugin-builder:${VERSION_TAG} /root/compile-plugin.sh
 if [[ $? -ne 0 ]]; then
    exit 1
 fi
 
-mkdir -p ./bin
-
-cp s3fs-fuse/src/s3fs ./bin/
-cp ibmcloud-object-storage-plugin/cmd/bin/*  ./bin/
+for arch in $ARCHS; do
+    cp ibmcloud-object-storage-plugin/cmd/bin/${arch}/ibmc-s3fs ibmcloud-object-storage-plugin/cmd/bin/${arch}/provisioner ./bin/${arch}/
+done
+cp ibmcloud-object-storage-plugin/cmd/bin/ca-certs.tar.gz ./bin/
 
 
 BUILD_DATE=$(date -u +"%Y-%m-%dT%H:%M:%SZ")
@@ -13,10 +13,13 @@ BUILD_DATE=$(date -u +"%Y-%m-%dT%H:%M:%SZ")
 GIT_COMMIT=$(git rev-parse HEAD 2>/dev/null)
 GIT_REMOTE_URL=$(git config --get remote.origin.url 2>/dev/null)
 
-CGO_ENABLED=0 go install -mod=mod -v -ldflags "-X main.Version=${git_commit_id} -X main.Build=${build_date}" github.com/IBM/ibmcloud-object-storage-plugin/cmd/driver
-CGO_ENABLED=0 go install -mod=mod -v github.com/IBM/ibmcloud-object-storage-plugin/cmd/provisioner
+ARCHS=${ARCHS:-"amd64 s390x ppc64le arm64"}
+
+for arch in $ARCHS; do
+    mkdir -p ./cmd/bin/$arch
+    CGO_ENABLED=0 GOARCH=$arch go build -mod=mod -v -ldflags "-X main.Version=${git_commit_id} -X main.Build=${build_date}" -o ./cmd/bin/$arch/ibmc-s3fs github.com/IBM/ibmcloud-object-storage-plugin/cmd/driver
+    CGO_ENABLED=0 GOARCH=$arch go build -mod=mod -v -o ./cmd/bin/$arch/provisioner github.com/IBM/ibmcloud-object-storage-plugin/cmd/provisioner
+done
 
 cd $GOPATH/src/github.com/IBM/ibmcloud-object-storage-plugin/cmd/bin
-cp $GOPATH/bin/*  ./
-cp ./driver ./ibmc-s3fs
 tar cC / ./etc/ssl  | gzip -n > ./ca-certs.tar.gz
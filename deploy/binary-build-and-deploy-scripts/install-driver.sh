@@ -4,17 +4,39 @@ set -ex
 DRIVER_LOCATION="/host/usr/libexec/kubernetes/kubelet-plugins/volume/exec/ibm~ibmc-s3fs"
 KUBELET_SVC_CONFIG="/host/lib/systemd/system/kubelet.service"
 
-cp /root/bin/s3fs /host/usr/local/bin/
+# Select the binaries built for the node architecture, so that mixed-architecture
+# clusters do not end up with binaries failing with "exec format error".
+# When the image does not carry them, they are fetched from S3FS_DOWNLOAD_URL/<arch>/.
+case "$(uname -m)" in
+	x86_64|amd64) ARCH="amd64" ;;
+	s390x) ARCH="s390x" ;;
+	ppc64le) ARCH="ppc64le" ;;
+	aarch64|arm64) ARCH="arm64" ;;
+	*) echo "Unsupported node architecture $(uname -m)"; exit 1 ;;
+esac
+BIN_DIR="/root/bin/$ARCH"
+mkdir -p $BIN_DIR
+for binary in s3fs ibmc-s3fs; do
+	if [ ! -e "$BIN_DIR/$binary" ]; then
+		if [ -z "$S3FS_DOWNLOAD_URL" ]; then
+			echo "No $binary binary for architecture $ARCH and S3FS_DOWNLOAD_URL not set"
+			exit 1
+		fi
+		curl -fsSL -o $BIN_DIR/$binary "$S3FS_DOWNLOAD_URL/$ARCH/$binary"
+	fi
+done
+
+cp $BIN_DIR/s3fs /host/usr/local/bin/
 cp /root/bin/install-dep.sh /host/root/
 chmod +x /host/usr/local/bin/s3fs /host/root/install-dep.sh 
 
 if [ -e "$DRIVER_LOCATION/ibmc-s3fs" ]
 then
-	mv /root/bin/ibmc-s3fs $DRIVER_LOCATION/
+	mv $BIN_DIR/ibmc-s3fs $DRIVER_LOCATION/
 	chmod +x $DRIVER_LOCATION/ibmc-s3fs
 else
 	mkdir -p $DRIVER_LOCATION
-        cp /root/bin/ibmc-s3fs $DRIVER_LOCATION/
+        cp $BIN_DIR/ibmc-s3fs $DRIVER_LOCATION/
 	chmod +x $DRIVER_LOCATION/ibmc-s3fs
 
 	# disable enable-controller-attach-detach
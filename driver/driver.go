@@ -11,6 +11,7 @@
 package driver
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
@@ -29,10 +30,14 @@ import (
 )
 
 const (
-	dataRootPath       = "/var/lib/ibmc-s3fs"
 	passwordFileName   = "passwd"
+	ahbeConfFileName   = "ahbe.conf"
 	cacheDirectoryName = "cache"
 	caPath             = "/tmp"
+	xattrProbeName     = "user.ibmc-s3fs.probe"
+	// volumeTmpfsSize is the size of the tmpfs of a volume holding its password file and the other files of its mount,
+	// tmpfs charging a page to every file: passwd, stats.json, ahbe.conf and rclone.conf
+	volumeTmpfsSize = 64 << 10
 	// SecretAccessKey is the key name for the AWS Access Key
 	SecretAccessKey = "access-key"
 	// SecretSecretKey is the key name for the AWS Secret Key
@@ -47,8 +52,21 @@ const (
 	defaultIAMEndPoint = "https://iam.cloud.ibm.com"
 	// CrtBundle is the base64 encoded crt bundle
 	CrtBundle = "ca-bundle-crt"
+	// SecretEncryptionKey is the key name for the client-side encryption passphrase
+	SecretEncryptionKey = "encryption-key"
+	// PermissionModePersistent keeps ownership/permissions in object metadata headers (s3fs default)
+	PermissionModePersistent = "persistent"
+	// PermissionModeStateless ignores object metadata and presents fixed ownership/permissions
+	PermissionModeStateless = "stateless"
+	// CompatProfileOpenShift makes volumes group-writable by gid 0 for pods running with arbitrary UIDs
+	CompatProfileOpenShift = "openshift"
+	// AuthModeInstanceIdentity gets IAM tokens of a trusted profile from the instance identity of the node
+	AuthModeInstanceIdentity = "instance-identity"
 )
 
+// dataRootPath holds the tmpfs of every volume and the records of their mounts
+var dataRootPath = "/var/lib/ibmc-s3fs"
+
 var (
 	command            = exec.Command
 	stat               = os.Stat
@@ -57,6 +75,8 @@ var (
 	writeFile          = ioutil.WriteFile
 	mkdirAll           = os.MkdirAll
 	removeAll          = os.RemoveAll
+	getxattr           = syscall.Getxattr
+	lookPath           = exec.LookPath
 	hostname, anyerror = os.Hostname()
 )
 
@@ -64,19 +84,20 @@ var (
 var buildVersion = ""
 var podUID = ""
 
-// Options are the FlexVolume driver options
+// Options are the FlexVolume driver options, the default tags are the values of the options left empty
 type Options struct {
-	ChunkSizeMB             int    `json:"chunk-size-mb,string"`
-	ParallelCount           int    `json:"parallel-count,string"`
-	MultiReqMax             int    `json:"multireq-max,string"`
-	StatCacheSize           int    `json:"stat-cache-size,string"`
+	ChunkSizeMB             int    `json:"chunk-size-mb,string" default:"10"`
+	ParallelCount           int    `json:"parallel-count,string" default:"5"`
+	MultiReqMax             int    `json:"multireq-max,string" default:"20"`
+	StatCacheSize           int    `json:"stat-cache-size,string" default:"100000"`
 	FSGroup                 string `json:"kubernetes.io/fsGroup,omitempty"`
 	FSGroupNew              string `json:"kubernetes.io/mounterArgs.FsGroup,omitempty"`
+	ReadWrite               string `json:"kubernetes.io/readwrite,omitempty"`
 	Endpoint                string `json:"endpoint,omitempty"` //Will be deprecated
 	Region                  string `json:"region,omitempty"`   //Will be deprecated
 	Bucket                  string `json:"bucket"`
 	ObjectPath              string `json:"object-path,omitempty"`
-	DebugLevel              string `json:"debug-level"`
+	DebugLevel              string `json:"debug-level" default:"warn"`
 	CurlDebug               bool   `json:"curl-debug,string"`
 	KernelCache             bool   `json:"kernel-cache,string,omitempty"`
 	TLSCipherSuite          string `json:"tls-cipher-suite,omitempty"`
@@ -97,6 +118,74 @@ type Options struct {
 	CosServiceIP            string `json:"service-ip,omitempty"`
 	AutoCache               bool   `json:"auto_cache,string,omitempty"`
 	AddMountParam           string `json:"add-mount-param,omitempty"`
+	TmpfsCacheSizeMB        string `json:"tmpfs-cache-size-mb,omitempty"`
+	WriteBackCache          bool   `json:"write-back-cache,string,omitempty"`
+	WriteBackDelaySeconds   string `json:"write-back-delay-seconds,omitempty"`
+	ReadAheadKB             string `json:"read-ahead-kb,omitempty"`
+	ReaddirOptimize         bool   `json:"readdir-optimize,string,omitempty"`
+	NoObjCache              bool   `json:"noobj-cache,string,omitempty"`
+	MultipartSizeMB         string `json:"multipart-size-mb,omitempty"`
+	SinglepartCopyLimitMB   string `json:"singlepart-copy-limit-mb,omitempty"`
+	MaxDirtyDataMB          string `json:"max-dirty-data-mb,omitempty"`
+	ListObjectMaxKeys       string `json:"list-object-max-keys,omitempty"`
+	PermissionMode          string `json:"permission-mode,omitempty"`
+	CompatDir               bool   `json:"compat-dir,string,omitempty"`
+	NotSupCompatDir         bool   `json:"notsup-compat-dir,string,omitempty"`
+	ComplementStat          bool   `json:"complement-stat,string,omitempty"`
+	AhbeConf                string `json:"ahbe-conf,omitempty"`
+	DetectContentType       bool   `json:"detect-content-type,string,omitempty"`
+	SELinuxContext          string `json:"selinux-context,omitempty"`
+	CompatProfile           string `json:"compat-profile,omitempty"`
+	LogFile                 bool   `json:"log-file,string,omitempty"`
+	MounterCPULimit         string `json:"mounter-cpu-limit,omitempty"`
+	MounterMemoryLimit      string `json:"mounter-memory-limit,omitempty"`
+	MountOptions            string `json:"mount-options,omitempty"`
+	ExtraMountOptions       string `json:"extra-mount-options,omitempty"`
+	Sources                 string `json:"sources,omitempty"`
+	ClientSideEncryption    bool   `json:"client-side-encryption,string,omitempty"`
+	EncryptionKeyB64        string `json:"kubernetes.io/secret/encryption-key,omitempty"`
+	Compression             bool   `json:"compression,string,omitempty"`
+	AuthMode                string `json:"auth-mode,omitempty"`
+	TrustedProfileID        string `json:"trusted-profile-id,omitempty"`
+	ResConfAPIKeyB64        string `json:"kubernetes.io/secret/res-conf-apikey,omitempty"`
+	BucketAccessCheck       string `json:"bucket-access-check,omitempty"`
+	ObjectPathAsPrefix      bool   `json:"object-path-as-prefix,string,omitempty"`
+	Backend                 string `json:"backend,omitempty"`
+}
+
+// MountableBackends are the object storage backends of the storage classes the driver mounts, with s3fs or rclone
+// through their S3 API
+var MountableBackends = []string{backend.DefaultBackendName, backend.S3BackendName}
+
+// checkBackend rejects the volumes of a backend the driver cannot mount, no backend is the default one
+func checkBackend(name string) error {
+	if name == "" {
+		return nil
+	}
+	for _, mountable := range MountableBackends {
+		if name == mountable {
+			return nil
+		}
+	}
+	return fmt.Errorf("object storage backend %q cannot be mounted, the driver mounts %s", name,
+		strings.Join(MountableBackends, ", "))
+}
+
+// fsGroup returns the fsGroup of the pod security context passed by kubelet, if any.
+// Older kubelets pass it as kubernetes.io/fsGroup, newer ones as kubernetes.io/mounterArgs.FsGroup.
+func fsGroup(mountRequest interfaces.FlexVolumeMountRequest, options *Options) string {
+	if _, ok := mountRequest.Opts["kubernetes.io/fsGroup"]; ok {
+		return options.FSGroup
+	} else if _, ok := mountRequest.Opts["kubernetes.io/mounterArgs.FsGroup"]; ok {
+		return options.FSGroupNew
+	}
+	return ""
+}
+
+// readOnly tells whether the volume is mounted read-only: it is ReadOnlyMany, or kubelet passes
+// kubernetes.io/readwrite=ro for a read-only volume source or pod volume
+func readOnly(options Options) bool {
+	return options.AccessMode == "ReadOnlyMany" || options.ReadWrite == "ro"
 }
 
 // PathExists returns true if the specified path exists.
@@ -137,8 +226,9 @@ func isCorruptedMnt(err error) bool {
 
 // S3fsPlugin supports mount & unmount requests of s3fs volumes
 type S3fsPlugin struct {
-	Backend backend.ObjectStorageSessionFactory
-	Logger  *zap.Logger
+	Backend      backend.ObjectStorageSessionFactory
+	AccessPolicy backend.AccessPolicyFactory
+	Logger       *zap.Logger
 }
 
 // SetBuildVersion sets the driver version
@@ -256,7 +346,7 @@ func (p *S3fsPlugin) createEmptyMountpoint(mountPath string) error {
 	// directory exists and unmounted
 	p.Logger.Info(podUID+":"+"Creating tmpfs mountpoint",
 		zap.String("mountPath", mountPath))
-	err = mount("tmpfs", mountPath, "tmpfs", 0, "size=4k")
+	err = mount("tmpfs", mountPath, "tmpfs", 0, "size="+strconv.Itoa(volumeTmpfsSize))
 	if err != nil {
 		p.Logger.Error(podUID+":Cannot create tmpfs mountpoint",
 			zap.String("mountPath", mountPath), zap.Error(err))
@@ -266,30 +356,79 @@ func (p *S3fsPlugin) createEmptyMountpoint(mountPath string) error {
 	return nil
 }
 
+// createTmpfsCache creates a tmpfs of sizeMB megabytes to hold the s3fs cache
+func (p *S3fsPlugin) createTmpfsCache(cacheDir, sizeMB string) error {
+	p.Logger.Info(podUID+":"+"Creating tmpfs cache",
+		zap.String("cacheDir", cacheDir), zap.String("sizeMB", sizeMB))
+
+	err := mkdirAll(cacheDir, 0700)
+	if err != nil {
+		p.Logger.Error(podUID+":Cannot create cache directory",
+			zap.String("cacheDir", cacheDir), zap.Error(err))
+		return fmt.Errorf("cannot create cache directory %s: %v", cacheDir, err)
+	}
+
+	err = mount("tmpfs", cacheDir, "tmpfs", 0, "size="+sizeMB+"m,mode=0700")
+	if err != nil {
+		p.Logger.Error(podUID+":Cannot mount tmpfs cache",
+			zap.String("cacheDir", cacheDir), zap.Error(err))
+		return fmt.Errorf("cannot mount tmpfs cache %s: %v", cacheDir, err)
+	}
+
+	return nil
+}
+
+// checkXattrSupport verifies that extended attributes can be used on the mounted volume
+func (p *S3fsPlugin) checkXattrSupport(mountDir string) error {
+	p.Logger.Info(podUID+":"+"Checking extended attribute support",
+		zap.String("mountDir", mountDir))
+
+	_, err := getxattr(mountDir, xattrProbeName, nil)
+	if err == syscall.ENOTSUP {
+		p.Logger.Error(podUID+":"+"Extended attributes are not supported by the kernel/FUSE on this node",
+			zap.String("mountDir", mountDir), zap.Error(err))
+		return fmt.Errorf("use-xattr is set but extended attributes are not supported by the kernel/FUSE on this node: %v", err)
+	}
+	return nil
+}
+
 // Init method is to initialize the flexvolume, it is a no op right now
 func (p *S3fsPlugin) Init() interfaces.FlexVolumeResponse {
 	p.Logger.Info(podUID + ":" + "S3fsPlugin-Init()-start")
 	defer p.Logger.Info(podUID + ":" + "S3fsPlugin-Init()-end")
 
+	if err := Preflight(); err != nil {
+		p.Logger.Error(podUID+":"+"Node cannot mount s3fs volumes", zap.Error(err))
+	}
+
+	p.cleanupStaleMounts()
+
+	if err := RotateMountLogs(); err != nil {
+		p.Logger.Error(podUID+":"+"Cannot rotate volume log files", zap.Error(err))
+	}
+
+	// fsGroup is applied at mount time through s3fs gid/umask options; advertising
+	// the capability would make kubelet chown every object of the bucket after mount.
+	// FUSE mounts cannot be relabeled either, the SELinux context is set with selinux-context instead.
 	return interfaces.FlexVolumeResponse{
 		Status:       interfaces.StatusSuccess,
 		Message:      "Plugin init successfully",
-		Capabilities: interfaces.CapabilitiesResponse{Attach: false, FSGroup: false},
+		Capabilities: interfaces.CapabilitiesResponse{Attach: false, FSGroup: false, SELinuxRelabel: false},
 	}
 }
 
-func (p *S3fsPlugin) checkBucket(endpoint, region, bucket string, creds *backend.ObjectStorageCredentials) error {
+func (p *S3fsPlugin) checkBucket(endpoint, region, bucket, objectpath, accessCheck string, creds *backend.ObjectStorageCredentials) error {
 	p.Logger.Info(podUID+":"+"Checking if bucket exists",
-		zap.String("bucket", bucket))
-	sess := p.Backend.NewObjectStorageSession(endpoint, region, creds, p.Logger)
-	return sess.CheckBucketAccess(bucket)
+		zap.String("bucket", bucket), zap.String("access-check", accessCheck))
+	sess := p.Backend.NewObjectStorageSession(endpoint, region, creds, backend.TransportConfig{}, p.Logger)
+	return backend.CheckAccess(context.Background(), sess, accessCheck, bucket, objectpath)
 }
 
-func (p *S3fsPlugin) checkObjectPath(endpoint, region, bucket, objectpath string, creds *backend.ObjectStorageCredentials) (bool, error) {
+func (p *S3fsPlugin) checkObjectPath(endpoint, region, bucket, objectpath string, asPrefix bool, creds *backend.ObjectStorageCredentials) (bool, error) {
 	p.Logger.Info(podUID+":"+"Checking if object-path exists inside bucket",
-		zap.String("bucket", bucket), zap.String("object-path", objectpath))
-	sess := p.Backend.NewObjectStorageSession(endpoint, region, creds, p.Logger)
-	return sess.CheckObjectPathExistence(bucket, objectpath)
+		zap.String("bucket", bucket), zap.String("object-path", objectpath), zap.Bool("as-prefix", asPrefix))
+	sess := p.Backend.NewObjectStorageSession(endpoint, region, creds, backend.TransportConfig{}, p.Logger)
+	return sess.CheckObjectPathExistence(context.Background(), bucket, objectpath, asPrefix)
 }
 
 func (p *S3fsPlugin) createDirectoryIfNotExists(path string) error {
@@ -320,20 +459,44 @@ func (p *S3fsPlugin) createDirectoryIfNotExists(path string) error {
 }
 
 // Mount method allows to mount the volume/fileset to a given location for a pod
-func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountRequest) error {
+func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountRequest) (err error) {
 	var options Options
 	var apiKey, serviceInstanceId, accessKey, secretKey string
 	var fInfo os.FileInfo
 	var regionValue, endptValue, iamEndpoint string
 	var fullBucketPath string
 
-	err := parser.UnmarshalMap(&mountRequest.Opts, &options)
+	// errors are bad options until the options are validated
+	errorCode := interfaces.ErrorCodeBadOptions
+	defer func() {
+		err = withCode(errorCode, err)
+	}()
+
+	err = parser.UnmarshalMap(&mountRequest.Opts, &options)
 	if err != nil {
 		p.Logger.Error(podUID+":"+"Cannot unmarshal driver options",
 			zap.Error(err))
 		return fmt.Errorf("cannot unmarshal driver options: %v", err)
 	}
 
+	if err = checkBackend(options.Backend); err != nil {
+		p.Logger.Error(podUID+":"+"Unsupported object storage backend",
+			zap.Error(err))
+		return err
+	}
+
+	// Several buckets mounted into subdirectories of the target directory
+	if options.Sources != "" {
+		sources, err := ParseSources(options.Sources)
+		if err != nil {
+			p.Logger.Error(podUID+":"+"Bad value for sources",
+				zap.Error(err))
+			return fmt.Errorf("Bad value for sources: %v", err)
+		}
+		errorCode = interfaces.ErrorCodeMountFailed
+		return p.mountSources(mountRequest, sources)
+	}
+
 	// Support both endpoint and object-store-endpoint option
 	if options.OSEndpoint != "" {
 		endptValue = options.OSEndpoint
@@ -377,6 +540,16 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		}
 	}
 
+	// mountOptions of the volume, merged into the s3fs options
+	var mountOptions []string
+	if options.MountOptions != "" {
+		if mountOptions, err = splitMountOptions(options.MountOptions); err != nil {
+			p.Logger.Error(podUID+":"+"Bad value for mount-options",
+				zap.Error(err))
+			return fmt.Errorf("Bad value for mount-options: %v", err)
+		}
+	}
+
 	//Check if value of stat-cache-expire-seconds parameter can be converted to integer
 	if options.StatCacheExpireSeconds != "" {
 		cacheExpireSeconds, err := strconv.Atoi(options.StatCacheExpireSeconds)
@@ -395,27 +568,225 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 
 	//Check if value of connect_timeout parameter can be converted to integer
 	if options.ConnectTimeoutSeconds != "" {
-		_, err := strconv.Atoi(options.ConnectTimeoutSeconds)
+		connectTimeout, err := strconv.Atoi(options.ConnectTimeoutSeconds)
 		if err != nil {
 			p.Logger.Error(podUID+":"+
 				"Cannot convert value of connect-timeout-seconds into integer",
 				zap.Error(err))
 			return fmt.Errorf("Cannot convert value of connect-timeout-seconds into integer: %v", err)
 		}
+		if connectTimeout < 1 {
+			p.Logger.Error(podUID+":"+
+				" value of connect-timeout should be >= 1",
+				zap.Error(err))
+			return fmt.Errorf("value of connect-timeout should be >= 1")
+		}
 	}
 
-	//Check if value of connect_timeout parameter can be converted to integer
+	//Check if value of readwrite_timeout parameter can be converted to integer
 	if options.ReadwriteTimeoutSeconds != "" {
-		_, err := strconv.Atoi(options.ReadwriteTimeoutSeconds)
+		readwriteTimeout, err := strconv.Atoi(options.ReadwriteTimeoutSeconds)
 		if err != nil {
 			p.Logger.Error(podUID+":"+
 				"Cannot convert value of readwrite-timeout-seconds into integer",
 				zap.Error(err))
 			return fmt.Errorf("Cannot convert value of readwrite-timeout-seconds into integer: %v", err)
 		}
+		if readwriteTimeout < 1 {
+			p.Logger.Error(podUID+":"+
+				" value of readwrite-timeout should be >= 1",
+				zap.Error(err))
+			return fmt.Errorf("value of readwrite-timeout should be >= 1")
+		}
+	}
+
+	//Check if value of tmpfs-cache-size-mb parameter can be converted to integer
+	if options.TmpfsCacheSizeMB != "" {
+		cacheSizeMB, err := strconv.Atoi(options.TmpfsCacheSizeMB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of tmpfs-cache-size-mb into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of tmpfs-cache-size-mb into integer: %v", err)
+		}
+		if cacheSizeMB < 1 {
+			p.Logger.Error(podUID+":"+
+				" value of tmpfs-cache-size-mb should be >= 1",
+				zap.Error(err))
+			return fmt.Errorf("value of tmpfs-cache-size-mb should be >= 1")
+		}
+	}
+
+	//Check if value of write-back-delay-seconds parameter can be converted to integer
+	if options.WriteBackDelaySeconds != "" {
+		delaySeconds, err := strconv.Atoi(options.WriteBackDelaySeconds)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of write-back-delay-seconds into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of write-back-delay-seconds into integer: %v", err)
+		}
+		if delaySeconds < 0 {
+			p.Logger.Error(podUID+":"+
+				" value of write-back-delay-seconds should be >= 0",
+				zap.Error(err))
+			return fmt.Errorf("value of write-back-delay-seconds should be >= 0")
+		}
+	}
+
+	//Check if value of read-ahead-kb parameter can be converted to integer
+	if options.ReadAheadKB != "" {
+		readAheadKB, err := strconv.Atoi(options.ReadAheadKB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of read-ahead-kb into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of read-ahead-kb into integer: %v", err)
+		}
+		if readAheadKB < 0 {
+			p.Logger.Error(podUID+":"+
+				" value of read-ahead-kb should be >= 0",
+				zap.Error(err))
+			return fmt.Errorf("value of read-ahead-kb should be >= 0")
+		}
+	}
+
+	//Check if value of multipart-size-mb parameter can be converted to integer
+	multipartSizeMB := strconv.Itoa(options.ChunkSizeMB)
+	if options.MultipartSizeMB != "" {
+		partSizeMB, err := strconv.Atoi(options.MultipartSizeMB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of multipart-size-mb into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of multipart-size-mb into integer: %v", err)
+		}
+		if partSizeMB < 5 {
+			p.Logger.Error(podUID+":"+
+				" value of multipart-size-mb should be >= 5",
+				zap.Error(err))
+			return fmt.Errorf("value of multipart-size-mb should be >= 5")
+		}
+		multipartSizeMB = options.MultipartSizeMB
+	}
+
+	//Check if value of singlepart-copy-limit-mb parameter can be converted to integer
+	if options.SinglepartCopyLimitMB != "" {
+		copyLimitMB, err := strconv.Atoi(options.SinglepartCopyLimitMB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of singlepart-copy-limit-mb into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of singlepart-copy-limit-mb into integer: %v", err)
+		}
+		if copyLimitMB < 0 {
+			p.Logger.Error(podUID+":"+
+				" value of singlepart-copy-limit-mb should be >= 0",
+				zap.Error(err))
+			return fmt.Errorf("value of singlepart-copy-limit-mb should be >= 0")
+		}
+	}
+
+	//Check if value of max-dirty-data-mb parameter can be converted to integer
+	if options.MaxDirtyDataMB != "" {
+		dirtyDataMB, err := strconv.Atoi(options.MaxDirtyDataMB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of max-dirty-data-mb into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of max-dirty-data-mb into integer: %v", err)
+		}
+		if dirtyDataMB != -1 && dirtyDataMB < 50 {
+			p.Logger.Error(podUID+":"+
+				" value of max-dirty-data-mb should be -1 or >= 50",
+				zap.Error(err))
+			return fmt.Errorf("value of max-dirty-data-mb should be -1 or >= 50")
+		}
+	}
+
+	//Check if value of list-object-max-keys parameter can be converted to integer
+	if options.ListObjectMaxKeys != "" {
+		maxKeys, err := strconv.Atoi(options.ListObjectMaxKeys)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				"Cannot convert value of list-object-max-keys into integer",
+				zap.Error(err))
+			return fmt.Errorf("Cannot convert value of list-object-max-keys into integer: %v", err)
+		}
+		if maxKeys < 1 {
+			p.Logger.Error(podUID+":"+
+				" value of list-object-max-keys should be >= 1",
+				zap.Error(err))
+			return fmt.Errorf("value of list-object-max-keys should be >= 1")
+		}
+	}
+
+	if options.PermissionMode != "" && options.PermissionMode != PermissionModePersistent &&
+		options.PermissionMode != PermissionModeStateless {
+		p.Logger.Error(podUID+":"+
+			" Bad value for permission-mode",
+			zap.String("permission-mode", options.PermissionMode))
+		return fmt.Errorf("Bad value for permission-mode \"%v\": must be %s or %s",
+			options.PermissionMode, PermissionModePersistent, PermissionModeStateless)
+	}
+
+	if options.CompatProfile != "" && options.CompatProfile != CompatProfileOpenShift {
+		p.Logger.Error(podUID+":"+
+			" Bad value for compat-profile",
+			zap.String("compat-profile", options.CompatProfile))
+		return fmt.Errorf("Bad value for compat-profile \"%v\": must be %s",
+			options.CompatProfile, CompatProfileOpenShift)
+	}
+
+	groupID := fsGroup(mountRequest, &options)
+	if groupID != "" {
+		if _, err := strconv.ParseUint(groupID, 10, 32); err != nil {
+			p.Logger.Error(podUID+":"+" Bad value for fsGroup",
+				zap.String("fsGroup", groupID), zap.Error(err))
+			return fmt.Errorf("Bad value for fsGroup \"%v\": %v", groupID, err)
+		}
 	}
 
-	if options.APIKeyB64 != "" {
+	//SELinux context must be of the form user:role:type[:level]
+	if options.SELinuxContext != "" && (len(strings.Split(options.SELinuxContext, ":")) < 3 ||
+		strings.ContainsAny(options.SELinuxContext, " \t\"'")) {
+		p.Logger.Error(podUID+":"+
+			" Bad value for selinux-context."+
+			" Must be of the form user:role:type[:level]",
+			zap.String("selinux-context", options.SELinuxContext))
+		return fmt.Errorf("Bad value for selinux-context \"%v\": must be of the form user:role:type[:level]",
+			options.SELinuxContext)
+	}
+
+	limits, err := scopeProperties(&options)
+	if err != nil {
+		p.Logger.Error(podUID+":"+" Bad mounter resource limits", zap.Error(err))
+		return err
+	}
+
+	if options.CompatDir && options.NotSupCompatDir {
+		p.Logger.Error(podUID + ":" +
+			" compat-dir and notsup-compat-dir cannot be set together")
+		return fmt.Errorf("compat-dir and notsup-compat-dir cannot be set together")
+	}
+
+	if options.AuthMode == AuthModeInstanceIdentity {
+		// The mounter pod serves the IAM token API, exchanging the trusted profile ID
+		// passed as API key for tokens of the instance identity. No secret is needed.
+		if options.TrustedProfileID == "" {
+			p.Logger.Error(podUID + ":" + " trusted-profile-id is required with auth-mode " + AuthModeInstanceIdentity)
+			return fmt.Errorf("trusted-profile-id is required with auth-mode %s", AuthModeInstanceIdentity)
+		}
+		if options.WriteBackCache || options.ClientSideEncryption || options.Compression {
+			p.Logger.Error(podUID + ":" + " auth-mode " + AuthModeInstanceIdentity + " is not supported with rclone mounts")
+			return fmt.Errorf("auth-mode %s cannot be used with write-back-cache, client-side-encryption or compression", AuthModeInstanceIdentity)
+		}
+		apiKey = options.TrustedProfileID
+	} else if options.AuthMode != "" {
+		p.Logger.Error(podUID+":"+" Bad value for auth-mode",
+			zap.String("auth-mode", options.AuthMode))
+		return fmt.Errorf("Bad value for auth-mode \"%v\": only %s is supported", options.AuthMode, AuthModeInstanceIdentity)
+	} else if options.APIKeyB64 != "" {
 		apiKey, err = parser.DecodeBase64(options.APIKeyB64)
 		if err != nil {
 			p.Logger.Error(podUID+":"+
@@ -448,7 +819,24 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		}
 	}
 
-	if apiKey != "" {
+	var encryptionKey string
+	if options.ClientSideEncryption {
+		encryptionKey, err = parser.DecodeBase64(options.EncryptionKeyB64)
+		if err != nil {
+			p.Logger.Error(podUID+":"+
+				" Cannot decode encryption key",
+				zap.Error(err))
+			return fmt.Errorf("cannot decode encryption key: %v", err)
+		}
+		if encryptionKey == "" {
+			p.Logger.Error(podUID + ":" + " client-side-encryption is set but the secret has no " + SecretEncryptionKey)
+			return fmt.Errorf("client-side-encryption requires the %s key in the volume secret", SecretEncryptionKey)
+		}
+	}
+
+	if options.AuthMode == AuthModeInstanceIdentity {
+		iamEndpoint = "http://" + tokenServerAddress
+	} else if apiKey != "" {
 		if options.IAMEndpoint == "" {
 			iamEndpoint = defaultIAMEndPoint
 		} else {
@@ -466,6 +854,8 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 			}
 		}
 	}
+	errorCode = interfaces.ErrorCodeMountFailed
+
 	if options.CAbundleB64 != "" {
 		CaBundleKey, err := parser.DecodeBase64(options.CAbundleB64)
 		caFileName := "_ca.crt"
@@ -498,7 +888,7 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		}
 	}
 	// check that bucket exists before doing the mount
-	err = p.checkBucket(endptValue, regionValue, options.Bucket,
+	err = p.checkBucket(endptValue, regionValue, options.Bucket, options.ObjectPath, options.BucketAccessCheck,
 		&backend.ObjectStorageCredentials{
 			AccessKey:         accessKey,
 			SecretKey:         secretKey,
@@ -508,12 +898,12 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 	if err != nil {
 		p.Logger.Error(podUID+":"+" Cannot access bucket",
 			zap.Error(err))
-		return fmt.Errorf("cannot access bucket: %v", err)
+		return withCode(backendErrorCode(err), fmt.Errorf("cannot access bucket: %v", err))
 	}
 
 	// check that object-path exists inside bucket before doing the mount
 	if options.ObjectPath != "" {
-		exist, err := p.checkObjectPath(endptValue, regionValue, options.Bucket, options.ObjectPath,
+		exist, err := p.checkObjectPath(endptValue, regionValue, options.Bucket, options.ObjectPath, options.ObjectPathAsPrefix,
 			&backend.ObjectStorageCredentials{
 				AccessKey:         accessKey,
 				SecretKey:         secretKey,
@@ -523,11 +913,13 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		if err != nil {
 			p.Logger.Error(podUID+":"+" Cannot access object-path inside bucket",
 				zap.String("bucket", options.Bucket), zap.String("object-path", options.ObjectPath), zap.Error(err))
-			return fmt.Errorf("cannot access object-path \"%s\" inside bucket %s: %v", options.ObjectPath, options.Bucket, err)
+			return withCode(backendErrorCode(err),
+				fmt.Errorf("cannot access object-path \"%s\" inside bucket %s: %v", options.ObjectPath, options.Bucket, err))
 		} else if !exist {
 			p.Logger.Error(podUID+":"+" object-path not found inside bucket",
 				zap.String("bucket", options.Bucket), zap.String("object-path", options.ObjectPath))
-			return fmt.Errorf("object-path \"%s\" not found inside bucket %s", options.ObjectPath, options.Bucket)
+			return withCode(interfaces.ErrorCodeNotFound,
+				fmt.Errorf("object-path \"%s\" not found inside bucket %s", options.ObjectPath, options.Bucket))
 		}
 	}
 
@@ -540,7 +932,8 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 	}
 
 	// mount data path
-	mountPath := path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(mountRequest.MountDir))))
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(mountRequest.MountDir)))
+	mountPath := path.Join(dataRootPath, mountHash)
 	done := false
 	err = p.createEmptyMountpoint(mountPath)
 	if err != nil {
@@ -572,6 +965,50 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 			zap.Error(err))
 		return fmt.Errorf("cannot create password file: %v", err)
 	}
+	p.saveStatsConfig(mountPath, options, apiKey, endptValue, regionValue, iamEndpoint)
+
+	// create additional header file
+	ahbeConfFile := path.Join(mountPath, ahbeConfFileName)
+	if options.AhbeConf != "" {
+		err = writeFile(ahbeConfFile, []byte(options.AhbeConf), 0600)
+		if err != nil {
+			p.Logger.Error(podUID+":"+" Cannot create additional header file",
+				zap.Error(err))
+			return fmt.Errorf("cannot create additional header file: %v", err)
+		}
+	}
+
+	// locate or create mime.types file
+	var mimeFile string
+	if options.DetectContentType {
+		mimeFile, err = p.mimeTypesFile()
+		if err != nil {
+			p.Logger.Error(podUID+":"+" Cannot create mime types file",
+				zap.Error(err))
+			return fmt.Errorf("cannot create mime types file: %v", err)
+		}
+	}
+
+	// create volume log directory
+	if options.LogFile {
+		err = p.createDirectoryIfNotExists(mountLogDir)
+		if err != nil {
+			p.Logger.Error(podUID+":"+" Cannot create log directory",
+				zap.Error(err))
+			return fmt.Errorf("cannot create log directory: %v", err)
+		}
+	}
+
+	// create size-bounded tmpfs cache directory
+	cacheDir := path.Join(mountPath, cacheDirectoryName)
+	if options.TmpfsCacheSizeMB != "" {
+		err = p.createTmpfsCache(cacheDir, options.TmpfsCacheSizeMB)
+		if err != nil {
+			p.Logger.Error(podUID+":"+" Cannot create tmpfs cache",
+				zap.Error(err))
+			return fmt.Errorf("cannot create tmpfs cache: %v", err)
+		}
+	}
 
 	if options.ObjectPath != "" {
 		if strings.HasPrefix(options.ObjectPath, "/") {
@@ -582,6 +1019,36 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 	} else {
 		fullBucketPath = options.Bucket
 	}
+
+	// Encrypted and compressed volumes are mounted with rclone, through crypt and compress remotes
+	var cryptPassword string
+	if options.ClientSideEncryption {
+		cryptPassword, err = p.obscure(encryptionKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.WriteBackCache || options.ClientSideEncryption || options.Compression {
+		err = p.mountWriteBack(mountRequest, mountPath, writeBackCacheDir(mountHash), limits,
+			&writeBackConfig{
+				endpoint:          endptValue,
+				region:            regionValue,
+				remotePath:        strings.Replace(fullBucketPath, ":/", "/", 1),
+				accessKey:         accessKey,
+				secretKey:         secretKey,
+				apiKey:            apiKey,
+				serviceInstanceID: serviceInstanceId,
+				cryptPassword:     cryptPassword,
+				compress:          options.Compression,
+			}, &options)
+		if err != nil {
+			return err
+		}
+		done = true
+		return nil
+	}
+
 	args := []string{fullBucketPath, mountRequest.MountDir,
 		"-o", "multireq_max=" + strconv.Itoa(options.MultiReqMax),
 		"-o", "use_path_request_style",
@@ -589,7 +1056,7 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		"-o", "url=" + endptValue,
 		"-o", "endpoint=" + regionValue,
 		"-o", "parallel_count=" + strconv.Itoa(options.ParallelCount),
-		"-o", "multipart_size=" + strconv.Itoa(options.ChunkSizeMB),
+		"-o", "multipart_size=" + multipartSizeMB,
 		"-o", "dbglevel=" + options.DebugLevel,
 		"-o", "max_stat_cache_size=" + strconv.Itoa(options.StatCacheSize),
 		"-o", "allow_other",
@@ -598,17 +1065,16 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		"-o", "instance_name=" + mountRequest.MountDir,
 	}
 
-	//if options.FSGroup != "" {
-	if _, ok := mountRequest.Opts["kubernetes.io/fsGroup"]; ok {
-		args = append(args, "-o", "gid="+options.FSGroup)
-		args = append(args, "-o", "uid="+options.FSGroup)
-	} else if _, ok := mountRequest.Opts["kubernetes.io/mounterArgs.FsGroup"]; ok {
-		args = append(args, "-o", "gid="+options.FSGroupNew)
-		args = append(args, "-o", "uid="+options.FSGroupNew)
+	if groupID != "" {
+		args = append(args, "-o", "gid="+groupID)
+		args = append(args, "-o", "uid="+groupID)
+	} else if options.CompatProfile == CompatProfileOpenShift {
+		// Pods with arbitrary UIDs always run with the root group on OpenShift
+		args = append(args, "-o", "gid=0")
 	}
 
-	// Check if AccessMode is ReadOnlyMany
-	if options.AccessMode == "ReadOnlyMany" {
+	// Check if the volume is read-only
+	if readOnly(options) {
 		args = append(args, "-o", "ro")
 	}
 
@@ -657,6 +1123,96 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 		args = append(args, "-o", "use_xattr")
 	}
 
+	if options.TmpfsCacheSizeMB != "" {
+		args = append(args, "-o", "use_cache="+cacheDir)
+	}
+
+	// FUSE read-ahead window, given in KB and passed to FUSE in bytes
+	if options.ReadAheadKB != "" {
+		readAheadKB, _ := strconv.Atoi(options.ReadAheadKB)
+		args = append(args, "-o", "max_readahead="+strconv.Itoa(readAheadKB*1024))
+	}
+
+	// List directories with a single request instead of a HEAD per entry
+	if options.ReaddirOptimize {
+		args = append(args, "-o", "readdir_optimize")
+	}
+
+	// Cache negative lookups so stats of missing paths don't hit the endpoint every time
+	if options.NoObjCache {
+		args = append(args, "-o", "enable_noobj_cache")
+	}
+
+	// Objects bigger than this are copied (renamed) with multipart copy
+	if options.SinglepartCopyLimitMB != "" {
+		args = append(args, "-o", "singlepart_copy_limit="+options.SinglepartCopyLimitMB)
+	}
+
+	// Flush a file being written once this much modified data is buffered
+	if options.MaxDirtyDataMB != "" {
+		args = append(args, "-o", "max_dirty_data="+options.MaxDirtyDataMB)
+	}
+
+	// Page size of the ListObjects requests issued for directory listings
+	if options.ListObjectMaxKeys != "" {
+		args = append(args, "-o", "list_object_max_keys="+options.ListObjectMaxKeys)
+	}
+
+	// In stateless mode every entry gets its permissions from the umask instead of x-amz-meta-mode.
+	// The same applies to pods with an fsGroup or using the openshift profile, so that the
+	// group they run with can write, unless persistent permissions were asked for explicitly.
+	if options.PermissionMode == PermissionModeStateless ||
+		((groupID != "" || options.CompatProfile == CompatProfileOpenShift) && options.PermissionMode == "") {
+		args = append(args, "-o", "umask=0002")
+	}
+
+	// Show directories that only exist as key prefixes (written by other S3 tools)
+	if options.CompatDir {
+		args = append(args, "-o", "compat_dir")
+	}
+
+	// Only treat placeholder objects as directories, saving requests on buckets written through s3fs
+	if options.NotSupCompatDir {
+		args = append(args, "-o", "notsup_compat_dir")
+	}
+
+	// Give objects uploaded without x-amz-meta-mode readable permissions instead of 000
+	if options.ComplementStat {
+		args = append(args, "-o", "complement_stat")
+	}
+
+	// Per-suffix HTTP headers added to objects written through the mount
+	if options.AhbeConf != "" {
+		args = append(args, "-o", "ahbe_conf="+ahbeConfFile)
+	}
+
+	// Set the Content-Type of uploaded objects from their file extension
+	if options.DetectContentType {
+		args = append(args, "-o", "mime="+mimeFile)
+	}
+
+	// SELinux label of every entry of the mount, quoted since MCS levels may contain commas
+	if options.SELinuxContext != "" {
+		args = append(args, "-o", "context=\""+options.SELinuxContext+"\"")
+	}
+
+	// Log to a file of the volume instead of syslog
+	if options.LogFile {
+		args = append(args, "-o", "logfile="+mountLogFile(mountRequest.MountDir, mountHash))
+	}
+
+	// Options of the mountOptions of the volume, before the annotations overriding them
+	for _, value := range mountOptions {
+		args = append(args, "-o", value)
+	}
+
+	// Options checked against the allowlist of the provisioner
+	if options.ExtraMountOptions != "" {
+		for _, value := range strings.Split(options.ExtraMountOptions, ",") {
+			args = append(args, "-o", value)
+		}
+	}
+
 	if options.AddMountParam != "" {
 		paramSlice := strings.Split(options.AddMountParam, ",")
 		for _, value := range paramSlice {
@@ -673,17 +1229,28 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 			zap.String("path:", mountRequest.MountDir))
 	}
 
+	// pick the s3fs build matching the FUSE version of the node
+	s3fs := s3fsBinaryName()
+	args = p.fuseOptions(args)
+
 	p.Logger.Info(podUID+":"+"Running s3fs",
-		zap.Reflect("args", args))
+		zap.String("binary", s3fs), zap.Reflect("args", args))
 
-	output, err := command("s3fs", "--version").CombinedOutput()
+	output, err := command(s3fs, "--version").CombinedOutput()
+	if wrongArchitecture(err) {
+		p.Logger.Error(podUID+":"+"s3fs binary does not match the node architecture",
+			zap.String("binary", s3fs), zap.Error(err))
+		return errWrongArchitecture(s3fs)
+	}
 	if err == nil {
 		version := strings.Split(string(output), "\n")
 		p.Logger.Info(podUID+":S3FS-Fuse info:", zap.String("Version", version[0]))
 	}
 	p.Logger.Info(podUID+":S3FS-Driver info:", zap.String("Version", buildVersion))
 
-	out, err := command("s3fs", args...).CombinedOutput()
+	state := &mountState{MountDir: mountRequest.MountDir, Command: s3fs, Args: args, ScopeProperties: limits}
+	p.saveMountState(mountPath, state)
+	out, err := p.runMounter(mountHash, state)
 	if err != nil {
 		p.Logger.Error(podUID+":"+"Running s3fs",
 			zap.String("Error", string(out)))
@@ -699,6 +1266,18 @@ func (p *S3fsPlugin) mountInternal(mountRequest interfaces.FlexVolumeMountReques
 			zap.String("path:", mountRequest.MountDir))
 	}
 
+	if options.UseXattr {
+		err = p.checkXattrSupport(mountRequest.MountDir)
+		if err != nil {
+			mounterr := p.unmountPath(mountRequest.MountDir, false)
+			if mounterr != nil {
+				p.Logger.Error(podUID+":"+"Error unmounting volume",
+					zap.Error(mounterr))
+			}
+			return err
+		}
+	}
+
 	done = true
 	return nil
 }
@@ -708,6 +1287,17 @@ func (p *S3fsPlugin) Mount(mountRequest interfaces.FlexVolumeMountRequest) inter
 	p.Logger.Info(podUID + ":" + "S3fsPlugin-Mount()-start")
 	defer p.Logger.Info(podUID + ":" + "S3fsPlugin-Mount()-end")
 
+	unlock := p.lockVolume(mountRequest.MountDir)
+	defer unlock()
+	if p.remountedThisBoot(mountRequest.MountDir) {
+		p.Logger.Info(podUID+":"+"Volume already mounted again after reboot",
+			zap.String("mountRequest.MountDir", mountRequest.MountDir))
+		return interfaces.FlexVolumeResponse{
+			Status:  interfaces.StatusSuccess,
+			Message: fmt.Sprintf("Volume mounted successfully to %s", mountRequest.MountDir),
+		}
+	}
+
 	err := p.mountInternal(mountRequest)
 	if err != nil {
 		p.Logger.Info(podUID+":"+"Error mounting volume",
@@ -716,9 +1306,11 @@ func (p *S3fsPlugin) Mount(mountRequest interfaces.FlexVolumeMountRequest) inter
 		return interfaces.FlexVolumeResponse{
 			Status:  interfaces.StatusFailure,
 			Message: fmt.Sprintf("Error mounting volume: %v", err),
+			Code:    ErrorCode(err, interfaces.ErrorCodeMountFailed),
 		}
 	}
 
+	p.saveRemountRecord(mountRequest)
 	p.Logger.Info(podUID+":"+"Successfully executed mount",
 		zap.String("mountRequest.MountDir", mountRequest.MountDir))
 
@@ -741,6 +1333,7 @@ func (p *S3fsPlugin) Unmount(unmountRequest interfaces.FlexVolumeUnmountRequest)
 		return interfaces.FlexVolumeResponse{
 			Status:  interfaces.StatusFailure,
 			Message: fmt.Sprintf("Error unmounting volume: %v", err),
+			Code:    interfaces.ErrorCodeUnmountFailed,
 		}
 	}
 
@@ -755,7 +1348,32 @@ func (p *S3fsPlugin) Unmount(unmountRequest interfaces.FlexVolumeUnmountRequest)
 
 // Unmount methods unmounts the volume/ fileset from the pod
 func (p *S3fsPlugin) unmountInternal(unmountRequest interfaces.FlexVolumeUnmountRequest) error {
-	err := p.unmountPath(unmountRequest.MountDir, false)
+	err := p.unmountRecordedSources(unmountRequest.MountDir)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot unmount volume sources",
+			zap.String("Request", unmountRequest.MountDir),
+			zap.Error(err))
+		return err
+	}
+
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(unmountRequest.MountDir)))
+	mountPath := path.Join(dataRootPath, mountHash)
+
+	// the rclone daemon of a write-back volume uploads its cache before the volume is detached
+	writeBackSynced, err := p.syncWriteBack(mountPath)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot sync write-back cache",
+			zap.String("mountpath", mountPath), zap.Error(err))
+		return err
+	}
+
+	err = p.unmountPath(unmountRequest.MountDir, false)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot unmount s3fs mount point. Stopping its FUSE daemon",
+			zap.String("Request", unmountRequest.MountDir),
+			zap.Error(err))
+		err = p.forceUnmount(unmountRequest.MountDir)
+	}
 	if err != nil {
 		p.Logger.Error(podUID+":"+"Cannot unmount s3fs mount point",
 			zap.String("Request", unmountRequest.MountDir),
@@ -763,7 +1381,23 @@ func (p *S3fsPlugin) unmountInternal(unmountRequest interfaces.FlexVolumeUnmount
 		return fmt.Errorf("cannot unmount s3fs mount point %s: %v", unmountRequest.MountDir, err)
 	}
 
-	mountPath := path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(unmountRequest.MountDir))))
+	cacheDir := path.Join(mountPath, cacheDirectoryName)
+	err = p.unmountPath(cacheDir, false)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot unmount cache directory",
+			zap.String("cacheDir", cacheDir), zap.Error(err))
+		return fmt.Errorf("cannot unmount cache directory %s: %v", cacheDir, err)
+	}
+
+	if writeBackSynced {
+		err = removeAll(writeBackCacheDir(mountHash))
+		if err != nil {
+			p.Logger.Error(podUID+":"+"Cannot remove write-back cache",
+				zap.String("mountpath", mountPath), zap.Error(err))
+			return fmt.Errorf("cannot remove write-back cache %s: %v", writeBackCacheDir(mountHash), err)
+		}
+	}
+
 	err = p.unmountPath(mountPath, true)
 	if err != nil {
 		p.Logger.Error(podUID+":"+"Cannot delete data  mount point",
@@ -771,5 +1405,14 @@ func (p *S3fsPlugin) unmountInternal(unmountRequest interfaces.FlexVolumeUnmount
 		return fmt.Errorf("cannot delete data mount point %s: %v", mountPath, err)
 	}
 
+	// the volume is no longer to be mounted again after a reboot, nor recovered when stale
+	for _, file := range []string{volumeFile(unmountRequest.MountDir, remountFileSuffix), volumeFile(unmountRequest.MountDir, lockFileSuffix),
+		mountStateFile(mountPath)} {
+		if err := removeAll(file); err != nil {
+			p.Logger.Error(podUID+":"+"Cannot remove remount record",
+				zap.String("file", file), zap.Error(err))
+		}
+	}
+
 	return nil
 }
@@ -11,20 +11,33 @@
 package driver
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/mounter"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
 	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
 	"github.com/IBM/ibmcloud-object-storage-plugin/utils/parser"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"runtime"
 	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -50,6 +63,36 @@ const (
 	optionServiceIP               = "service-ip"
 	optionAutoCache               = "auto_cache"
 	optionAddMountParam           = "add-mount-param"
+	optionTmpfsCacheSizeMB        = "tmpfs-cache-size-mb"
+	optionWriteBackCache          = "write-back-cache"
+	optionWriteBackDelaySeconds   = "write-back-delay-seconds"
+	optionReadAheadKB             = "read-ahead-kb"
+	optionReaddirOptimize         = "readdir-optimize"
+	optionNoObjCache              = "noobj-cache"
+	optionMultipartSizeMB         = "multipart-size-mb"
+	optionSinglepartCopyLimitMB   = "singlepart-copy-limit-mb"
+	optionMaxDirtyDataMB          = "max-dirty-data-mb"
+	optionListObjectMaxKeys       = "list-object-max-keys"
+	optionPermissionMode          = "permission-mode"
+	optionCompatDir               = "compat-dir"
+	optionNotSupCompatDir         = "notsup-compat-dir"
+	optionComplementStat          = "complement-stat"
+	optionAhbeConf                = "ahbe-conf"
+	optionDetectContentType       = "detect-content-type"
+	optionSELinuxContext          = "selinux-context"
+	optionCompatProfile           = "compat-profile"
+	optionLogFile                 = "log-file"
+	optionMounterCPULimit         = "mounter-cpu-limit"
+	optionMounterMemoryLimit      = "mounter-memory-limit"
+	optionExtraMountOptions       = "extra-mount-options"
+	optionMountOptions            = "mount-options"
+	optionSources                 = "sources"
+	optionClientSideEncryption    = "client-side-encryption"
+	optionEncryptionKey           = "kubernetes.io/secret/encryption-key"
+	optionCompression             = "compression"
+	optionAuthMode                = "auth-mode"
+	optionTrustedProfileID        = "trusted-profile-id"
+	optionResConfAPIKey           = "kubernetes.io/secret/res-conf-apikey"
 
 	testDir            = "/tmp/"
 	testChunkSizeMB    = 500
@@ -91,9 +134,33 @@ var (
 
 	writeFileSuccess = func(string, []byte, os.FileMode) error { return nil }
 	writeFileError   = func(string, []byte, os.FileMode) error { return errors.New("") }
+
+	getxattrNoData       = func(string, string, []byte) (int, error) { return 0, syscall.ENODATA }
+	getxattrNotSupported = func(string, string, []byte) (int, error) { return 0, syscall.ENOTSUP }
+
+	lookPathSuccess  = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	lookPathNotFound = func(file string) (string, error) { return "", exec.ErrNotFound }
+
+	killSuccess = func(pid int, sig syscall.Signal) error { return nil }
+
+	readDirNotExist = func(string) ([]os.FileInfo, error) { return nil, os.ErrNotExist }
 )
 
 var commandArgs []string
+
+type fakeMounterClient struct {
+	req *mounter.Request
+	err error
+}
+
+func (c *fakeMounterClient) Run(req *mounter.Request) ([]byte, error) {
+	c.req = req
+	if c.err != nil {
+		return []byte("mounter output"), c.err
+	}
+	return nil, nil
+}
+
 var commandOutput string
 var commandFailure bool
 
@@ -105,6 +172,14 @@ func getPlugin() *S3fsPlugin {
 	removeAll = removeAllSuccess
 	unmount = unmountSuccess
 	writeFile = writeFileSuccess
+	getxattr = getxattrNoData
+	lookPath = lookPathNotFound
+	mounterSocketPath = ""
+	procRoot = "/nonexistent-proc"
+	kill = killSuccess
+	unmountRetryInterval = 0
+	writeBackSyncTimeout, writeBackSyncInterval = 0, 0
+	readDir = readDirNotExist
 	commandArgs = nil
 	command = func(cmd string, args ...string) *exec.Cmd {
 		commandArgs = args
@@ -121,8 +196,9 @@ func getPlugin() *S3fsPlugin {
 		return ret
 	}
 	return &S3fsPlugin{
-		Backend: &fake.ObjectStorageSessionFactory{},
-		Logger:  zap.NewNop(),
+		Backend:      &fake.ObjectStorageSessionFactory{},
+		AccessPolicy: &fake.FakeAccessPolicyFactory{},
+		Logger:       zap.NewNop(),
 	}
 }
 
@@ -173,6 +249,20 @@ func Test_Mount_BadDriverOptions(t *testing.T) {
 	}
 }
 
+func Test_Mount_UnknownBackend(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts["backend"] = "azure"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeBadOptions, resp.Code)
+		assert.Contains(t, resp.Message, `object storage backend "azure" cannot be mounted`)
+	}
+	assert.NoError(t, checkBackend(""))
+	assert.NoError(t, checkBackend(backend.S3BackendName))
+}
+
 func Test_Mount_BadAPIKey(t *testing.T) {
 	p := getPlugin()
 	r := getMountRequest()
@@ -288,6 +378,30 @@ func Test_Mount_FailBucketAccess(t *testing.T) {
 	}
 }
 
+func Test_Mount_BucketAccessCheck_HeadObject(t *testing.T) {
+	p := getPlugin()
+	factory := p.Backend.(*fake.ObjectStorageSessionFactory)
+	r := getMountRequest()
+	r.Opts["bucket-access-check"] = backend.AccessCheckHeadObject
+
+	resp := p.Mount(r)
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.Equal(t, testBucket, factory.LastCheckedBucket)
+	assert.Equal(t, ".ibm-s3fs-access-check", factory.LastCheckedObject)
+}
+
+func Test_Mount_CheckObjectPath_AsPrefix(t *testing.T) {
+	p := getPlugin()
+	factory := p.Backend.(*fake.ObjectStorageSessionFactory)
+	r := getMountRequest()
+	r.Opts[optionObjectPath] = testObjectPath
+	r.Opts["object-path-as-prefix"] = "true"
+
+	resp := p.Mount(r)
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.True(t, factory.LastObjectPathAsPrefix)
+}
+
 func Test_Mount_CheckObjectPath_Error(t *testing.T) {
 	p := &S3fsPlugin{
 		Backend: &fake.ObjectStorageSessionFactory{CheckObjectPathExistenceError: true},
@@ -315,6 +429,7 @@ func Test_Mount_CheckObjectPath_PathNotFound(t *testing.T) {
 	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
 		assert.Contains(t, resp.Message, fmt.Sprintf("object-path \"%s\" not found inside bucket %s",
 			r.Opts[optionObjectPath], r.Opts["bucket"]))
+		assert.Equal(t, interfaces.ErrorCodeNotFound, resp.Code)
 	}
 }
 
@@ -712,6 +827,7 @@ func Test_Mount_fsGroup_Nogroup_Positive(t *testing.T) {
 		"-o", "uid=65534",
 		"-o", "cipher_suites=" + testTLSCipherSuite,
 		"-o", "default_acl=private",
+		"-o", "umask=0002",
 	}
 	resp := p.Mount(r)
 	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
@@ -749,6 +865,16 @@ func Test_Mount_ReadOnly_Positive(t *testing.T) {
 	}
 }
 
+func Test_Mount_ReadOnlyVolumeSource_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts["kubernetes.io/readwrite"] = "ro"
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Contains(t, strings.Join(commandArgs, " "), "-o ro")
+	}
+}
+
 func Test_Mount_DummyOSStorageClass_Positive(t *testing.T) {
 	p := getPlugin()
 	r := getMountRequest()
@@ -921,6 +1047,8 @@ func Test_Init_Positive(t *testing.T) {
 	p := getPlugin()
 	resp := p.Init()
 	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.False(t, resp.Capabilities.FSGroup)
+	assert.False(t, resp.Capabilities.SELinuxRelabel)
 }
 func Test_ConnectTimeoutSeconds_NonInt(t *testing.T) {
 	p := getPlugin()
@@ -1118,6 +1246,7 @@ func Test_Mount_fsGroupNew_Nogroup_Positive(t *testing.T) {
 		"-o", "uid=65534",
 		"-o", "cipher_suites=" + testTLSCipherSuite,
 		"-o", "default_acl=private",
+		"-o", "umask=0002",
 	}
 	resp := p.Mount(r)
 	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
@@ -1189,3 +1318,2378 @@ func Test_AddMountParam(t *testing.T) {
 		assert.Equal(t, expectedArgs, commandArgs)
 	}
 }
+
+func Test_Mount_BadTmpfsCacheSizeMB_NonInt(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionTmpfsCacheSizeMB] = "non-int-value"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Cannot convert value of tmpfs-cache-size-mb into integer")
+	}
+}
+
+func Test_Mount_BadTmpfsCacheSizeMB_Zero(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionTmpfsCacheSizeMB] = "0"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of tmpfs-cache-size-mb should be >= 1")
+	}
+}
+
+func Test_Mount_TmpfsCacheMountError(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionTmpfsCacheSizeMB] = "64"
+	mount = func(source string, target string, fstype string, flags uintptr, data string) error {
+		if path.Base(target) == cacheDirectoryName {
+			return errors.New("")
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "cannot create tmpfs cache")
+	}
+}
+
+func Test_TmpfsCacheSizeMB_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionTmpfsCacheSizeMB] = "64"
+
+	var cacheMountData string
+	mount = func(source string, target string, fstype string, flags uintptr, data string) error {
+		if path.Base(target) == cacheDirectoryName {
+			cacheMountData = data
+		}
+		return nil
+	}
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "use_cache=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), cacheDirectoryName),
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+		assert.Equal(t, "size=64m,mode=0700", cacheMountData)
+	}
+}
+
+func Test_Mount_BadWriteBackDelaySeconds_NonInt(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionWriteBackDelaySeconds] = "non-int-value"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Cannot convert value of write-back-delay-seconds into integer")
+	}
+}
+
+func Test_Mount_WriteBackConfigFileError(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionWriteBackCache] = "true"
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == rcloneConfigFileName {
+			return errors.New("")
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "cannot create rclone config file")
+	}
+}
+
+func Test_WriteBackCache_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionWriteBackCache] = "true"
+	r.Opts[optionWriteBackDelaySeconds] = "30"
+	r.Opts[optionObjectPath] = testObjectPath
+
+	var rcloneConfig string
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == rcloneConfigFileName {
+			rcloneConfig = string(data)
+		}
+		return nil
+	}
+
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+	expectedArgs := []string{
+		"mount", rcloneRemoteName + ":", testDir,
+		"--config", path.Join(dataRootPath, mountHash, rcloneConfigFileName),
+		"--cache-dir", path.Join(writeBackRootPath, mountHash),
+		"--vfs-cache-mode", "writes",
+		"--vfs-write-back", "30s",
+		"--allow-other",
+		"--umask", "002",
+		"--daemon",
+		"--rc",
+		"--rc-addr", "unix://" + path.Join(dataRootPath, mountHash, rcloneRCSocketName),
+		"--rc-no-auth",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+		assert.Contains(t, rcloneConfig, "endpoint = "+testOSEndpoint)
+		assert.Contains(t, rcloneConfig, "access_key_id = "+testAccessKey)
+		assert.Contains(t, rcloneConfig, "remote = cos:"+testBucket+testObjectPath)
+	}
+}
+
+// getTmpfsUsage mounts a volume and returns the size of its tmpfs, and the bytes the files written into it take,
+// tmpfs charging whole pages
+func getTmpfsUsage(t *testing.T, p *S3fsPlugin, r interfaces.FlexVolumeMountRequest) (int, int) {
+	mountPath := path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(r.MountDir))))
+	size := 0
+	mount = func(source, target, fstype string, flags uintptr, data string) error {
+		if target == mountPath && fstype == "tmpfs" {
+			var err error
+			size, err = strconv.Atoi(strings.TrimPrefix(data, "size="))
+			assert.NoError(t, err)
+		}
+		return nil
+	}
+	files := map[string]int{}
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Dir(name) == mountPath {
+			files[name] = len(data)
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message)
+	used := 0
+	for _, n := range files {
+		used += (n + os.Getpagesize() - 1) / os.Getpagesize() * os.Getpagesize()
+	}
+	return size, used
+}
+
+func Test_Mount_TmpfsSize(t *testing.T) {
+	// s3fs with every file it may write
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAhbeConf] = ".gz Content-Encoding gzip\n"
+	r.Opts[optionDetectContentType] = "true"
+	stat = func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist }
+	size, used := getTmpfsUsage(t, p, r)
+	assert.LessOrEqual(t, used, size)
+
+	// rclone
+	p = getPlugin()
+	r = getMountRequest()
+	r.Opts[optionWriteBackCache] = "true"
+	size, used = getTmpfsUsage(t, p, r)
+	assert.LessOrEqual(t, used, size)
+}
+
+// getWriteBackUnmount returns the plugin unmounting a write-back volume whose rclone daemon answers vfs/stats
+// with stats, the removed paths and the arguments of the rclone calls
+func getWriteBackUnmount(stats string) (*S3fsPlugin, *[]string, *[]string) {
+	p := getPlugin()
+	mountPath := path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))))
+	state, _ := json.Marshal(&mountState{MountDir: testDir, Command: "rclone",
+		Args: []string{"--rc-addr", "unix://" + path.Join(mountPath, rcloneRCSocketName)}})
+	readFile = func(name string) ([]byte, error) {
+		if name == mountStateFile(mountPath) {
+			return state, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	commandOutput = stats
+	var removed, rcloneArgs []string
+	removeAll = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	helper := command
+	command = func(cmd string, args ...string) *exec.Cmd {
+		if cmd == "rclone" {
+			rcloneArgs = args
+		}
+		return helper(cmd, args...)
+	}
+	return p, &removed, &rcloneArgs
+}
+
+func Test_Unmount_WriteBackSynced(t *testing.T) {
+	defer func(output string) { readFile, commandOutput = ioutil.ReadFile, output }(commandOutput)
+	p, removed, rcloneArgs := getWriteBackUnmount(`{"diskCache": {"uploadsInProgress": 0, "uploadsQueued": 0}}`)
+
+	resp := p.Unmount(getUnmountRequest())
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+		assert.Equal(t, []string{"rc", "--unix-socket", path.Join(dataRootPath, mountHash, rcloneRCSocketName), "vfs/stats"},
+			*rcloneArgs)
+		assert.Contains(t, *removed, writeBackCacheDir(mountHash))
+	}
+}
+
+func Test_Unmount_WriteBackPending(t *testing.T) {
+	defer func(output string) { readFile, commandOutput = ioutil.ReadFile, output }(commandOutput)
+	p, _, _ := getWriteBackUnmount(`{"diskCache": {"uploadsInProgress": 1, "uploadsQueued": 2}}`)
+	unmounted := false
+	unmount = func(target string, flags int) error {
+		unmounted = true
+		return nil
+	}
+
+	resp := p.Unmount(getUnmountRequest())
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "3 files of the write-back cache are still being uploaded")
+		assert.False(t, unmounted)
+	}
+}
+
+func Test_Unmount_WriteBackUnreachable(t *testing.T) {
+	defer func(output string) { readFile, commandOutput = ioutil.ReadFile, output }(commandOutput)
+	p, removed, _ := getWriteBackUnmount("not json")
+
+	resp := p.Unmount(getUnmountRequest())
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+		assert.NotContains(t, *removed, writeBackCacheDir(mountHash))
+	}
+}
+
+func Test_WriteBackConfig_IAM(t *testing.T) {
+	cfg := &writeBackConfig{
+		endpoint:          testOSEndpoint,
+		region:            testStorageClass,
+		remotePath:        testBucket,
+		apiKey:            testAPIKey,
+		serviceInstanceID: "sid",
+	}
+
+	conf := cfg.rcloneConfig()
+	assert.Contains(t, conf, "ibm_api_key = "+testAPIKey)
+	assert.Contains(t, conf, "ibm_resource_instance_id = sid")
+	assert.NotContains(t, conf, "access_key_id")
+}
+
+func Test_Mount_BadReadAheadKB_NonInt(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionReadAheadKB] = "non-int-value"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Cannot convert value of read-ahead-kb into integer")
+	}
+}
+
+func Test_ReadAhead_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionReadAheadKB] = "1024"
+	r.Opts[optionReaddirOptimize] = "true"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "max_readahead=1048576",
+		"-o", "readdir_optimize",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_NoObjCache_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionNoObjCache] = "true"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "enable_noobj_cache",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_BadMultipartSizeMB_TooSmall(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMultipartSizeMB] = "1"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of multipart-size-mb should be >= 5")
+	}
+}
+
+func Test_Mount_BadSinglepartCopyLimitMB_NonInt(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSinglepartCopyLimitMB] = "non-int-value"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Cannot convert value of singlepart-copy-limit-mb into integer")
+	}
+}
+
+func Test_MultipartSize_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMultipartSizeMB] = "64"
+	r.Opts[optionSinglepartCopyLimitMB] = "128"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=64",
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "singlepart_copy_limit=128",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_BadMaxDirtyDataMB(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMaxDirtyDataMB] = "10"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of max-dirty-data-mb should be -1 or >= 50")
+	}
+}
+
+func Test_MaxDirtyData_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMaxDirtyDataMB] = "256"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "max_dirty_data=256",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_ConnectTimeoutSeconds_Zero(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionConnectTimeoutSeconds] = "0"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of connect-timeout should be >= 1")
+	}
+}
+
+func Test_ReadwriteTimeoutSeconds_Negative(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionReadwriteTimeoutSeconds] = "-5"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of readwrite-timeout should be >= 1")
+	}
+}
+
+func Test_Mount_BadListObjectMaxKeys(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionListObjectMaxKeys] = "0"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "value of list-object-max-keys should be >= 1")
+	}
+}
+
+func Test_ListObjectMaxKeys_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionListObjectMaxKeys] = "500"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "list_object_max_keys=500",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_UseXattr_NotSupported(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionUseXattr] = "true"
+	getxattr = getxattrNotSupported
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "extended attributes are not supported")
+	}
+}
+
+func Test_Mount_BadPermissionMode(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionPermissionMode] = "bad-mode"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for permission-mode")
+	}
+}
+
+func Test_PermissionMode_Stateless_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionPermissionMode] = PermissionModeStateless
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "umask=0002",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_CompatDirConflict(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompatDir] = "true"
+	r.Opts[optionNotSupCompatDir] = "true"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "compat-dir and notsup-compat-dir cannot be set together")
+	}
+}
+
+func Test_CompatDir_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompatDir] = "true"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "compat_dir",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_ComplementStat_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionComplementStat] = "true"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "complement_stat",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_AhbeConf_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAhbeConf] = ".gz Content-Encoding gzip\n"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "ahbe_conf=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), ahbeConfFileName),
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+// useTmpfs lets the driver mount the tmpfs of the volumes and write their files for real under a temporary
+// dataRootPath, and returns the tmpfs of the volume of testDir. The test is skipped if tmpfs cannot be mounted.
+func useTmpfs(t *testing.T) string {
+	root, err := ioutil.TempDir("", "ibmc-s3fs")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	if err := syscall.Mount("tmpfs", root, "tmpfs", 0, "size=1m"); err != nil {
+		t.Skipf("cannot mount a tmpfs: %v", err)
+	}
+	t.Cleanup(func() { syscall.Unmount(root, syscall.MNT_DETACH) })
+
+	mountPath := path.Join(root, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))))
+	t.Cleanup(func() { syscall.Unmount(mountPath, syscall.MNT_DETACH) })
+	t.Cleanup(func(previous string) func() {
+		return func() { dataRootPath = previous }
+	}(dataRootPath))
+	dataRootPath = root
+	mount = syscall.Mount
+	mkdirAll = os.MkdirAll
+	writeFile = ioutil.WriteFile
+	readFile = ioutil.ReadFile
+	return mountPath
+}
+
+func Test_AhbeConf_Tmpfs(t *testing.T) {
+	p := getPlugin()
+	mountPath := useTmpfs(t)
+	r := getMountRequest()
+	r.Opts[optionAhbeConf] = ".gz Content-Encoding gzip\n"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message) {
+		content, err := ioutil.ReadFile(path.Join(mountPath, ahbeConfFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, ".gz Content-Encoding gzip\n", string(content))
+	}
+}
+
+func Test_DetectContentType_SystemMimeTypes(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionDetectContentType] = "true"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "mime=" + systemMimeTypesFile,
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_DetectContentType_BundledMimeTypes(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionDetectContentType] = "true"
+	stat = func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist }
+	var mimeTypes []byte
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == mimeTypesFileName {
+			mimeTypes = data
+		}
+		return nil
+	}
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "mime=" + path.Join(dataRootPath, mimeTypesFileName),
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+		assert.Contains(t, string(mimeTypes), "text/html")
+	}
+}
+
+func Test_DetectContentType_BundledMimeTypesTmpfs(t *testing.T) {
+	p := getPlugin()
+	mountPath := useTmpfs(t)
+	r := getMountRequest()
+	r.Opts[optionDetectContentType] = "true"
+	stat = func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist }
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message) {
+		assert.Contains(t, commandArgs, "mime="+path.Join(dataRootPath, mimeTypesFileName))
+		content, err := ioutil.ReadFile(path.Join(dataRootPath, mimeTypesFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMimeTypes, string(content))
+		_, err = os.Stat(path.Join(mountPath, mimeTypesFileName))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func Test_Mount_fsGroup_PersistentPermissions(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts["kubernetes.io/mounterArgs.FsGroup"] = "1000"
+	r.Opts[optionPermissionMode] = PermissionModePersistent
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "gid=1000",
+		"-o", "uid=1000",
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+	}
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_fsGroup_Invalid(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts["kubernetes.io/fsGroup"] = "group"
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for fsGroup \"group\"")
+	}
+}
+
+func Test_SELinuxContext_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSELinuxContext] = "system_u:object_r:container_file_t:s0:c1,c2"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "context=\"system_u:object_r:container_file_t:s0:c1,c2\"",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_SELinuxContext_Invalid(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSELinuxContext] = "container_file_t"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for selinux-context")
+	}
+}
+
+func Test_CompatProfile_OpenShift(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompatProfile] = CompatProfileOpenShift
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "gid=0",
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "umask=0002",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_CompatProfile_OpenShift_fsGroup(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompatProfile] = CompatProfileOpenShift
+	r.Opts["kubernetes.io/mounterArgs.FsGroup"] = "1000"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "gid=1000",
+		"-o", "uid=1000",
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "umask=0002",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_CompatProfile_Invalid(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompatProfile] = "bad-profile"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for compat-profile")
+	}
+}
+
+func Test_Mount_SystemdScope(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	lookPath = lookPathSuccess
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+
+	expectedArgs := []string{
+		"--scope", "--collect", "--quiet",
+		"--unit=" + scopeUnitPrefix + mountHash,
+		"--description=IBM COS volume mounter " + mountHash,
+		"s3fs",
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, mountHash, passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_Mount_SystemdScope_NotRunningSystemd(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	lookPath = lookPathSuccess
+	stat = func(name string) (os.FileInfo, error) {
+		if name == systemdRuntimeDir {
+			return nil, os.ErrNotExist
+		}
+		return nil, nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, testBucket, commandArgs[0])
+	}
+}
+
+func Test_Mount_MounterPod(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	socket, err := ioutil.TempFile("", "mounter.sock")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(socket.Name())
+	mounterSocketPath = socket.Name()
+	client := &fakeMounterClient{}
+	newMounterClient = func(socketPath string) mounterClient { return client }
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) && assert.NotNil(t, client.req) {
+		assert.Equal(t, "s3fs", client.req.Command)
+		assert.Equal(t, testBucket, client.req.Args[0])
+		assert.Equal(t, testDir, client.req.Args[1])
+	}
+}
+
+func Test_Mount_MounterPod_Error(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	socket, err := ioutil.TempFile("", "mounter.sock")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(socket.Name())
+	mounterSocketPath = socket.Name()
+	newMounterClient = func(socketPath string) mounterClient {
+		return &fakeMounterClient{err: errors.New("exit status 1")}
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "s3fs mount failed: mounter output")
+	}
+}
+
+func getFakeProcRoot(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdlines := map[string]string{
+		"100": "s3fs\x00" + testBucket + "\x00" + testDir + "\x00-o\x00allow_other\x00",
+		"101": "/usr/bin/rclone\x00mount\x00volume:\x00/tmp/other\x00",
+		"102": "bash\x00" + testDir + "\x00",
+		"103": "",
+	}
+	for pid, cmdline := range cmdlines {
+		if err := os.Mkdir(path.Join(dir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, pid, "cmdline"), []byte(cmdline), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_FindMounterProcesses(t *testing.T) {
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+
+	pids, err := findMounterProcesses(testDir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{100}, pids)
+	}
+	pids, err = findMounterProcesses("/tmp/other")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{101}, pids)
+	}
+}
+
+func Test_Unmount_HungFUSEDaemon(t *testing.T) {
+	p := getPlugin()
+	r := getUnmountRequest()
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+	// lazy and forced unmount fail until the daemon is stopped
+	killed := map[int]syscall.Signal{}
+	kill = func(pid int, sig syscall.Signal) error {
+		killed[pid] = sig
+		return nil
+	}
+	unmount = func(target string, flags int) error {
+		if len(killed) == 0 {
+			return errors.New("device or resource busy")
+		}
+		return nil
+	}
+
+	resp := p.Unmount(r)
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.Equal(t, map[int]syscall.Signal{100: syscall.SIGTERM}, killed)
+}
+
+func Test_Unmount_HungFUSEDaemon_GiveUp(t *testing.T) {
+	p := getPlugin()
+	r := getUnmountRequest()
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+	var signals []syscall.Signal
+	kill = func(pid int, sig syscall.Signal) error {
+		signals = append(signals, sig)
+		return nil
+	}
+	unmount = unmountError
+
+	resp := p.Unmount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "giving up after 3 attempts, FUSE daemon pids [100]")
+	}
+	assert.Equal(t, []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL, syscall.SIGKILL}, signals)
+}
+
+// setMountState makes the driver find a single mount with the given state in its data directory
+func setMountState(t *testing.T, state *mountState) func() {
+	dir, err := ioutil.TempDir("", "ibmc-s3fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path.Join(dir, "hash"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	readDir = func(string) ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }
+	readFile = func(name string) ([]byte, error) {
+		if name != mountStateFile(path.Join(dataRootPath, "hash")) {
+			return nil, os.ErrNotExist
+		}
+		return json.Marshal(state)
+	}
+	return func() {
+		readFile = ioutil.ReadFile
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_Init_CleanupOrphanedMount(t *testing.T) {
+	p := getPlugin()
+	defer setMountState(t, &mountState{MountDir: "/nonexistent/pod/volume", Command: "s3fs",
+		Args: []string{testBucket, "/nonexistent/pod/volume"}})()
+
+	resp := p.Init()
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	// the volume of a deleted pod is not mounted again
+	assert.Nil(t, commandArgs)
+}
+
+func Test_Init_RemountDeadDaemon(t *testing.T) {
+	p := getPlugin()
+	args := []string{testBucket, testDir, "-o", "allow_other"}
+	defer setMountState(t, &mountState{MountDir: testDir, Command: "s3fs", Args: args})()
+	commandOutput = "... is a mountpoint"
+
+	resp := p.Init()
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.Equal(t, args, commandArgs)
+}
+
+func Test_Init_HealthyMount(t *testing.T) {
+	p := getPlugin()
+	args := []string{testBucket, testDir, "-o", "allow_other"}
+	defer setMountState(t, &mountState{MountDir: testDir, Command: "s3fs", Args: args})()
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+	commandOutput = "... is a mountpoint"
+
+	resp := p.Init()
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.Equal(t, []string{testDir}, commandArgs)
+}
+
+func Test_Preflight_Positive(t *testing.T) {
+	getPlugin()
+	lookPath = lookPathSuccess
+	readFile = func(name string) ([]byte, error) { return []byte("nodev\tsysfs\nnodev\tfuse\n\text4\n"), nil }
+	defer func() { readFile = ioutil.ReadFile }()
+
+	assert.NoError(t, Preflight())
+}
+
+func Test_Preflight_NoS3fs(t *testing.T) {
+	getPlugin()
+
+	err := Preflight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "s3fs binary not found")
+	}
+}
+
+func Test_Preflight_NoFUSEDevice(t *testing.T) {
+	getPlugin()
+	lookPath = lookPathSuccess
+	stat = statErrNotExist
+
+	err := Preflight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "FUSE device not available")
+	}
+}
+
+func Test_Preflight_NoKernelSupport(t *testing.T) {
+	getPlugin()
+	lookPath = lookPathSuccess
+	readFile = func(name string) ([]byte, error) { return []byte("nodev\tsysfs\nnodev\tfusectl\n\text4\n"), nil }
+	defer func() { readFile = ioutil.ReadFile }()
+
+	err := Preflight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kernel does not support FUSE")
+	}
+}
+
+func Test_Preflight_WrongArchitecture(t *testing.T) {
+	getPlugin()
+	lookPath = lookPathSuccess
+	binary := writeForeignBinary(t)
+	defer os.RemoveAll(path.Dir(binary))
+	command = func(cmd string, args ...string) *exec.Cmd { return exec.Command(binary, args...) }
+
+	err := Preflight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "s3fs binary is not built for the node architecture "+runtime.GOARCH)
+	}
+}
+
+func Test_Mount_WrongArchitecture(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	binary := writeForeignBinary(t)
+	defer os.RemoveAll(path.Dir(binary))
+	command = func(cmd string, args ...string) *exec.Cmd { return exec.Command(binary, args...) }
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "s3fs binary is not built for the node architecture "+runtime.GOARCH)
+		assert.Equal(t, interfaces.ErrorCodeMountFailed, resp.Code)
+	}
+}
+
+func Test_LogFile_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionLogFile] = "true"
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, mountHash, passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "logfile=" + path.Join(mountLogDir, "tmp-"+mountHash[:8]+".log"),
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_RotateMountLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountLogDir = dir
+	mountLogMaxBytes = 10
+	defer func() {
+		mountLogDir = "/var/log/ibmc-s3fs/volumes"
+		mountLogMaxBytes = 50 * 1024 * 1024
+	}()
+	logFile := path.Join(dir, "pv1.log")
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("first rotation\n"), 0640))
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "pv2.log"), []byte("small\n"), 0640))
+
+	assert.NoError(t, RotateMountLogs())
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("second rotation\n"), 0640))
+	assert.NoError(t, RotateMountLogs())
+
+	content, _ := ioutil.ReadFile(logFile)
+	assert.Empty(t, content)
+	content, _ = ioutil.ReadFile(logFile + ".1")
+	assert.Equal(t, "second rotation\n", string(content))
+	content, _ = ioutil.ReadFile(logFile + ".2")
+	assert.Equal(t, "first rotation\n", string(content))
+	content, _ = ioutil.ReadFile(path.Join(dir, "pv2.log"))
+	assert.Equal(t, "small\n", string(content))
+}
+
+func Test_MountLogForwarder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountLogDir = dir
+	defer func() { mountLogDir = "/var/log/ibmc-s3fs/volumes" }()
+	logFile := path.Join(dir, "pv1.log")
+	var out bytes.Buffer
+	f := &MountLogForwarder{Out: &out}
+
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("line 1\nline 2\npartial"), 0640))
+	assert.NoError(t, f.Forward())
+	assert.Equal(t, "[pv1] line 1\n[pv1] line 2\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("line 1\nline 2\npartial line 3\n"), 0640))
+	assert.NoError(t, f.Forward())
+	assert.Equal(t, "[pv1] partial line 3\n", out.String())
+
+	// truncated by a rotation
+	out.Reset()
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("line 4\n"), 0640))
+	assert.NoError(t, f.Forward())
+	assert.Equal(t, "[pv1] line 4\n", out.String())
+}
+
+func Test_ListMounts_Mounted(t *testing.T) {
+	p := getPlugin()
+	args := []string{testBucket, testDir, "-o", "url=" + testOSEndpoint}
+	defer setMountState(t, &mountState{MountDir: testDir, Command: "s3fs", Args: args})()
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+	commandOutput = "... is a mountpoint"
+
+	mounts, err := p.ListMounts()
+	if assert.NoError(t, err) && assert.Len(t, mounts, 1) {
+		assert.Equal(t, testDir, mounts[0].MountDir)
+		assert.Equal(t, testBucket, mounts[0].Bucket)
+		assert.Equal(t, testOSEndpoint, mounts[0].Endpoint)
+		assert.Equal(t, "s3fs", mounts[0].Mounter)
+		assert.Equal(t, 100, mounts[0].PID)
+		assert.NotEmpty(t, mounts[0].Uptime)
+		assert.Equal(t, MountStateMounted, mounts[0].State)
+	}
+}
+
+func Test_ListMounts_Stale(t *testing.T) {
+	p := getPlugin()
+	defer setMountState(t, &mountState{MountDir: testDir, Command: "s3fs", Args: []string{testBucket, testDir}})()
+	commandOutput = "... is a mountpoint"
+
+	mounts, err := p.ListMounts()
+	if assert.NoError(t, err) && assert.Len(t, mounts, 1) {
+		assert.Equal(t, 0, mounts[0].PID)
+		assert.Equal(t, MountStateStale, mounts[0].State)
+	}
+}
+
+func Test_ListMounts_NoMounts(t *testing.T) {
+	p := getPlugin()
+
+	mounts, err := p.ListMounts()
+	assert.NoError(t, err)
+	assert.Empty(t, mounts)
+}
+
+func Test_ListMounts_Tmpfs(t *testing.T) {
+	p := getPlugin()
+	mountPath := useTmpfs(t)
+	readDir = ioutil.ReadDir
+	commandOutput = "... is a mountpoint"
+
+	resp := p.Mount(getMountRequest())
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message) {
+		mounts, err := p.ListMounts()
+		if assert.NoError(t, err) && assert.Len(t, mounts, 1) {
+			assert.Equal(t, testDir, mounts[0].MountDir)
+			assert.Equal(t, testBucket, mounts[0].Bucket)
+		}
+		// the state outlives the tmpfs
+		assert.NoError(t, syscall.Unmount(mountPath, syscall.MNT_DETACH))
+		_, err = os.Stat(mountStateFile(mountPath))
+		assert.NoError(t, err)
+	}
+}
+
+func Test_MountMetrics(t *testing.T) {
+	p := getPlugin()
+	args := []string{testBucket, testDir, "-o", "url=" + testOSEndpoint}
+	defer setMountState(t, &mountState{MountDir: testDir, Command: "s3fs", Args: args})()
+	procRoot = getFakeProcRoot(t)
+	defer os.RemoveAll(procRoot)
+	commandOutput = "... is a mountpoint"
+	dir, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountLogDir = dir
+	defer func() { mountLogDir = "/var/log/ibmc-s3fs/volumes" }()
+	logFile := mountLogFile(testDir, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))))
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("[INF] mounted\n[ERR] HTTP response code 503\n"), 0640))
+	m := &MountMetrics{Plugin: p}
+
+	expected := `
+# HELP ibmc_s3fs_mount_errors_total Errors logged by the FUSE daemon of the volume since the exporter started, for the volumes with log-file enabled
+# TYPE ibmc_s3fs_mount_errors_total counter
+ibmc_s3fs_mount_errors_total{pod_uid="",volume="tmp"} %d
+# HELP ibmc_s3fs_mount_restarts_total FUSE daemons of the volume started again since the exporter started
+# TYPE ibmc_s3fs_mount_restarts_total counter
+ibmc_s3fs_mount_restarts_total{pod_uid="",volume="tmp"} %d
+# HELP ibmc_s3fs_mount_up 1 when the volume is mounted and served by a running FUSE daemon, 0 when it is stale or not mounted
+# TYPE ibmc_s3fs_mount_up gauge
+ibmc_s3fs_mount_up{bucket="` + testBucket + `",mounter="s3fs",pod_uid="",volume="tmp"} %d
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(fmt.Sprintf(expected, 1, 0, 1))))
+
+	// the daemon is started again with another PID and logs errors
+	assert.NoError(t, os.Rename(path.Join(procRoot, "100"), path.Join(procRoot, "200")))
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("[INF] mounted\n[ERR] HTTP response code 503\n[ERR] timeout\n"), 0640))
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(fmt.Sprintf(expected, 2, 1, 1))))
+
+	// the daemon died
+	assert.NoError(t, os.RemoveAll(path.Join(procRoot, "200")))
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(fmt.Sprintf(expected, 2, 1, 0))))
+}
+
+func Test_MountPodUID(t *testing.T) {
+	assert.Equal(t, "0b8f6c1e", mountPodUID("/var/lib/kubelet/pods/0b8f6c1e/volumes/ibm~ibmc-s3fs/pv-1"))
+	assert.Equal(t, "", mountPodUID("/mnt/pv-1"))
+}
+
+func Test_LastLoggedError(t *testing.T) {
+	logFile, err := ioutil.TempFile("", "pv.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(logFile.Name())
+	_, _ = logFile.WriteString("[INF] mounted\n[ERR] curl.cpp:RequestPerform(2418): HTTP response code 403\n[INF] retrying\n")
+	logFile.Close()
+
+	assert.Equal(t, "[ERR] curl.cpp:RequestPerform(2418): HTTP response code 403", lastLoggedError(logFile.Name()))
+	assert.Equal(t, "", lastLoggedError("/nonexistent.log"))
+}
+
+func Test_Mount_SystemdScope_Limits(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMounterCPULimit] = "1500m"
+	r.Opts[optionMounterMemoryLimit] = "512Mi"
+	lookPath = lookPathSuccess
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(testDir)))
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, []string{"--scope", "--collect", "--quiet",
+			"--unit=" + scopeUnitPrefix + mountHash,
+			"--description=IBM COS volume mounter " + mountHash,
+			"--property=CPUQuota=150%",
+			"--property=MemoryMax=536870912",
+			"s3fs", testBucket, testDir}, commandArgs[:10])
+	}
+}
+
+func Test_Mount_BadMounterMemoryLimit(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMounterMemoryLimit] = "-1Gi"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for mounter-memory-limit")
+	}
+}
+
+func Test_Mount_BadMounterCPULimit(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMounterCPULimit] = "fast"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for mounter-cpu-limit")
+	}
+}
+
+func Test_ExtraMountOptions_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionExtraMountOptions] = "nocopyapi,retries=3"
+
+	expectedArgs := []string{
+		testBucket,
+		testDir,
+		"-o", "multireq_max=" + strconv.Itoa(testMultiReqMax),
+		"-o", "use_path_request_style",
+		"-o", "passwd_file=" + path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), passwordFileName),
+		"-o", "url=" + testOSEndpoint,
+		"-o", "endpoint=" + testStorageClass,
+		"-o", "parallel_count=" + strconv.Itoa(testParallelCount),
+		"-o", "multipart_size=" + strconv.Itoa(testChunkSizeMB),
+		"-o", "dbglevel=" + testDebugLevel,
+		"-o", "max_stat_cache_size=" + strconv.Itoa(testStatCacheSize),
+		"-o", "allow_other",
+		"-o", "max_background=1000",
+		"-o", "mp_umask=002",
+		"-o", "instance_name=" + testDir,
+		"-o", "cipher_suites=" + testTLSCipherSuite,
+		"-o", "default_acl=private",
+		"-o", "nocopyapi",
+		"-o", "retries=3",
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, expectedArgs, commandArgs)
+	}
+}
+
+func Test_MountOptions_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMountOptions] = "retries=5,enable_content_md5"
+	r.Opts[optionExtraMountOptions] = "retries=3"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		// the annotation comes last and wins over the mountOptions
+		assert.Equal(t, []string{"-o", "retries=5", "-o", "enable_content_md5", "-o", "retries=3"},
+			commandArgs[len(commandArgs)-6:])
+	}
+}
+
+func Test_MountOptions_Reserved(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionMountOptions] = "passwd_file=/etc/passwd"
+
+	resp := p.Mount(r)
+	assert.Equal(t, interfaces.StatusFailure, resp.Status)
+	assert.Contains(t, resp.Message, "Bad value for mount-options: mount option \"passwd_file\" is set by the driver")
+}
+
+func Test_ParseSources(t *testing.T) {
+	sources, err := ParseSources("logs=bucket-a,images=bucket-b/prefix/2020/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Source{
+			{Name: "logs", Bucket: "bucket-a"},
+			{Name: "images", Bucket: "bucket-b", ObjectPath: "prefix/2020"},
+		}, sources)
+	}
+
+	for _, value := range []string{"bucket-a", "=bucket-a", "../x=bucket-a", "a=", "a=/prefix", "a=bucket-a,a=bucket-b"} {
+		_, err = ParseSources(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_Mount_Sources_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSources] = "logs=bucket-a,images=bucket-b/prefix"
+
+	var mountedBuckets []string
+	command = func(cmd string, args ...string) *exec.Cmd {
+		if cmd == "s3fs" && len(args) > 1 {
+			mountedBuckets = append(mountedBuckets, args[0]+" "+args[1])
+		}
+		return exec.Command("true")
+	}
+	var recorded []byte
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if name == sourcesFile(testDir) {
+			recorded = data
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, []string{
+			"bucket-a " + path.Join(testDir, "logs"),
+			"bucket-b:/prefix " + path.Join(testDir, "images"),
+		}, mountedBuckets)
+		assert.JSONEq(t, `["`+path.Join(testDir, "logs")+`","`+path.Join(testDir, "images")+`"]`, string(recorded))
+	}
+}
+
+func Test_Mount_Sources_Invalid(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSources] = "logs=bucket-a,logs=bucket-b"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for sources: duplicate source name \"logs\"")
+	}
+}
+
+func Test_Mount_Sources_MountError(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSources] = "logs=bucket-a,images=bucket-b"
+
+	var removed []string
+	removeAll = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	command = func(cmd string, args ...string) *exec.Cmd {
+		if cmd == "s3fs" && len(args) > 0 && args[0] == "bucket-b" {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "cannot mount source images")
+		assert.Contains(t, removed, path.Join(testDir, "logs"))
+	}
+}
+
+func Test_Unmount_Sources(t *testing.T) {
+	p := getPlugin()
+	readFile = func(name string) ([]byte, error) {
+		if name == sourcesFile(testDir) {
+			return []byte(`["` + path.Join(testDir, "logs") + `"]`), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	defer func() { readFile = ioutil.ReadFile }()
+	var removed []string
+	removeAll = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+
+	resp := p.Unmount(getUnmountRequest())
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Contains(t, removed, path.Join(testDir, "logs"))
+		assert.Contains(t, removed, sourcesFile(testDir))
+	}
+}
+
+func Test_ClientSideEncryption_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionClientSideEncryption] = "true"
+	r.Opts[optionEncryptionKey] = base64.StdEncoding.EncodeToString([]byte("passphrase"))
+	commandOutput = "obscured-passphrase"
+	defer func() { commandOutput = "" }()
+
+	var rcloneConfig string
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == rcloneConfigFileName {
+			rcloneConfig = string(data)
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, []string{"mount", rcloneRemoteName + ":", testDir}, commandArgs[:3])
+		assert.Contains(t, rcloneConfig, "[crypt]\ntype = crypt\nremote = cos:"+testBucket+"\n")
+		assert.Contains(t, rcloneConfig, "[volume]\ntype = alias\nremote = crypt:\n")
+		assert.Contains(t, rcloneConfig, "password = obscured-passphrase")
+		assert.NotContains(t, rcloneConfig, "= passphrase")
+	}
+}
+
+func Test_ClientSideEncryption_MissingKey(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionClientSideEncryption] = "true"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "client-side-encryption requires the encryption-key key in the volume secret")
+	}
+}
+
+func Test_ClientSideEncryption_ObscureError(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionClientSideEncryption] = "true"
+	r.Opts[optionEncryptionKey] = base64.StdEncoding.EncodeToString([]byte("passphrase"))
+	command = func(cmd string, args ...string) *exec.Cmd {
+		if cmd == "rclone" && args[0] == "obscure" {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "cannot obscure encryption key")
+	}
+}
+
+func Test_Compression_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionCompression] = "true"
+
+	var rcloneConfig string
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == rcloneConfigFileName {
+			rcloneConfig = string(data)
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, []string{"mount", rcloneRemoteName + ":", testDir}, commandArgs[:3])
+		assert.Contains(t, rcloneConfig, "[compress]\ntype = compress\nremote = cos:"+testBucket+"\nmode = gzip\n")
+		assert.Contains(t, rcloneConfig, "[volume]\ntype = alias\nremote = compress:\n")
+	}
+}
+
+func Test_WriteBackConfig_CompressedAndEncrypted(t *testing.T) {
+	cfg := &writeBackConfig{
+		endpoint:      testOSEndpoint,
+		region:        testStorageClass,
+		remotePath:    testBucket,
+		accessKey:     testAccessKey,
+		secretKey:     testSecretKey,
+		cryptPassword: "obscured",
+		compress:      true,
+	}
+
+	conf := cfg.rcloneConfig()
+	assert.Contains(t, conf, "[crypt]\ntype = crypt\nremote = cos:"+testBucket+"\n")
+	assert.Contains(t, conf, "[compress]\ntype = compress\nremote = crypt:\n")
+	assert.Contains(t, conf, "[volume]\ntype = alias\nremote = compress:\n")
+}
+
+// lookPathFuse3Only mocks a node providing fuse3 only, with the given binaries installed
+func lookPathFuse3Only(binaries ...string) func(string) (string, error) {
+	return func(file string) (string, error) {
+		for _, binary := range append(binaries, fusermount3Binary) {
+			if file == binary {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+func Test_Mount_Fuse3(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAddMountParam] = "nonempty,big_writes,retries=3"
+	lookPath = lookPathFuse3Only(s3fsBinary, s3fsFuse3Binary)
+
+	var binary string
+	var args []string
+	command = func(cmd string, arg ...string) *exec.Cmd {
+		if len(arg) > 0 && arg[0] != "--version" {
+			binary, args = cmd, arg
+		}
+		return exec.Command("true")
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, s3fsFuse3Binary, binary)
+		assert.NotContains(t, args, "nonempty")
+		assert.NotContains(t, args, "big_writes")
+		assert.Contains(t, args, "retries=3")
+	}
+}
+
+func Test_Mount_Fuse2KeepsOptions(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAddMountParam] = "nonempty"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Contains(t, commandArgs, "nonempty")
+	}
+}
+
+func Test_Preflight_Fuse3WithoutFuse3Build(t *testing.T) {
+	getPlugin()
+	lookPath = lookPathFuse3Only(s3fsBinary)
+
+	err := Preflight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "node only provides fuse3 and s3fs-fuse3 binary not found")
+	}
+}
+
+func getInstanceIdentityMountRequest() interfaces.FlexVolumeMountRequest {
+	r := getMountRequest()
+	delete(r.Opts, "kubernetes.io/secret/access-key")
+	delete(r.Opts, "kubernetes.io/secret/secret-key")
+	r.Opts[optionAuthMode] = AuthModeInstanceIdentity
+	r.Opts[optionTrustedProfileID] = "Profile-9fd84246"
+	return r
+}
+
+func Test_Mount_InstanceIdentity_Positive(t *testing.T) {
+	p := getPlugin()
+	r := getInstanceIdentityMountRequest()
+	var password string
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == passwordFileName {
+			password = string(data)
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, ":Profile-9fd84246", password)
+		assert.Contains(t, commandArgs, "ibm_iam_auth")
+		assert.Contains(t, commandArgs, "ibm_iam_endpoint=http://"+tokenServerAddress)
+		creds := p.Backend.(*fake.ObjectStorageSessionFactory).LastCredentials
+		assert.Equal(t, "Profile-9fd84246", creds.APIKey)
+		assert.Equal(t, "http://"+tokenServerAddress, creds.IAMEndpoint)
+	}
+}
+
+func Test_Mount_InstanceIdentity_MissingProfile(t *testing.T) {
+	p := getPlugin()
+	r := getInstanceIdentityMountRequest()
+	delete(r.Opts, optionTrustedProfileID)
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "trusted-profile-id is required with auth-mode instance-identity")
+	}
+}
+
+func Test_Mount_InstanceIdentity_WriteBackCache(t *testing.T) {
+	p := getPlugin()
+	r := getInstanceIdentityMountRequest()
+	r.Opts[optionWriteBackCache] = "true"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "auth-mode instance-identity cannot be used with write-back-cache")
+	}
+}
+
+func Test_Mount_BadAuthMode(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAuthMode] = "metadata"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Contains(t, resp.Message, "Bad value for auth-mode \"metadata\"")
+	}
+}
+
+func Test_Mount_ErrorCode_BadOptions(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionS3FSFUSERetryCount] = "many"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeBadOptions, resp.Code)
+	}
+}
+
+func Test_Mount_ErrorCode_MountFailed(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	command = func(cmd string, args ...string) *exec.Cmd {
+		return exec.Command("false")
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeMountFailed, resp.Code)
+	}
+}
+
+func Test_Mount_ErrorCode_Sources(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionSources] = "logs=bucket-a"
+	r.Opts[optionS3FSFUSERetryCount] = "0"
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeBadOptions, resp.Code)
+	}
+}
+
+func Test_Unmount_ErrorCode(t *testing.T) {
+	p := getPlugin()
+	commandOutput = "... is a mountpoint"
+	defer func() { commandOutput = "" }()
+	unmount = unmountError
+
+	resp := p.Unmount(getUnmountRequest())
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeUnmountFailed, resp.Code)
+	}
+}
+
+func Test_BackendErrorCode(t *testing.T) {
+	assert.Equal(t, interfaces.ErrorCodeAuthFailure, backendErrorCode(errors.New("AccessKey/SecretKey is wrong")))
+	assert.Equal(t, interfaces.ErrorCodeAuthFailure,
+		backendErrorCode(&smithy.GenericAPIError{Code: "InvalidAccessKeyId"}))
+	assert.Equal(t, interfaces.ErrorCodeAuthFailure,
+		backendErrorCode(&smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 403}},
+			Err: errors.New("Forbidden")}))
+	assert.Equal(t, interfaces.ErrorCodeNotFound,
+		backendErrorCode(&smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 404}},
+			Err: errors.New("NotFound")}))
+	assert.Equal(t, interfaces.ErrorCodeNetworkFailure,
+		backendErrorCode(&smithyhttp.RequestSendError{Err: errors.New("connection reset")}))
+	assert.Equal(t, interfaces.ErrorCodeNetworkFailure,
+		backendErrorCode(fmt.Errorf("cannot access bucket: %w", context.DeadlineExceeded)))
+	assert.Equal(t, interfaces.ErrorCodeNetworkFailure,
+		backendErrorCode(fmt.Errorf("cannot access bucket: %w", backend.ErrEndpointUnavailable)))
+	assert.Equal(t, interfaces.ErrorCodeNetworkFailure,
+		backendErrorCode(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, interfaces.ErrorCodeMountFailed, backendErrorCode(errors.New("")))
+}
+
+// writeForeignBinary writes an executable the kernel cannot run, like a binary built for another architecture
+func writeForeignBinary(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "foreign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := path.Join(dir, "s3fs")
+	if err := ioutil.WriteFile(binary, []byte{0x7f, 'E', 'L', 'F', 0, 0, 0, 0}, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return binary
+}
+
+func Test_Mount_SavesRemountRecord(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[PVNameOpt] = "pv1"
+	var record []byte
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if name == volumeFile(testDir, remountFileSuffix) {
+			record = data
+		}
+		return nil
+	}
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Contains(t, string(record), `"kubernetes.io/pvOrVolumeName":"pv1"`)
+		assert.Contains(t, string(record), `"bucket":"`+testBucket+`"`)
+		assert.NotContains(t, string(record), "kubernetes.io/secret/")
+	}
+}
+
+// setRemountRecord makes the driver find a single remount record, written during another boot
+func setRemountRecord(t *testing.T, record *remountRecord) func() {
+	dir, err := ioutil.TempDir("", "ibmc-s3fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := volumeFile(record.MountDir, remountFileSuffix)
+	if err := ioutil.WriteFile(path.Join(dir, path.Base(file)), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	readDir = func(string) ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }
+	readFile = func(name string) ([]byte, error) {
+		switch name {
+		case bootIDFile:
+			return []byte("new-boot\n"), nil
+		case file:
+			return json.Marshal(record)
+		}
+		return nil, os.ErrNotExist
+	}
+	return func() {
+		readFile = ioutil.ReadFile
+		os.RemoveAll(dir)
+	}
+}
+
+func getRemountRecord() *remountRecord {
+	r := getMountRequest()
+	opts := map[string]string{PVNameOpt: "pv1"}
+	for key, value := range r.Opts {
+		if !strings.HasPrefix(key, secretOptPrefix) {
+			opts[key] = value
+		}
+	}
+	return &remountRecord{MountDir: testDir, Opts: opts, BootID: "old-boot"}
+}
+
+func Test_RemountAfterReboot_Positive(t *testing.T) {
+	p := getPlugin()
+	defer setRemountRecord(t, getRemountRecord())()
+	commandOutput = testDir + " is not a mountpoint"
+	defer func() { commandOutput = "" }()
+	var passwd, record []byte
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		switch path.Base(name) {
+		case passwordFileName:
+			passwd = data
+		case path.Base(volumeFile(testDir, remountFileSuffix)):
+			record = data
+		}
+		return nil
+	}
+	var pvName string
+
+	p.RemountAfterReboot(func(name string) (map[string][]byte, error) {
+		pvName = name
+		return map[string][]byte{"access-key": []byte(testAccessKey), "secret-key": []byte(testSecretKey)}, nil
+	})
+	assert.Equal(t, "pv1", pvName)
+	assert.Equal(t, testAccessKey+":"+testSecretKey, string(passwd))
+	assert.Contains(t, commandArgs, testBucket)
+	assert.Contains(t, string(record), `"bootID":"new-boot"`)
+}
+
+func Test_RemountAfterReboot_AlreadyMounted(t *testing.T) {
+	p := getPlugin()
+	defer setRemountRecord(t, getRemountRecord())()
+	commandOutput = testDir + " is a mountpoint"
+	defer func() { commandOutput = "" }()
+	called := false
+
+	p.RemountAfterReboot(func(string) (map[string][]byte, error) {
+		called = true
+		return nil, nil
+	})
+	assert.False(t, called)
+	assert.Equal(t, []string{testDir}, commandArgs)
+}
+
+func Test_RemountAfterReboot_SameBoot(t *testing.T) {
+	p := getPlugin()
+	record := getRemountRecord()
+	record.BootID = "new-boot"
+	defer setRemountRecord(t, record)()
+	called := false
+
+	p.RemountAfterReboot(func(string) (map[string][]byte, error) {
+		called = true
+		return nil, nil
+	})
+	assert.False(t, called)
+	assert.Nil(t, commandArgs)
+}
+
+func Test_RemountAfterReboot_TargetGone(t *testing.T) {
+	p := getPlugin()
+	record := getRemountRecord()
+	record.MountDir = "/nonexistent/pod/volume"
+	defer setRemountRecord(t, record)()
+	var removed []string
+	removeAll = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+
+	p.RemountAfterReboot(func(string) (map[string][]byte, error) {
+		t.Fatal("secret read for a deleted volume")
+		return nil, nil
+	})
+	assert.Equal(t, []string{volumeFile(record.MountDir, remountFileSuffix)}, removed)
+}
+
+func Test_RemountAfterReboot_SecretError(t *testing.T) {
+	p := getPlugin()
+	defer setRemountRecord(t, getRemountRecord())()
+	commandOutput = testDir + " is not a mountpoint"
+	defer func() { commandOutput = "" }()
+
+	p.RemountAfterReboot(func(string) (map[string][]byte, error) {
+		return nil, errors.New("forbidden")
+	})
+	assert.Equal(t, []string{testDir}, commandArgs)
+}
+
+func Test_Mount_RemountedThisBoot(t *testing.T) {
+	p := getPlugin()
+	record := getRemountRecord()
+	record.BootID = "new-boot"
+	defer setRemountRecord(t, record)()
+	commandOutput = testDir + " is a mountpoint"
+	defer func() { commandOutput = "" }()
+
+	resp := p.Mount(getMountRequest())
+	assert.Equal(t, interfaces.StatusSuccess, resp.Status)
+	assert.Equal(t, []string{testDir}, commandArgs)
+}
+
+// captureStatsConfig records the volume stats configuration written by a mount
+func captureStatsConfig(config *statsConfig) {
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		if path.Base(name) == statsConfigFileName {
+			return json.Unmarshal(data, config)
+		}
+		return nil
+	}
+}
+
+func Test_Mount_StatsConfig_APIKey(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionAPIKey] = base64.StdEncoding.EncodeToString([]byte(testAPIKey))
+	var config statsConfig
+	captureStatsConfig(&config)
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, statsConfig{APIKey: testAPIKey, Bucket: testBucket, OSEndpoint: testOSEndpoint, IAMEndpoint: testIAMEndpoint}, config)
+	}
+}
+
+func Test_Mount_StatsConfig_ResConfAPIKey(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionResConfAPIKey] = base64.StdEncoding.EncodeToString([]byte("res-conf-key"))
+	var config statsConfig
+	captureStatsConfig(&config)
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, "res-conf-key", config.APIKey)
+		assert.Equal(t, testIAMEndpoint, config.IAMEndpoint)
+	}
+}
+
+func Test_Mount_StatsConfig_HMAC(t *testing.T) {
+	p := getPlugin()
+	r := getMountRequest()
+	r.Opts[optionObjectPath] = testObjectPath
+	var config statsConfig
+	captureStatsConfig(&config)
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, statsConfig{Bucket: testBucket, OSEndpoint: testOSEndpoint, Region: testStorageClass, ObjectPath: testObjectPath}, config)
+	}
+}
+
+// setStatsConfig makes the driver find the given volume stats configuration for testDir
+func setStatsConfig(config *statsConfig) func() {
+	readFile = func(name string) ([]byte, error) {
+		if name != path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))), statsConfigFileName) {
+			return nil, os.ErrNotExist
+		}
+		return json.Marshal(config)
+	}
+	return func() { readFile = ioutil.ReadFile }
+}
+
+func Test_GetVolumeStats_Positive(t *testing.T) {
+	p := getPlugin()
+	accessPolicy := &fake.FakeAccessPolicyFactory{
+		BucketUsage: backend.BucketUsage{BytesUsed: 300, ObjectCount: 4, HardQuota: 1000},
+	}
+	p.AccessPolicy = accessPolicy
+	defer setStatsConfig(&statsConfig{APIKey: testAPIKey, Bucket: testBucket, OSEndpoint: testOSEndpoint, IAMEndpoint: testIAMEndpoint})()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, &interfaces.VolumeStats{CapacityBytes: 1000, UsedBytes: 300, AvailableBytes: 700, InodesUsed: 4}, resp.VolumeStats)
+		assert.Equal(t, testAPIKey, accessPolicy.LastUsageAPIKey)
+	}
+}
+
+func Test_GetVolumeStats_NoQuota(t *testing.T) {
+	p := getPlugin()
+	p.AccessPolicy = &fake.FakeAccessPolicyFactory{
+		BucketUsage: backend.BucketUsage{BytesUsed: 300, ObjectCount: 4},
+	}
+	defer setStatsConfig(&statsConfig{APIKey: testAPIKey, Bucket: testBucket})()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, &interfaces.VolumeStats{UsedBytes: 300, InodesUsed: 4}, resp.VolumeStats)
+	}
+}
+
+func Test_GetVolumeStats_HMAC(t *testing.T) {
+	p := getPlugin()
+	factory := &fake.ObjectStorageSessionFactory{BucketUsage: backend.BucketUsage{BytesUsed: 300, ObjectCount: 4}}
+	p.Backend = factory
+	config, _ := json.Marshal(&statsConfig{Bucket: testBucket, OSEndpoint: testOSEndpoint, Region: testStorageClass, ObjectPath: testObjectPath})
+	mountPath := path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(testDir))))
+	readFile = func(name string) ([]byte, error) {
+		switch name {
+		case path.Join(mountPath, statsConfigFileName):
+			return config, nil
+		case path.Join(mountPath, passwordFileName):
+			return []byte(testAccessKey + ":" + testSecretKey), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	defer func() { readFile = ioutil.ReadFile }()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status) {
+		assert.Equal(t, &interfaces.VolumeStats{UsedBytes: 300, InodesUsed: 4}, resp.VolumeStats)
+		assert.Equal(t, testObjectPath, factory.LastUsagePrefix)
+		assert.Equal(t, testStorageClass, factory.LastRegion)
+		assert.Equal(t, &backend.ObjectStorageCredentials{AccessKey: testAccessKey, SecretKey: testSecretKey}, factory.LastCredentials)
+	}
+}
+
+func Test_GetVolumeStats_NotMounted(t *testing.T) {
+	p := getPlugin()
+	readFile = func(string) ([]byte, error) { return nil, os.ErrNotExist }
+	defer func() { readFile = ioutil.ReadFile }()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeNotFound, resp.Code)
+		assert.Contains(t, resp.Message, "volume not mounted")
+	}
+}
+
+func Test_GetVolumeStats_EmptyConfig(t *testing.T) {
+	p := getPlugin()
+	readFile = func(string) ([]byte, error) { return []byte{}, nil }
+	defer func() { readFile = ioutil.ReadFile }()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeNotFound, resp.Code)
+		assert.Contains(t, resp.Message, "cannot read volume stats configuration")
+	}
+}
+
+func Test_GetVolumeStats_Tmpfs(t *testing.T) {
+	p := getPlugin()
+	useTmpfs(t)
+	p.AccessPolicy = &fake.FakeAccessPolicyFactory{BucketUsage: backend.BucketUsage{BytesUsed: 300, ObjectCount: 4}}
+	r := getMountRequest()
+	r.Opts[optionAPIKey] = base64.StdEncoding.EncodeToString([]byte(testAPIKey))
+
+	resp := p.Mount(r)
+	if assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message) {
+		resp = p.GetVolumeStats(testDir)
+		if assert.Equal(t, interfaces.StatusSuccess, resp.Status, resp.Message) {
+			assert.Equal(t, &interfaces.VolumeStats{UsedBytes: 300, InodesUsed: 4}, resp.VolumeStats)
+		}
+	}
+}
+
+func Test_GetVolumeStats_BackendError(t *testing.T) {
+	p := getPlugin()
+	p.AccessPolicy = &fake.FakeAccessPolicyFactory{FailGetBucketUsage: true}
+	defer setStatsConfig(&statsConfig{APIKey: testAPIKey, Bucket: testBucket})()
+
+	resp := p.GetVolumeStats(testDir)
+	if assert.Equal(t, interfaces.StatusFailure, resp.Status) {
+		assert.Equal(t, interfaces.ErrorCodeStatsFailed, resp.Code)
+		assert.Contains(t, resp.Message, "cannot get usage of bucket "+testBucket)
+	}
+}
+
+func getMountTestNames(steps []MountTestStep) (passed, failed, skipped []string) {
+	for _, step := range steps {
+		switch {
+		case step.Skipped:
+			skipped = append(skipped, step.Name)
+		case step.Err != nil:
+			failed = append(failed, step.Name)
+		default:
+			passed = append(passed, step.Name)
+		}
+	}
+	return passed, failed, skipped
+}
+
+func Test_MountTest_Positive(t *testing.T) {
+	p := getPlugin()
+	dir, err := ioutil.TempDir("", "mount-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	steps := p.MountTest(dir, func() (map[string]string, error) { return getMountRequest().Opts, nil })
+	passed, failed, skipped := getMountTestNames(steps)
+	assert.Equal(t, []string{MountTestCredentials, MountTestMount, MountTestWrite, MountTestRead,
+		MountTestList, MountTestDelete, MountTestUnmount}, passed)
+	assert.Empty(t, failed)
+	assert.Empty(t, skipped)
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func Test_MountTest_CredentialsFailure(t *testing.T) {
+	p := getPlugin()
+	steps := p.MountTest(testDir, func() (map[string]string, error) { return nil, errors.New("secret not found") })
+	passed, failed, skipped := getMountTestNames(steps)
+	assert.Empty(t, passed)
+	assert.Equal(t, []string{MountTestCredentials}, failed)
+	assert.Len(t, skipped, 6)
+}
+
+func Test_MountTest_MountFailure(t *testing.T) {
+	p := getPlugin()
+	steps := p.MountTest(testDir, func() (map[string]string, error) {
+		opts := getMountRequest().Opts
+		opts[optionConnectTimeoutSeconds] = "non-int-value"
+		return opts, nil
+	})
+	passed, failed, skipped := getMountTestNames(steps)
+	assert.Equal(t, []string{MountTestCredentials}, passed)
+	assert.Equal(t, []string{MountTestMount}, failed)
+	assert.Equal(t, []string{MountTestWrite, MountTestRead, MountTestList, MountTestDelete, MountTestUnmount}, skipped)
+}
+
+func Test_MountTest_WriteFailure(t *testing.T) {
+	p := getPlugin()
+	steps := p.MountTest("/nonexistent/mount-test", func() (map[string]string, error) { return getMountRequest().Opts, nil })
+	passed, failed, skipped := getMountTestNames(steps)
+	assert.Contains(t, failed, MountTestWrite)
+	assert.Equal(t, []string{MountTestRead, MountTestList, MountTestDelete}, skipped)
+	assert.Contains(t, append(passed, failed...), MountTestUnmount)
+}
+
+func Test_WithSecretOpts(t *testing.T) {
+	opts := map[string]string{"bucket": testBucket}
+	merged := WithSecretOpts(opts, map[string][]byte{"api-key": []byte(testAPIKey)})
+	assert.Equal(t, map[string]string{
+		"bucket":                       testBucket,
+		"kubernetes.io/secret/api-key": base64.StdEncoding.EncodeToString([]byte(testAPIKey)),
+	}, merged)
+	assert.Len(t, opts, 1)
+}
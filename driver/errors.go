@@ -0,0 +1,71 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"strings"
+)
+
+// Error is a driver error carrying one of the interfaces.ErrorCode values
+type Error struct {
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// withCode tags err with code, unless it already has one
+func withCode(code string, err error) error {
+	var coded *Error
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// ErrorCode returns the code of err, or fallback when it has none
+func ErrorCode(err error, fallback string) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return fallback
+}
+
+// backendErrorCode tells authentication, connectivity and missing bucket errors of the object storage apart
+func backendErrorCode(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return interfaces.ErrorCodeNetworkFailure
+	}
+	if strings.Contains(err.Error(), "AccessKey/SecretKey is wrong") {
+		return interfaces.ErrorCodeAuthFailure
+	}
+
+	switch backend.ErrorKindOf(err) {
+	case backend.ErrorUnreachable:
+		return interfaces.ErrorCodeNetworkFailure
+	case backend.ErrorAccessDenied:
+		return interfaces.ErrorCodeAuthFailure
+	case backend.ErrorNotFound:
+		return interfaces.ErrorCodeNotFound
+	}
+	return interfaces.ErrorCodeMountFailed
+}
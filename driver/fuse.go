@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	s3fsBinary = "s3fs"
+	// s3fsFuse3Binary is the s3fs build linked against libfuse3, installed next to the libfuse2 one
+	s3fsFuse3Binary   = "s3fs-fuse3"
+	fusermountBinary  = "fusermount"
+	fusermount3Binary = "fusermount3"
+)
+
+// fuse3RemovedOptions are the FUSE mount options libfuse3 no longer accepts
+var fuse3RemovedOptions = map[string]bool{
+	"nonempty":       true,
+	"big_writes":     true,
+	"atomic_o_trunc": true,
+	"large_read":     true,
+}
+
+// fuse3Only tells whether the node only provides fuse3, as on distros that dropped fuse2
+func fuse3Only() bool {
+	if _, err := lookPath(fusermountBinary); err == nil {
+		return false
+	}
+	_, err := lookPath(fusermount3Binary)
+	return err == nil
+}
+
+// s3fsBinaryName returns the s3fs build to run on this node
+func s3fsBinaryName() string {
+	if fuse3Only() {
+		if _, err := lookPath(s3fsFuse3Binary); err == nil {
+			return s3fsFuse3Binary
+		}
+	}
+	return s3fsBinary
+}
+
+// fuseOptions drops from the s3fs arguments the FUSE options the node does not support
+func (p *S3fsPlugin) fuseOptions(args []string) []string {
+	if !fuse3Only() {
+		return args
+	}
+	filtered := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-o" && i+1 < len(args) && fuse3RemovedOptions[strings.SplitN(args[i+1], "=", 2)[0]] {
+			p.Logger.Info(podUID+":"+"Dropping mount option not supported by fuse3",
+				zap.String("option", args[i+1]))
+			i++
+			continue
+		}
+		filtered = append(filtered, args[i])
+	}
+	return filtered
+}
@@ -19,6 +19,31 @@ const (
 	StatusFailure = "Failure"
 	// StatusNotSupported returned when the operation is not supported
 	StatusNotSupported = "Not supported"
+
+	// ResponseVersion is the version of the schema of the driver responses
+	ResponseVersion = "1"
+)
+
+// Error codes of failed responses
+const (
+	// ErrorCodeBadRequest returned when the driver is called with wrong arguments
+	ErrorCodeBadRequest = "BAD_REQUEST"
+	// ErrorCodeBadOptions returned when the volume options are invalid
+	ErrorCodeBadOptions = "BAD_OPTIONS"
+	// ErrorCodeAuthFailure returned when the object storage rejects the credentials
+	ErrorCodeAuthFailure = "AUTH_FAILURE"
+	// ErrorCodeNetworkFailure returned when the object storage cannot be reached
+	ErrorCodeNetworkFailure = "NETWORK_FAILURE"
+	// ErrorCodeNotFound returned when the bucket or object-path does not exist
+	ErrorCodeNotFound = "NOT_FOUND"
+	// ErrorCodeMountFailed returned when the volume cannot be mounted
+	ErrorCodeMountFailed = "MOUNT_FAILED"
+	// ErrorCodeUnmountFailed returned when the volume cannot be unmounted
+	ErrorCodeUnmountFailed = "UNMOUNT_FAILED"
+	// ErrorCodeStatsFailed returned when the usage of the volume cannot be retrieved
+	ErrorCodeStatsFailed = "STATS_FAILED"
+	// ErrorCodeUnsupportedOS returned when the driver is called on a node it cannot mount volumes on
+	ErrorCodeUnsupportedOS = "UNSUPPORTED_OS"
 )
 
 // FlexPlugin is a partial interface of the flexvolume volume plugin
@@ -39,6 +64,8 @@ type CapabilitiesResponse struct {
 	// Attach value is True/False (depending if the driver implements attach and detach)
 	Attach  bool `json:"attach"`
 	FSGroup bool `json:"fsGroup"`
+	// SELinuxRelabel value is True/False (depending if kubelet may relabel the mounted volume)
+	SELinuxRelabel bool `json:"selinuxRelabel"`
 }
 
 // FlexVolumeResponse represents a response of the volume plugin
@@ -47,8 +74,25 @@ type FlexVolumeResponse struct {
 	Status string `json:"status"`
 	// Reason for success or failure.
 	Message string `json:"message,omitempty"`
+	// Version of the response schema
+	Version string `json:"version,omitempty"`
+	// Code is one of the ErrorCode values when Status is "Failure"
+	Code string `json:"code,omitempty"`
 	// Capabilities used in Init responses
 	Capabilities CapabilitiesResponse `json:"capabilities,omitempty"`
+	// VolumeStats used in getvolumestats responses
+	VolumeStats *VolumeStats `json:"volumeStats,omitempty"`
+}
+
+// VolumeStats represents the storage usage of a volume
+type VolumeStats struct {
+	// CapacityBytes is the bucket quota, 0 when the bucket has none
+	CapacityBytes int64 `json:"capacityBytes"`
+	UsedBytes     int64 `json:"usedBytes"`
+	// AvailableBytes is what is left of the quota, 0 when the bucket has none
+	AvailableBytes int64 `json:"availableBytes"`
+	// InodesUsed is the number of objects in the bucket
+	InodesUsed int64 `json:"inodesUsed"`
 }
 
 // FlexVolumeMountRequest represents a mount request from the volume plugin
@@ -0,0 +1,153 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+var (
+	mountUpDesc = prometheus.NewDesc("ibmc_s3fs_mount_up",
+		"1 when the volume is mounted and served by a running FUSE daemon, 0 when it is stale or not mounted",
+		[]string{"volume", "pod_uid", "bucket", "mounter"}, nil)
+	mountRestartsDesc = prometheus.NewDesc("ibmc_s3fs_mount_restarts_total",
+		"FUSE daemons of the volume started again since the exporter started",
+		[]string{"volume", "pod_uid"}, nil)
+	mountErrorsDesc = prometheus.NewDesc("ibmc_s3fs_mount_errors_total",
+		"Errors logged by the FUSE daemon of the volume since the exporter started, for the volumes with log-file enabled",
+		[]string{"volume", "pod_uid"}, nil)
+)
+
+// MountMetrics is a Prometheus collector of the health of the volumes mounted on the node, read from
+// ListMounts and the volume log files at each scrape
+type MountMetrics struct {
+	Plugin *S3fsPlugin
+
+	mutex sync.Mutex
+	// pids are the last FUSE daemons seen, by mount directory
+	pids     map[string]int
+	restarts map[string]float64
+	// offsets are how much of the log files of the mounts is counted already
+	offsets map[string]int64
+	errors  map[string]float64
+}
+
+// Describe sends the descriptions of the metrics
+func (m *MountMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mountUpDesc
+	ch <- mountRestartsDesc
+	ch <- mountErrorsDesc
+}
+
+// Collect sends the metrics of the volumes mounted on the node
+func (m *MountMetrics) Collect(ch chan<- prometheus.Metric) {
+	mounts, err := m.Plugin.ListMounts()
+	if err != nil {
+		m.Plugin.Logger.Error("Cannot list the mounts of the node", zap.Error(err))
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.pids == nil {
+		m.pids, m.restarts = map[string]int{}, map[string]float64{}
+		m.offsets, m.errors = map[string]int64{}, map[string]float64{}
+	}
+	seen := map[string]bool{}
+	for _, mount := range mounts {
+		seen[mount.MountDir] = true
+		volume, podUID := path.Base(mount.MountDir), mountPodUID(mount.MountDir)
+		up := 0.0
+		if mount.State == MountStateMounted {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(mountUpDesc, prometheus.GaugeValue, up, volume, podUID, mount.Bucket, mount.Mounter)
+
+		if mount.PID != 0 {
+			if previous, ok := m.pids[mount.MountDir]; ok && previous != mount.PID {
+				m.restarts[mount.MountDir]++
+			}
+			m.pids[mount.MountDir] = mount.PID
+		}
+		ch <- prometheus.MustNewConstMetric(mountRestartsDesc, prometheus.CounterValue, m.restarts[mount.MountDir], volume, podUID)
+
+		m.countErrors(mount.MountDir)
+		ch <- prometheus.MustNewConstMetric(mountErrorsDesc, prometheus.CounterValue, m.errors[mount.MountDir], volume, podUID)
+	}
+	// the unmounted volumes are dropped, a volume mounted again starts from zero
+	for mountDir := range m.pids {
+		if !seen[mountDir] {
+			delete(m.pids, mountDir)
+			delete(m.restarts, mountDir)
+		}
+	}
+	for mountDir := range m.offsets {
+		if !seen[mountDir] {
+			delete(m.offsets, mountDir)
+			delete(m.errors, mountDir)
+		}
+	}
+}
+
+// countErrors adds the error lines appended to the log file of a mount since the previous call
+func (m *MountMetrics) countErrors(mountDir string) {
+	file, err := os.Open(mountLogFile(mountDir, fmt.Sprintf("%x", sha256.Sum256([]byte(mountDir)))))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	offset := m.offsets[mountDir]
+	if info.Size() < offset {
+		// the file was rotated
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// keep incomplete lines for the next call
+			break
+		}
+		offset += int64(len(line))
+		if isErrorLine(line) {
+			m.errors[mountDir]++
+		}
+	}
+	m.offsets[mountDir] = offset
+}
+
+// mountPodUID returns the UID of the pod of a kubelet mount directory, /var/lib/kubelet/pods/<uid>/volumes/...
+func mountPodUID(mountDir string) string {
+	parts := strings.Split(mountDir, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "pods" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
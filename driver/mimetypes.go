@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"os"
+	"path"
+)
+
+const (
+	// systemMimeTypesFile is the mime.types file s3fs reads when present on the node
+	systemMimeTypesFile = "/etc/mime.types"
+	mimeTypesFileName   = "mime.types"
+)
+
+// defaultMimeTypes is used when the node has no mime.types file of its own
+const defaultMimeTypes = `application/gzip				gz
+application/javascript				js mjs
+application/json				json
+application/octet-stream			bin
+application/pdf					pdf
+application/xml					xml
+application/zip					zip
+audio/mpeg					mp3
+font/woff					woff
+font/woff2					woff2
+image/gif					gif
+image/jpeg					jpeg jpg
+image/png					png
+image/svg+xml					svg svgz
+image/webp					webp
+text/css					css
+text/csv					csv
+text/html					html htm
+text/markdown					md
+text/plain					txt log
+video/mp4					mp4
+video/webm					webm
+`
+
+// mimeTypesFile returns the mime.types file s3fs should use to set the Content-Type of uploaded objects,
+// writing the bundled list under dataRootPath if the node does not provide one. The file holds no secret,
+// it is shared by the volumes and kept out of their tmpfs.
+func (p *S3fsPlugin) mimeTypesFile() (string, error) {
+	_, err := stat(systemMimeTypesFile)
+	if err == nil {
+		return systemMimeTypesFile, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	mimeFile := path.Join(dataRootPath, mimeTypesFileName)
+	if content, err := readFile(mimeFile); err == nil && string(content) == defaultMimeTypes {
+		return mimeFile, nil
+	}
+	err = writeFile(mimeFile, []byte(defaultMimeTypes), 0644)
+	if err != nil {
+		return "", err
+	}
+	return mimeFile, nil
+}
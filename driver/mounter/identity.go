@@ -0,0 +1,175 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package mounter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenAddress is where the mounter serves the IAM tokens of the node instance identity
+	DefaultTokenAddress = "127.0.0.1:8219"
+	// DefaultMetadataEndpoint is the VPC instance metadata service
+	DefaultMetadataEndpoint = "http://169.254.169.254"
+	// TokenURLPath is the path of the IAM token API, appended by s3fs and the COS SDK to the IAM endpoint
+	TokenURLPath       = "/identity/token"
+	metadataAPIVersion = "2022-03-01"
+	apiKeyGrantType    = "urn:ibm:params:oauth:grant-type:apikey"
+	// tokenRefreshMargin is how long before expiry a cached token is replaced
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+var trustedProfileIDRegexp = regexp.MustCompile(`^Profile-[0-9a-fA-F-]+$`)
+
+// TokenServer answers IAM API key token requests with IAM tokens of a trusted profile,
+// obtained from the VPC instance metadata service. The "apikey" of the request holds the
+// trusted profile ID, so that s3fs and the COS SDK use the server like the IAM endpoint and
+// no long-lived credentials need to be on the node.
+type TokenServer struct {
+	Logger           *zap.Logger
+	MetadataEndpoint string
+
+	httpClient *http.Client
+	mutex      sync.Mutex
+	tokens     map[string]*iamToken
+}
+
+type iamToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	expiration  time.Time
+}
+
+// NewTokenServer returns a token server using the given instance metadata service
+func NewTokenServer(logger *zap.Logger, metadataEndpoint string) *TokenServer {
+	return &TokenServer{
+		Logger:           logger,
+		MetadataEndpoint: metadataEndpoint,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		tokens:           map[string]*iamToken{},
+	}
+}
+
+// ServeHTTP handles IAM token requests
+func (s *TokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != TokenURLPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != apiKeyGrantType {
+		http.Error(w, "unsupported grant type", http.StatusBadRequest)
+		return
+	}
+	profileID := r.PostForm.Get("apikey")
+	if !trustedProfileIDRegexp.MatchString(profileID) {
+		http.Error(w, "apikey must be a trusted profile ID", http.StatusBadRequest)
+		return
+	}
+
+	token, err := s.token(profileID)
+	if err != nil {
+		s.Logger.Error("Cannot get IAM token from instance identity",
+			zap.String("profile", profileID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  token.AccessToken,
+		"refresh_token": "not_supported",
+		"token_type":    "Bearer",
+		"expires_in":    int64(time.Until(token.expiration).Seconds()),
+		"expiration":    token.expiration.Unix(),
+	})
+	if err != nil {
+		s.Logger.Error("Cannot encode token response", zap.Error(err))
+	}
+}
+
+// token returns a cached IAM token of the trusted profile, or a new one when it is about to expire
+func (s *TokenServer) token(profileID string) (*iamToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if token, ok := s.tokens[profileID]; ok && time.Until(token.expiration) > tokenRefreshMargin {
+		return token, nil
+	}
+
+	identity, err := s.metadataRequest(http.MethodPut, "/instance_identity/v1/token",
+		map[string]string{"Metadata-Flavor": "ibm"},
+		map[string]interface{}{"expires_in": 300})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get instance identity token: %v", err)
+	}
+	token, err := s.metadataRequest(http.MethodPost, "/instance_identity/v1/iam_token",
+		map[string]string{"Authorization": "Bearer " + identity.AccessToken},
+		map[string]interface{}{"trusted_profile": map[string]string{"id": profileID}})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get IAM token: %v", err)
+	}
+
+	token.expiration = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	s.tokens[profileID] = token
+	return token, nil
+}
+
+func (s *TokenServer) metadataRequest(method, urlPath string, headers map[string]string, body interface{}) (*iamToken, error) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, s.MetadataEndpoint+urlPath+"?version="+metadataAPIVersion, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg.Bytes()))
+	}
+
+	var token iamToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("cannot decode response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("response has no access token")
+	}
+	return &token, nil
+}
+
+// ListenAndServe serves token requests on the given local address until it fails
+func (s *TokenServer) ListenAndServe(address string) error {
+	s.Logger.Info("Token server listening", zap.String("address", address))
+	return http.ListenAndServe(address, s)
+}
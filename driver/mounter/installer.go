@@ -0,0 +1,126 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package mounter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+)
+
+const (
+	// DefaultPluginDir is where kubelet looks for FlexVolume drivers
+	DefaultPluginDir = "/usr/libexec/kubernetes/kubelet-plugins/volume/exec"
+	// DriverDirName is the directory of the driver in the FlexVolume plugin directory, <vendor>~<driver>
+	DriverDirName = "ibm~ibmc-s3fs"
+	// DriverBinaryName is the executable kubelet runs in the driver directory
+	DriverBinaryName = "ibmc-s3fs"
+)
+
+// InstalledFile is an executable the installer keeps in place on the node
+type InstalledFile struct {
+	// Source is the reference copy shipped in the image
+	Source string
+	// Target is where the file is installed on the node
+	Target string
+}
+
+// Installer keeps the FlexVolume driver installed on the node. Node image updates may
+// wipe the kubelet plugin directory or replace the s3fs binary, after which every mount fails.
+type Installer struct {
+	Logger *zap.Logger
+	Files  []InstalledFile
+}
+
+// Repair installs again the files that are missing or differ from their source,
+// and returns the targets it installed
+func (i *Installer) Repair() ([]string, error) {
+	var repaired []string
+	for _, file := range i.Files {
+		same, err := sameContent(file.Source, file.Target)
+		if err != nil {
+			return repaired, err
+		}
+		if same {
+			continue
+		}
+		i.Logger.Info("Installing driver file", zap.String("source", file.Source), zap.String("target", file.Target))
+		if err := install(file.Source, file.Target); err != nil {
+			return repaired, fmt.Errorf("cannot install %s: %v", file.Target, err)
+		}
+		repaired = append(repaired, file.Target)
+	}
+	return repaired, nil
+}
+
+// sameContent tells whether the target exists with the content of the source
+func sameContent(source, target string) (bool, error) {
+	sourceSum, err := fileSum(source)
+	if err != nil {
+		return false, fmt.Errorf("cannot read %s: %v", source, err)
+	}
+	targetSum, err := fileSum(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot read %s: %v", target, err)
+	}
+	return bytes.Equal(sourceSum, targetSum), nil
+}
+
+func fileSum(name string) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// install copies source to target through a temporary file, so that kubelet never runs a partial binary
+func install(source, target string) error {
+	if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := target + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0755)
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package mounter lets the FlexVolume driver start its FUSE daemons inside a node-level
+// mounter pod instead of the kubelet context, so that resource limits, upgrades and crash
+// isolation apply to them like to any other workload.
+package mounter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// DefaultSocketPath is where the mounter pod listens, shared with the host through a hostPath volume
+	DefaultSocketPath = "/var/lib/ibmc-s3fs/mounter.sock"
+	mountURLPath      = "/mount"
+	healthURLPath     = "/healthz"
+	requestTimeout    = 5 * time.Minute
+)
+
+// allowedCommands are the only binaries the mounter agrees to run
+var allowedCommands = map[string]bool{
+	"s3fs":       true,
+	"s3fs-fuse3": true,
+	"rclone":     true,
+}
+
+var command = exec.Command
+
+// Request asks the mounter to run a FUSE daemon
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Env holds additional KEY=value environment variables for the command
+	Env []string `json:"env,omitempty"`
+}
+
+// Response holds the combined output of the command and its error, if any
+type Response struct {
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Server runs the mount requests received from the driver
+type Server struct {
+	Logger *zap.Logger
+}
+
+// ServeHTTP handles mount and health requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case healthURLPath:
+		w.WriteHeader(http.StatusOK)
+	case mountURLPath:
+		s.mount(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) mount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !allowedCommands[req.Command] {
+		http.Error(w, fmt.Sprintf("command %q is not allowed", req.Command), http.StatusForbidden)
+		return
+	}
+
+	s.Logger.Info("Running mount command",
+		zap.String("command", req.Command), zap.Reflect("args", req.Args))
+
+	cmd := command(req.Command, req.Args...)
+	cmd.Env = append(os.Environ(), req.Env...)
+	out, err := cmd.CombinedOutput()
+
+	resp := Response{Output: string(out)}
+	if err != nil {
+		s.Logger.Error("Mount command failed",
+			zap.String("command", req.Command), zap.String("Error", string(out)))
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		s.Logger.Error("Cannot encode response", zap.Error(err))
+	}
+}
+
+// ListenAndServe serves mount requests on the given unix socket until it fails
+func (s *Server) ListenAndServe(socketPath string) error {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove stale socket %s: %v", socketPath, err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("cannot restrict permissions of %s: %v", socketPath, err)
+	}
+	s.Logger.Info("Mounter listening", zap.String("socket", socketPath))
+	return http.Serve(listener, s)
+}
+
+// Client sends mount requests to the mounter pod of the node
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient returns a client talking to the mounter listening on socketPath
+func NewClient(socketPath string) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Run runs the command in the mounter pod and returns its combined output
+func (c *Client) Run(req *Request) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := c.httpClient.Post("http://mounter"+mountURLPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach mounter: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(httpResp.Body)
+		return nil, fmt.Errorf("mounter rejected request: %s", bytes.TrimSpace(msg.Bytes()))
+	}
+
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("cannot decode mounter response: %v", err)
+	}
+	if resp.Error != "" {
+		return []byte(resp.Output), fmt.Errorf("%s", resp.Error)
+	}
+	return []byte(resp.Output), nil
+}
@@ -0,0 +1,354 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8fake "k8s.io/client-go/kubernetes/fake"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+var commandName string
+
+func startServer(t *testing.T) (*Client, func()) {
+	commandName = ""
+	command = func(name string, args ...string) *exec.Cmd {
+		commandName = name
+		return exec.Command("echo", args...)
+	}
+
+	dir, err := ioutil.TempDir("", "mounter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := path.Join(dir, "mounter.sock")
+	s := &Server{Logger: zap.NewNop()}
+	go func() { _ = s.ListenAndServe(socketPath) }()
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socketPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return NewClient(socketPath), func() { os.RemoveAll(dir) }
+}
+
+func Test_Run_Positive(t *testing.T) {
+	c, cleanup := startServer(t)
+	defer cleanup()
+
+	out, err := c.Run(&Request{Command: "s3fs", Args: []string{"bucket", "/mnt"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "s3fs", commandName)
+		assert.Equal(t, "bucket /mnt\n", string(out))
+	}
+}
+
+func Test_Run_CommandNotAllowed(t *testing.T) {
+	c, cleanup := startServer(t)
+	defer cleanup()
+
+	_, err := c.Run(&Request{Command: "sh", Args: []string{"-c", "true"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "command \"sh\" is not allowed")
+	}
+	assert.Equal(t, "", commandName)
+}
+
+func Test_Run_CommandFailure(t *testing.T) {
+	c, cleanup := startServer(t)
+	defer cleanup()
+	command = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", "echo mount failed; exit 1")
+	}
+
+	out, err := c.Run(&Request{Command: "s3fs"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "mount failed\n", string(out))
+	}
+}
+
+func Test_Run_NoMounter(t *testing.T) {
+	c := NewClient(path.Join(os.TempDir(), "no-such-mounter.sock"))
+
+	_, err := c.Run(&Request{Command: "s3fs"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot reach mounter")
+	}
+}
+
+func Test_LabelNode(t *testing.T) {
+	client := k8fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+
+	err := LabelNode(context.Background(), client, "node1", "ibm.io/ready", true)
+	if assert.NoError(t, err) {
+		node, _ := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+		assert.Equal(t, "true", node.Labels["ibm.io/ready"])
+	}
+}
+
+func Test_LabelNode_NotFound(t *testing.T) {
+	client := k8fake.NewSimpleClientset()
+
+	err := LabelNode(context.Background(), client, "node1", "ibm.io/ready", false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot label node node1")
+	}
+}
+
+func Test_PVSecretData(t *testing.T) {
+	client := k8fake.NewSimpleClientset(
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{
+					Driver:    "ibm/ibmc-s3fs",
+					SecretRef: &v1.SecretReference{Name: "cos", Namespace: "ns1"},
+				},
+			}},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cos", Namespace: "ns1"},
+			Data:       map[string][]byte{"api-key": []byte("key")},
+		},
+	)
+
+	data, err := PVSecretData(context.Background(), client, "pv1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string][]byte{"api-key": []byte("key")}, data)
+	}
+}
+
+func Test_PVSecretData_NoSecretRef(t *testing.T) {
+	client := k8fake.NewSimpleClientset(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			FlexVolume: &v1.FlexPersistentVolumeSource{Driver: "ibm/ibmc-s3fs"},
+		}},
+	})
+
+	data, err := PVSecretData(context.Background(), client, "pv1")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func Test_PVSecretData_SecretNotFound(t *testing.T) {
+	client := k8fake.NewSimpleClientset(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			FlexVolume: &v1.FlexPersistentVolumeSource{
+				Driver:    "ibm/ibmc-s3fs",
+				SecretRef: &v1.SecretReference{Name: "cos", Namespace: "ns1"},
+			},
+		}},
+	})
+
+	_, err := PVSecretData(context.Background(), client, "pv1")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot get secret ns1/cos")
+	}
+}
+
+func Test_PVFlexVolume(t *testing.T) {
+	client := k8fake.NewSimpleClientset(
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{
+					Driver:    "ibm/ibmc-s3fs",
+					Options:   map[string]string{"bucket": "b"},
+					SecretRef: &v1.SecretReference{Name: "cos", Namespace: "ns1"},
+				},
+			}},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cos", Namespace: "ns1"},
+			Data:       map[string][]byte{"api-key": []byte("key")},
+		},
+		&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv2"}},
+	)
+
+	options, data, err := PVFlexVolume(context.Background(), client, "pv1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"bucket": "b"}, options)
+		assert.Equal(t, map[string][]byte{"api-key": []byte("key")}, data)
+	}
+
+	_, _, err = PVFlexVolume(context.Background(), client, "pv2")
+	assert.Error(t, err)
+	_, _, err = PVFlexVolume(context.Background(), client, "pv3")
+	assert.Error(t, err)
+}
+
+const testProfileID = "Profile-9fd84246-7df4-4667-94e4-8cecdc25ef3c"
+
+// startMetadataService fakes the instance identity API of the VPC instance metadata service
+func startMetadataService(t *testing.T, iamRequests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/instance_identity/v1/token":
+			assert.Equal(t, "ibm", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token":"identity-token","expires_in":300}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/instance_identity/v1/iam_token":
+			*iamRequests++
+			assert.Equal(t, "Bearer identity-token", r.Header.Get("Authorization"))
+			var body map[string]map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, testProfileID, body["trusted_profile"]["id"])
+			_, _ = w.Write([]byte(`{"access_token":"iam-token","expires_in":3600}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func requestToken(s *TokenServer, apiKey string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {apiKeyGrantType}, "apikey": {apiKey}}
+	req := httptest.NewRequest(http.MethodPost, TokenURLPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func Test_TokenServer_Positive(t *testing.T) {
+	iamRequests := 0
+	metadata := startMetadataService(t, &iamRequests)
+	defer metadata.Close()
+	s := NewTokenServer(zap.NewNop(), metadata.URL)
+
+	w := requestToken(s, testProfileID)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "iam-token", resp["access_token"])
+		assert.Equal(t, "Bearer", resp["token_type"])
+	}
+
+	// the token is cached until it is about to expire
+	requestToken(s, testProfileID)
+	assert.Equal(t, 1, iamRequests)
+}
+
+func Test_TokenServer_NotAProfileID(t *testing.T) {
+	s := NewTokenServer(zap.NewNop(), "http://127.0.0.1:1")
+
+	w := requestToken(s, "some-api-key")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_TokenServer_MetadataError(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "instance identity disabled", http.StatusForbidden)
+	}))
+	defer metadata.Close()
+	s := NewTokenServer(zap.NewNop(), metadata.URL)
+
+	w := requestToken(s, testProfileID)
+	if assert.Equal(t, http.StatusBadGateway, w.Code) {
+		assert.Contains(t, w.Body.String(), "instance identity disabled")
+	}
+}
+
+func Test_NodeEvent(t *testing.T) {
+	client := k8fake.NewSimpleClientset()
+
+	err := NodeEvent(context.Background(), client, "node1", v1.EventTypeNormal, "DriverRepaired", "Reinstalled driver")
+	if assert.NoError(t, err) {
+		events, _ := client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+		if assert.Len(t, events.Items, 1) {
+			assert.Equal(t, "Node", events.Items[0].InvolvedObject.Kind)
+			assert.Equal(t, "node1", events.Items[0].InvolvedObject.Name)
+			assert.Equal(t, "DriverRepaired", events.Items[0].Reason)
+		}
+	}
+}
+
+// getInstaller returns an installer of a driver binary into a plugin directory of a temporary directory
+func getInstaller(t *testing.T) (*Installer, string) {
+	dir, err := ioutil.TempDir("", "installer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := path.Join(dir, DriverBinaryName)
+	if err := ioutil.WriteFile(source, []byte("driver v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Installer{
+		Logger: zap.NewNop(),
+		Files:  []InstalledFile{{Source: source, Target: path.Join(dir, "plugins", DriverDirName, DriverBinaryName)}},
+	}, dir
+}
+
+func Test_Installer_Missing(t *testing.T) {
+	installer, dir := getInstaller(t)
+	defer os.RemoveAll(dir)
+
+	repaired, err := installer.Repair()
+	if assert.NoError(t, err) {
+		target := installer.Files[0].Target
+		assert.Equal(t, []string{target}, repaired)
+		content, _ := ioutil.ReadFile(target)
+		assert.Equal(t, "driver v2", string(content))
+		info, _ := os.Stat(target)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+}
+
+func Test_Installer_Changed(t *testing.T) {
+	installer, dir := getInstaller(t)
+	defer os.RemoveAll(dir)
+	target := installer.Files[0].Target
+	_ = os.MkdirAll(path.Dir(target), 0755)
+	_ = ioutil.WriteFile(target, []byte("driver v1"), 0755)
+
+	repaired, err := installer.Repair()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{target}, repaired)
+		content, _ := ioutil.ReadFile(target)
+		assert.Equal(t, "driver v2", string(content))
+	}
+}
+
+func Test_Installer_Intact(t *testing.T) {
+	installer, dir := getInstaller(t)
+	defer os.RemoveAll(dir)
+	_, _ = installer.Repair()
+
+	repaired, err := installer.Repair()
+	assert.NoError(t, err)
+	assert.Empty(t, repaired)
+}
+
+func Test_Installer_NoSource(t *testing.T) {
+	installer, dir := getInstaller(t)
+	defer os.RemoveAll(dir)
+	installer.Files[0].Source = path.Join(dir, "nonexistent")
+
+	_, err := installer.Repair()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot read")
+	}
+}
@@ -0,0 +1,103 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"strconv"
+)
+
+// LabelNode sets label to "true" or "false" on the node, depending on whether it can mount volumes
+func LabelNode(ctx context.Context, client kubernetes.Interface, nodeName, label string, ready bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{label: strconv.FormatBool(ready)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot label node %s: %v", nodeName, err)
+	}
+	return nil
+}
+
+// PVSecretData returns the data of the secret referenced by a FlexVolume PV, nil when it references none
+func PVSecretData(ctx context.Context, client kubernetes.Interface, pvName string) (map[string][]byte, error) {
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get PV %s: %v", pvName, err)
+	}
+	if pv.Spec.FlexVolume == nil || pv.Spec.FlexVolume.SecretRef == nil {
+		return nil, nil
+	}
+	ref := pv.Spec.FlexVolume.SecretRef
+	return SecretData(ctx, client, ref.Namespace, ref.Name)
+}
+
+// PVFlexVolume returns the FlexVolume options of a PV and the data of the secret they reference
+func PVFlexVolume(ctx context.Context, client kubernetes.Interface, pvName string) (map[string]string, map[string][]byte, error) {
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get PV %s: %v", pvName, err)
+	}
+	flex := pv.Spec.FlexVolume
+	if flex == nil {
+		return nil, nil, fmt.Errorf("PV %s is not a FlexVolume", pvName)
+	}
+	if flex.SecretRef == nil {
+		return flex.Options, nil, nil
+	}
+	data, err := SecretData(ctx, client, flex.SecretRef.Namespace, flex.SecretRef.Name)
+	return flex.Options, data, err
+}
+
+// SecretData returns the data of a secret
+func SecretData(ctx context.Context, client kubernetes.Interface, namespace, name string) (map[string][]byte, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get secret %s/%s: %v", namespace, name, err)
+	}
+	return secret.Data, nil
+}
+
+// NodeEvent records an event of the given type ("Normal" or "Warning") about the node
+func NodeEvent(ctx context.Context, client kubernetes.Interface, nodeName, eventType, reason, message string) error {
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: nodeName + ".", Namespace: metav1.NamespaceDefault},
+		InvolvedObject: v1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			UID:  types.UID(nodeName),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "ibmcloud-object-storage-mounter", Host: nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := client.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot record event on node %s: %v", nodeName, err)
+	}
+	return nil
+}
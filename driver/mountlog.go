@@ -0,0 +1,147 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	mountLogBackups = 3
+)
+
+var (
+	// mountLogDir holds one log file per volume mounted with log-file enabled
+	mountLogDir = "/var/log/ibmc-s3fs/volumes"
+	// mountLogMaxBytes is the size above which a volume log file is rotated
+	mountLogMaxBytes int64 = 50 * 1024 * 1024
+)
+
+// mountLogFile returns the log file of the FUSE daemon of a mount, named after its volume
+func mountLogFile(mountDir, mountHash string) string {
+	return path.Join(mountLogDir, path.Base(mountDir)+"-"+mountHash[:8]+".log")
+}
+
+// copyTruncate copies the content of name to dest and empties name, so that the FUSE
+// daemon keeps writing to the same file descriptor
+func copyTruncate(name, dest string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Truncate(name, 0)
+}
+
+// RotateMountLogs rotates the volume log files bigger than the maximum size, keeping a few backups
+func RotateMountLogs() error {
+	logFiles, err := filepath.Glob(path.Join(mountLogDir, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, logFile := range logFiles {
+		info, err := os.Stat(logFile)
+		if err != nil || info.Size() < mountLogMaxBytes {
+			continue
+		}
+		for i := mountLogBackups - 1; i > 0; i-- {
+			backup := fmt.Sprintf("%s.%d", logFile, i)
+			if _, err := os.Stat(backup); err == nil {
+				if err := os.Rename(backup, fmt.Sprintf("%s.%d", logFile, i+1)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := copyTruncate(logFile, logFile+".1"); err != nil {
+			return fmt.Errorf("cannot rotate %s: %v", logFile, err)
+		}
+	}
+	return nil
+}
+
+// MountLogForwarder copies the lines appended to the volume log files to Out,
+// prefixed with the name of the volume, for node log collectors to pick them up
+type MountLogForwarder struct {
+	Out     io.Writer
+	offsets map[string]int64
+}
+
+// Forward writes the lines appended since the previous call
+func (f *MountLogForwarder) Forward() error {
+	if f.offsets == nil {
+		f.offsets = map[string]int64{}
+	}
+	logFiles, err := filepath.Glob(path.Join(mountLogDir, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, logFile := range logFiles {
+		if err := f.forwardFile(logFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *MountLogForwarder) forwardFile(logFile string) error {
+	file, err := os.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := f.offsets[logFile]
+	if info.Size() < offset {
+		// the file was rotated
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	volume := strings.TrimSuffix(path.Base(logFile), ".log")
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// keep incomplete lines for the next call
+			break
+		}
+		offset += int64(len(line))
+		if _, err := fmt.Fprintf(f.Out, "[%s] %s", volume, line); err != nil {
+			return err
+		}
+	}
+	f.offsets[logFile] = offset
+	return nil
+}
@@ -0,0 +1,41 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reservedMountOptions are the s3fs options of the credentials, connection and host paths of a volume,
+// set by the driver alone
+var reservedMountOptions = map[string]bool{
+	"passwd_file": true, "url": true, "endpoint": true, "instance_name": true, "ibm_iam_auth": true,
+	"ibm_iam_endpoint": true, "use_cache": true, "logfile": true, "ahbe_conf": true, "mime": true,
+	"context": true, "allow_other": true, "uid": true, "gid": true, "umask": true, "mp_umask": true,
+	"cipher_suites": true, "default_acl": true, "ssl_verify_hostname": true, "no_check_certificate": true,
+}
+
+// splitMountOptions returns the options of the comma-separated mountOptions of a volume, or an error for
+// a malformed or reserved option
+func splitMountOptions(mountOptions string) ([]string, error) {
+	var options []string
+	for _, option := range strings.Split(mountOptions, ",") {
+		if option == "" || strings.ContainsAny(option, " \t\"'") {
+			return nil, fmt.Errorf("malformed mount option %q", option)
+		}
+		if name := strings.SplitN(option, "=", 2)[0]; reservedMountOptions[name] {
+			return nil, fmt.Errorf("mount option %q is set by the driver", name)
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
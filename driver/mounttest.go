@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Names of the steps of MountTest, in the order they run
+const (
+	MountTestCredentials = "credentials"
+	MountTestMount       = "mount"
+	MountTestWrite       = "write"
+	MountTestRead        = "read"
+	MountTestList        = "list"
+	MountTestDelete      = "delete"
+	MountTestUnmount     = "unmount"
+)
+
+// mountTestFilePrefix names the file written into the volume by MountTest
+const mountTestFilePrefix = ".ibmc-s3fs-mount-test-"
+
+// MountTestStep is the outcome of one step of a mount test
+type MountTestStep struct {
+	Name string
+	// Latency is how long the step took, zero when it was skipped
+	Latency time.Duration
+	// Err is nil when the step passed
+	Err error
+	// Skipped tells the step was not run because a step it depends on failed
+	Skipped bool
+}
+
+// MountOptsFunc returns the mount options of the volume to test, its secrets included
+type MountOptsFunc func() (map[string]string, error)
+
+// MountTest mounts a volume on mountDir as kubelet would, writes, reads back and lists a probe
+// file, deletes it and unmounts the volume, timing each step. The volume is unmounted whenever
+// it was mounted. A step is skipped when one it depends on failed.
+func (p *S3fsPlugin) MountTest(mountDir string, opts MountOptsFunc) []MountTestStep {
+	var steps []MountTestStep
+	run := func(name string, step func() error) bool {
+		start := time.Now()
+		err := step()
+		steps = append(steps, MountTestStep{Name: name, Latency: time.Since(start), Err: err})
+		return err == nil
+	}
+	skip := func(names ...string) []MountTestStep {
+		for _, name := range names {
+			steps = append(steps, MountTestStep{Name: name, Skipped: true})
+		}
+		return steps
+	}
+
+	var mountRequest interfaces.FlexVolumeMountRequest
+	if !run(MountTestCredentials, func() (err error) {
+		mountRequest.MountDir = mountDir
+		mountRequest.Opts, err = opts()
+		return err
+	}) {
+		return skip(MountTestMount, MountTestWrite, MountTestRead, MountTestList, MountTestDelete, MountTestUnmount)
+	}
+	if !run(MountTestMount, func() error { return p.mountInternal(mountRequest) }) {
+		return skip(MountTestWrite, MountTestRead, MountTestList, MountTestDelete, MountTestUnmount)
+	}
+
+	name := mountTestFilePrefix + strconv.FormatInt(time.Now().UnixNano(), 36)
+	file := path.Join(mountDir, name)
+	content := []byte("ibmc-s3fs mount test " + name + "\n")
+	if run(MountTestWrite, func() error { return ioutil.WriteFile(file, content, 0600) }) {
+		run(MountTestRead, func() error {
+			read, err := ioutil.ReadFile(file)
+			if err == nil && !bytes.Equal(read, content) {
+				err = errors.New("read content differs from the written one")
+			}
+			return err
+		})
+		run(MountTestList, func() error {
+			entries, err := ioutil.ReadDir(mountDir)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if entry.Name() == name {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s not listed in %s", name, mountDir)
+		})
+		run(MountTestDelete, func() error { return os.Remove(file) })
+	} else {
+		skip(MountTestRead, MountTestList, MountTestDelete)
+	}
+
+	run(MountTestUnmount, func() error {
+		return p.unmountInternal(interfaces.FlexVolumeUnmountRequest{MountDir: mountDir})
+	})
+	return steps
+}
@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+)
+
+const sourcesFileSuffix = ".sources"
+
+// Source is a bucket, or a prefix inside a bucket, mounted into a subdirectory of a multi-source volume
+type Source struct {
+	Name       string `json:"name"`
+	Bucket     string `json:"bucket"`
+	ObjectPath string `json:"objectPath,omitempty"`
+}
+
+// ParseSources parses a list of sources of the form "name=bucket[/object-path],..."
+func ParseSources(value string) ([]Source, error) {
+	var sources []Source
+	names := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("source %q is not of the form name=bucket[/object-path]", entry)
+		}
+		name := parts[0]
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/ ") {
+			return nil, fmt.Errorf("invalid source name %q", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate source name %q", name)
+		}
+		names[name] = true
+
+		location := strings.SplitN(parts[1], "/", 2)
+		if location[0] == "" {
+			return nil, fmt.Errorf("bucket of source %q is empty", name)
+		}
+		source := Source{Name: name, Bucket: location[0]}
+		if len(location) == 2 {
+			source.ObjectPath = strings.Trim(location[1], "/")
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// sourcesFile is where the sources mounted for a volume are recorded until it is unmounted
+func sourcesFile(mountDir string) string {
+	return volumeFile(mountDir, sourcesFileSuffix)
+}
+
+// mountSources mounts every source into its subdirectory of the target directory.
+// Each source is a regular mount of its own, with its own FUSE daemon and credentials copy.
+func (p *S3fsPlugin) mountSources(mountRequest interfaces.FlexVolumeMountRequest, sources []Source) error {
+	var mounted []string
+	done := false
+	defer func() {
+		// unmount the sources already mounted upon error
+		if !done {
+			p.unmountSources(mounted)
+		}
+	}()
+
+	for _, source := range sources {
+		opts := make(map[string]string, len(mountRequest.Opts))
+		for key, value := range mountRequest.Opts {
+			opts[key] = value
+		}
+		delete(opts, "sources")
+		opts["bucket"] = source.Bucket
+		opts["object-path"] = source.ObjectPath
+
+		sourceDir := path.Join(mountRequest.MountDir, source.Name)
+		p.Logger.Info(podUID+":"+"Mounting source",
+			zap.String("source", source.Name), zap.String("bucket", source.Bucket),
+			zap.String("object-path", source.ObjectPath))
+		err := p.mountInternal(interfaces.FlexVolumeMountRequest{MountDir: sourceDir, Opts: opts})
+		if err != nil {
+			return fmt.Errorf("cannot mount source %s: %w", source.Name, err)
+		}
+		mounted = append(mounted, sourceDir)
+	}
+
+	content, err := json.Marshal(mounted)
+	if err == nil {
+		err = writeFile(sourcesFile(mountRequest.MountDir), content, 0600)
+	}
+	if err != nil {
+		p.Logger.Error(podUID+":"+" Cannot record volume sources",
+			zap.Error(err))
+		return fmt.Errorf("cannot record volume sources: %v", err)
+	}
+
+	done = true
+	return nil
+}
+
+// unmountSources unmounts the sources of a multi-source volume and removes their subdirectories
+func (p *S3fsPlugin) unmountSources(sourceDirs []string) error {
+	var failed []string
+	for _, sourceDir := range sourceDirs {
+		err := p.unmountInternal(interfaces.FlexVolumeUnmountRequest{MountDir: sourceDir})
+		if err == nil {
+			err = removeAll(sourceDir)
+		}
+		if err != nil {
+			p.Logger.Error(podUID+":"+"Cannot unmount source",
+				zap.String("source", sourceDir), zap.Error(err))
+			failed = append(failed, sourceDir)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot unmount sources %v", failed)
+	}
+	return nil
+}
+
+// unmountRecordedSources unmounts the sources recorded for the target directory, if it is a multi-source volume
+func (p *S3fsPlugin) unmountRecordedSources(mountDir string) error {
+	file := sourcesFile(mountDir)
+	content, err := readFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	var sourceDirs []string
+	if err == nil {
+		err = json.Unmarshal(content, &sourceDirs)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read volume sources: %v", err)
+	}
+
+	err = p.unmountSources(sourceDirs)
+	if err != nil {
+		return err
+	}
+	return removeAll(file)
+}
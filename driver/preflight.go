@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+const (
+	// NodeReadyLabel is set on nodes by the mounter pod to tell whether the node can mount volumes
+	NodeReadyLabel  = "ibm.io/ibmc-s3fs-ready"
+	fuseDevice      = "/dev/fuse"
+	filesystemsPath = "/proc/filesystems"
+)
+
+// Preflight checks that the node has what s3fs volumes need: the s3fs binary
+// matching the FUSE version of the node, the FUSE device and FUSE support in the kernel
+func Preflight() error {
+	s3fs := s3fsBinaryName()
+	if _, err := lookPath(s3fs); err != nil {
+		return fmt.Errorf("%s binary not found: %v", s3fs, err)
+	}
+	if fuse3Only() && s3fs != s3fsFuse3Binary {
+		return fmt.Errorf("node only provides fuse3 and %s binary not found", s3fsFuse3Binary)
+	}
+	out, err := command(s3fs, "--version").CombinedOutput()
+	if wrongArchitecture(err) {
+		return errWrongArchitecture(s3fs)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run s3fs: %s", strings.TrimSpace(string(out)))
+	}
+
+	if _, err := stat(fuseDevice); err != nil {
+		return fmt.Errorf("FUSE device not available: %v", err)
+	}
+
+	filesystems, err := readFile(filesystemsPath)
+	if err != nil {
+		return fmt.Errorf("cannot read supported filesystems: %v", err)
+	}
+	for _, line := range strings.Split(string(filesystems), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == "fuse" {
+			return nil
+		}
+	}
+	return fmt.Errorf("kernel does not support FUSE")
+}
+
+// wrongArchitecture tells whether a binary could not be run because it is built for another architecture
+func wrongArchitecture(err error) bool {
+	return errors.Is(err, syscall.ENOEXEC)
+}
+
+func errWrongArchitecture(binary string) error {
+	return fmt.Errorf("%s binary is not built for the node architecture %s, install the %s build", binary, runtime.GOARCH, runtime.GOARCH)
+}
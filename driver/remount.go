@@ -0,0 +1,206 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+const (
+	remountFileSuffix = ".remount"
+	lockFileSuffix    = ".lock"
+	secretOptPrefix   = "kubernetes.io/secret/"
+	// PVNameOpt is the mount option kubelet sets to the name of the PV of the volume
+	PVNameOpt = "kubernetes.io/pvOrVolumeName"
+)
+
+var bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// remountRecord keeps what a volume was mounted with on the node disk, out of the tmpfs holding
+// its credentials, so that the volume can be mounted again after the node reboots.
+// Secrets are left out, they are read again from the secret referenced by the PV.
+type remountRecord struct {
+	MountDir string            `json:"mountDir"`
+	Opts     map[string]string `json:"opts"`
+	// BootID identifies the boot during which the volume was mounted
+	BootID string `json:"bootID"`
+}
+
+// SecretFunc returns the data of the secret referenced by a PV, nil when it references none
+type SecretFunc func(pvName string) (map[string][]byte, error)
+
+// WithSecretOpts returns the mount options of a volume with the data of its secret, as kubelet passes them
+func WithSecretOpts(opts map[string]string, secretData map[string][]byte) map[string]string {
+	merged := make(map[string]string, len(opts)+len(secretData))
+	for key, value := range opts {
+		merged[key] = value
+	}
+	for key, value := range secretData {
+		merged[secretOptPrefix+key] = base64.StdEncoding.EncodeToString(value)
+	}
+	return merged
+}
+
+func volumeFile(mountDir, suffix string) string {
+	return path.Join(dataRootPath, fmt.Sprintf("%x", sha256.Sum256([]byte(mountDir)))+suffix)
+}
+
+// bootID returns the identifier of the current boot of the node
+func bootID() string {
+	content, err := readFile(bootIDFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// lockVolume serializes the mounts of a target directory between kubelet and the mounter pod.
+// Locking is best effort, the mount goes on unlocked when the lock file cannot be used.
+func (p *S3fsPlugin) lockVolume(mountDir string) func() {
+	file, err := os.OpenFile(volumeFile(mountDir, lockFileSuffix), os.O_CREATE|os.O_RDWR, 0600)
+	if err == nil {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		p.Logger.Info(podUID+":"+"Cannot lock volume", zap.String("mountDir", mountDir), zap.Error(err))
+		return func() {}
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}
+}
+
+// saveRemountRecord records the options of a successful mount, without its secrets
+func (p *S3fsPlugin) saveRemountRecord(mountRequest interfaces.FlexVolumeMountRequest) {
+	record := remountRecord{MountDir: mountRequest.MountDir, Opts: map[string]string{}, BootID: bootID()}
+	for key, value := range mountRequest.Opts {
+		if !strings.HasPrefix(key, secretOptPrefix) {
+			record.Opts[key] = value
+		}
+	}
+	content, err := json.Marshal(record)
+	if err == nil {
+		err = writeFile(volumeFile(mountRequest.MountDir, remountFileSuffix), content, 0600)
+	}
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot save remount record, volume not mounted again after reboot",
+			zap.String("mountDir", mountRequest.MountDir), zap.Error(err))
+	}
+}
+
+// readRemountRecord returns the remount record of a target directory, nil when there is none
+func readRemountRecord(mountDir string) *remountRecord {
+	content, err := readFile(volumeFile(mountDir, remountFileSuffix))
+	if err != nil {
+		return nil
+	}
+	var record remountRecord
+	if err := json.Unmarshal(content, &record); err != nil || record.MountDir != mountDir {
+		return nil
+	}
+	return &record
+}
+
+// remountedThisBoot tells whether the target directory was already mounted during this boot,
+// by the mounter pod re-establishing the volumes of the node after it rebooted
+func (p *S3fsPlugin) remountedThisBoot(mountDir string) bool {
+	record := readRemountRecord(mountDir)
+	if record == nil || record.BootID == "" || record.BootID != bootID() {
+		return false
+	}
+	isMount, err := p.isMountpoint(mountDir)
+	return err == nil && isMount
+}
+
+// RemountAfterReboot mounts again the volumes that were mounted on the node before it rebooted
+// and whose target directories kubelet kept, instead of waiting for kubelet to mount each of them
+// again. Mount records of target directories that are gone are dropped.
+func (p *S3fsPlugin) RemountAfterReboot(secret SecretFunc) {
+	entries, err := readDir(dataRootPath)
+	if err != nil {
+		p.Logger.Info(podUID+":"+"No volumes to mount again", zap.Error(err))
+		return
+	}
+
+	currentBootID := bootID()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), remountFileSuffix) {
+			continue
+		}
+		file := path.Join(dataRootPath, entry.Name())
+		content, err := readFile(file)
+		if err != nil {
+			continue
+		}
+		var record remountRecord
+		if err := json.Unmarshal(content, &record); err != nil || record.MountDir == "" {
+			p.Logger.Error(podUID+":"+"Cannot parse remount record",
+				zap.String("file", file), zap.Error(err))
+			continue
+		}
+		if record.BootID == currentBootID {
+			// mounted during this boot, stale mounts are handled by the driver init
+			continue
+		}
+
+		exist, err := pathExists(record.MountDir)
+		if !exist && err == nil {
+			p.Logger.Info(podUID+":"+"Dropping remount record of deleted volume",
+				zap.String("mountDir", record.MountDir))
+			_ = removeAll(file)
+			continue
+		}
+
+		if err := p.remount(&record, secret); err != nil {
+			p.Logger.Error(podUID+":"+"Cannot mount volume again after reboot, left to kubelet",
+				zap.String("mountDir", record.MountDir), zap.Error(err))
+		}
+	}
+}
+
+// remount mounts a recorded volume again with the current data of its secret
+func (p *S3fsPlugin) remount(record *remountRecord, secret SecretFunc) error {
+	unlock := p.lockVolume(record.MountDir)
+	defer unlock()
+
+	// kubelet may have been faster
+	if isMount, err := p.isMountpoint(record.MountDir); err == nil && isMount {
+		return nil
+	}
+
+	data, err := secret(record.Opts[PVNameOpt])
+	if err != nil {
+		return fmt.Errorf("cannot read secret of PV %s: %v", record.Opts[PVNameOpt], err)
+	}
+	opts := WithSecretOpts(record.Opts, data)
+
+	p.Logger.Info(podUID+":"+"Mounting volume again after reboot",
+		zap.String("mountDir", record.MountDir))
+	mountRequest := interfaces.FlexVolumeMountRequest{MountDir: record.MountDir, Opts: opts}
+	if err := p.mountInternal(mountRequest); err != nil {
+		return err
+	}
+	p.saveRemountRecord(mountRequest)
+	return nil
+}
@@ -0,0 +1,125 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/mounter"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	systemdRunBinary = "systemd-run"
+	// systemdRuntimeDir only exists when systemd is the running init system
+	systemdRuntimeDir = "/run/systemd/system"
+	scopeUnitPrefix   = "ibmc-s3fs-"
+)
+
+// mounterClient runs FUSE daemons in the mounter pod of the node
+type mounterClient interface {
+	Run(req *mounter.Request) ([]byte, error)
+}
+
+var (
+	mounterSocketPath = mounter.DefaultSocketPath
+	// tokenServerAddress is where the mounter pod serves IAM tokens of the instance identity
+	tokenServerAddress = mounter.DefaultTokenAddress
+	newMounterClient   = func(socketPath string) mounterClient { return mounter.NewClient(socketPath) }
+)
+
+// scopeProperties converts the mounter-cpu-limit and mounter-memory-limit options,
+// given as Kubernetes quantities, into systemd resource control properties
+func scopeProperties(options *Options) ([]string, error) {
+	var properties []string
+	if options.MounterCPULimit != "" {
+		cpu, err := resource.ParseQuantity(options.MounterCPULimit)
+		if err != nil || cpu.MilliValue() < 10 {
+			return nil, fmt.Errorf("Bad value for mounter-cpu-limit \"%v\": must be a CPU quantity of at least 10m", options.MounterCPULimit)
+		}
+		// CPUQuota is a percentage of one CPU
+		properties = append(properties, "CPUQuota="+strconv.FormatInt(cpu.MilliValue()/10, 10)+"%")
+	}
+	if options.MounterMemoryLimit != "" {
+		memory, err := resource.ParseQuantity(options.MounterMemoryLimit)
+		if err != nil || memory.Value() <= 0 {
+			return nil, fmt.Errorf("Bad value for mounter-memory-limit \"%v\": must be a positive memory quantity", options.MounterMemoryLimit)
+		}
+		properties = append(properties, "MemoryMax="+strconv.FormatInt(memory.Value(), 10))
+	}
+	return properties, nil
+}
+
+// runMounter starts the FUSE daemon of a mount and returns its combined output.
+// The daemon is handed over to the mounter pod when one runs on the node, otherwise
+// it is started locally.
+func (p *S3fsPlugin) runMounter(mountHash string, state *mountState) ([]byte, error) {
+	name, args := state.Command, state.Args
+	if exist, err := pathExists(mounterSocketPath); err == nil && exist {
+		p.Logger.Info(podUID+":"+"Starting FUSE daemon in mounter pod",
+			zap.String("socket", mounterSocketPath))
+		if len(state.ScopeProperties) > 0 {
+			p.Logger.Info(podUID+":"+"Mounter limits of the volume ignored, the limits of the mounter pod apply",
+				zap.Strings("limits", state.ScopeProperties))
+		}
+		var env []string
+		for _, key := range []string{"CURL_CA_BUNDLE", "AWS_CA_BUNDLE"} {
+			if value, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+value)
+			}
+		}
+		return newMounterClient(mounterSocketPath).Run(&mounter.Request{Command: name, Args: args, Env: env})
+	}
+	return p.mounterCommand(mountHash, state).CombinedOutput()
+}
+
+// systemdAvailable tells whether FUSE daemons can be started in their own transient scope
+func (p *S3fsPlugin) systemdAvailable() bool {
+	if _, err := lookPath(systemdRunBinary); err != nil {
+		return false
+	}
+	if _, err := stat(systemdRuntimeDir); err != nil {
+		return false
+	}
+	return true
+}
+
+// mounterCommand returns the command starting a FUSE daemon for the given mount.
+// When systemd is available the daemon is started in a transient scope unit, so that it
+// no longer belongs to the cgroup of kubelet and is not killed when kubelet or the
+// driver are restarted or upgraded.
+// Resource limits of the mount, if any, are set on the scope.
+func (p *S3fsPlugin) mounterCommand(mountHash string, state *mountState) *exec.Cmd {
+	if !p.systemdAvailable() {
+		if len(state.ScopeProperties) > 0 {
+			p.Logger.Error(podUID+":"+"systemd not available, cannot limit resources of FUSE daemon",
+				zap.Strings("limits", state.ScopeProperties))
+		}
+		return command(state.Command, state.Args...)
+	}
+
+	unit := scopeUnitPrefix + mountHash
+	p.Logger.Info(podUID+":"+"Starting FUSE daemon in transient scope",
+		zap.String("unit", unit+".scope"))
+
+	scopeArgs := []string{"--scope", "--collect", "--quiet",
+		"--unit=" + unit,
+		"--description=IBM COS volume mounter " + mountHash,
+	}
+	for _, property := range state.ScopeProperties {
+		scopeArgs = append(scopeArgs, "--property="+property)
+	}
+	scopeArgs = append(scopeArgs, state.Command)
+	return command(systemdRunBinary, append(scopeArgs, state.Args...)...)
+}
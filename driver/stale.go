@@ -0,0 +1,129 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"encoding/json"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"path"
+)
+
+// mountStateFileSuffix names the state of the mount of a volume, next to the tmpfs of the volume
+const mountStateFileSuffix = ".mount.json"
+
+var (
+	readDir  = ioutil.ReadDir
+	readFile = ioutil.ReadFile
+)
+
+// mountState records how the FUSE daemon of a volume was started, so that it can be
+// cleaned up or started again when the driver finds it stale
+type mountState struct {
+	MountDir string   `json:"mountDir"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	// ScopeProperties are the resource limits of the FUSE daemon
+	ScopeProperties []string `json:"scopeProperties,omitempty"`
+}
+
+// mountStateFile returns the state of the mount of the volume whose tmpfs is mountPath. It is kept on the node
+// disk with the remount records, out of the tmpfs.
+func mountStateFile(mountPath string) string {
+	return mountPath + mountStateFileSuffix
+}
+
+// saveMountState writes the state of a mount next to the tmpfs of the volume
+func (p *S3fsPlugin) saveMountState(mountPath string, state *mountState) {
+	content, err := json.Marshal(state)
+	if err == nil {
+		err = writeFile(mountStateFile(mountPath), content, 0600)
+	}
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot save mount state, stale mount recovery disabled",
+			zap.String("mountDir", state.MountDir), zap.Error(err))
+	}
+}
+
+// cleanupStaleMounts goes through the mounts this driver made on the node.
+// Mounts whose target directory is gone (pod deleted while the node was down) are
+// cleaned up, mounts whose FUSE daemon died or hangs are started again.
+func (p *S3fsPlugin) cleanupStaleMounts() {
+	entries, err := readDir(dataRootPath)
+	if err != nil {
+		p.Logger.Info(podUID+":"+"No mounts to check", zap.Error(err))
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		mountHash := entry.Name()
+		content, err := readFile(mountStateFile(path.Join(dataRootPath, mountHash)))
+		if err != nil {
+			// mounted by an older driver or not fully set up, nothing to recover from
+			continue
+		}
+		var state mountState
+		if err := json.Unmarshal(content, &state); err != nil || state.MountDir == "" {
+			p.Logger.Error(podUID+":"+"Cannot parse mount state",
+				zap.String("mountHash", mountHash), zap.Error(err))
+			continue
+		}
+
+		exist, err := pathExists(state.MountDir)
+		if !exist && err == nil {
+			p.Logger.Info(podUID+":"+"Cleaning up orphaned mount",
+				zap.String("mountDir", state.MountDir))
+			if err := p.unmountInternal(interfaces.FlexVolumeUnmountRequest{MountDir: state.MountDir}); err != nil {
+				p.Logger.Error(podUID+":"+"Cannot clean up orphaned mount",
+					zap.String("mountDir", state.MountDir), zap.Error(err))
+			}
+			continue
+		}
+
+		stale := err != nil && isCorruptedMnt(err)
+		if !stale {
+			isMount, err := p.isMountpoint(state.MountDir)
+			if err != nil || !isMount {
+				// left to kubelet, which mounts the volume again if its pod still runs
+				continue
+			}
+			pids, err := findMounterProcesses(state.MountDir)
+			stale = err == nil && len(pids) == 0
+		}
+		if stale {
+			p.remountStale(mountHash, &state)
+		}
+	}
+}
+
+// remountStale detaches a mount whose FUSE daemon is gone and starts the daemon again
+func (p *S3fsPlugin) remountStale(mountHash string, state *mountState) {
+	p.Logger.Info(podUID+":"+"Re-establishing stale mount",
+		zap.String("mountDir", state.MountDir), zap.String("command", state.Command))
+
+	if err := p.unmountPath(state.MountDir, false); err != nil {
+		if err := p.forceUnmount(state.MountDir); err != nil {
+			p.Logger.Error(podUID+":"+"Cannot unmount stale mount",
+				zap.String("mountDir", state.MountDir), zap.Error(err))
+			return
+		}
+	}
+
+	out, err := p.runMounter(mountHash, state)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot re-establish stale mount",
+			zap.String("mountDir", state.MountDir), zap.String("Error", string(out)))
+	}
+}
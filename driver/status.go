@@ -0,0 +1,164 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MountStateMounted is reported for mounts served by a running FUSE daemon
+	MountStateMounted = "mounted"
+	// MountStateStale is reported for mounts whose FUSE daemon died or does not answer
+	MountStateStale = "stale"
+	// MountStateNotMounted is reported for volumes whose target is not mounted anymore
+	MountStateNotMounted = "not-mounted"
+	// lastErrorWindow is how much of the end of a volume log file is searched for errors
+	lastErrorWindow = 64 * 1024
+)
+
+// MountStatus describes a volume mounted by the driver on the node
+type MountStatus struct {
+	MountDir  string `json:"mountDir"`
+	Bucket    string `json:"bucket"`
+	Endpoint  string `json:"endpoint"`
+	Mounter   string `json:"mounter"`
+	PID       int    `json:"pid,omitempty"`
+	Uptime    string `json:"uptime,omitempty"`
+	State     string `json:"state"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ListMounts returns the status of the volumes mounted on the node
+func (p *S3fsPlugin) ListMounts() ([]MountStatus, error) {
+	entries, err := readDir(dataRootPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var mounts []MountStatus
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		mountHash := entry.Name()
+		content, err := readFile(mountStateFile(path.Join(dataRootPath, mountHash)))
+		if err != nil {
+			continue
+		}
+		var state mountState
+		if err := json.Unmarshal(content, &state); err != nil || state.MountDir == "" {
+			continue
+		}
+		mounts = append(mounts, p.mountStatus(mountHash, &state))
+	}
+	return mounts, nil
+}
+
+func (p *S3fsPlugin) mountStatus(mountHash string, state *mountState) MountStatus {
+	status := MountStatus{MountDir: state.MountDir, Mounter: state.Command}
+
+	if state.Command == "rclone" {
+		// bucket and endpoint are in the rclone config of the volume
+		content, _ := readFile(path.Join(dataRootPath, mountHash, rcloneConfigFileName))
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "endpoint = ") {
+				status.Endpoint = strings.TrimPrefix(line, "endpoint = ")
+			} else if strings.HasPrefix(line, "remote = cos:") {
+				status.Bucket = strings.TrimPrefix(line, "remote = cos:")
+			}
+		}
+	} else {
+		if len(state.Args) > 0 {
+			status.Bucket = state.Args[0]
+		}
+		for _, arg := range state.Args {
+			if strings.HasPrefix(arg, "url=") {
+				status.Endpoint = strings.TrimPrefix(arg, "url=")
+			}
+		}
+	}
+
+	pids, _ := findMounterProcesses(state.MountDir)
+	if len(pids) > 0 {
+		status.PID = pids[0]
+		if info, err := os.Stat(path.Join(procRoot, strconv.Itoa(status.PID))); err == nil {
+			status.Uptime = time.Since(info.ModTime()).Round(time.Second).String()
+		}
+	}
+
+	exist, err := pathExists(state.MountDir)
+	switch {
+	case err != nil && isCorruptedMnt(err):
+		status.State = MountStateStale
+	case !exist:
+		status.State = MountStateNotMounted
+	default:
+		isMount, err := p.isMountpoint(state.MountDir)
+		if err != nil && isMount {
+			status.State = MountStateStale
+		} else if !isMount {
+			status.State = MountStateNotMounted
+		} else if status.PID == 0 {
+			status.State = MountStateStale
+		} else {
+			status.State = MountStateMounted
+		}
+	}
+
+	status.LastError = lastLoggedError(mountLogFile(state.MountDir,
+		fmt.Sprintf("%x", sha256.Sum256([]byte(state.MountDir)))))
+	return status
+}
+
+// lastLoggedError returns the last error line of a volume log file, if any
+func lastLoggedError(logFile string) string {
+	file, err := os.Open(logFile)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := info.Size() - lastErrorWindow
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		return ""
+	}
+
+	lines := strings.Split(string(buf), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if isErrorLine(lines[i]) {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	return ""
+}
+
+// isErrorLine tells whether a line of a volume log file reports an error, of s3fs or of rclone
+func isErrorLine(line string) bool {
+	return strings.Contains(line, "[ERR]") || strings.Contains(line, "ERROR")
+}
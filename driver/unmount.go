@@ -0,0 +1,102 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// unmountRetries bounds the number of times a hung FUSE daemon is killed before giving up
+	unmountRetries = 3
+)
+
+var (
+	procRoot             = "/proc"
+	kill                 = syscall.Kill
+	unmountRetryInterval = 2 * time.Second
+	// mounterBinaries are the FUSE daemons the driver starts
+	mounterBinaries = map[string]bool{s3fsBinary: true, s3fsFuse3Binary: true, "rclone": true}
+)
+
+// findMounterProcesses returns the pids of the FUSE daemons serving mountDir
+func findMounterProcesses(mountDir string) ([]int, error) {
+	cmdlines, err := filepath.Glob(path.Join(procRoot, "[0-9]*", "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, cmdline := range cmdlines {
+		content, err := ioutil.ReadFile(cmdline)
+		if err != nil || len(content) == 0 {
+			// process exited meanwhile or is a kernel thread
+			continue
+		}
+		args := bytes.Split(bytes.TrimRight(content, "\x00"), []byte{0})
+		if !mounterBinaries[path.Base(string(args[0]))] {
+			continue
+		}
+		for _, arg := range args[1:] {
+			if string(arg) == mountDir {
+				pid, err := strconv.Atoi(path.Base(path.Dir(cmdline)))
+				if err == nil {
+					pids = append(pids, pid)
+				}
+				break
+			}
+		}
+	}
+	return pids, nil
+}
+
+// forceUnmount unmounts a FUSE mount whose daemon does not answer anymore, by killing the
+// daemon (SIGTERM first, SIGKILL on the next attempts) and retrying the unmount
+func (p *S3fsPlugin) forceUnmount(mountDir string) error {
+	var err error
+	var pids []int
+	for attempt := 1; attempt <= unmountRetries; attempt++ {
+		pids, err = findMounterProcesses(mountDir)
+		if err != nil {
+			return fmt.Errorf("cannot look for FUSE daemon of %s: %v", mountDir, err)
+		}
+
+		signal := syscall.SIGTERM
+		if attempt > 1 {
+			signal = syscall.SIGKILL
+		}
+		for _, pid := range pids {
+			p.Logger.Info(podUID+":"+"Stopping FUSE daemon",
+				zap.String("Mount path", mountDir), zap.Int("pid", pid),
+				zap.String("signal", signal.String()), zap.Int("attempt", attempt))
+			if killErr := kill(pid, signal); killErr != nil && killErr != syscall.ESRCH {
+				p.Logger.Error(podUID+":"+"Cannot stop FUSE daemon",
+					zap.Int("pid", pid), zap.Error(killErr))
+			}
+		}
+
+		time.Sleep(unmountRetryInterval)
+
+		err = p.unmountPath(mountDir, false)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts, FUSE daemon pids %v: %v", unmountRetries, pids, err)
+}
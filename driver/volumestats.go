@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/parser"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"strings"
+)
+
+const statsConfigFileName = "stats.json"
+
+// statsConfig is what the driver needs to query the usage of the bucket of a volume.
+// It holds an API key, so it is kept in the tmpfs of the volume next to its password file.
+// Without API key, the usage is counted by listing the bucket with the HMAC keys of the password file.
+type statsConfig struct {
+	APIKey      string `json:"apiKey"`
+	Bucket      string `json:"bucket"`
+	OSEndpoint  string `json:"osEndpoint"`
+	IAMEndpoint string `json:"iamEndpoint"`
+	Region      string `json:"region,omitempty"`
+	ObjectPath  string `json:"objectPath,omitempty"`
+}
+
+// saveStatsConfig records how to query the bucket usage of a volume. The res-conf-apikey of the
+// secret is preferred, the API key of the volume is used otherwise. HMAC-only volumes list their
+// object-path instead.
+func (p *S3fsPlugin) saveStatsConfig(mountPath string, options Options, apiKey, osEndpoint, region, iamEndpoint string) {
+	config := statsConfig{APIKey: apiKey, Bucket: options.Bucket, OSEndpoint: osEndpoint, IAMEndpoint: iamEndpoint}
+	if options.ResConfAPIKeyB64 != "" {
+		resConfAPIKey, err := parser.DecodeBase64(options.ResConfAPIKeyB64)
+		if err == nil && resConfAPIKey != "" {
+			config.APIKey = resConfAPIKey
+			config.IAMEndpoint = options.IAMEndpoint
+			if config.IAMEndpoint == "" {
+				config.IAMEndpoint = defaultIAMEndPoint
+			}
+		}
+	}
+	if config.APIKey == "" {
+		config = statsConfig{Bucket: options.Bucket, OSEndpoint: osEndpoint, Region: region, ObjectPath: options.ObjectPath}
+	}
+
+	content, err := json.Marshal(config)
+	if err == nil {
+		err = writeFile(path.Join(mountPath, statsConfigFileName), content, 0600)
+	}
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot save volume stats configuration, volume stats disabled",
+			zap.Error(err))
+	}
+}
+
+// volumeStats returns the storage usage of a mounted volume, from the usage and quota metered by
+// the object storage for its bucket. Objects outside the object-path of the volume are accounted too.
+// Volumes mounted with HMAC keys have no quota, their usage is counted under their object-path.
+func (p *S3fsPlugin) volumeStats(mountDir string) (*interfaces.VolumeStats, error) {
+	mountHash := fmt.Sprintf("%x", sha256.Sum256([]byte(mountDir)))
+	content, err := readFile(path.Join(dataRootPath, mountHash, statsConfigFileName))
+	if os.IsNotExist(err) {
+		return nil, withCode(interfaces.ErrorCodeNotFound,
+			fmt.Errorf("no stats for %s: volume not mounted", mountDir))
+	}
+	var config statsConfig
+	if err == nil {
+		err = json.Unmarshal(content, &config)
+	}
+	if err != nil {
+		// left empty or partial by a failed write, the stats of the volume are disabled
+		return nil, withCode(interfaces.ErrorCodeNotFound,
+			fmt.Errorf("no stats for %s: cannot read volume stats configuration: %v", mountDir, err))
+	}
+
+	var usage *backend.BucketUsage
+	if config.APIKey != "" {
+		rcc := &backend.UpdateAPObj{}
+		usage, err = p.AccessPolicy.NewAccessPolicy().GetBucketUsage(config.APIKey, config.Bucket, config.OSEndpoint, config.IAMEndpoint, rcc)
+	} else {
+		usage, err = p.listBucketUsage(path.Join(dataRootPath, mountHash, passwordFileName), config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get usage of bucket %s: %v", config.Bucket, err)
+	}
+
+	stats := &interfaces.VolumeStats{
+		CapacityBytes: usage.HardQuota,
+		UsedBytes:     usage.BytesUsed,
+		InodesUsed:    usage.ObjectCount,
+	}
+	if usage.HardQuota > usage.BytesUsed {
+		stats.AvailableBytes = usage.HardQuota - usage.BytesUsed
+	}
+	return stats, nil
+}
+
+// listBucketUsage counts the usage under the object-path of a volume with the HMAC keys of its password file
+func (p *S3fsPlugin) listBucketUsage(passwordFile string, config statsConfig) (*backend.BucketUsage, error) {
+	content, err := readFile(passwordFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read password file: %v", err)
+	}
+	keys := strings.SplitN(string(content), ":", 2)
+	if len(keys) != 2 {
+		return nil, fmt.Errorf("malformed password file")
+	}
+
+	creds := &backend.ObjectStorageCredentials{AccessKey: keys[0], SecretKey: keys[1]}
+	sess := p.Backend.NewObjectStorageSession(config.OSEndpoint, config.Region, creds, backend.TransportConfig{}, p.Logger)
+	return sess.GetBucketUsage(context.Background(), config.Bucket, config.ObjectPath)
+}
+
+// GetVolumeStats returns the capacity, used and available bytes of a mounted volume
+func (p *S3fsPlugin) GetVolumeStats(mountDir string) interfaces.FlexVolumeResponse {
+	p.Logger.Info(podUID + ":" + "S3fsPlugin-GetVolumeStats()-start")
+	defer p.Logger.Info(podUID + ":" + "S3fsPlugin-GetVolumeStats()-end")
+
+	stats, err := p.volumeStats(mountDir)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Cannot get volume stats",
+			zap.String("mountDir", mountDir), zap.Error(err))
+		return interfaces.FlexVolumeResponse{
+			Status:  interfaces.StatusFailure,
+			Message: fmt.Sprintf("Error getting volume stats: %v", err),
+			Code:    ErrorCode(err, interfaces.ErrorCodeStatsFailed),
+		}
+	}
+
+	return interfaces.FlexVolumeResponse{
+		Status:      interfaces.StatusSuccess,
+		VolumeStats: stats,
+	}
+}
@@ -0,0 +1,253 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver/interfaces"
+	"go.uber.org/zap"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	writeBackRootPath    = "/var/lib/ibmc-s3fs-writeback"
+	rcloneConfigFileName = "rclone.conf"
+	// rcloneRemoteName is the alias pointing at bucket[/object-path] in the generated rclone config
+	rcloneRemoteName = "volume"
+	// defaultWriteBackDelaySeconds is the time rclone waits after a file is closed before uploading it
+	defaultWriteBackDelaySeconds = "5"
+	// rcloneRCSocketName is the unix socket of the remote control of the rclone daemon of a write-back volume
+	rcloneRCSocketName = "rclone-rc.sock"
+)
+
+var (
+	// writeBackSyncTimeout bounds the wait for the uploads of a write-back volume before its unmount fails
+	writeBackSyncTimeout = 10 * time.Minute
+	// writeBackSyncInterval is the interval of the checks of the uploads left
+	writeBackSyncInterval = time.Second
+)
+
+// writeBackConfig holds what is needed to render the rclone config of a write-back volume
+type writeBackConfig struct {
+	endpoint          string
+	region            string
+	remotePath        string
+	accessKey         string
+	secretKey         string
+	apiKey            string
+	serviceInstanceID string
+	// cryptPassword is the obscured passphrase of client-side encrypted volumes
+	cryptPassword string
+	// compress stores the files of the volume gzipped in the bucket
+	compress bool
+}
+
+// rcloneConfig renders an rclone config with a "cos" remote and a "volume" alias for the mounted path.
+// Compression and encryption are layered in between, compressing before encrypting since
+// encrypted data does not compress.
+func (c *writeBackConfig) rcloneConfig() string {
+	lines := []string{
+		"[cos]",
+		"type = s3",
+		"provider = IBMCOS",
+		"endpoint = " + c.endpoint,
+		"location_constraint = " + c.region,
+	}
+	if c.apiKey != "" {
+		lines = append(lines,
+			"ibm_api_key = "+c.apiKey,
+			"ibm_resource_instance_id = "+c.serviceInstanceID)
+	} else {
+		lines = append(lines,
+			"access_key_id = "+c.accessKey,
+			"secret_access_key = "+c.secretKey,
+			"acl = private")
+	}
+	remote := "cos:" + c.remotePath
+	if c.cryptPassword != "" {
+		lines = append(lines,
+			"",
+			"[crypt]",
+			"type = crypt",
+			"remote = "+remote,
+			"password = "+c.cryptPassword,
+			"filename_encryption = standard",
+			"directory_name_encryption = true")
+		remote = "crypt:"
+	}
+	if c.compress {
+		lines = append(lines,
+			"",
+			"[compress]",
+			"type = compress",
+			"remote = "+remote,
+			"mode = gzip")
+		remote = "compress:"
+	}
+	lines = append(lines,
+		"",
+		"["+rcloneRemoteName+"]",
+		"type = alias",
+		"remote = "+remote,
+		"")
+	return strings.Join(lines, "\n")
+}
+
+// obscure returns the passphrase in the obscured form rclone expects in its config.
+// It is fed through stdin so that it never shows up in the process list.
+func (p *S3fsPlugin) obscure(passphrase string) (string, error) {
+	cmd := command("rclone", "obscure", "-")
+	cmd.Stdin = strings.NewReader(passphrase)
+	out, err := cmd.Output()
+	if err != nil {
+		p.Logger.Error(podUID+":"+" Cannot obscure encryption key",
+			zap.Error(err))
+		return "", fmt.Errorf("cannot obscure encryption key: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// writeBackCacheDir returns the persistent staging directory of a write-back volume
+func writeBackCacheDir(mountHash string) string {
+	return path.Join(writeBackRootPath, mountHash)
+}
+
+// mountWriteBack mounts the volume with rclone VFS so that writes land on local disk and are uploaded asynchronously
+func (p *S3fsPlugin) mountWriteBack(mountRequest interfaces.FlexVolumeMountRequest, mountPath, cacheDir string, limits []string, cfg *writeBackConfig, options *Options) error {
+	p.Logger.Info(podUID+":"+"Mounting volume in write-back mode",
+		zap.String("mountDir", mountRequest.MountDir), zap.String("cacheDir", cacheDir))
+
+	err := p.createDirectoryIfNotExists(cacheDir)
+	if err != nil {
+		return fmt.Errorf("cannot create write-back cache directory: %v", err)
+	}
+
+	configFile := path.Join(mountPath, rcloneConfigFileName)
+	err = writeFile(configFile, []byte(cfg.rcloneConfig()), 0600)
+	if err != nil {
+		p.Logger.Error(podUID+":"+" Cannot create rclone config file",
+			zap.Error(err))
+		return fmt.Errorf("cannot create rclone config file: %v", err)
+	}
+
+	delay := defaultWriteBackDelaySeconds
+	if options.WriteBackDelaySeconds != "" {
+		delay = options.WriteBackDelaySeconds
+	}
+
+	args := []string{"mount", rcloneRemoteName + ":", mountRequest.MountDir,
+		"--config", configFile,
+		"--cache-dir", cacheDir,
+		"--vfs-cache-mode", "writes",
+		"--vfs-write-back", delay + "s",
+		"--allow-other",
+		"--umask", "002",
+		"--daemon",
+		"--rc",
+		"--rc-addr", "unix://" + path.Join(mountPath, rcloneRCSocketName),
+		"--rc-no-auth",
+	}
+
+	if options.S3FSFUSERetryCount != "" {
+		args = append(args, "--low-level-retries", options.S3FSFUSERetryCount)
+	}
+
+	if readOnly(*options) {
+		args = append(args, "--read-only")
+	}
+
+	if options.LogFile {
+		args = append(args, "--log-file", mountLogFile(mountRequest.MountDir, path.Base(mountPath)))
+	}
+
+	if options.SELinuxContext != "" {
+		args = append(args, "--option", "context=\""+options.SELinuxContext+"\"")
+	}
+
+	if groupID := fsGroup(mountRequest, options); groupID != "" {
+		args = append(args, "--uid", groupID, "--gid", groupID)
+	} else if options.CompatProfile == CompatProfileOpenShift {
+		args = append(args, "--gid", "0")
+	}
+
+	p.Logger.Info(podUID+":"+"Running rclone",
+		zap.Reflect("args", args))
+
+	state := &mountState{MountDir: mountRequest.MountDir, Command: "rclone", Args: args, ScopeProperties: limits}
+	p.saveMountState(mountPath, state)
+	out, err := p.runMounter(path.Base(mountPath), state)
+	if err != nil {
+		p.Logger.Error(podUID+":"+"Running rclone",
+			zap.String("Error", string(out)))
+		return fmt.Errorf("rclone mount failed: %s", string(out))
+	}
+	return nil
+}
+
+// rcloneVFSStats is the part of the vfs/stats answer of rclone counting the uploads of the write-back cache
+type rcloneVFSStats struct {
+	DiskCache struct {
+		UploadsInProgress int `json:"uploadsInProgress"`
+		UploadsQueued     int `json:"uploadsQueued"`
+	} `json:"diskCache"`
+}
+
+// syncWriteBack waits until the rclone daemon of a write-back volume has uploaded the files of its cache,
+// the queued ones included, through its remote control. It tells whether the cache is fully uploaded,
+// false when the volume is not in write-back mode or its daemon cannot be reached, and fails when uploads
+// are still pending after writeBackSyncTimeout so that the volume stays mounted until they are done.
+func (p *S3fsPlugin) syncWriteBack(mountPath string) (bool, error) {
+	content, err := readFile(mountStateFile(mountPath))
+	if err != nil {
+		return false, nil
+	}
+	var state mountState
+	socket := path.Join(mountPath, rcloneRCSocketName)
+	if err := json.Unmarshal(content, &state); err != nil || state.Command != "rclone" {
+		return false, nil
+	}
+	remoteControl := false
+	for _, arg := range state.Args {
+		remoteControl = remoteControl || arg == "unix://"+socket
+	}
+	if !remoteControl {
+		// mounted without remote control, its uploads left are kept in the cache directory
+		return false, nil
+	}
+
+	deadline := time.Now().Add(writeBackSyncTimeout)
+	for {
+		out, err := command("rclone", "rc", "--unix-socket", socket, "vfs/stats").Output()
+		var stats rcloneVFSStats
+		if err == nil {
+			err = json.Unmarshal(out, &stats)
+		}
+		if err != nil {
+			// the uploads left stay in the cache directory, kept
+			p.Logger.Error(podUID+":"+"Cannot get the uploads of the write-back cache, keeping it",
+				zap.String("mountpath", mountPath), zap.Error(err))
+			return false, nil
+		}
+		pending := stats.DiskCache.UploadsInProgress + stats.DiskCache.UploadsQueued
+		if pending == 0 {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("%d files of the write-back cache are still being uploaded after %v", pending, writeBackSyncTimeout)
+		}
+		p.Logger.Info(podUID+":"+"Waiting for the uploads of the write-back cache",
+			zap.String("mountpath", mountPath), zap.Int("pending", pending))
+		time.Sleep(writeBackSyncInterval)
+	}
+}
@@ -0,0 +1,253 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The apply functions create a resource, or update the fields the operator sets when they differ.
+// The fields defaulted by the API server are left alone.
+
+// mergeMeta sets the labels and owners of desired on existing and tells whether it changed
+func mergeMeta(existing *metav1.ObjectMeta, desired metav1.ObjectMeta) bool {
+	changed := false
+	for key, value := range desired.Labels {
+		if existing.Labels[key] != value {
+			if existing.Labels == nil {
+				existing.Labels = map[string]string{}
+			}
+			existing.Labels[key] = value
+			changed = true
+		}
+	}
+	for key, value := range desired.Annotations {
+		if existing.Annotations[key] != value {
+			if existing.Annotations == nil {
+				existing.Annotations = map[string]string{}
+			}
+			existing.Annotations[key] = value
+			changed = true
+		}
+	}
+	if !equality.Semantic.DeepEqual(existing.OwnerReferences, desired.OwnerReferences) {
+		existing.OwnerReferences = desired.OwnerReferences
+		changed = true
+	}
+	return changed
+}
+
+func (o *Operator) applyServiceAccount(ctx context.Context, desired *v1.ServiceAccount) error {
+	client := o.Client.CoreV1().ServiceAccounts(desired.Namespace)
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil && mergeMeta(&existing.ObjectMeta, desired.ObjectMeta) {
+		_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("cannot apply ServiceAccount %s/%s: %v", desired.Namespace, desired.Name, err)
+	}
+	return nil
+}
+
+func (o *Operator) applyClusterRole(ctx context.Context, desired *rbacv1.ClusterRole) error {
+	client := o.Client.RbacV1().ClusterRoles()
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil {
+		changed := mergeMeta(&existing.ObjectMeta, desired.ObjectMeta)
+		if !equality.Semantic.DeepEqual(existing.Rules, desired.Rules) {
+			existing.Rules = desired.Rules
+			changed = true
+		}
+		if changed {
+			_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot apply ClusterRole %s: %v", desired.Name, err)
+	}
+	return nil
+}
+
+func (o *Operator) applyClusterRoleBinding(ctx context.Context, desired *rbacv1.ClusterRoleBinding) error {
+	client := o.Client.RbacV1().ClusterRoleBindings()
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil {
+		changed := mergeMeta(&existing.ObjectMeta, desired.ObjectMeta)
+		if !equality.Semantic.DeepEqual(existing.Subjects, desired.Subjects) {
+			existing.Subjects = desired.Subjects
+			changed = true
+		}
+		if changed {
+			_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot apply ClusterRoleBinding %s: %v", desired.Name, err)
+	}
+	return nil
+}
+
+// currentDeployment returns the provisioner Deployment, nil when it is not installed
+func (o *Operator) currentDeployment(ctx context.Context, namespace string) (*appsv1.Deployment, error) {
+	deployment, err := o.Client.AppsV1().Deployments(namespace).Get(ctx, provisionerName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get Deployment %s/%s: %v", namespace, provisionerName, err)
+	}
+	return deployment, nil
+}
+
+func (o *Operator) applyDeployment(ctx context.Context, desired *appsv1.Deployment) (*appsv1.Deployment, error) {
+	client := o.Client.AppsV1().Deployments(desired.Namespace)
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil {
+		changed := mergeMeta(&existing.ObjectMeta, desired.ObjectMeta)
+		if !equality.Semantic.DeepDerivative(desired.Spec.Template, existing.Spec.Template) ||
+			!equality.Semantic.DeepEqual(desired.Spec.Replicas, existing.Spec.Replicas) {
+			o.Logger.Info("Updating the provisioner", zap.String("image", desired.Spec.Template.Spec.Containers[0].Image))
+			existing.Spec.Template = desired.Spec.Template
+			existing.Spec.Replicas = desired.Spec.Replicas
+			changed = true
+		}
+		if changed {
+			existing, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot apply Deployment %s/%s: %v", desired.Namespace, desired.Name, err)
+	}
+	return existing, nil
+}
+
+func (o *Operator) applyDaemonSet(ctx context.Context, desired *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	client := o.Client.AppsV1().DaemonSets(desired.Namespace)
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil {
+		changed := mergeMeta(&existing.ObjectMeta, desired.ObjectMeta)
+		if !equality.Semantic.DeepDerivative(desired.Spec.Template, existing.Spec.Template) ||
+			existing.Spec.UpdateStrategy.Type != desired.Spec.UpdateStrategy.Type {
+			o.Logger.Info("Updating the mounter", zap.String("image", desired.Spec.Template.Spec.Containers[0].Image))
+			existing.Spec.Template = desired.Spec.Template
+			existing.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+			changed = true
+		}
+		if changed {
+			existing, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot apply DaemonSet %s/%s: %v", desired.Namespace, desired.Name, err)
+	}
+	return existing, nil
+}
+
+// applyStorageClasses installs the storage classes and deletes the ones the operator installed
+// that are not desired anymore. The parameters of a storage class cannot be updated, it is
+// replaced when they change: the volumes provisioned with it are not affected.
+func (o *Operator) applyStorageClasses(ctx context.Context, desired []*storagev1.StorageClass) error {
+	client := o.Client.StorageV1().StorageClasses()
+	installed, err := client.List(ctx, metav1.ListOptions{LabelSelector: managedByLabel + "=" + operatorName})
+	if err != nil {
+		return fmt.Errorf("cannot list the storage classes: %v", err)
+	}
+	wanted := map[string]bool{}
+	for _, class := range desired {
+		wanted[class.Name] = true
+	}
+	for _, class := range installed.Items {
+		if !wanted[class.Name] {
+			o.Logger.Info("Deleting a storage class removed from the spec", zap.String("storageClass", class.Name))
+			if err := client.Delete(ctx, class.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("cannot delete StorageClass %s: %v", class.Name, err)
+			}
+		}
+	}
+
+	for _, class := range desired {
+		existing, err := client.Get(ctx, class.Name, metav1.GetOptions{})
+		if err == nil && (existing.Provisioner != class.Provisioner ||
+			!equality.Semantic.DeepEqual(existing.Parameters, class.Parameters) ||
+			(class.ReclaimPolicy != nil && !equality.Semantic.DeepEqual(existing.ReclaimPolicy, class.ReclaimPolicy))) {
+			o.Logger.Info("Replacing a storage class whose parameters changed", zap.String("storageClass", class.Name))
+			if err = client.Delete(ctx, class.Name, metav1.DeleteOptions{}); err == nil {
+				err = apierrors.NewNotFound(storagev1.Resource("storageclasses"), class.Name)
+			}
+		}
+		if apierrors.IsNotFound(err) {
+			_, err = client.Create(ctx, class, metav1.CreateOptions{})
+		} else if err == nil {
+			changed := mergeMeta(&existing.ObjectMeta, class.ObjectMeta)
+			if _, isDefault := class.Annotations[defaultClassAnnotation]; !isDefault && existing.Annotations[defaultClassAnnotation] != "" {
+				delete(existing.Annotations, defaultClassAnnotation)
+				changed = true
+			}
+			if changed {
+				_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("cannot apply StorageClass %s: %v", class.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyWebhookConfiguration installs the webhooks, or deletes their configuration when there is none
+func (o *Operator) applyWebhookConfiguration(ctx context.Context, desired *admissionregistrationv1.ValidatingWebhookConfiguration) error {
+	client := o.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	existing, err := client.Get(ctx, desired.Name, metav1.GetOptions{})
+	switch {
+	case len(desired.Webhooks) == 0:
+		if err == nil && existing.Labels[managedByLabel] == operatorName {
+			err = client.Delete(ctx, desired.Name, metav1.DeleteOptions{})
+		}
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+	case apierrors.IsNotFound(err):
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{})
+	case err == nil:
+		changed := mergeMeta(&existing.ObjectMeta, desired.ObjectMeta)
+		if !equality.Semantic.DeepDerivative(desired.Webhooks, existing.Webhooks) {
+			existing.Webhooks = desired.Webhooks
+			changed = true
+		}
+		if changed {
+			_, err = client.Update(ctx, existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot apply ValidatingWebhookConfiguration %s: %v", desired.Name, err)
+	}
+	return nil
+}
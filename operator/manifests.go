@@ -0,0 +1,272 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package operator
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The resources installed mirror the manifests of the deploy directory
+const (
+	provisionerName          = "ibmcloud-object-storage-plugin"
+	provisionerContainerName = "ibmcloud-object-storage-plugin-container"
+	secretReaderName         = "ibmcloud-object-storage-secret-reader"
+	mounterName              = "ibmcloud-object-storage-mounter"
+	webhookConfigurationName = "ibmcloud-object-storage-plugin"
+	provisionerType          = "ibm.io/ibmc-s3fs"
+	defaultNamespace         = "kube-system"
+	defaultProvisionerImage  = "ibmcloud-object-storage-plugin"
+	defaultMounterImage      = "ibmcloud-object-storage-mounter"
+
+	// managedByLabel marks the resources installed by the operator, the storage classes
+	// carrying it are deleted when they leave the spec
+	managedByLabel = "app.kubernetes.io/managed-by"
+	operatorName   = "ibmc-s3fs-operator"
+	// defaultClassAnnotation makes a storage class the default one of the cluster
+	defaultClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+)
+
+// namespace returns the namespace of the provisioner and the mounter
+func (p *ObjectStoragePlugin) namespace() string {
+	if p.Spec.Namespace == "" {
+		return defaultNamespace
+	}
+	return p.Spec.Namespace
+}
+
+// provisionerImage returns the provisioner image of a version
+func (p *ObjectStoragePlugin) provisionerImage(version string) string {
+	if p.Spec.ProvisionerImage == "" {
+		return defaultProvisionerImage + ":" + version
+	}
+	return p.Spec.ProvisionerImage + ":" + version
+}
+
+// mounterImage returns the mounter image of the spec
+func (p *ObjectStoragePlugin) mounterImage() string {
+	if p.Spec.MounterImage == "" {
+		return defaultMounterImage + ":" + p.Spec.Version
+	}
+	return p.Spec.MounterImage + ":" + p.Spec.Version
+}
+
+// objectMeta returns the metadata of a resource installed for the plugin, owned by it
+// so that deleting the plugin uninstalls it
+func (p *ObjectStoragePlugin) objectMeta(name, namespace string) metav1.ObjectMeta {
+	controller, block := true, true
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    map[string]string{managedByLabel: operatorName},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion:         Group + "/" + Version,
+			Kind:               Kind,
+			Name:               p.Name,
+			UID:                p.UID,
+			Controller:         &controller,
+			BlockOwnerDeletion: &block,
+		}},
+	}
+}
+
+func (p *ObjectStoragePlugin) serviceAccounts() []*v1.ServiceAccount {
+	return []*v1.ServiceAccount{
+		{ObjectMeta: p.objectMeta(provisionerName, p.namespace())},
+		{ObjectMeta: p.objectMeta(mounterName, p.namespace())},
+	}
+}
+
+func (p *ObjectStoragePlugin) clusterRoles() []*rbacv1.ClusterRole {
+	return []*rbacv1.ClusterRole{
+		{
+			ObjectMeta: p.objectMeta(provisionerName, ""),
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"get", "list", "watch", "update"}},
+				{APIGroups: []string{""}, Resources: []string{"persistentvolumes"}, Verbs: []string{"get", "list", "watch", "create", "update", "delete"}},
+				{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"storageclasses"}, Verbs: []string{"get", "list", "watch"}},
+				{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"list", "watch", "create"}},
+			},
+		},
+		{
+			ObjectMeta: p.objectMeta(secretReaderName, ""),
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+			},
+		},
+		{
+			ObjectMeta: p.objectMeta(mounterName, ""),
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "patch"}},
+				{APIGroups: []string{""}, Resources: []string{"persistentvolumes", "secrets"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}},
+			},
+		},
+	}
+}
+
+func (p *ObjectStoragePlugin) clusterRoleBindings() []*rbacv1.ClusterRoleBinding {
+	binding := func(role, serviceAccount string) *rbacv1.ClusterRoleBinding {
+		return &rbacv1.ClusterRoleBinding{
+			ObjectMeta: p.objectMeta(role, ""),
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount, Namespace: p.namespace()}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+		}
+	}
+	return []*rbacv1.ClusterRoleBinding{
+		binding(provisionerName, provisionerName),
+		binding(secretReaderName, provisionerName),
+		binding(mounterName, mounterName),
+	}
+}
+
+// deployment returns the provisioner Deployment running a version
+func (p *ObjectStoragePlugin) deployment(version string) *appsv1.Deployment {
+	replicas := int32(1)
+	labels := map[string]string{"app": provisionerName}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: p.objectMeta(provisionerName, p.namespace()),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					Tolerations:        []v1.Toleration{{Operator: v1.TolerationOpExists}},
+					ServiceAccountName: provisionerName,
+					Containers: []v1.Container{{
+						Name:            provisionerContainerName,
+						Image:           p.provisionerImage(version),
+						ImagePullPolicy: v1.PullIfNotPresent,
+						Args:            append([]string{"-provisioner=" + provisionerType}, p.Spec.ProvisionerArgs...),
+						Env:             []v1.EnvVar{{Name: "DEBUG_TRACE", Value: "false"}},
+					}},
+				},
+			},
+		},
+	}
+	deployment.Labels["app"] = provisionerName
+	return deployment
+}
+
+// daemonSet returns the mounter DaemonSet running the version of the spec
+func (p *ObjectStoragePlugin) daemonSet() *appsv1.DaemonSet {
+	labels := map[string]string{"app": mounterName}
+	privileged := true
+	hostPath := func(name, path string, pathType v1.HostPathType) v1.Volume {
+		volume := v1.Volume{Name: name, VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: path}}}
+		if pathType != "" {
+			volume.HostPath.Type = &pathType
+		}
+		return volume
+	}
+	bidirectional := v1.MountPropagationBidirectional
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: p.objectMeta(mounterName, p.namespace()),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			// Restarting the pod kills the FUSE daemons it runs, only roll it on node drain
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: map[string]string{
+					"prometheus.io/scrape": "true",
+					"prometheus.io/port":   "9811",
+				}},
+				Spec: v1.PodSpec{
+					Tolerations:        []v1.Toleration{{Operator: v1.TolerationOpExists}},
+					PriorityClassName:  "system-node-critical",
+					ServiceAccountName: mounterName,
+					HostNetwork:        true,
+					Containers: []v1.Container{{
+						Name:            mounterName,
+						Image:           p.mounterImage(),
+						ImagePullPolicy: v1.PullIfNotPresent,
+						Args: []string{
+							"-socket=/var/lib/ibmc-s3fs/mounter.sock",
+							"-forward-mount-logs=true",
+							"-token-address=127.0.0.1:8219",
+							"-driver-binary=/usr/local/bin/ibmc-s3fs",
+							"-s3fs-binary=/usr/local/bin/s3fs",
+							"-metrics-address=:9811",
+						},
+						Env: []v1.EnvVar{{
+							Name:      "NODE_NAME",
+							ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+						}},
+						SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+						Resources: v1.ResourceRequirements{
+							Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("128Mi")},
+							Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+						},
+						VolumeMounts: []v1.VolumeMount{
+							{Name: "kubelet-dir", MountPath: "/var/lib/kubelet", MountPropagation: &bidirectional},
+							{Name: "data-dir", MountPath: "/var/lib/ibmc-s3fs", MountPropagation: &bidirectional},
+							{Name: "writeback-dir", MountPath: "/var/lib/ibmc-s3fs-writeback"},
+							{Name: "ca-dir", MountPath: "/tmp"},
+							{Name: "log-dir", MountPath: "/var/log/ibmc-s3fs"},
+							{Name: "fuse-device", MountPath: "/dev/fuse"},
+							{Name: "plugin-dir", MountPath: "/usr/libexec/kubernetes/kubelet-plugins/volume/exec"},
+							{Name: "host-bin-dir", MountPath: "/host/usr/local/bin"},
+						},
+					}},
+					Volumes: []v1.Volume{
+						hostPath("kubelet-dir", "/var/lib/kubelet", ""),
+						hostPath("data-dir", "/var/lib/ibmc-s3fs", v1.HostPathDirectoryOrCreate),
+						hostPath("writeback-dir", "/var/lib/ibmc-s3fs-writeback", v1.HostPathDirectoryOrCreate),
+						hostPath("ca-dir", "/tmp", ""),
+						hostPath("log-dir", "/var/log/ibmc-s3fs", v1.HostPathDirectoryOrCreate),
+						hostPath("fuse-device", "/dev/fuse", ""),
+						hostPath("plugin-dir", "/usr/libexec/kubernetes/kubelet-plugins/volume/exec", v1.HostPathDirectoryOrCreate),
+						hostPath("host-bin-dir", "/usr/local/bin", v1.HostPathDirectoryOrCreate),
+					},
+				},
+			},
+		},
+	}
+	daemonSet.Labels["app"] = mounterName
+	return daemonSet
+}
+
+// storageClasses returns the storage classes of the spec
+func (p *ObjectStoragePlugin) storageClasses() []*storagev1.StorageClass {
+	var classes []*storagev1.StorageClass
+	for _, spec := range p.Spec.StorageClasses {
+		class := &storagev1.StorageClass{
+			ObjectMeta:  p.objectMeta(spec.Name, ""),
+			Provisioner: provisionerType,
+			Parameters:  spec.Parameters,
+		}
+		if spec.ReclaimPolicy != "" {
+			reclaimPolicy := spec.ReclaimPolicy
+			class.ReclaimPolicy = &reclaimPolicy
+		}
+		if spec.Default {
+			class.Annotations = map[string]string{defaultClassAnnotation: "true"}
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// webhookConfiguration returns the ValidatingWebhookConfiguration of the webhooks of the spec
+func (p *ObjectStoragePlugin) webhookConfiguration() *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: p.objectMeta(webhookConfigurationName, ""),
+		Webhooks:   p.Spec.Webhooks,
+	}
+}
@@ -0,0 +1,294 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Operator installs the plugin described by the ObjectStoragePlugin resources of the cluster.
+// A single plugin is installed, the resources created after the first one are marked Degraded.
+type Operator struct {
+	// Client installs the resources of the plugin
+	Client kubernetes.Interface
+	// Dynamic reads the ObjectStoragePlugin resources and writes their status
+	Dynamic dynamic.Interface
+	Logger  *zap.Logger
+}
+
+// Run reconciles the ObjectStoragePlugin resources every period until ctx is done
+func (o *Operator) Run(ctx context.Context, period time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := o.Sync(ctx); err != nil {
+			o.Logger.Error("Cannot reconcile the ObjectStoragePlugin resources", zap.Error(err))
+		}
+	}, period)
+}
+
+// Sync reconciles the ObjectStoragePlugin resources once and records their status
+func (o *Operator) Sync(ctx context.Context) error {
+	list, err := o.Dynamic.Resource(Resource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	plugins := make([]ObjectStoragePlugin, len(list.Items))
+	for i, item := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &plugins[i]); err != nil {
+			return fmt.Errorf("cannot read ObjectStoragePlugin %s: %v", item.GetName(), err)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		if !plugins[i].CreationTimestamp.Equal(&plugins[j].CreationTimestamp) {
+			return plugins[i].CreationTimestamp.Before(&plugins[j].CreationTimestamp)
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	var errs []string
+	for i := range plugins {
+		plugin := &plugins[i]
+		var status ObjectStoragePluginStatus
+		if i == 0 {
+			status = o.Reconcile(ctx, plugin)
+		} else {
+			status = newStatus(plugin)
+			setCondition(&status, plugin, ConditionDegraded, metav1.ConditionTrue, ReasonDuplicate,
+				fmt.Sprintf("ObjectStoragePlugin %s installs the plugin, delete this one", plugins[0].Name))
+		}
+		if equality.Semantic.DeepEqual(status, plugin.Status) {
+			continue
+		}
+		plugin.Status = status
+		if err := o.updateStatus(ctx, plugin); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (o *Operator) updateStatus(ctx context.Context, plugin *ObjectStoragePlugin) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(plugin)
+	if err != nil {
+		return err
+	}
+	object := &unstructured.Unstructured{Object: content}
+	object.SetAPIVersion(Group + "/" + Version)
+	object.SetKind(Kind)
+	if _, err := o.Dynamic.Resource(Resource).UpdateStatus(ctx, object, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("cannot update the status of ObjectStoragePlugin %s: %v", plugin.Name, err)
+	}
+	return nil
+}
+
+// Reconcile installs or upgrades the plugin and returns its status.
+//
+// The mounter DaemonSet is updated first. Its pods only roll when they are deleted, on node drain,
+// since restarting them kills the FUSE daemons of the volumes. The provisioner is upgraded once every
+// mounter pod runs a version it supports, at most one minor version behind it. Until then it keeps
+// its version and the Progressing condition names the nodes to drain.
+func (o *Operator) Reconcile(ctx context.Context, plugin *ObjectStoragePlugin) ObjectStoragePluginStatus {
+	status := newStatus(plugin)
+	target, err := validate(plugin)
+	if err != nil {
+		setCondition(&status, plugin, ConditionDegraded, metav1.ConditionTrue, ReasonInvalidSpec, err.Error())
+		return status
+	}
+	installed, err := o.install(ctx, plugin, target)
+	if err != nil {
+		o.Logger.Error("Cannot install the plugin", zap.String("plugin", plugin.Name), zap.Error(err))
+		setCondition(&status, plugin, ConditionDegraded, metav1.ConditionTrue, ReasonApplyFailed, err.Error())
+		return status
+	}
+	setCondition(&status, plugin, ConditionDegraded, metav1.ConditionFalse, ReasonAsExpected, "")
+	status.ProvisionerVersion = installed.provisionerVersion
+	status.MounterVersions = installed.mounterVersions
+
+	switch {
+	case installed.deployment == nil || installed.deployment.Status.AvailableReplicas == 0:
+		setCondition(&status, plugin, ConditionAvailable, metav1.ConditionFalse, ReasonProvisionerUnavailable,
+			"no provisioner pod is available")
+	case installed.daemonSet.Status.NumberAvailable < installed.daemonSet.Status.DesiredNumberScheduled:
+		setCondition(&status, plugin, ConditionAvailable, metav1.ConditionFalse, ReasonMounterUnavailable,
+			fmt.Sprintf("%d of %d mounter pods are available", installed.daemonSet.Status.NumberAvailable,
+				installed.daemonSet.Status.DesiredNumberScheduled))
+	default:
+		setCondition(&status, plugin, ConditionAvailable, metav1.ConditionTrue, ReasonAsExpected, "")
+	}
+
+	outdated := 0
+	for version, count := range installed.mounterVersions {
+		if version != plugin.Spec.Version {
+			outdated += int(count)
+		}
+	}
+	switch {
+	case len(installed.skewedNodes) != 0:
+		setCondition(&status, plugin, ConditionProgressing, metav1.ConditionTrue, ReasonVersionSkew,
+			fmt.Sprintf("the provisioner stays at version %q until the mounter pods of nodes %s, too old for version %s, are recreated by draining the nodes",
+				installed.provisionerVersion, strings.Join(installed.skewedNodes, ", "), plugin.Spec.Version))
+	case installed.provisionerVersion != plugin.Spec.Version || installed.deployment.Status.UpdatedReplicas < installed.deployment.Status.Replicas:
+		setCondition(&status, plugin, ConditionProgressing, metav1.ConditionTrue, ReasonUpgrading,
+			fmt.Sprintf("the provisioner is rolling out version %s", plugin.Spec.Version))
+	case outdated != 0:
+		setCondition(&status, plugin, ConditionProgressing, metav1.ConditionTrue, ReasonUpgrading,
+			fmt.Sprintf("%d mounter pods run another version than %s, they are recreated with it when their node is drained",
+				outdated, plugin.Spec.Version))
+	default:
+		setCondition(&status, plugin, ConditionProgressing, metav1.ConditionFalse, ReasonComplete,
+			fmt.Sprintf("version %s is installed", plugin.Spec.Version))
+	}
+	return status
+}
+
+// newStatus returns a status of the plugin keeping the conditions of its current status,
+// copied for the transition times of the unchanged ones
+func newStatus(plugin *ObjectStoragePlugin) ObjectStoragePluginStatus {
+	return ObjectStoragePluginStatus{
+		ObservedGeneration: plugin.Generation,
+		ProvisionerVersion: plugin.Status.ProvisionerVersion,
+		MounterVersions:    plugin.Status.MounterVersions,
+		Conditions:         append([]metav1.Condition(nil), plugin.Status.Conditions...),
+	}
+}
+
+func setCondition(status *ObjectStoragePluginStatus, plugin *ObjectStoragePlugin, conditionType string,
+	conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: plugin.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// validate checks the spec of the plugin and returns its version
+func validate(plugin *ObjectStoragePlugin) (version, error) {
+	target, err := parseVersion(plugin.Spec.Version)
+	if err != nil {
+		return version{}, err
+	}
+	names := map[string]bool{}
+	for _, class := range plugin.Spec.StorageClasses {
+		if msgs := validation.IsDNS1123Subdomain(class.Name); len(msgs) != 0 {
+			return version{}, fmt.Errorf("invalid storage class name %q: %s", class.Name, strings.Join(msgs, ", "))
+		}
+		if names[class.Name] {
+			return version{}, fmt.Errorf("storage class %s is listed twice", class.Name)
+		}
+		names[class.Name] = true
+	}
+	return target, nil
+}
+
+// installation is what Reconcile installed
+type installation struct {
+	// deployment is nil when the provisioner could not be installed for the version skew
+	deployment         *appsv1.Deployment
+	daemonSet          *appsv1.DaemonSet
+	provisionerVersion string
+	mounterVersions    map[string]int32
+	// skewedNodes run mounter pods the provisioner of the spec does not support
+	skewedNodes []string
+}
+
+func (o *Operator) install(ctx context.Context, plugin *ObjectStoragePlugin, target version) (*installation, error) {
+	for _, serviceAccount := range plugin.serviceAccounts() {
+		if err := o.applyServiceAccount(ctx, serviceAccount); err != nil {
+			return nil, err
+		}
+	}
+	for _, role := range plugin.clusterRoles() {
+		if err := o.applyClusterRole(ctx, role); err != nil {
+			return nil, err
+		}
+	}
+	for _, binding := range plugin.clusterRoleBindings() {
+		if err := o.applyClusterRoleBinding(ctx, binding); err != nil {
+			return nil, err
+		}
+	}
+	daemonSet, err := o.applyDaemonSet(ctx, plugin.daemonSet())
+	if err != nil {
+		return nil, err
+	}
+	if err := o.applyStorageClasses(ctx, plugin.storageClasses()); err != nil {
+		return nil, err
+	}
+	if err := o.applyWebhookConfiguration(ctx, plugin.webhookConfiguration()); err != nil {
+		return nil, err
+	}
+
+	installed := &installation{daemonSet: daemonSet, mounterVersions: map[string]int32{}}
+	pods, err := o.Client.CoreV1().Pods(plugin.namespace()).List(ctx, metav1.ListOptions{LabelSelector: "app=" + mounterName})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the mounter pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			if container.Name != mounterName {
+				continue
+			}
+			tag := imageTag(container.Image)
+			installed.mounterVersions[tag]++
+			if mounter, err := parseVersion(tag); err != nil || !target.supportsMounter(mounter) {
+				installed.skewedNodes = append(installed.skewedNodes, pod.Spec.NodeName)
+			}
+		}
+	}
+	sort.Strings(installed.skewedNodes)
+
+	installed.provisionerVersion = plugin.Spec.Version
+	if len(installed.skewedNodes) != 0 {
+		current, err := o.currentDeployment(ctx, plugin.namespace())
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			installed.provisionerVersion = ""
+			return installed, nil
+		}
+		installed.provisionerVersion = provisionerVersion(current)
+	}
+	if installed.deployment, err = o.applyDeployment(ctx, plugin.deployment(installed.provisionerVersion)); err != nil {
+		return nil, err
+	}
+	return installed, nil
+}
+
+// provisionerVersion returns the image tag of the provisioner container of a Deployment
+func provisionerVersion(deployment *appsv1.Deployment) string {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == provisionerContainerName {
+			return imageTag(container.Image)
+		}
+	}
+	return ""
+}
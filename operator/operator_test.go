@@ -0,0 +1,294 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package operator
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func testPlugin(version string) *ObjectStoragePlugin {
+	return &ObjectStoragePlugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "ibmc-s3fs", UID: "uid", Generation: 2},
+		Spec: ObjectStoragePluginSpec{
+			Version: version,
+			StorageClasses: []StorageClassSpec{{
+				Name:          "ibmc-s3fs-standard",
+				Default:       true,
+				ReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+				Parameters:    map[string]string{"ibm.io/chunk-size-mb": "10"},
+			}},
+		},
+	}
+}
+
+func mounterPod(node, version string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: mounterName + "-" + node, Namespace: defaultNamespace, Labels: map[string]string{"app": mounterName}},
+		Spec: v1.PodSpec{
+			NodeName:   node,
+			Containers: []v1.Container{{Name: mounterName, Image: defaultMounterImage + ":" + version}},
+		},
+	}
+}
+
+func getDeployment(t *testing.T, o *Operator) *appsv1.Deployment {
+	deployment, err := o.Client.AppsV1().Deployments(defaultNamespace).Get(context.Background(), provisionerName, metav1.GetOptions{})
+	require.NoError(t, err)
+	return deployment
+}
+
+func getDaemonSet(t *testing.T, o *Operator) *appsv1.DaemonSet {
+	daemonSet, err := o.Client.AppsV1().DaemonSets(defaultNamespace).Get(context.Background(), mounterName, metav1.GetOptions{})
+	require.NoError(t, err)
+	return daemonSet
+}
+
+func condition(status ObjectStoragePluginStatus, conditionType string) metav1.Condition {
+	if c := meta.FindStatusCondition(status.Conditions, conditionType); c != nil {
+		return *c
+	}
+	return metav1.Condition{}
+}
+
+func Test_Reconcile_Install(t *testing.T) {
+	o := &Operator{Client: fake.NewSimpleClientset(), Logger: zap.NewNop()}
+	status := o.Reconcile(context.Background(), testPlugin("v1.2.0"))
+
+	assert.Equal(t, metav1.ConditionFalse, condition(status, ConditionDegraded).Status)
+	assert.Equal(t, ReasonProvisionerUnavailable, condition(status, ConditionAvailable).Reason)
+	assert.Equal(t, "v1.2.0", status.ProvisionerVersion)
+	assert.Equal(t, int64(2), status.ObservedGeneration)
+
+	deployment := getDeployment(t, o)
+	assert.Equal(t, "ibmcloud-object-storage-plugin:v1.2.0", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "uid", string(deployment.OwnerReferences[0].UID))
+	daemonSet := getDaemonSet(t, o)
+	assert.Equal(t, "ibmcloud-object-storage-mounter:v1.2.0", daemonSet.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, appsv1.OnDeleteDaemonSetStrategyType, daemonSet.Spec.UpdateStrategy.Type)
+
+	class, err := o.Client.StorageV1().StorageClasses().Get(context.Background(), "ibmc-s3fs-standard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, provisionerType, class.Provisioner)
+	assert.Equal(t, "true", class.Annotations[defaultClassAnnotation])
+	assert.Equal(t, v1.PersistentVolumeReclaimRetain, *class.ReclaimPolicy)
+
+	for _, name := range []string{provisionerName, secretReaderName, mounterName} {
+		_, err := o.Client.RbacV1().ClusterRoleBindings().Get(context.Background(), name, metav1.GetOptions{})
+		assert.NoError(t, err, name)
+	}
+	_, err = o.Client.CoreV1().ServiceAccounts(defaultNamespace).Get(context.Background(), mounterName, metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func Test_Reconcile_Available(t *testing.T) {
+	plugin := testPlugin("1.2.0")
+	deployment := plugin.deployment("1.2.0")
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	daemonSet := plugin.daemonSet()
+	daemonSet.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 2}
+	o := &Operator{
+		Client: fake.NewSimpleClientset(deployment, daemonSet, mounterPod("node-a", "1.2.0"), mounterPod("node-b", "1.2.1")),
+		Logger: zap.NewNop(),
+	}
+
+	status := o.Reconcile(context.Background(), plugin)
+	assert.Equal(t, metav1.ConditionTrue, condition(status, ConditionAvailable).Status)
+	assert.Equal(t, map[string]int32{"1.2.0": 1, "1.2.1": 1}, status.MounterVersions)
+	// the mounter pod of node-b runs another patch version
+	assert.Equal(t, ReasonUpgrading, condition(status, ConditionProgressing).Reason)
+
+	require.NoError(t, o.Client.CoreV1().Pods(defaultNamespace).Delete(context.Background(), mounterName+"-node-b", metav1.DeleteOptions{}))
+	status = o.Reconcile(context.Background(), plugin)
+	assert.Equal(t, metav1.ConditionFalse, condition(status, ConditionProgressing).Status)
+	assert.Equal(t, ReasonComplete, condition(status, ConditionProgressing).Reason)
+}
+
+func Test_Reconcile_Upgrade(t *testing.T) {
+	old := testPlugin("1.1.0")
+	o := &Operator{
+		Client: fake.NewSimpleClientset(old.deployment("1.1.0"), old.daemonSet(), mounterPod("node-a", "1.1.0")),
+		Logger: zap.NewNop(),
+	}
+
+	status := o.Reconcile(context.Background(), testPlugin("1.2.0"))
+	assert.Equal(t, "ibmcloud-object-storage-plugin:1.2.0", getDeployment(t, o).Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "ibmcloud-object-storage-mounter:1.2.0", getDaemonSet(t, o).Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "1.2.0", status.ProvisionerVersion)
+	assert.Equal(t, ReasonUpgrading, condition(status, ConditionProgressing).Reason)
+}
+
+func Test_Reconcile_VersionSkew(t *testing.T) {
+	old := testPlugin("1.0.0")
+	o := &Operator{
+		Client: fake.NewSimpleClientset(old.deployment("1.0.0"), old.daemonSet(),
+			mounterPod("node-a", "1.0.0"), mounterPod("node-b", "1.1.0"), mounterPod("node-c", "latest")),
+		Logger: zap.NewNop(),
+	}
+
+	status := o.Reconcile(context.Background(), testPlugin("1.2.0"))
+	// the mounter is updated, the provisioner waits for node-a and node-c to be drained
+	assert.Equal(t, "ibmcloud-object-storage-mounter:1.2.0", getDaemonSet(t, o).Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "ibmcloud-object-storage-plugin:1.0.0", getDeployment(t, o).Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "1.0.0", status.ProvisionerVersion)
+	progressing := condition(status, ConditionProgressing)
+	assert.Equal(t, ReasonVersionSkew, progressing.Reason)
+	assert.Contains(t, progressing.Message, "node-a, node-c")
+
+	for _, node := range []string{"node-a", "node-c"} {
+		require.NoError(t, o.Client.CoreV1().Pods(defaultNamespace).Delete(context.Background(), mounterName+"-"+node, metav1.DeleteOptions{}))
+	}
+	status = o.Reconcile(context.Background(), testPlugin("1.2.0"))
+	assert.Equal(t, "ibmcloud-object-storage-plugin:1.2.0", getDeployment(t, o).Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, ReasonUpgrading, condition(status, ConditionProgressing).Reason)
+}
+
+func Test_Reconcile_VersionSkew_NotInstalled(t *testing.T) {
+	o := &Operator{Client: fake.NewSimpleClientset(mounterPod("node-a", "0.9.0")), Logger: zap.NewNop()}
+
+	status := o.Reconcile(context.Background(), testPlugin("1.2.0"))
+	_, err := o.Client.AppsV1().Deployments(defaultNamespace).Get(context.Background(), provisionerName, metav1.GetOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, ReasonVersionSkew, condition(status, ConditionProgressing).Reason)
+	assert.Equal(t, ReasonProvisionerUnavailable, condition(status, ConditionAvailable).Reason)
+}
+
+func Test_Reconcile_StorageClasses(t *testing.T) {
+	plugin := testPlugin("1.2.0")
+	removed := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "removed", Labels: map[string]string{managedByLabel: operatorName}},
+		Provisioner: provisionerType,
+	}
+	unmanaged := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}, Provisioner: provisionerType}
+	changed := plugin.storageClasses()[0]
+	changed.Parameters = map[string]string{"ibm.io/chunk-size-mb": "52"}
+	o := &Operator{Client: fake.NewSimpleClientset(removed, unmanaged, changed), Logger: zap.NewNop()}
+
+	plugin.Spec.StorageClasses[0].Default = false
+	status := o.Reconcile(context.Background(), plugin)
+	assert.Equal(t, metav1.ConditionFalse, condition(status, ConditionDegraded).Status)
+
+	classes, err := o.Client.StorageV1().StorageClasses().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, class := range classes.Items {
+		names = append(names, class.Name)
+	}
+	assert.ElementsMatch(t, []string{"ibmc-s3fs-standard", "unmanaged"}, names)
+	class, err := o.Client.StorageV1().StorageClasses().Get(context.Background(), "ibmc-s3fs-standard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "10", class.Parameters["ibm.io/chunk-size-mb"])
+	assert.Empty(t, class.Annotations[defaultClassAnnotation])
+}
+
+func Test_Reconcile_Webhooks(t *testing.T) {
+	o := &Operator{Client: fake.NewSimpleClientset(), Logger: zap.NewNop()}
+	plugin := testPlugin("1.2.0")
+	plugin.Spec.Webhooks = []admissionregistrationv1.ValidatingWebhook{{Name: "pvc.ibm.io"}}
+
+	o.Reconcile(context.Background(), plugin)
+	webhooks, err := o.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), webhookConfigurationName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "pvc.ibm.io", webhooks.Webhooks[0].Name)
+
+	plugin.Spec.Webhooks = nil
+	o.Reconcile(context.Background(), plugin)
+	_, err = o.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), webhookConfigurationName, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func Test_Reconcile_InvalidSpec(t *testing.T) {
+	o := &Operator{Client: fake.NewSimpleClientset(), Logger: zap.NewNop()}
+
+	status := o.Reconcile(context.Background(), testPlugin("latest"))
+	degraded := condition(status, ConditionDegraded)
+	assert.Equal(t, metav1.ConditionTrue, degraded.Status)
+	assert.Equal(t, ReasonInvalidSpec, degraded.Reason)
+	_, err := o.Client.AppsV1().DaemonSets(defaultNamespace).Get(context.Background(), mounterName, metav1.GetOptions{})
+	assert.Error(t, err)
+
+	plugin := testPlugin("1.2.0")
+	plugin.Spec.StorageClasses = append(plugin.Spec.StorageClasses, plugin.Spec.StorageClasses[0])
+	status = o.Reconcile(context.Background(), plugin)
+	assert.Contains(t, condition(status, ConditionDegraded).Message, "listed twice")
+}
+
+func Test_Sync(t *testing.T) {
+	toUnstructured := func(plugin *ObjectStoragePlugin, created time.Time) *unstructured.Unstructured {
+		plugin.CreationTimestamp = metav1.NewTime(created)
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(plugin)
+		require.NoError(t, err)
+		object := &unstructured.Unstructured{Object: content}
+		object.SetAPIVersion(Group + "/" + Version)
+		object.SetKind(Kind)
+		return object
+	}
+	first := testPlugin("1.2.0")
+	second := testPlugin("1.2.0")
+	second.Name = "duplicate"
+	now := time.Now()
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{Resource: Kind + "List"},
+		toUnstructured(second, now), toUnstructured(first, now.Add(-time.Hour)))
+	o := &Operator{Client: fake.NewSimpleClientset(), Dynamic: dynamic, Logger: zap.NewNop()}
+
+	require.NoError(t, o.Sync(context.Background()))
+
+	status := func(name string) ObjectStoragePluginStatus {
+		object, err := dynamic.Resource(Resource).Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		var plugin ObjectStoragePlugin
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &plugin))
+		return plugin.Status
+	}
+	assert.Equal(t, "1.2.0", status("ibmc-s3fs").ProvisionerVersion)
+	assert.Equal(t, metav1.ConditionFalse, condition(status("ibmc-s3fs"), ConditionDegraded).Status)
+	assert.Equal(t, ReasonDuplicate, condition(status("duplicate"), ConditionDegraded).Reason)
+	getDeployment(t, o)
+}
+
+func Test_parseVersion(t *testing.T) {
+	for s, expected := range map[string]version{"1.2.3": {1, 2}, "v0.10": {0, 10}, "2.0.1-rc1": {2, 0}, "1.3-dev": {1, 3}} {
+		v, err := parseVersion(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, v, s)
+	}
+	for _, s := range []string{"", "latest", "1", "a.b.c", "1.x"} {
+		_, err := parseVersion(s)
+		assert.Error(t, err, s)
+	}
+	assert.True(t, version{1, 2}.supportsMounter(version{1, 1}))
+	assert.True(t, version{1, 2}.supportsMounter(version{1, 3}))
+	assert.False(t, version{1, 2}.supportsMounter(version{1, 0}))
+	assert.False(t, version{2, 0}.supportsMounter(version{1, 9}))
+}
+
+func Test_imageTag(t *testing.T) {
+	assert.Equal(t, "1.2.0", imageTag("icr.io/ibm/mounter:1.2.0"))
+	assert.Equal(t, "1.2.0", imageTag("registry:5000/mounter:1.2.0@sha256:abc"))
+	assert.Equal(t, "", imageTag("registry:5000/mounter"))
+	assert.Equal(t, "", imageTag("mounter"))
+}
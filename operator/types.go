@@ -0,0 +1,99 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package operator installs and upgrades the provisioner, the mounter and their storage classes
+// from a single ObjectStoragePlugin custom resource
+package operator
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// Group, Version and Kind of the ObjectStoragePlugin resource, see deploy/operator/crd.yaml
+	Group   = "ibm.io"
+	Version = "v1alpha1"
+	Kind    = "ObjectStoragePlugin"
+)
+
+// Resource is the ObjectStoragePlugin resource of the API server
+var Resource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "objectstorageplugins"}
+
+// ObjectStoragePlugin describes the plugin installed in the cluster
+type ObjectStoragePlugin struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectStoragePluginSpec   `json:"spec"`
+	Status ObjectStoragePluginStatus `json:"status,omitempty"`
+}
+
+// ObjectStoragePluginSpec is the plugin to install
+type ObjectStoragePluginSpec struct {
+	// Version is the tag of the provisioner and mounter images, of the form [v]MAJOR.MINOR[.PATCH]
+	Version string `json:"version"`
+	// ProvisionerImage and MounterImage are the repositories of the images, tagged with Version
+	ProvisionerImage string `json:"provisionerImage,omitempty"`
+	MounterImage     string `json:"mounterImage,omitempty"`
+	// Namespace runs the provisioner and the mounter, kube-system when empty
+	Namespace string `json:"namespace,omitempty"`
+	// ProvisionerArgs are added to the arguments of the provisioner
+	ProvisionerArgs []string `json:"provisionerArgs,omitempty"`
+	// StorageClasses are the storage classes of the plugin, the other ones it installed are deleted
+	StorageClasses []StorageClassSpec `json:"storageClasses,omitempty"`
+	// Webhooks are installed in a ValidatingWebhookConfiguration of the plugin
+	Webhooks []admissionregistrationv1.ValidatingWebhook `json:"webhooks,omitempty"`
+}
+
+// StorageClassSpec is a storage class of the provisioner
+type StorageClassSpec struct {
+	Name string `json:"name"`
+	// Default makes it the default storage class of the cluster
+	Default       bool                             `json:"default,omitempty"`
+	ReclaimPolicy v1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	Parameters    map[string]string                `json:"parameters,omitempty"`
+}
+
+// ObjectStoragePluginStatus is the plugin installed
+type ObjectStoragePluginStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ProvisionerVersion is the version the provisioner runs
+	ProvisionerVersion string `json:"provisionerVersion,omitempty"`
+	// MounterVersions is the number of mounter pods running each version
+	MounterVersions map[string]int32 `json:"mounterVersions,omitempty"`
+	// Conditions are the Available, Progressing and Degraded conditions of the plugin
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types of the status
+const (
+	// ConditionAvailable is true when the provisioner and every mounter pod are available
+	ConditionAvailable = "Available"
+	// ConditionProgressing is true while the provisioner or mounter pods do not run the version of the spec
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when the spec is invalid or a resource could not be installed
+	ConditionDegraded = "Degraded"
+)
+
+// Condition reasons of the status
+const (
+	ReasonAsExpected             = "AsExpected"
+	ReasonComplete               = "Complete"
+	ReasonUpgrading              = "Upgrading"
+	ReasonVersionSkew            = "VersionSkew"
+	ReasonInvalidSpec            = "InvalidSpec"
+	ReasonApplyFailed            = "ApplyFailed"
+	ReasonDuplicate              = "Duplicate"
+	ReasonProvisionerUnavailable = "ProvisionerUnavailable"
+	ReasonMounterUnavailable     = "MounterUnavailable"
+)
@@ -0,0 +1,57 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package operator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is the major and minor version of an image, the patch does not matter to the skew
+type version struct {
+	major, minor int
+}
+
+// parseVersion parses a version of the form [v]MAJOR.MINOR[.PATCH][-SUFFIX]
+func parseVersion(s string) (version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(s, "v"), ".", 3)
+	if len(parts) < 2 {
+		return version{}, fmt.Errorf("version %q is not of the form MAJOR.MINOR.PATCH", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return version{}, fmt.Errorf("version %q is not of the form MAJOR.MINOR.PATCH", s)
+	}
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil || minor < 0 {
+		return version{}, fmt.Errorf("version %q is not of the form MAJOR.MINOR.PATCH", s)
+	}
+	return version{major: major, minor: minor}, nil
+}
+
+// supportsMounter tells whether a provisioner of version v may run with a mounter of version m:
+// the mounter may be one minor version behind the provisioner, or ahead of it
+func (v version) supportsMounter(m version) bool {
+	return v.major == m.major && v.minor-m.minor <= 1
+}
+
+// imageTag returns the tag of an image, empty when it has none
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"errors"
+	"fmt"
+	"k8s.io/api/core/v1"
+)
+
+// AnnotationAccessSemantics is the annotation of the volumes describing what their access mode means for s3fs
+const AnnotationAccessSemantics = "ibm.io/access-semantics"
+
+// ConfigAllowReadWriteOncePod accepts the ReadWriteOncePod claims, for clusters enforcing the single pod access
+// themselves since kubelet does not enforce it for flex volumes
+var ConfigAllowReadWriteOncePod *bool
+
+// accessModeSemantics describes the access modes the provisioner supports
+var accessModeSemantics = map[v1.PersistentVolumeAccessMode]string{
+	v1.ReadWriteOnce: "read-write; s3fs does not keep other nodes from mounting the bucket",
+	v1.ReadWriteMany: "read-write from any number of nodes; concurrent writes to an object are last-writer-wins",
+	v1.ReadOnlyMany:  "read-only from any number of nodes; the bucket is mounted with the s3fs ro option",
+	v1.ReadWriteOncePod: "read-write by a single pod; the single pod access is enforced by the cluster, " +
+		"not by the driver",
+}
+
+// validateAccessModes returns the single access mode of a claim, or an error when it is not supported
+func validateAccessModes(modes []v1.PersistentVolumeAccessMode) (v1.PersistentVolumeAccessMode, error) {
+	if len(modes) == 0 {
+		return "", errors.New("access mode not specified")
+	}
+	if len(modes) > 1 {
+		return "", errors.New("More that one access mode is not supported.")
+	}
+	mode := modes[0]
+	if mode == v1.ReadWriteOncePod && (ConfigAllowReadWriteOncePod == nil || !*ConfigAllowReadWriteOncePod) {
+		return "", errors.New("access mode ReadWriteOncePod is not enforced for flex volumes, " +
+			"the provisioner must be started with -allowReadWriteOncePod=true to accept it")
+	}
+	if _, ok := accessModeSemantics[mode]; !ok {
+		return "", fmt.Errorf("access mode %s is not supported", mode)
+	}
+	return mode, nil
+}
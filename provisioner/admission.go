@@ -0,0 +1,118 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"strings"
+)
+
+// AdmissionWebhook is a validating admission webhook rejecting upfront the created claims of the storage classes
+// of the provisioner which Provision would refuse, instead of leaving them pending
+type AdmissionWebhook struct {
+	Provisioner *IBMS3fsProvisioner
+	// Provisioners are the names served by the provisioner, the claims of the other storage classes are admitted
+	Provisioners []string
+	Logger       *zap.Logger
+}
+
+// ServeHTTP answers an AdmissionReview of the admission.k8s.io/v1 API
+func (h *AdmissionWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "expects an AdmissionReview", http.StatusBadRequest)
+		return
+	}
+	review.Response = h.review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		h.Logger.Error("Cannot write the admission review", zap.Error(err))
+	}
+}
+
+func (h *AdmissionWebhook) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Kind != "PersistentVolumeClaim" || req.Operation != admissionv1.Create {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(req.Object.Raw, pvc); err != nil {
+		return deny(http.StatusBadRequest, metav1.StatusReasonBadRequest, "cannot decode the claim: "+err.Error())
+	}
+	if pvc.Namespace == "" {
+		pvc.Namespace = req.Namespace
+	}
+	// the default storage class is set by the admission plugins running before the webhooks, the claims without
+	// one are not dynamically provisioned
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	sc, err := h.Provisioner.Client.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// the claim stays pending until its storage class is created
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	if err != nil {
+		h.Logger.Error("Cannot get the storage class of the claim", zap.String("namespace", pvc.Namespace),
+			zap.String("name", pvc.Name), zap.Error(err))
+		return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError,
+			fmt.Sprintf("cannot get storage class %s: %v", *pvc.Spec.StorageClassName, err))
+	}
+	if !h.serves(sc.Provisioner) {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	// the claims would be provisioned without the annotations their storage class locks
+	ignored, err := h.Provisioner.claimIgnoredOverrides(pvc, sc)
+	if err != nil {
+		return deny(http.StatusForbidden, metav1.StatusReasonForbidden, err.Error())
+	}
+	if len(ignored) > 0 {
+		return deny(http.StatusForbidden, metav1.StatusReasonForbidden,
+			fmt.Sprintf("annotations %s are not allowed, storage class %s does not allow the claims to override them",
+				strings.Join(ignored, ", "), sc.Name))
+	}
+
+	if limit, ok := h.Provisioner.NamespaceQuota.Current().Limit(pvc.Namespace); ok {
+		count, allowed, err := h.Provisioner.NamespaceQuota.admits(pvc, limit)
+		if err != nil {
+			return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+		}
+		if !allowed {
+			return deny(http.StatusForbidden, metav1.StatusReasonForbidden,
+				fmt.Sprintf("namespace %s has %d object storage volumes, its quota allows %d", pvc.Namespace, count, limit))
+		}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func (h *AdmissionWebhook) serves(provisioner string) bool {
+	for _, name := range h.Provisioners {
+		if name == provisioner {
+			return true
+		}
+	}
+	return false
+}
+
+func deny(code int32, reason metav1.StatusReason, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Result: &metav1.Status{
+		Status: metav1.StatusFailure, Code: code, Reason: reason, Message: message}}
+}
@@ -0,0 +1,119 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getAdmissionWebhook(t *testing.T, pvs ...*v1.PersistentVolume) *AdmissionWebhook {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{}, pvs...)
+	for _, sc := range []*storagev1.StorageClass{
+		{ObjectMeta: metav1.ObjectMeta{Name: "cos"}, Provisioner: "ibm.io/ibmc-s3fs"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Provisioner: "other.io/other"},
+		{ObjectMeta: metav1.ObjectMeta{Name: "locked"}, Provisioner: "ibm.io/ibmc-s3fs",
+			Parameters: map[string]string{AnnotationAllowPVCOverrides: "false"}},
+	} {
+		_, err := p.Client.StorageV1().StorageClasses().Create(context.Background(), sc, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	return &AdmissionWebhook{Provisioner: p, Provisioners: []string{"ibm.io/ibmc-s3fs"}, Logger: zap.NewNop()}
+}
+
+func reviewClaim(t *testing.T, h *AdmissionWebhook, storageClass string, annotations map[string]string) *admissionv1.AdmissionResponse {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "team-a", Annotations: annotations},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &storageClass},
+	}
+	raw, err := json.Marshal(pvc)
+	require.NoError(t, err)
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "review-1",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+			Namespace: "team-a",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var review admissionv1.AdmissionReview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &review))
+	require.NotNil(t, review.Response)
+	assert.Equal(t, "review-1", string(review.Response.UID))
+	return review.Response
+}
+
+func Test_AdmissionWebhook_NamespaceQuota(t *testing.T) {
+	h := getAdmissionWebhook(t, getClaimedPV("pv-1", "team-a", v1.VolumeBound), getClaimedPV("pv-2", "team-a", v1.VolumeBound))
+	h.Provisioner.NamespaceQuota = getNamespaceQuota(t, h.Provisioner.Client, map[string]string{NamespaceQuotaDefaultKey: "2"})
+
+	resp := reviewClaim(t, h, "cos", nil)
+	assert.False(t, resp.Allowed)
+	if assert.NotNil(t, resp.Result) {
+		assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+		assert.Equal(t, "namespace team-a has 2 object storage volumes, its quota allows 2", resp.Result.Message)
+	}
+	// the other provisioners and the missing storage classes are not checked
+	assert.True(t, reviewClaim(t, h, "other", nil).Allowed)
+	assert.True(t, reviewClaim(t, h, "missing", nil).Allowed)
+}
+
+func Test_AdmissionWebhook_IgnoredOverrides(t *testing.T) {
+	h := getAdmissionWebhook(t)
+	annotations := map[string]string{"ibm.io/bucket": "bucket-1", "ibm.io/parallel-count": "20"}
+
+	resp := reviewClaim(t, h, "locked", annotations)
+	assert.False(t, resp.Allowed)
+	if assert.NotNil(t, resp.Result) {
+		assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+		assert.Equal(t, "annotations ibm.io/parallel-count are not allowed, storage class locked does not allow the claims to override them",
+			resp.Result.Message)
+	}
+	assert.True(t, reviewClaim(t, h, "locked", map[string]string{"ibm.io/bucket": "bucket-1"}).Allowed)
+	assert.True(t, reviewClaim(t, h, "cos", annotations).Allowed)
+}
+
+func Test_AdmissionWebhook_Positive(t *testing.T) {
+	h := getAdmissionWebhook(t, getClaimedPV("pv-1", "team-a", v1.VolumeBound))
+	h.Provisioner.NamespaceQuota = getNamespaceQuota(t, h.Provisioner.Client, map[string]string{NamespaceQuotaDefaultKey: "2"})
+	assert.True(t, reviewClaim(t, h, "cos", nil).Allowed)
+
+	// no quota
+	h.Provisioner.NamespaceQuota = nil
+	assert.True(t, reviewClaim(t, h, "cos", nil).Allowed)
+}
+
+func Test_AdmissionWebhook_BadRequest(t *testing.T) {
+	h := getAdmissionWebhook(t)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
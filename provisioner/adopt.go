@@ -0,0 +1,149 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeAdoptedReason is the reason of the events recorded on the volumes bound to a new claim by Adopt
+const VolumeAdoptedReason = "VolumeAdopted"
+
+// Adoption is the binding of a released volume to a new claim
+type Adoption struct {
+	// PV is the name of the volume, Released with the Retain reclaim policy
+	PV string
+	// Namespace and Claim name the claim, created when it does not exist
+	Namespace string
+	Claim     string
+	// DryRun checks the volume, its credentials and its bucket without changing anything
+	DryRun bool
+}
+
+// Adopt binds a volume released with the Retain reclaim policy, and its bucket, to a new claim. The credentials
+// of the secret of the volume and the access to its bucket are checked again first, the secret must allow the
+// namespace of the claim. The claim is created when missing, or must be pending and not bound to another volume.
+func (p *IBMS3fsProvisioner) Adopt(ctx context.Context, adoption Adoption) (*v1.PersistentVolumeClaim, error) {
+	pv, err := p.Client.CoreV1().PersistentVolumes().Get(ctx, adoption.PV, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get PV %s: %v", adoption.PV, err)
+	}
+	flex := pv.Spec.FlexVolume
+	if flex == nil || flex.Driver != driverName {
+		return nil, fmt.Errorf("PV %s is not a volume of %s", pv.Name, driverName)
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimRetain || pv.Status.Phase != v1.VolumeReleased {
+		return nil, fmt.Errorf("PV %s is %s with the %s reclaim policy, expects Released with Retain", pv.Name,
+			pv.Status.Phase, pv.Spec.PersistentVolumeReclaimPolicy)
+	}
+	if flex.SecretRef == nil {
+		return nil, fmt.Errorf("PV %s has no secret to check the access to bucket %s with", pv.Name, flex.Options["bucket"])
+	}
+
+	secret, err := p.Client.CoreV1().Secrets(flex.SecretRef.Namespace).Get(ctx, flex.SecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve secret %s: %v", flex.SecretRef.Name, err)
+	}
+	creds, allowedNamespace, _, err := credentialsFromSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get credentials: %v", err)
+	}
+	if len(allowedNamespace) > 0 && !containsString(allowedNamespace, adoption.Namespace) {
+		return nil, fmt.Errorf("secret %s does not allow the PVCs of namespace %s", secret.Name, adoption.Namespace)
+	}
+	sess, err := p.flexSession(flex, creds)
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.CheckAccess(ctx, sess, flex.Options["bucket-access-check"], flex.Options["bucket"],
+		flex.Options["object-path"]); err != nil {
+		return nil, fmt.Errorf("cannot access bucket %s: %v", flex.Options["bucket"], err)
+	}
+
+	claims := p.Client.CoreV1().PersistentVolumeClaims(adoption.Namespace)
+	pvc, err := claims.Get(ctx, adoption.Claim, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		pvc = &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: adoption.Claim, Namespace: adoption.Namespace},
+			Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes:      pv.Spec.AccessModes,
+				Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: pv.Spec.Capacity[v1.ResourceStorage]}},
+				StorageClassName: &pv.Spec.StorageClassName,
+				VolumeName:       pv.Name,
+			},
+		}
+		if !adoption.DryRun {
+			if pvc, err = claims.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+				return nil, fmt.Errorf("cannot create PVC %s/%s: %v", adoption.Namespace, adoption.Claim, err)
+			}
+		}
+	case err != nil:
+		return nil, fmt.Errorf("cannot get PVC %s/%s: %v", adoption.Namespace, adoption.Claim, err)
+	case pvc.Status.Phase != v1.ClaimPending || (pvc.Spec.VolumeName != "" && pvc.Spec.VolumeName != pv.Name):
+		return nil, fmt.Errorf("PVC %s/%s is %s with volume %q, expects a pending PVC", pvc.Namespace, pvc.Name,
+			pvc.Status.Phase, pvc.Spec.VolumeName)
+	case pvc.Spec.VolumeName == "" && !adoption.DryRun:
+		pvc.Spec.VolumeName = pv.Name
+		if pvc, err = claims.Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("cannot update PVC %s/%s: %v", adoption.Namespace, adoption.Claim, err)
+		}
+	}
+	if adoption.DryRun {
+		return pvc, nil
+	}
+
+	// the claim reference of the released claim is replaced, the volume binds to the new claim
+	previous := pv.Spec.ClaimRef
+	pv.Spec.ClaimRef = &v1.ObjectReference{Kind: "PersistentVolumeClaim", APIVersion: "v1", Namespace: pvc.Namespace,
+		Name: pvc.Name, UID: pvc.UID, ResourceVersion: pvc.ResourceVersion}
+	if _, err := p.Client.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("cannot bind PV %s to PVC %s/%s: %v", pv.Name, pvc.Namespace, pvc.Name, err)
+	}
+	message := fmt.Sprintf("bound to PVC %s/%s", pvc.Namespace, pvc.Name)
+	if previous != nil {
+		message += fmt.Sprintf(", released by PVC %s/%s", previous.Namespace, previous.Name)
+	}
+	ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+	if err := createEvent(ctx, p.Client, driverName, ref, v1.EventTypeNormal, VolumeAdoptedReason, message); err != nil {
+		p.Logger.Error("Cannot record the adoption event", zap.String("name", pv.Name), zap.Error(err))
+	}
+	return pvc, nil
+}
+
+// flexSession opens a session on the object storage of the options of a volume with the credentials of its secret
+func (p *IBMS3fsProvisioner) flexSession(flex *v1.FlexPersistentVolumeSource, creds *backend.ObjectStorageCredentials) (backend.ObjectStorageSession, error) {
+	factory, err := p.sessionFactory(flex.Options["backend"])
+	if err != nil {
+		return nil, err
+	}
+	// the credentials may be shared by several volumes, the IAM endpoint of each one is set on a copy
+	volumeCreds := *creds
+	volumeCreds.IAMEndpoint = flex.Options["iam-endpoint"]
+	return factory.NewObjectStorageSession(flex.Options["object-store-endpoint"], flex.Options["object-store-storage-class"],
+		&volumeCreds, backend.TransportConfig{}, p.Logger), nil
+}
+
+// containsString tells whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+const testAdoptedPV = "pv-retained"
+
+// getReleasedVolume provisions a volume and stores it released by its claim
+func getReleasedVolume(t *testing.T, factory *fake.ObjectStorageSessionFactory) *IBMS3fsProvisioner {
+	p := getCustomProvisioner(&clientGoConfig{}, factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{}, uuid.NewCryptoGenerator())
+	v := getVolumeOptions()
+	v.PVName = testAdoptedPV
+	v.PVC.Annotations[annotationBucket] = testBucket
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	pv.Spec.ClaimRef = &v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: testNamespace, Name: "old-claim", UID: "old-uid"}
+	pv.Status.Phase = v1.VolumeReleased
+	_, err = p.Client.CoreV1().PersistentVolumes().Create(context.Background(), pv, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return p
+}
+
+func Test_Adopt_NewClaim(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getReleasedVolume(t, factory)
+
+	pvc, err := p.Adopt(context.Background(), Adoption{PV: testAdoptedPV, Namespace: testNamespace, Claim: "new-claim"})
+	require.NoError(t, err)
+	assert.Equal(t, testAdoptedPV, pvc.Spec.VolumeName)
+	assert.Equal(t, testBucket, factory.LastCheckedBucket)
+
+	pv, _ := p.Client.CoreV1().PersistentVolumes().Get(context.Background(), testAdoptedPV, metav1.GetOptions{})
+	assert.Equal(t, "new-claim", pv.Spec.ClaimRef.Name)
+	assert.Equal(t, pvc.UID, pv.Spec.ClaimRef.UID)
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, VolumeAdoptedReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "released by PVC "+testNamespace+"/old-claim")
+	}
+}
+
+func Test_Adopt_DryRun(t *testing.T) {
+	p := getReleasedVolume(t, &fake.ObjectStorageSessionFactory{})
+
+	_, err := p.Adopt(context.Background(), Adoption{PV: testAdoptedPV, Namespace: testNamespace, Claim: "new-claim", DryRun: true})
+	require.NoError(t, err)
+	_, err = p.Client.CoreV1().PersistentVolumeClaims(testNamespace).Get(context.Background(), "new-claim", metav1.GetOptions{})
+	assert.Error(t, err)
+	pv, _ := p.Client.CoreV1().PersistentVolumes().Get(context.Background(), testAdoptedPV, metav1.GetOptions{})
+	assert.Equal(t, "old-claim", pv.Spec.ClaimRef.Name)
+}
+
+func Test_Adopt_Errors(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getReleasedVolume(t, factory)
+
+	factory.FailCheckBucketAccess = true
+	_, err := p.Adopt(context.Background(), Adoption{PV: testAdoptedPV, Namespace: testNamespace, Claim: "new-claim"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot access bucket "+testBucket)
+	}
+	factory.FailCheckBucketAccess = false
+
+	bound := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "bound-claim", Namespace: testNamespace},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "other-pv"},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}
+	_, err = p.Client.CoreV1().PersistentVolumeClaims(testNamespace).Create(context.Background(), bound, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = p.Adopt(context.Background(), Adoption{PV: testAdoptedPV, Namespace: testNamespace, Claim: "bound-claim"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects a pending PVC")
+	}
+
+	pv, _ := p.Client.CoreV1().PersistentVolumes().Get(context.Background(), testAdoptedPV, metav1.GetOptions{})
+	pv.Status.Phase = v1.VolumeBound
+	_, err = p.Client.CoreV1().PersistentVolumes().Update(context.Background(), pv, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = p.Adopt(context.Background(), Adoption{PV: testAdoptedPV, Namespace: testNamespace, Claim: "new-claim"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects Released with Retain")
+	}
+}
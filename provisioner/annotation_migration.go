@@ -0,0 +1,239 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeprecatedAnnotations maps the deprecated annotations of the claims, recorded on their volumes,
+// to their replacement
+var DeprecatedAnnotations = map[string]string{
+	"ibm.io/endpoint": "ibm.io/object-store-endpoint",
+	"ibm.io/region":   "ibm.io/object-store-storage-class",
+}
+
+// AnnotationMigratedReason is the reason of the events recorded on the migrated claims and volumes
+const AnnotationMigratedReason = "AnnotationMigrated"
+
+// DeprecatedAnnotationReason is the reason of the warning events recorded on the claims provisioned
+// with a deprecated annotation
+const DeprecatedAnnotationReason = "DeprecatedAnnotation"
+
+var deprecatedAnnotationUsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ibmc_s3fs_deprecated_annotation_total",
+	Help: "Claims provisioned with a deprecated annotation",
+}, []string{"annotation"})
+
+func init() {
+	prometheus.MustRegister(deprecatedAnnotationUsed)
+}
+
+// Migration is the rewriting of the deprecated annotations of a claim or volume
+type Migration struct {
+	// Kind is PersistentVolumeClaim or PersistentVolume
+	Kind      string
+	Namespace string
+	Name      string
+	// Changes describe the annotations rewritten
+	Changes []string
+}
+
+// AnnotationMigrator rewrites the deprecated annotations of the claims of the storage classes of
+// the provisioner and of their volumes, and records an event describing each migration
+type AnnotationMigrator struct {
+	Client kubernetes.Interface
+	// Provisioner is the name of the provisioner of the storage classes whose claims are migrated
+	Provisioner string
+	Logger      *zap.Logger
+}
+
+// Run migrates the claims and volumes every period until ctx is done
+func (m *AnnotationMigrator) Run(ctx context.Context, period time.Duration) {
+	for {
+		migrations, err := m.Migrate(ctx)
+		if err != nil {
+			m.Logger.Error("Cannot migrate the deprecated annotations", zap.Error(err))
+		}
+		if len(migrations) > 0 {
+			m.Logger.Info("Migrated the deprecated annotations", zap.Int("objects", len(migrations)))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(period):
+		}
+	}
+}
+
+// Migrate rewrites the deprecated annotations of the claims and volumes once and returns the
+// migrations done. A claim or volume that cannot be updated is retried by the next migration.
+func (m *AnnotationMigrator) Migrate(ctx context.Context) ([]Migration, error) {
+	classes := map[string]bool{}
+	scs, err := m.Client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list storage classes: %v", err)
+	}
+	for _, sc := range scs.Items {
+		if sc.Provisioner == m.Provisioner {
+			classes[sc.Name] = true
+		}
+	}
+
+	var migrations []Migration
+	var errs []string
+	pvcs, err := m.Client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list persistent volume claims: %v", err)
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if !classes[claimClass(pvc)] {
+			continue
+		}
+		changes := migrateAnnotations(pvc.Annotations)
+		if len(changes) == 0 {
+			continue
+		}
+		if _, err := m.Client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Sprintf("PVC %s/%s: %v", pvc.Namespace, pvc.Name, err))
+			continue
+		}
+		migration := Migration{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, Changes: changes}
+		m.recordEvent(ctx, migration, pvc.UID)
+		migrations = append(migrations, migration)
+	}
+
+	pvs, err := m.Client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return migrations, fmt.Errorf("cannot list persistent volumes: %v", err)
+	}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Spec.FlexVolume == nil || pv.Spec.FlexVolume.Driver != driverName {
+			continue
+		}
+		changes := migrateAnnotations(pv.Annotations)
+		if len(changes) == 0 {
+			continue
+		}
+		if _, err := m.Client.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Sprintf("PV %s: %v", pv.Name, err))
+			continue
+		}
+		migration := Migration{Kind: "PersistentVolume", Name: pv.Name, Changes: changes}
+		m.recordEvent(ctx, migration, pv.UID)
+		migrations = append(migrations, migration)
+	}
+
+	if len(errs) > 0 {
+		return migrations, fmt.Errorf("cannot migrate: %s", strings.Join(errs, "; "))
+	}
+	return migrations, nil
+}
+
+// claimClass returns the storage class of a claim, set by the field or the beta annotation
+func claimClass(pvc *v1.PersistentVolumeClaim) string {
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName
+	}
+	return pvc.Annotations[v1.BetaStorageClassAnnotation]
+}
+
+// migrateAnnotations rewrites the deprecated annotations in place and describes the changes.
+// When the replacement is already set it takes precedence, as it does for the provisioner,
+// and the deprecated annotation is removed.
+func migrateAnnotations(annotations map[string]string) []string {
+	var changes []string
+	for _, key := range deprecatedAnnotationsOf(annotations) {
+		value, replacement := annotations[key], DeprecatedAnnotations[key]
+		if current, ok := annotations[replacement]; ok && current != value {
+			changes = append(changes, fmt.Sprintf("removed deprecated annotation %s=%q, overridden by %s=%q",
+				key, value, replacement, current))
+		} else {
+			annotations[replacement] = value
+			changes = append(changes, fmt.Sprintf("replaced deprecated annotation %s=%q by %s", key, value, replacement))
+		}
+		delete(annotations, key)
+	}
+	return changes
+}
+
+// recordEvent records a migration on the claim or volume, the events of the volumes are in the default namespace
+func (m *AnnotationMigrator) recordEvent(ctx context.Context, migration Migration, uid types.UID) {
+	m.Logger.Info("Migrated deprecated annotations", zap.String("kind", migration.Kind),
+		zap.String("namespace", migration.Namespace), zap.String("name", migration.Name),
+		zap.Strings("changes", migration.Changes))
+	ref := v1.ObjectReference{Kind: migration.Kind, Namespace: migration.Namespace, Name: migration.Name, UID: uid}
+	if err := createEvent(ctx, m.Client, m.Provisioner, ref, v1.EventTypeNormal, AnnotationMigratedReason,
+		strings.Join(migration.Changes, "; ")); err != nil {
+		m.Logger.Error("Cannot record the migration event", zap.String("name", migration.Name), zap.Error(err))
+	}
+}
+
+// deprecatedAnnotationsOf returns the deprecated annotations set, sorted
+func deprecatedAnnotationsOf(annotations map[string]string) []string {
+	var deprecated []string
+	for key := range DeprecatedAnnotations {
+		if _, ok := annotations[key]; ok {
+			deprecated = append(deprecated, key)
+		}
+	}
+	sort.Strings(deprecated)
+	return deprecated
+}
+
+// warnDeprecatedAnnotations counts the deprecated annotations of a claim being provisioned and records a
+// warning event on the claim
+func (p *IBMS3fsProvisioner) warnDeprecatedAnnotations(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string, deprecated []string) {
+	var uses []string
+	for _, key := range deprecated {
+		deprecatedAnnotationUsed.WithLabelValues(key).Inc()
+		uses = append(uses, fmt.Sprintf("annotation %s is deprecated, use %s", key, DeprecatedAnnotations[key]))
+	}
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, DeprecatedAnnotationReason,
+		strings.Join(uses, "; ")); err != nil {
+		p.Logger.Error("Cannot record the deprecated annotation event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+}
+
+// createEvent records an event on an object, the events of the cluster scoped objects are in the default namespace
+func createEvent(ctx context.Context, client kubernetes.Interface, component string, ref v1.ObjectReference, eventType, reason, message string) error {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{GenerateName: ref.Name + ".", Namespace: namespace},
+		InvolvedObject: ref,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: component},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
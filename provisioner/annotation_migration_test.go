@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func getMigrationClient() *fakeclient.Clientset {
+	className, otherClass := "ibmc-s3fs-standard", "other"
+	return fakeclient.NewSimpleClientset(
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: className}, Provisioner: "ibm.io/ibmc-s3fs"},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: otherClass}, Provisioner: "other.io/other"},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-s3fs", Namespace: "ns1", Annotations: map[string]string{
+				annotationEndpoint: "https://endpoint",
+				annotationRegion:   "us-standard",
+				annotationBucket:   "bucket",
+			}},
+			Spec: v1.PersistentVolumeClaimSpec{StorageClassName: &className},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-beta", Namespace: "ns2", Annotations: map[string]string{
+				v1.BetaStorageClassAnnotation: className,
+				annotationRegion:              "us-standard",
+				annotationOSStorageClass:      "us-south-standard",
+			}},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-other", Namespace: "ns1",
+				Annotations: map[string]string{annotationRegion: "us-standard"}},
+			Spec: v1.PersistentVolumeClaimSpec{StorageClassName: &otherClass},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-migrated", Namespace: "ns1",
+				Annotations: map[string]string{annotationOSEndpoint: "https://endpoint"}},
+			Spec: v1.PersistentVolumeClaimSpec{StorageClassName: &className},
+		},
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-s3fs", Annotations: map[string]string{annotationEndpoint: "https://endpoint"}},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{Driver: driverName},
+			}},
+		},
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-other", Annotations: map[string]string{annotationEndpoint: "https://endpoint"}},
+		},
+	)
+}
+
+func Test_AnnotationMigrator_Positive(t *testing.T) {
+	client := getMigrationClient()
+	m := &AnnotationMigrator{Client: client, Provisioner: "ibm.io/ibmc-s3fs", Logger: zap.NewNop()}
+
+	migrations, err := m.Migrate(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 3) {
+		assert.Equal(t, "pvc-s3fs", migrations[0].Name)
+		assert.Equal(t, []string{
+			`replaced deprecated annotation ibm.io/endpoint="https://endpoint" by ibm.io/object-store-endpoint`,
+			`replaced deprecated annotation ibm.io/region="us-standard" by ibm.io/object-store-storage-class`,
+		}, migrations[0].Changes)
+		assert.Equal(t, "pvc-beta", migrations[1].Name)
+		assert.Equal(t, "PersistentVolume", migrations[2].Kind)
+		assert.Equal(t, "pv-s3fs", migrations[2].Name)
+	}
+
+	pvc, _ := client.CoreV1().PersistentVolumeClaims("ns1").Get(context.Background(), "pvc-s3fs", metav1.GetOptions{})
+	assert.Equal(t, map[string]string{
+		annotationOSEndpoint:     "https://endpoint",
+		annotationOSStorageClass: "us-standard",
+		annotationBucket:         "bucket",
+	}, pvc.Annotations)
+	// the replacement set on the claim takes precedence
+	pvc, _ = client.CoreV1().PersistentVolumeClaims("ns2").Get(context.Background(), "pvc-beta", metav1.GetOptions{})
+	assert.Equal(t, "us-south-standard", pvc.Annotations[annotationOSStorageClass])
+	assert.NotContains(t, pvc.Annotations, annotationRegion)
+	pvc, _ = client.CoreV1().PersistentVolumeClaims("ns1").Get(context.Background(), "pvc-other", metav1.GetOptions{})
+	assert.Contains(t, pvc.Annotations, annotationRegion)
+	pv, _ := client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-s3fs", metav1.GetOptions{})
+	assert.Equal(t, map[string]string{annotationOSEndpoint: "https://endpoint"}, pv.Annotations)
+	pv, _ = client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-other", metav1.GetOptions{})
+	assert.Contains(t, pv.Annotations, annotationEndpoint)
+
+	events, _ := client.CoreV1().Events("ns1").List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, AnnotationMigratedReason, events.Items[0].Reason)
+		assert.Equal(t, "pvc-s3fs", events.Items[0].InvolvedObject.Name)
+		assert.Contains(t, events.Items[0].Message, "ibm.io/object-store-storage-class")
+	}
+	events, _ = client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, "PersistentVolume", events.Items[0].InvolvedObject.Kind)
+	}
+
+	// migrating again has nothing to do
+	migrations, err = m.Migrate(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, migrations)
+}
+
+func Test_Provision_DeprecatedAnnotations_Warning(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationEndpoint] = "https://test-endpoint"
+	before := testutil.ToFloat64(deprecatedAnnotationUsed.WithLabelValues(annotationEndpoint))
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(deprecatedAnnotationUsed.WithLabelValues(annotationEndpoint)))
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+		assert.Equal(t, DeprecatedAnnotationReason, events.Items[0].Reason)
+		assert.Equal(t, v.PVC.Name, events.Items[0].InvolvedObject.Name)
+		assert.Contains(t, events.Items[0].Message, "annotation ibm.io/endpoint is deprecated, use ibm.io/object-store-endpoint")
+	}
+}
+
+func Test_Provision_DeprecatedAnnotations_Rejected(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationRegion] = "us-standard"
+	reject := true
+	ConfigRejectDeprecatedAnnotations = &reject
+	defer func() { ConfigRejectDeprecatedAnnotations = nil }()
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "deprecated annotation ibm.io/region is rejected by the provisioner configuration")
+	}
+}
@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AnnotationChunkSize sets chunk-size-mb with a quantity, e.g. 64Mi, and wins over it
+const AnnotationChunkSize = "ibm.io/chunk-size"
+
+// sizeAnnotations are the size annotations and parameters accepting quantities, with the number of bytes
+// of the unit of their integer value, 1 for counts
+var sizeAnnotations = map[string]int64{
+	"ibm.io/chunk-size-mb":            1 << 20,
+	"ibm.io/tmpfs-cache-size-mb":      1 << 20,
+	"ibm.io/multipart-size-mb":        1 << 20,
+	"ibm.io/singlepart-copy-limit-mb": 1 << 20,
+	"ibm.io/max-dirty-data-mb":        1 << 20,
+	"ibm.io/read-ahead-kb":            1 << 10,
+	"ibm.io/stat-cache-size":          1,
+}
+
+// convertSizes replaces the quantities of the size annotations of m by the integers s3fs expects.
+// The invalid values are reported and dropped.
+func convertSizes(m map[string]string) []error {
+	if m == nil {
+		return nil
+	}
+	if value, ok := m[AnnotationChunkSize]; ok {
+		if value != "" {
+			m["ibm.io/chunk-size-mb"] = value
+		}
+		delete(m, AnnotationChunkSize)
+	}
+	keys := make([]string, 0, len(sizeAnnotations))
+	for key := range sizeAnnotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		value := m[key]
+		if value == "" {
+			continue
+		}
+		size, err := parser.ParseSize(strings.TrimSpace(value), sizeAnnotations[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of %s into integer: %v",
+				strings.TrimPrefix(key, "ibm.io/"), err))
+			delete(m, key)
+			continue
+		}
+		m[key] = strconv.Itoa(size)
+	}
+	return errs
+}
@@ -0,0 +1,73 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_convertSizes(t *testing.T) {
+	m := map[string]string{
+		AnnotationChunkSize:          "64Mi",
+		"ibm.io/chunk-size-mb":       "10",
+		"ibm.io/read-ahead-kb":       "1Mi",
+		"ibm.io/stat-cache-size":     "100k",
+		"ibm.io/tmpfs-cache-size-mb": "128",
+		"ibm.io/max-dirty-data-mb":   "-1",
+	}
+	errs := convertSizes(m)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]string{
+		"ibm.io/chunk-size-mb":       "64",
+		"ibm.io/read-ahead-kb":       "1024",
+		"ibm.io/stat-cache-size":     "100000",
+		"ibm.io/tmpfs-cache-size-mb": "128",
+		"ibm.io/max-dirty-data-mb":   "-1",
+	}, m)
+}
+
+func Test_Provision_Sizes_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationChunkSize] = "32Mi"
+	v.StorageClass.Parameters["ibm.io/stat-cache-size"] = "50k"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "32", pv.Spec.FlexVolume.Options[optionChunkSizeMB])
+	assert.Equal(t, "50000", pv.Spec.FlexVolume.Options[optionStatCacheSize])
+}
+
+func Test_Provision_Sizes_Error(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations["ibm.io/chunk-size-mb"] = "64M"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(),
+			"Cannot convert value of chunk-size-mb into integer: invalid size \"64M\", expects a whole multiple of 1Mi")
+	}
+}
+
+func Test_Provision_Sizes_Caps(t *testing.T) {
+	p := getProvisioner()
+	p.GlobalDefaults = getGlobalDefaults(t, map[string]string{GlobalCapsKey: "ibm.io/chunk-size-mb: 32"})
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationChunkSize] = "1Gi"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of ibm.io/chunk-size-mb 1024 exceeds the cluster cap 32")
+	}
+}
@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UnknownAnnotationReason is the reason of the warning events recorded on the claims provisioned
+// with an ibm.io annotation the provisioner does not read
+const UnknownAnnotationReason = "UnknownAnnotation"
+
+// knownAnnotations are the ibm.io annotations of the claims read by the provisioner
+var knownAnnotations = func() map[string]bool {
+	known := map[string]bool{AnnotationChunkSize: true, AnnotationRequestedBy: true,
+		AnnotationProvisioningFailures: true, AnnotationProvisioningRetryAfter: true,
+		AnnotationBackupBucket: true, AnnotationBackupInterval: true, AnnotationBackupPrefix: true}
+	for key := range DeprecatedAnnotations {
+		known[key] = true
+	}
+	t := reflect.TypeOf(pvcAnnotations{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" {
+			known[name] = true
+		}
+	}
+	return known
+}()
+
+// UnknownAnnotations describes the ibm.io annotations of a claim the provisioner does not read, sorted,
+// with the closest known annotation when it looks like a typo
+func UnknownAnnotations(annotations map[string]string) []string {
+	var unknown []string
+	for key := range annotations {
+		if !strings.HasPrefix(key, "ibm.io/") || knownAnnotations[key] {
+			continue
+		}
+		message := fmt.Sprintf("annotation %s is unknown and ignored", key)
+		if suggestion := closestAnnotation(key); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+		unknown = append(unknown, message)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// closestAnnotation returns the known annotation at most 2 edits away from key, empty when there is none
+func closestAnnotation(key string) string {
+	closest, closestDistance := "", 3
+	for known := range knownAnnotations {
+		if d := editDistance(key, known); d < closestDistance || (d == closestDistance && known < closest) {
+			closest, closestDistance = known, d
+		}
+	}
+	return closest
+}
+
+// editDistance is the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// warnUnknownAnnotations records a warning event on a claim being provisioned with unknown annotations
+func (p *IBMS3fsProvisioner) warnUnknownAnnotations(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string, unknown []string) {
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, UnknownAnnotationReason,
+		strings.Join(unknown, "; ")); err != nil {
+		p.Logger.Error("Cannot record the unknown annotation event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+}
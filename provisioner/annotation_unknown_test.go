@@ -0,0 +1,54 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_UnknownAnnotations(t *testing.T) {
+	unknown := UnknownAnnotations(map[string]string{
+		"ibm.io/parallel-counts": "5",
+		"ibm.io/something-else":  "x",
+		annotationBucket:         "bucket",
+		AnnotationChunkSize:      "64Mi",
+		annotationEndpoint:       "https://endpoint",
+		"example.com/other":      "x",
+	})
+	assert.Equal(t, []string{
+		"annotation ibm.io/parallel-counts is unknown and ignored, did you mean ibm.io/parallel-count?",
+		"annotation ibm.io/something-else is unknown and ignored",
+	}, unknown)
+}
+
+func Test_Provision_UnknownAnnotations(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	assert.Empty(t, events.Items)
+
+	v.PVC.Annotations["ibm.io/parallel-counts"] = "10"
+	_, _, err = p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	events, _ = p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+		assert.Equal(t, UnknownAnnotationReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "did you mean ibm.io/parallel-count?")
+	}
+}
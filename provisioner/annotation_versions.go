@@ -0,0 +1,107 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnnotationAPIVersion is the annotation of the claims and the parameter of the storage classes
+// naming the version of the schema of their annotations or parameters
+const AnnotationAPIVersion = "ibm.io/api-version"
+
+// Versions of the annotation schema
+const (
+	// AnnotationsV1 is the schema of the claims and storage classes without api-version,
+	// with the deprecated ibm.io/endpoint and ibm.io/region
+	AnnotationsV1 = "v1"
+	// AnnotationsV2 replaces ibm.io/endpoint and ibm.io/region by ibm.io/object-store-endpoint
+	// and ibm.io/object-store-storage-class
+	AnnotationsV2 = "v2"
+)
+
+// AnnotationAPIVersions are the supported versions of the annotation schema, oldest first.
+// The provisioner converts the annotations to the last one.
+var AnnotationAPIVersions = []string{AnnotationsV1, AnnotationsV2}
+
+// annotationConversion converts the annotations of a version of the schema to the next one
+type annotationConversion struct {
+	// renamed maps the annotations of the version to their name in the next version,
+	// a value already set with the new name takes precedence
+	renamed map[string]string
+}
+
+// annotationConversions holds the conversion of each version of AnnotationAPIVersions but the last
+var annotationConversions = map[string]annotationConversion{
+	AnnotationsV1: {renamed: DeprecatedAnnotations},
+}
+
+// latestAnnotationAPIVersion is the version of the schema read by the provisioner
+func latestAnnotationAPIVersion() string {
+	return AnnotationAPIVersions[len(AnnotationAPIVersions)-1]
+}
+
+// convertAnnotations returns a copy of the annotations, or parameters, converted from the version set by
+// their api-version, v1 when unset, to the latest version. The annotations renamed by an older version
+// than theirs are left out and reported instead of being silently ignored.
+func convertAnnotations(annotations map[string]string) (map[string]string, error) {
+	version := annotations[AnnotationAPIVersion]
+	if version == "" {
+		version = AnnotationsV1
+	}
+	index := -1
+	for i, v := range AnnotationAPIVersions {
+		if v == version {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("unknown %s %q, expects one of %s", AnnotationAPIVersion, version,
+			strings.Join(AnnotationAPIVersions, ", "))
+	}
+
+	converted := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		converted[key] = value
+	}
+
+	var invalid []string
+	for _, older := range AnnotationAPIVersions[:index] {
+		for key, replacement := range annotationConversions[older].renamed {
+			if _, ok := converted[key]; ok {
+				invalid = append(invalid, fmt.Sprintf("annotation %s is not part of %s %s, use %s",
+					key, AnnotationAPIVersion, version, replacement))
+				delete(converted, key)
+			}
+		}
+	}
+
+	for _, v := range AnnotationAPIVersions[index : len(AnnotationAPIVersions)-1] {
+		for key, replacement := range annotationConversions[v].renamed {
+			value, ok := converted[key]
+			if !ok {
+				continue
+			}
+			if _, set := converted[replacement]; !set {
+				converted[replacement] = value
+			}
+			delete(converted, key)
+		}
+	}
+	converted[AnnotationAPIVersion] = latestAnnotationAPIVersion()
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return converted, fmt.Errorf("%s", strings.Join(invalid, "; "))
+	}
+	return converted, nil
+}
@@ -0,0 +1,78 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_convertAnnotations_V1(t *testing.T) {
+	annotations := map[string]string{
+		annotationEndpoint:       "https://endpoint",
+		annotationRegion:         "us-standard",
+		annotationOSStorageClass: "us-south-standard",
+		annotationBucket:         "bucket",
+	}
+	converted, err := convertAnnotations(annotations)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		AnnotationAPIVersion:     AnnotationsV2,
+		annotationOSEndpoint:     "https://endpoint",
+		annotationOSStorageClass: "us-south-standard",
+		annotationBucket:         "bucket",
+	}, converted)
+	// the annotations of the claim are left as they are
+	assert.Contains(t, annotations, annotationEndpoint)
+}
+
+func Test_convertAnnotations_V2(t *testing.T) {
+	converted, err := convertAnnotations(map[string]string{
+		AnnotationAPIVersion: AnnotationsV2,
+		annotationEndpoint:   "https://endpoint",
+		annotationBucket:     "bucket",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "annotation ibm.io/endpoint is not part of ibm.io/api-version v2, use ibm.io/object-store-endpoint")
+	}
+	assert.Equal(t, map[string]string{AnnotationAPIVersion: AnnotationsV2, annotationBucket: "bucket"}, converted)
+}
+
+func Test_convertAnnotations_UnknownVersion(t *testing.T) {
+	converted, err := convertAnnotations(map[string]string{AnnotationAPIVersion: "v9"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown ibm.io/api-version \"v9\", expects one of v1, v2")
+	}
+	assert.Nil(t, converted)
+}
+
+func Test_Provision_AnnotationAPIVersion_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationAPIVersion] = AnnotationsV2
+	v.StorageClass.Parameters[AnnotationAPIVersion] = AnnotationsV1
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, AnnotationsV2, pv.Annotations[AnnotationAPIVersion])
+}
+
+func Test_Provision_AnnotationAPIVersion_Unknown(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationAPIVersion] = "v9"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot convert PVC annotations: unknown ibm.io/api-version \"v9\"")
+	}
+}
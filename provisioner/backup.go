@@ -0,0 +1,216 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+// Annotations of the claims, copied to their volumes, scheduling the backup of the bucket of the volume
+const (
+	// AnnotationBackupBucket is the bucket the objects of the volume are copied to, with the credentials of its secret
+	AnnotationBackupBucket = "ibm.io/backup-bucket"
+	// AnnotationBackupInterval is the time between two backups, a duration of at least MinBackupInterval
+	AnnotationBackupInterval = "ibm.io/backup-interval"
+	// AnnotationBackupPrefix is the prefix of the copies inside the backup bucket, "<pv name>/" by default
+	AnnotationBackupPrefix = "ibm.io/backup-prefix"
+)
+
+// Annotations of the volumes recording the status of their backups
+const (
+	// AnnotationBackupLastAttempt is the RFC 3339 time the last backup started
+	AnnotationBackupLastAttempt = "ibm.io/backup-last-attempt"
+	// AnnotationBackupLastSuccess is the RFC 3339 time the last successful backup started
+	AnnotationBackupLastSuccess = "ibm.io/backup-last-success"
+	// AnnotationBackupStatus describes the result of the last backup
+	AnnotationBackupStatus = "ibm.io/backup-status"
+)
+
+// MinBackupInterval is the shortest backup-interval, a backup lists all the objects of both buckets
+const MinBackupInterval = time.Minute
+
+// BackupFailedReason is the reason of the warning events recorded on the volumes whose backup failed
+const BackupFailedReason = "BackupFailed"
+
+// BackupObjectsSkippedReason is the reason of the warning events recorded on the volumes whose backup skipped the
+// objects too large to be copied server-side
+const BackupObjectsSkippedReason = "BackupObjectsSkipped"
+
+// backupAnnotations validates the backup annotations of a claim and returns those its volume gets, nil when the
+// claim has no backup
+func backupAnnotations(annotations map[string]string) (map[string]string, error) {
+	bucket := strings.TrimSpace(annotations[AnnotationBackupBucket])
+	interval := strings.TrimSpace(annotations[AnnotationBackupInterval])
+	prefix := strings.TrimSpace(annotations[AnnotationBackupPrefix])
+	if bucket == "" && interval == "" && prefix == "" {
+		return nil, nil
+	}
+	if bucket == "" {
+		return nil, errors.New("backup-interval and backup-prefix need a backup-bucket")
+	}
+	if _, err := parseBackupInterval(interval); err != nil {
+		return nil, err
+	}
+	backup := map[string]string{AnnotationBackupBucket: bucket, AnnotationBackupInterval: interval}
+	if prefix != "" {
+		backup[AnnotationBackupPrefix] = prefix
+	}
+	return backup, nil
+}
+
+func parseBackupInterval(value string) (time.Duration, error) {
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < MinBackupInterval {
+		return 0, fmt.Errorf("invalid backup-interval %q, expects a duration of at least %s", value, MinBackupInterval)
+	}
+	return interval, nil
+}
+
+// BackupVolume copies, server-side, the objects of the bucket of a volume under its object-path to the backup
+// bucket of its annotations, with the credentials of its secret. The copies up to date are skipped and the
+// objects deleted from the volume are kept in the backup.
+func (p *IBMS3fsProvisioner) BackupVolume(ctx context.Context, pv *v1.PersistentVolume) (*backend.SyncResult, error) {
+	flex := pv.Spec.FlexVolume
+	if flex == nil || flex.Driver != driverName {
+		return nil, fmt.Errorf("PV %s is not a volume of %s", pv.Name, driverName)
+	}
+	backupBucket := pv.Annotations[AnnotationBackupBucket]
+	if backupBucket == "" {
+		return nil, fmt.Errorf("PV %s has no %s annotation", pv.Name, AnnotationBackupBucket)
+	}
+	prefix := pv.Annotations[AnnotationBackupPrefix]
+	if prefix == "" {
+		prefix = pv.Name + "/"
+	}
+	if flex.SecretRef == nil {
+		return nil, fmt.Errorf("PV %s has no secret to back up bucket %s with", pv.Name, flex.Options["bucket"])
+	}
+	secret, err := p.Client.CoreV1().Secrets(flex.SecretRef.Namespace).Get(ctx, flex.SecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve secret %s: %v", flex.SecretRef.Name, err)
+	}
+	creds, _, _, err := credentialsFromSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get credentials: %v", err)
+	}
+	sess, err := p.flexSession(flex, creds)
+	if err != nil {
+		return nil, err
+	}
+	ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+	ctx, stop := p.trackProgress(ctx, ref, driverName, "backing up bucket "+flex.Options["bucket"]+" to "+backupBucket)
+	defer stop()
+	result, err := sess.SyncPrefix(ctx, flex.Options["bucket"], flex.Options["object-path"], backupBucket, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot back up bucket %s to %s: %v", flex.Options["bucket"], backupBucket, err)
+	}
+	return result, nil
+}
+
+// BackupController backs up periodically the volumes of the driver with a backup-bucket annotation, and records
+// the status of their backups in their annotations
+type BackupController struct {
+	Provisioner *IBMS3fsProvisioner
+	Logger      *zap.Logger
+}
+
+// Run checks every period, until ctx is done, which volumes are due for a backup, and backs them up in turn
+func (c *BackupController) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		c.backupDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupDue backs up the volumes whose last backup attempt is older than their backup-interval at now
+func (c *BackupController) backupDue(ctx context.Context, now time.Time) {
+	pvs, err := c.Provisioner.Client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.Logger.Error("Cannot list the volumes to back up", zap.Error(err))
+		return
+	}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		flex := pv.Spec.FlexVolume
+		if flex == nil || flex.Driver != driverName || pv.Annotations[AnnotationBackupBucket] == "" {
+			continue
+		}
+		interval, err := parseBackupInterval(pv.Annotations[AnnotationBackupInterval])
+		if err != nil {
+			c.Logger.Warn("Invalid backup schedule, the volume is not backed up", zap.String("pv", pv.Name), zap.Error(err))
+			continue
+		}
+		if lastAttempt, err := time.Parse(time.RFC3339, pv.Annotations[AnnotationBackupLastAttempt]); err == nil &&
+			now.Before(lastAttempt.Add(interval)) {
+			continue
+		}
+		c.backup(ctx, pv)
+	}
+}
+
+// backup backs up a volume and patches the status of the backup on it, the volume of the list may be stale
+func (c *BackupController) backup(ctx context.Context, pv *v1.PersistentVolume) {
+	start := time.Now().UTC().Format(time.RFC3339)
+	annotations := map[string]interface{}{AnnotationBackupLastAttempt: start}
+	result, err := c.Provisioner.BackupVolume(ctx, pv)
+	if err != nil {
+		c.Logger.Error("Cannot back up the volume", zap.String("pv", pv.Name), zap.Error(err))
+		annotations[AnnotationBackupStatus] = "Failed: " + err.Error()
+		ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+		if err := createEvent(ctx, c.Provisioner.Client, driverName, ref, v1.EventTypeWarning, BackupFailedReason,
+			err.Error()); err != nil {
+			c.Logger.Error("Cannot record the backup event", zap.String("name", pv.Name), zap.Error(err))
+		}
+	} else {
+		bytes := resource.NewQuantity(result.BytesCopied, resource.BinarySI)
+		status := fmt.Sprintf("Succeeded: %d objects copied (%s), %d unchanged", result.Copied, bytes.String(),
+			result.Unchanged)
+		if len(result.Skipped) > 0 {
+			status += fmt.Sprintf(", %d skipped", len(result.Skipped))
+			ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+			if err := createEvent(ctx, c.Provisioner.Client, driverName, ref, v1.EventTypeWarning,
+				BackupObjectsSkippedReason, fmt.Sprintf("objects %s larger than 5 GiB are not backed up",
+					strings.Join(result.Skipped, ", "))); err != nil {
+				c.Logger.Error("Cannot record the backup event", zap.String("name", pv.Name), zap.Error(err))
+			}
+		}
+		annotations[AnnotationBackupStatus] = status
+		annotations[AnnotationBackupLastSuccess] = start
+		c.Logger.Info("Backed up the volume", zap.String("pv", pv.Name), zap.Int64("copied", result.Copied),
+			zap.Int64("unchanged", result.Unchanged), zap.Strings("skipped", result.Skipped))
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		c.Logger.Error("Cannot encode the backup status", zap.String("name", pv.Name), zap.Error(err))
+		return
+	}
+	if _, err := c.Provisioner.Client.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{}); err != nil {
+		c.Logger.Error("Cannot record the backup status", zap.String("name", pv.Name), zap.Error(err))
+	}
+}
@@ -0,0 +1,147 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func getBackupPV(name string, annotations map[string]string) *v1.PersistentVolume {
+	pv := getMeteredPV(name, "team-a", "bucket-"+name)
+	pv.Annotations = annotations
+	return pv
+}
+
+func Test_backupAnnotations(t *testing.T) {
+	backup, err := backupAnnotations(map[string]string{annotationBucket: testBucket})
+	assert.NoError(t, err)
+	assert.Nil(t, backup)
+
+	backup, err = backupAnnotations(map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "24h"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "24h"}, backup)
+
+	_, err = backupAnnotations(map[string]string{AnnotationBackupInterval: "24h"})
+	assert.EqualError(t, err, "backup-interval and backup-prefix need a backup-bucket")
+	_, err = backupAnnotations(map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "10s"})
+	assert.EqualError(t, err, `invalid backup-interval "10s", expects a duration of at least 1m0s`)
+	_, err = backupAnnotations(map[string]string{AnnotationBackupBucket: "backups"})
+	assert.Error(t, err)
+}
+
+func Test_Provision_BackupAnnotations(t *testing.T) {
+	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{}, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationBackupBucket] = "backups"
+	v.PVC.Annotations[AnnotationBackupInterval] = "6h"
+	v.PVC.Annotations[AnnotationBackupPrefix] = "team-a/"
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, "backups", pv.Annotations[AnnotationBackupBucket])
+	assert.Equal(t, "6h", pv.Annotations[AnnotationBackupInterval])
+	assert.Equal(t, "team-a/", pv.Annotations[AnnotationBackupPrefix])
+
+	v = getVolumeOptions()
+	v.PVC.Annotations[AnnotationBackupBucket] = "backups"
+	v.PVC.Annotations[AnnotationBackupInterval] = "often"
+	_, _, err = p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid backup-interval "often"`)
+	}
+}
+
+func Test_BackupVolume(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	pv := getBackupPV("pv-1", map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "1h"})
+	p := getOrphanProvisioner(t, factory, pv)
+
+	_, err := p.BackupVolume(context.Background(), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket-pv-1/data", factory.LastSyncSource)
+	assert.Equal(t, "backups/pv-1/", factory.LastSyncTarget)
+
+	pv.Annotations[AnnotationBackupPrefix] = "team-a/pv-1"
+	_, err = p.BackupVolume(context.Background(), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, "backups/team-a/pv-1", factory.LastSyncTarget)
+
+	pv.Spec.FlexVolume.SecretRef = nil
+	_, err = p.BackupVolume(context.Background(), pv)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no secret")
+	}
+}
+
+func Test_BackupController_Skipped(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{SyncResult: backend.SyncResult{Copied: 1, BytesCopied: 1024,
+		Skipped: []string{"data/big"}}}
+	p := getOrphanProvisioner(t, factory,
+		getBackupPV("pv-due", map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "1h"}))
+	c := &BackupController{Provisioner: p, Logger: zap.NewNop()}
+
+	c.backupDue(context.Background(), time.Now())
+	pv, err := p.Client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-due", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Succeeded: 1 objects copied (1Ki), 0 unchanged, 1 skipped", pv.Annotations[AnnotationBackupStatus])
+	assert.NotEmpty(t, pv.Annotations[AnnotationBackupLastSuccess])
+	events, err := p.Client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, BackupObjectsSkippedReason, events.Items[0].Reason)
+		assert.Equal(t, "objects data/big larger than 5 GiB are not backed up", events.Items[0].Message)
+	}
+}
+
+func Test_BackupController(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{SyncResult: backend.SyncResult{Copied: 2, BytesCopied: 2048, Unchanged: 3}}
+	recent := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	p := getOrphanProvisioner(t, factory,
+		getBackupPV("pv-due", map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "1h"}),
+		getBackupPV("pv-recent", map[string]string{AnnotationBackupBucket: "backups", AnnotationBackupInterval: "1h",
+			AnnotationBackupLastAttempt: recent}),
+		getBackupPV("pv-none", nil),
+	)
+	c := &BackupController{Provisioner: p, Logger: zap.NewNop()}
+
+	c.backupDue(context.Background(), time.Now())
+	assert.Equal(t, "bucket-pv-due/data", factory.LastSyncSource)
+	pv, err := p.Client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-due", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Succeeded: 2 objects copied (2Ki), 3 unchanged", pv.Annotations[AnnotationBackupStatus])
+	assert.NotEmpty(t, pv.Annotations[AnnotationBackupLastAttempt])
+	assert.Equal(t, pv.Annotations[AnnotationBackupLastAttempt], pv.Annotations[AnnotationBackupLastSuccess])
+	pv, err = p.Client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-recent", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, pv.Annotations[AnnotationBackupStatus])
+
+	// the failures are recorded on the volume and retried after the interval
+	factory.ResetStats()
+	factory.FailSyncPrefix = true
+	c.backupDue(context.Background(), time.Now().Add(2*time.Hour))
+	pv, err = p.Client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-recent", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, pv.Annotations[AnnotationBackupStatus], "Failed: cannot back up bucket bucket-pv-recent to backups")
+	assert.Empty(t, pv.Annotations[AnnotationBackupLastSuccess])
+	events, err := p.Client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, events.Items)
+}
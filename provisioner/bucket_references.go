@@ -0,0 +1,88 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+)
+
+// BucketInUseReason is the reason of the events of the volumes whose bucket is kept on their deletion,
+// other volumes referring to it
+const BucketInUseReason = "BucketInUse"
+
+// bucketReferences returns the names of the persistent volumes of the driver referring to each bucket,
+// as their bucket or as one of their sources, sorted
+func (p *IBMS3fsProvisioner) bucketReferences(ctx context.Context) (map[string][]string, error) {
+	pvs, err := p.Client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list persistent volumes: %v", err)
+	}
+
+	references := map[string][]string{}
+	for _, pv := range pvs.Items {
+		flex := pv.Spec.FlexVolume
+		if flex == nil || flex.Driver != driverName {
+			continue
+		}
+		buckets := map[string]bool{flex.Options["bucket"]: true}
+		// the volumes mounting several buckets, as checked by the provisioner
+		for _, value := range []string{flex.Options["sources"], pv.Annotations["ibm.io/sources"]} {
+			if value == "" {
+				continue
+			}
+			sources, err := driver.ParseSources(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse the sources of persistent volume %s: %v", pv.Name, err)
+			}
+			for _, source := range sources {
+				buckets[source.Bucket] = true
+			}
+		}
+		for bucket := range buckets {
+			references[bucket] = append(references[bucket], pv.Name)
+		}
+	}
+	for bucket := range references {
+		sort.Strings(references[bucket])
+	}
+	return references, nil
+}
+
+// otherBucketReferences returns the persistent volumes other than pv referring to its bucket
+func (p *IBMS3fsProvisioner) otherBucketReferences(ctx context.Context, pv *v1.PersistentVolume, bucket string) ([]string, error) {
+	references, err := p.bucketReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for _, name := range references[bucket] {
+		if name != pv.Name {
+			others = append(others, name)
+		}
+	}
+	return others, nil
+}
+
+// warnBucketInUse records an event on a volume whose bucket is kept
+func (p *IBMS3fsProvisioner) warnBucketInUse(ctx context.Context, pv *v1.PersistentVolume, bucket string, others []string) {
+	ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+	if err := createEvent(ctx, p.Client, driverName, ref, v1.EventTypeWarning, BucketInUseReason,
+		fmt.Sprintf("bucket %s is not deleted, PVs %s still refer to it", bucket, strings.Join(others, ", "))); err != nil {
+		p.Logger.Error("Cannot record the bucket in use event", zap.String("name", pv.Name), zap.Error(err))
+	}
+}
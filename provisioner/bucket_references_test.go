@@ -0,0 +1,62 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_bucketReferences(t *testing.T) {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{},
+		getOrphanPV("pv-1", driverName, map[string]string{"bucket": "shared", "sources": "logs=other/prefix/"}, nil),
+		getOrphanPV("pv-2", driverName, map[string]string{"bucket": "shared"}, nil),
+		getOrphanPV("pv-3", "other/driver", map[string]string{"bucket": "shared"}, nil),
+	)
+
+	references, err := p.bucketReferences(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"shared": {"pv-1", "pv-2"}, "other": {"pv-1"}}, references)
+}
+
+func Test_Delete_BucketInUse(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getOrphanProvisioner(t, factory,
+		getOrphanPV("pv-static", driverName, map[string]string{"bucket": testBucket}, nil))
+	pv := getAutoDeletePersistentVolume()
+	pv.Name = "pv-dynamic"
+	pv.Annotations[annotationBucket] = testBucket
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Empty(t, factory.LastDeletedBucket)
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, BucketInUseReason, events.Items[0].Reason)
+		assert.Equal(t, "bucket test-bucket is not deleted, PVs pv-static still refer to it", events.Items[0].Message)
+	}
+}
+
+func Test_Delete_BucketNotShared(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	pv := getAutoDeletePersistentVolume()
+	pv.Name = "pv-dynamic"
+	pv.Annotations[annotationBucket] = testBucket
+	pv.Spec.FlexVolume.Driver = driverName
+	pv.Spec.FlexVolume.Options["bucket"] = testBucket
+	p := getOrphanProvisioner(t, factory, pv)
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Equal(t, testBucket, factory.LastDeletedBucket)
+}
@@ -0,0 +1,273 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"path"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ProvisionerPodSelector and MounterPodSelector select the pods of the provisioner and of the mounter
+	ProvisionerPodSelector = "app=ibmcloud-object-storage-plugin"
+	MounterPodSelector     = "app=ibmcloud-object-storage-mounter"
+	// DriverLogFile is the log file of the driver in the log directory of the nodes
+	DriverLogFile = "ibmc-s3fs.log"
+	// redacted replaces the values of the secrets of a debug bundle
+	redacted = "<redacted>"
+)
+
+// DebugBundle tells what CollectDebugBundle gathers
+type DebugBundle struct {
+	Client kubernetes.Interface
+	// Namespace is the namespace of the provisioner and mounter pods
+	Namespace string
+	// Provisioner is the name of the provisioner of the storage classes to gather
+	Provisioner string
+	// LogTail bounds the number of lines of each log, 0 for whole logs
+	LogTail int64
+	// MountStates returns the mount states listed by the driver in a mounter pod, not gathered when nil
+	MountStates func(ctx context.Context, pod *v1.Pod) ([]byte, error)
+	// NodeLog returns a file of the log directory of a node, the driver logs are not gathered when nil
+	NodeLog func(ctx context.Context, node, file string) ([]byte, error)
+}
+
+// bundleWriter adds files to the archive of a debug bundle and records what could not be gathered
+type bundleWriter struct {
+	tar      *tar.Writer
+	modified time.Time
+	errors   []string
+}
+
+func (w *bundleWriter) add(name string, content []byte, err error) error {
+	if err != nil {
+		w.errors = append(w.errors, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: w.modified}
+	if err := w.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = w.tar.Write(content)
+	return err
+}
+
+// addYAML adds an object as YAML unless it could not be read
+func (w *bundleWriter) addYAML(name string, object interface{}, err error) error {
+	var content []byte
+	if err == nil {
+		content, err = yaml.Marshal(object)
+	}
+	return w.add(name, content, err)
+}
+
+// CollectDebugBundle writes a gzipped tar archive of what support needs to look into volume issues:
+// the storage classes of the provisioner, their PVs and PVCs, the secrets of the PVs with their values
+// redacted, the logs of the provisioner and mounter pods, and the driver logs and mount states of the
+// nodes. What cannot be gathered is listed in errors.txt of the archive instead of failing the bundle.
+func CollectDebugBundle(ctx context.Context, bundle DebugBundle, out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	w := &bundleWriter{tar: tar.NewWriter(gz), modified: time.Now()}
+
+	if err := bundle.collectSpecs(ctx, w); err != nil {
+		return err
+	}
+	if err := bundle.collectPods(ctx, w); err != nil {
+		return err
+	}
+	if len(w.errors) > 0 {
+		if err := w.add("errors.txt", []byte(strings.Join(w.errors, "\n")+"\n"), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// collectSpecs adds the storage classes of the provisioner, their volumes and claims, and the redacted secrets of the volumes
+func (b *DebugBundle) collectSpecs(ctx context.Context, w *bundleWriter) error {
+	classes := map[string]bool{}
+	scs, err := b.Client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err == nil {
+		items := scs.Items[:0]
+		for _, sc := range scs.Items {
+			if sc.Provisioner == b.Provisioner {
+				classes[sc.Name] = true
+				sc.ManagedFields = nil
+				items = append(items, sc)
+			}
+		}
+		scs.Items = items
+	}
+	if err := w.addYAML("cluster/storageclasses.yaml", scs, err); err != nil {
+		return err
+	}
+
+	volumes := map[string]bool{}
+	secrets := map[string]v1.SecretReference{}
+	pvs, err := b.Client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err == nil {
+		items := pvs.Items[:0]
+		for _, pv := range pvs.Items {
+			if pv.Spec.FlexVolume == nil || pv.Spec.FlexVolume.Driver != driverName {
+				continue
+			}
+			volumes[pv.Name] = true
+			if ref := pv.Spec.FlexVolume.SecretRef; ref != nil {
+				secrets[ref.Namespace+"/"+ref.Name] = *ref
+			}
+			pv.ManagedFields = nil
+			items = append(items, pv)
+		}
+		pvs.Items = items
+	}
+	if err := w.addYAML("cluster/persistentvolumes.yaml", pvs, err); err != nil {
+		return err
+	}
+
+	pvcs, err := b.Client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err == nil {
+		items := pvcs.Items[:0]
+		for _, pvc := range pvcs.Items {
+			class := ""
+			if pvc.Spec.StorageClassName != nil {
+				class = *pvc.Spec.StorageClassName
+			}
+			if volumes[pvc.Spec.VolumeName] || classes[class] {
+				pvc.ManagedFields = nil
+				items = append(items, pvc)
+			}
+		}
+		pvcs.Items = items
+	}
+	if err := w.addYAML("cluster/persistentvolumeclaims.yaml", pvcs, err); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := &v1.SecretList{}
+	for _, key := range keys {
+		ref := secrets[key]
+		secret, err := b.Client.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			w.errors = append(w.errors, fmt.Sprintf("secret %s: %v", key, err))
+			continue
+		}
+		list.Items = append(list.Items, redactSecret(secret))
+	}
+	return w.addYAML("cluster/secrets.yaml", list, nil)
+}
+
+// collectPods adds the logs of the provisioner and mounter pods, and the driver logs and mount states of the mounter nodes
+func (b *DebugBundle) collectPods(ctx context.Context, w *bundleWriter) error {
+	var tail *int64
+	if b.LogTail > 0 {
+		tail = &b.LogTail
+	}
+	pods := b.Client.CoreV1().Pods(b.Namespace)
+
+	provisioners, err := pods.List(ctx, metav1.ListOptions{LabelSelector: ProvisionerPodSelector})
+	if err != nil {
+		w.errors = append(w.errors, fmt.Sprintf("provisioner pods: %v", err))
+	} else {
+		for _, pod := range provisioners.Items {
+			logs, err := pods.GetLogs(pod.Name, &v1.PodLogOptions{TailLines: tail}).DoRaw(ctx)
+			if err := w.add(path.Join("provisioner", pod.Name+".log"), logs, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	mounters, err := pods.List(ctx, metav1.ListOptions{LabelSelector: MounterPodSelector})
+	if err != nil {
+		w.errors = append(w.errors, fmt.Sprintf("mounter pods: %v", err))
+		return nil
+	}
+	for i := range mounters.Items {
+		pod := &mounters.Items[i]
+		dir := path.Join("nodes", pod.Spec.NodeName)
+		logs, err := pods.GetLogs(pod.Name, &v1.PodLogOptions{TailLines: tail}).DoRaw(ctx)
+		if err := w.add(path.Join(dir, pod.Name+".log"), logs, err); err != nil {
+			return err
+		}
+		if b.MountStates != nil {
+			states, err := b.MountStates(ctx, pod)
+			if err := w.add(path.Join(dir, "mounts.json"), states, err); err != nil {
+				return err
+			}
+		}
+		if b.NodeLog != nil && pod.Spec.NodeName != "" {
+			driverLog, err := b.NodeLog(ctx, pod.Spec.NodeName, DriverLogFile)
+			if err == nil && b.LogTail > 0 {
+				driverLog = tailLines(driverLog, int(b.LogTail))
+			}
+			if err := w.add(path.Join(dir, DriverLogFile), driverLog, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// redactSecret returns a copy of a secret with its keys but none of its values
+func redactSecret(secret *v1.Secret) v1.Secret {
+	redactedSecret := v1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: *secret.ObjectMeta.DeepCopy(),
+		Type:       secret.Type,
+		StringData: map[string]string{},
+	}
+	redactedSecret.ManagedFields = nil
+	// kubectl apply keeps the whole secret in this annotation
+	delete(redactedSecret.Annotations, v1.LastAppliedConfigAnnotation)
+	for key := range secret.Data {
+		redactedSecret.StringData[key] = redacted
+	}
+	for key := range secret.StringData {
+		redactedSecret.StringData[key] = redacted
+	}
+	return redactedSecret
+}
+
+// tailLines returns the last lines of a log
+func tailLines(content []byte, lines int) []byte {
+	end := len(content)
+	if end > 0 && content[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if content[i] == '\n' {
+			lines--
+			if lines == 0 {
+				return content[i+1:]
+			}
+		}
+	}
+	return content
+}
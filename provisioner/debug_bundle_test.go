@@ -0,0 +1,145 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func getDebugBundleClient() *fakeclient.Clientset {
+	className := "ibmc-s3fs-standard"
+	otherClass := "other"
+	objects := []runtime.Object{
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: className}, Provisioner: "ibm.io/ibmc-s3fs"},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: otherClass}, Provisioner: "other.io/other"},
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-s3fs"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{
+					Driver:    driverName,
+					SecretRef: &v1.SecretReference{Name: "cos", Namespace: "ns1"},
+				},
+			}},
+		},
+		&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-other"}},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-s3fs", Namespace: "ns1"},
+			Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &className, VolumeName: "pv-s3fs"},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-other", Namespace: "ns1"},
+			Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &otherClass, VolumeName: "pv-other"},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cos", Namespace: "ns1",
+				Annotations: map[string]string{v1.LastAppliedConfigAnnotation: `{"data":{"api-key":"c2VjcmV0"}}`}},
+			Type: driverName,
+			Data: map[string][]byte{"api-key": []byte("secret-api-key")},
+		},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "provisioner-1", Namespace: "kube-system",
+			Labels: map[string]string{"app": "ibmcloud-object-storage-plugin"}}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mounter-1", Namespace: "kube-system",
+			Labels: map[string]string{"app": "ibmcloud-object-storage-mounter"}},
+			Spec: v1.PodSpec{NodeName: "node-1"}},
+	}
+	return fakeclient.NewSimpleClientset(objects...)
+}
+
+// readDebugBundle returns the files of a debug bundle by name
+func readDebugBundle(t *testing.T, archive []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(reader)
+		files[header.Name] = string(content)
+	}
+	return files
+}
+
+func Test_CollectDebugBundle_Positive(t *testing.T) {
+	bundle := DebugBundle{
+		Client:      getDebugBundleClient(),
+		Namespace:   "kube-system",
+		Provisioner: "ibm.io/ibmc-s3fs",
+		LogTail:     2,
+		MountStates: func(ctx context.Context, pod *v1.Pod) ([]byte, error) {
+			return []byte(`[{"mountDir":"/mnt","state":"mounted"}]`), nil
+		},
+		NodeLog: func(ctx context.Context, node, file string) ([]byte, error) {
+			return []byte("line 1\nline 2\nline 3\n"), nil
+		},
+	}
+	var out bytes.Buffer
+	assert.NoError(t, CollectDebugBundle(context.Background(), bundle, &out))
+	files := readDebugBundle(t, out.Bytes())
+
+	assert.Contains(t, files["cluster/storageclasses.yaml"], "ibmc-s3fs-standard")
+	assert.NotContains(t, files["cluster/storageclasses.yaml"], "other.io/other")
+	assert.Contains(t, files["cluster/persistentvolumes.yaml"], "pv-s3fs")
+	assert.NotContains(t, files["cluster/persistentvolumes.yaml"], "pv-other")
+	assert.Contains(t, files["cluster/persistentvolumeclaims.yaml"], "pvc-s3fs")
+	assert.NotContains(t, files["cluster/persistentvolumeclaims.yaml"], "pvc-other")
+	assert.Contains(t, files["cluster/secrets.yaml"], "api-key: "+redacted)
+	assert.NotContains(t, files["cluster/secrets.yaml"], "secret-api-key")
+	assert.NotContains(t, files["cluster/secrets.yaml"], "c2VjcmV0")
+	assert.Contains(t, files, "provisioner/provisioner-1.log")
+	assert.Contains(t, files, "nodes/node-1/mounter-1.log")
+	assert.Contains(t, files["nodes/node-1/mounts.json"], "mounted")
+	assert.Equal(t, "line 2\nline 3\n", files["nodes/node-1/"+DriverLogFile])
+	assert.NotContains(t, files, "errors.txt")
+}
+
+func Test_CollectDebugBundle_Errors(t *testing.T) {
+	client := getDebugBundleClient()
+	assert.NoError(t, client.CoreV1().Secrets("ns1").Delete(context.Background(), "cos", metav1.DeleteOptions{}))
+	bundle := DebugBundle{
+		Client:      client,
+		Namespace:   "kube-system",
+		Provisioner: "ibm.io/ibmc-s3fs",
+		MountStates: func(ctx context.Context, pod *v1.Pod) ([]byte, error) {
+			return nil, errors.New("cannot exec")
+		},
+	}
+	var out bytes.Buffer
+	assert.NoError(t, CollectDebugBundle(context.Background(), bundle, &out))
+	files := readDebugBundle(t, out.Bytes())
+
+	assert.NotContains(t, files, "nodes/node-1/mounts.json")
+	assert.NotContains(t, files, "nodes/node-1/"+DriverLogFile)
+	assert.Contains(t, files["errors.txt"], "secret ns1/cos")
+	assert.Contains(t, files["errors.txt"], "nodes/node-1/mounts.json: cannot exec")
+}
+
+func Test_TailLines(t *testing.T) {
+	assert.Equal(t, "c\n", string(tailLines([]byte("a\nb\nc\n"), 1)))
+	assert.Equal(t, "b\nc", string(tailLines([]byte("a\nb\nc"), 2)))
+	assert.Equal(t, "a\nb\n", string(tailLines([]byte("a\nb\n"), 5)))
+}
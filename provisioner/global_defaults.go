@@ -0,0 +1,184 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Keys of the data of the global defaults ConfigMap, each holds a YAML map keyed by storage class parameter
+const (
+	// GlobalDefaultsKey are the values of the parameters the storage classes leave empty
+	GlobalDefaultsKey = "defaults"
+	// GlobalOverridesKey are the values replacing the storage class parameters and PVC annotations
+	GlobalOverridesKey = "overrides"
+	// GlobalCapsKey are the highest values of the integer parameters and annotations
+	GlobalCapsKey = "caps"
+)
+
+// GlobalDefaults are the cluster-wide defaults, overrides and caps of the storage class parameters
+type GlobalDefaults struct {
+	Defaults  map[string]string
+	Overrides map[string]string
+	Caps      map[string]int
+}
+
+// ParseGlobalDefaults reads the global defaults from the data of a ConfigMap
+func ParseGlobalDefaults(data map[string]string) (*GlobalDefaults, error) {
+	d := &GlobalDefaults{}
+	if err := yaml.Unmarshal([]byte(data[GlobalDefaultsKey]), &d.Defaults); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", GlobalDefaultsKey, err)
+	}
+	if err := yaml.Unmarshal([]byte(data[GlobalOverridesKey]), &d.Overrides); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", GlobalOverridesKey, err)
+	}
+	if err := yaml.Unmarshal([]byte(data[GlobalCapsKey]), &d.Caps); err != nil {
+		return nil, fmt.Errorf("invalid %s, expects integers: %v", GlobalCapsKey, err)
+	}
+	return d, nil
+}
+
+// apply returns copies of the annotations of a claim and of the parameters of its storage class with the
+// defaults and overrides set. An override replaces the parameter and drops the annotation of the same name.
+func (d *GlobalDefaults) apply(annotations, parameters map[string]string) (map[string]string, map[string]string) {
+	if d == nil {
+		return annotations, parameters
+	}
+	pvc := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		pvc[key] = value
+	}
+	sc := make(map[string]string, len(parameters)+len(d.Defaults))
+	for key, value := range parameters {
+		sc[key] = value
+	}
+	for key, value := range d.Defaults {
+		if sc[key] == "" {
+			sc[key] = value
+		}
+	}
+	for key, value := range d.Overrides {
+		sc[key] = value
+		delete(pvc, key)
+	}
+	return pvc, sc
+}
+
+// checkCaps reports the integer annotations, or parameters when the claim does not set them, above their cap.
+// The values that are not integers are left to the validation of the annotations.
+func (d *GlobalDefaults) checkCaps(annotations, parameters map[string]string) []error {
+	if d == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(d.Caps))
+	for key := range d.Caps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		value := annotations[key]
+		if value == "" {
+			value = parameters[key]
+		}
+		if n, err := strconv.Atoi(value); err == nil && n > d.Caps[key] {
+			errs = append(errs, fmt.Errorf("value of %s %d exceeds the cluster cap %d", key, n, d.Caps[key]))
+		}
+	}
+	return errs
+}
+
+// GlobalDefaultsWatcher keeps the global defaults of a ConfigMap up to date, so they apply without restarting
+// the provisioner. A missing ConfigMap means no defaults, an invalid one keeps the previous defaults.
+type GlobalDefaultsWatcher struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+	Logger    *zap.Logger
+
+	mutex    sync.RWMutex
+	defaults *GlobalDefaults
+}
+
+// Current returns the global defaults in effect, nil when there are none
+func (w *GlobalDefaultsWatcher) Current() *GlobalDefaults {
+	if w == nil {
+		return nil
+	}
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.defaults
+}
+
+// Start watches the ConfigMap until ctx is done, and returns once its current content is loaded
+func (w *GlobalDefaultsWatcher) Start(ctx context.Context, resyncPeriod time.Duration) error {
+	if err := watchConfigMap(ctx, w.Client, w.Namespace, w.Name, resyncPeriod, w.load, func() { w.set(nil) }); err != nil {
+		return errors.New("cannot load the global defaults ConfigMap " + w.Namespace + "/" + w.Name)
+	}
+	return nil
+}
+
+// watchConfigMap calls load with the content of a ConfigMap on every change, and remove when it is deleted,
+// until ctx is done. It returns once the current content is loaded.
+func watchConfigMap(ctx context.Context, client kubernetes.Interface, namespace, name string,
+	resyncPeriod time.Duration, load func(obj interface{}), remove func()) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, resyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = "metadata.name=" + name
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    load,
+		UpdateFunc: func(_, obj interface{}) { load(obj) },
+		DeleteFunc: func(interface{}) { remove() },
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("cache not synced")
+	}
+	return nil
+}
+
+func (w *GlobalDefaultsWatcher) load(obj interface{}) {
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok || configMap.Name != w.Name {
+		return
+	}
+	defaults, err := ParseGlobalDefaults(configMap.Data)
+	if err != nil {
+		w.Logger.Error("Invalid global defaults ConfigMap, keeping the previous defaults",
+			zap.String("configmap", w.Namespace+"/"+w.Name), zap.Error(err))
+		return
+	}
+	w.set(defaults)
+}
+
+func (w *GlobalDefaultsWatcher) set(defaults *GlobalDefaults) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.defaults = defaults
+	w.Logger.Info("Loaded the global defaults", zap.String("configmap", w.Namespace+"/"+w.Name),
+		zap.Bool("set", defaults != nil))
+}
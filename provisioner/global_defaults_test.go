@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func getGlobalDefaults(t *testing.T, data map[string]string) *GlobalDefaultsWatcher {
+	w := &GlobalDefaultsWatcher{Logger: zap.NewNop()}
+	defaults, err := ParseGlobalDefaults(data)
+	require.NoError(t, err)
+	w.set(defaults)
+	return w
+}
+
+func Test_ParseGlobalDefaults_Error(t *testing.T) {
+	_, err := ParseGlobalDefaults(map[string]string{GlobalCapsKey: "ibm.io/parallel-count: many"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid caps, expects integers")
+	}
+}
+
+func Test_Provision_GlobalDefaults_Positive(t *testing.T) {
+	p := getProvisioner()
+	p.GlobalDefaults = getGlobalDefaults(t, map[string]string{
+		GlobalDefaultsKey:  "ibm.io/tls-cipher-suite: AESGCM\nibm.io/parallel-count: \"7\"",
+		GlobalOverridesKey: "ibm.io/iam-endpoint: https://private.iam.cloud.ibm.com",
+	})
+	v := getVolumeOptions()
+	delete(v.StorageClass.Parameters, parameterTLSCipherSuite)
+	v.PVC.Annotations[annotationIAMEndpoint] = "https://iam.cloud.ibm.com"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "AESGCM", pv.Spec.FlexVolume.Options[optionTLSCipherSuite])
+	// the parameters set by the storage class are kept
+	assert.Equal(t, v.StorageClass.Parameters[parameterParallelCount], pv.Spec.FlexVolume.Options[optionParallelCount])
+	assert.Equal(t, "https://private.iam.cloud.ibm.com", pv.Spec.FlexVolume.Options[optionIAMEndpoint])
+}
+
+func Test_Provision_GlobalDefaults_Caps(t *testing.T) {
+	p := getProvisioner()
+	p.GlobalDefaults = getGlobalDefaults(t, map[string]string{GlobalCapsKey: "ibm.io/parallel-count: 4"})
+	v := getVolumeOptions()
+	v.PVC.Annotations["ibm.io/parallel-count"] = "16"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of ibm.io/parallel-count 16 exceeds the cluster cap 4")
+	}
+}
+
+func Test_GlobalDefaultsWatcher_Reload(t *testing.T) {
+	client := fakeclient.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3fs-defaults", Namespace: "kube-system"},
+		Data:       map[string]string{GlobalDefaultsKey: "ibm.io/debug-level: info"},
+	})
+	w := &GlobalDefaultsWatcher{Client: client, Namespace: "kube-system", Name: "s3fs-defaults", Logger: zap.NewNop()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, w.Start(ctx, 0))
+	if assert.NotNil(t, w.Current()) {
+		assert.Equal(t, "info", w.Current().Defaults["ibm.io/debug-level"])
+	}
+
+	configMaps := client.CoreV1().ConfigMaps("kube-system")
+	cm, _ := configMaps.Get(ctx, "s3fs-defaults", metav1.GetOptions{})
+	cm.Data[GlobalDefaultsKey] = "ibm.io/debug-level: debug"
+	_, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return w.Current() != nil && w.Current().Defaults["ibm.io/debug-level"] == "debug"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// an invalid ConfigMap keeps the previous defaults
+	cm.Data[GlobalCapsKey] = "ibm.io/parallel-count: many"
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "debug", w.Current().Defaults["ibm.io/debug-level"])
+
+	require.NoError(t, configMaps.Delete(ctx, "s3fs-defaults", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool { return w.Current() == nil }, 5*time.Second, 10*time.Millisecond)
+}
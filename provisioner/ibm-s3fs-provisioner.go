@@ -25,25 +25,33 @@ import (
 	"google.golang.org/grpc"
 	"io/ioutil"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
 	"net"
 	"os"
 	"path"
 	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// PVC annotations
+// PVC annotations of the latest version of AnnotationAPIVersions
 type pvcAnnotations struct {
+	APIVersion              string `json:"ibm.io/api-version,omitempty"`
 	AutoCreateBucket        string `json:"ibm.io/auto-create-bucket"`
 	AutoDeleteBucket        string `json:"ibm.io/auto-delete-bucket"`
 	Bucket                  string `json:"ibm.io/bucket"`
 	ObjectPath              string `json:"ibm.io/object-path,omitempty"`
-	Endpoint                string `json:"ibm.io/endpoint,omitempty"` //Will be deprecated
-	Region                  string `json:"ibm.io/region,omitempty"`   //Will be deprecated
+	Sources                 string `json:"ibm.io/sources,omitempty"`
+	CreateObjectPath        string `json:"ibm.io/create-object-path,omitempty"`
+	ObjectPathAsPrefix      string `json:"ibm.io/object-path-as-prefix,omitempty"`
+	ObjectPathMarker        string `json:"ibm.io/object-path-marker,omitempty"`
+	OSEndpoint              string `json:"ibm.io/object-store-endpoint,omitempty"`
+	OSStorageClass          string `json:"ibm.io/object-store-storage-class,omitempty"`
 	SecretName              string `json:"ibm.io/secret-name"`
 	ChunkSizeMB             string `json:"ibm.io/chunk-size-mb,omitempty"`
 	ParallelCount           string `json:"ibm.io/parallel-count,omitempty"`
@@ -51,6 +59,7 @@ type pvcAnnotations struct {
 	StatCacheSize           string `json:"ibm.io/stat-cache-size,omitempty"`
 	S3FSFUSERetryCount      string `json:"ibm.io/s3fs-fuse-retry-count,omitempty"`
 	StatCacheExpireSeconds  string `json:"ibm.io/stat-cache-expire-seconds,omitempty"`
+	StatCacheExpire         string `json:"ibm.io/stat-cache-expire,omitempty"`
 	IAMEndpoint             string `json:"ibm.io/iam-endpoint,omitempty"`
 	ValidateBucket          string `json:"ibm.io/validate-bucket,omitempty"`
 	SecretNamespace         string `json:"ibm.io/secret-namespace,omitempty"`
@@ -67,26 +76,49 @@ type pvcAnnotations struct {
 	AccessPolicyAllowedIps  string `json:"ibm.io/access-policy-allowed-ips,omitempty"`
 	AddMountParam           string `json:"ibm.io/add-mount-param,omitempty"`
 	QuotaLimit              string `json:"ibm.io/quota-limit,omitempty"`
+	TmpfsCacheSizeMB        string `json:"ibm.io/tmpfs-cache-size-mb,omitempty"`
+	WriteBackCache          bool   `json:"ibm.io/write-back-cache,string,omitempty"`
+	WriteBackDelaySeconds   string `json:"ibm.io/write-back-delay-seconds,omitempty"`
+	ReadAheadKB             string `json:"ibm.io/read-ahead-kb,omitempty"`
+	ReaddirOptimize         bool   `json:"ibm.io/readdir-optimize,string,omitempty"`
+	NoObjCache              bool   `json:"ibm.io/noobj-cache,string,omitempty"`
+	CompatDir               bool   `json:"ibm.io/compat-dir,string,omitempty"`
+	NotSupCompatDir         bool   `json:"ibm.io/notsup-compat-dir,string,omitempty"`
+	ComplementStat          bool   `json:"ibm.io/complement-stat,string,omitempty"`
+	AhbeConfigMap           string `json:"ibm.io/ahbe-configmap,omitempty"`
+	DetectContentType       bool   `json:"ibm.io/detect-content-type,string,omitempty"`
+	SELinuxContext          string `json:"ibm.io/selinux-context,omitempty"`
+	LogFile                 bool   `json:"ibm.io/log-file,string,omitempty"`
+	ExtraMountOptions       string `json:"ibm.io/extra-mount-options,omitempty"`
+	ClientSideEncryption    bool   `json:"ibm.io/client-side-encryption,string,omitempty"`
+	Compression             bool   `json:"ibm.io/compression,string,omitempty"`
+	AuthMode                string `json:"ibm.io/auth-mode,omitempty"`
+	TrustedProfileID        string `json:"ibm.io/trusted-profile-id,omitempty"`
+	MultipartSizeMB         string `json:"ibm.io/multipart-size-mb,omitempty"`
+	SinglepartCopyLimitMB   string `json:"ibm.io/singlepart-copy-limit-mb,omitempty"`
+	MaxDirtyDataMB          string `json:"ibm.io/max-dirty-data-mb,omitempty"`
+	ListObjectMaxKeys       string `json:"ibm.io/list-object-max-keys,omitempty"`
 }
 
-// Storage Class options
+// Storage Class options, the default tags are the values of the parameters left empty
 type scOptions struct {
-	AutoCreateBucket        string `json:"ibm.io/auto-create-bucket,omitempty"`
-	AutoDeleteBucket        string `json:"ibm.io/auto-delete-bucket,omitempty"`
+	AutoCreateBucket        string `json:"ibm.io/auto-create-bucket,omitempty" default:"true"`
+	AutoDeleteBucket        string `json:"ibm.io/auto-delete-bucket,omitempty" default:"false"`
 	Bucket                  string `json:"ibm.io/bucket,omitempty"`
 	ObjectPath              string `json:"ibm.io/object-path,omitempty"`
 	SecretName              string `json:"ibm.io/secret-name,omitempty"`
 	SecretNamespace         string `json:"ibm.io/secret-namespace,omitempty"`
-	ChunkSizeMB             int    `json:"ibm.io/chunk-size-mb,string"`
-	ParallelCount           int    `json:"ibm.io/parallel-count,string"`
-	MultiReqMax             int    `json:"ibm.io/multireq-max,string"`
-	StatCacheSize           int    `json:"ibm.io/stat-cache-size,string"`
-	TLSCipherSuite          string `json:"ibm.io/tls-cipher-suite,omitempty"`
-	DebugLevel              string `json:"ibm.io/debug-level"`
+	ChunkSizeMB             int    `json:"ibm.io/chunk-size-mb,string" default:"10"`
+	ParallelCount           int    `json:"ibm.io/parallel-count,string" default:"5"`
+	MultiReqMax             int    `json:"ibm.io/multireq-max,string" default:"20"`
+	StatCacheSize           int    `json:"ibm.io/stat-cache-size,string" default:"100000"`
+	TLSCipherSuite          string `json:"ibm.io/tls-cipher-suite,omitempty" default:"AES"`
+	DebugLevel              string `json:"ibm.io/debug-level" default:"warn"`
 	CurlDebug               bool   `json:"ibm.io/curl-debug,string,omitempty"`
 	KernelCache             bool   `json:"ibm.io/kernel-cache,string,omitempty"`
 	S3FSFUSERetryCount      string `json:"ibm.io/s3fs-fuse-retry-count,omitempty"`
 	StatCacheExpireSeconds  string `json:"ibm.io/stat-cache-expire-seconds,omitempty"`
+	StatCacheExpire         string `json:"ibm.io/stat-cache-expire,omitempty"`
 	IAMEndpoint             string `json:"ibm.io/iam-endpoint,omitempty"`
 	OSEndpoint              string `json:"ibm.io/object-store-endpoint,omitempty"`
 	OSStorageClass          string `json:"ibm.io/object-store-storage-class,omitempty"`
@@ -94,6 +126,43 @@ type scOptions struct {
 	ReadwriteTimeoutSeconds string `json:"ibm.io/readwrite-timeout,omitempty"`
 	UseXattr                bool   `json:"ibm.io/use-xattr,string"`
 	AddMountParam           string `json:"ibm.io/add-mount-param,omitempty"`
+	TmpfsCacheSizeMB        string `json:"ibm.io/tmpfs-cache-size-mb,omitempty"`
+	WriteBackCache          bool   `json:"ibm.io/write-back-cache,string,omitempty"`
+	WriteBackDelaySeconds   string `json:"ibm.io/write-back-delay-seconds,omitempty"`
+	ReadAheadKB             string `json:"ibm.io/read-ahead-kb,omitempty"`
+	ReaddirOptimize         bool   `json:"ibm.io/readdir-optimize,string,omitempty"`
+	NoObjCache              bool   `json:"ibm.io/noobj-cache,string,omitempty"`
+	CompatDir               bool   `json:"ibm.io/compat-dir,string,omitempty"`
+	NotSupCompatDir         bool   `json:"ibm.io/notsup-compat-dir,string,omitempty"`
+	ComplementStat          bool   `json:"ibm.io/complement-stat,string,omitempty"`
+	AhbeConfigMap           string `json:"ibm.io/ahbe-configmap,omitempty"`
+	DetectContentType       bool   `json:"ibm.io/detect-content-type,string,omitempty"`
+	SELinuxContext          string `json:"ibm.io/selinux-context,omitempty"`
+	LogFile                 bool   `json:"ibm.io/log-file,string,omitempty"`
+	ExtraMountOptions       string `json:"ibm.io/extra-mount-options,omitempty"`
+	ClientSideEncryption    bool   `json:"ibm.io/client-side-encryption,string,omitempty"`
+	Compression             bool   `json:"ibm.io/compression,string,omitempty"`
+	AuthMode                string `json:"ibm.io/auth-mode,omitempty"`
+	TrustedProfileID        string `json:"ibm.io/trusted-profile-id,omitempty"`
+	MultipartSizeMB         string `json:"ibm.io/multipart-size-mb,omitempty"`
+	SinglepartCopyLimitMB   string `json:"ibm.io/singlepart-copy-limit-mb,omitempty"`
+	MaxDirtyDataMB          string `json:"ibm.io/max-dirty-data-mb,omitempty"`
+	ListObjectMaxKeys       string `json:"ibm.io/list-object-max-keys,omitempty"`
+	PermissionMode          string `json:"ibm.io/permission-mode,omitempty"`
+	CompatProfile           string `json:"ibm.io/compat-profile,omitempty"`
+	MounterCPULimit         string `json:"ibm.io/mounter-cpu-limit,omitempty"`
+	MounterMemoryLimit      string `json:"ibm.io/mounter-memory-limit,omitempty"`
+	CosMaxIdleConns         string `json:"ibm.io/cos-max-idle-conns,omitempty"`
+	CosIdleConnTimeout      string `json:"ibm.io/cos-idle-conn-timeout-seconds,omitempty"`
+	CosTLSHandshakeTimeout  string `json:"ibm.io/cos-tls-handshake-timeout-seconds,omitempty"`
+	CosResponseTimeout      string `json:"ibm.io/cos-response-header-timeout-seconds,omitempty"`
+	CosKeepAlive            string `json:"ibm.io/cos-keep-alive-seconds,omitempty"`
+	BucketAccessCheck       string `json:"ibm.io/bucket-access-check,omitempty"`
+	Backend                 string `json:"ibm.io/backend,omitempty"`
+	BucketACL               string `json:"ibm.io/bucket-acl,omitempty"`
+	ReplicationTargetBucket string `json:"ibm.io/replication-target-bucket,omitempty"`
+	ReplicationTargetRegion string `json:"ibm.io/replication-target-region,omitempty"`
+	ReplicationPriority     string `json:"ibm.io/replication-priority,omitempty"`
 }
 
 const (
@@ -111,11 +180,110 @@ const (
 var SockEndpoint *string
 var ConfigBucketAccessPolicy *bool
 var ConfigQuotaLimit *bool
+var ConfigNodeReadinessAffinity *bool
+var ConfigExtraMountOptionsAllowlist *string
+var ConfigForbidPublicBuckets *bool
+var ConfigRejectDeprecatedAnnotations *bool
+
+// defaultExtraMountOptionsAllowlist are the s3fs options users may pass with extra-mount-options
+// unless the operator configures another list. Options touching host paths or credentials are left out.
+const defaultExtraMountOptionsAllowlist = "stat_cache_interval_expire,enable_content_md5,nomultipart," +
+	"nocopyapi,norenameapi,nomixupload,multipart_copy_size,max_stat_cache_size,stat_cache_expire," +
+	"parallel_count,multireq_max,retries,connect_timeout,readwrite_timeout,list_object_max_keys," +
+	"readdir_optimize,enable_noobj_cache,compat_dir,notsup_compat_dir,complement_stat,dbglevel"
+
+// validateExtraMountOptions checks that every option of the comma-separated list is allowed
+func validateExtraMountOptions(options string) error {
+	allowlist := defaultExtraMountOptionsAllowlist
+	if ConfigExtraMountOptionsAllowlist != nil && *ConfigExtraMountOptionsAllowlist != "" {
+		allowlist = *ConfigExtraMountOptionsAllowlist
+	}
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(allowlist, ",") {
+		allowed[strings.TrimSpace(name)] = true
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		if option == "" || strings.ContainsAny(option, " \t\"'") {
+			return fmt.Errorf("malformed mount option %q", option)
+		}
+		name := strings.SplitN(option, "=", 2)[0]
+		if !allowed[name] {
+			return fmt.Errorf("mount option %q is not allowed", name)
+		}
+	}
+	return nil
+}
+
+// cosTransport returns the HTTP transport settings of the storage class for the object storage
+// sessions, zero for the settings left to the provisioner flags
+func cosTransport(sc scOptions) (backend.TransportConfig, error) {
+	var transport backend.TransportConfig
+	if sc.CosMaxIdleConns != "" {
+		maxIdleConns, err := strconv.Atoi(sc.CosMaxIdleConns)
+		if err != nil || maxIdleConns < 1 {
+			return transport, fmt.Errorf("value of cos-max-idle-conns should be an integer >= 1, got: %s", sc.CosMaxIdleConns)
+		}
+		transport.MaxIdleConns = maxIdleConns
+	}
+	for _, setting := range []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"cos-idle-conn-timeout-seconds", sc.CosIdleConnTimeout, &transport.IdleConnTimeout},
+		{"cos-tls-handshake-timeout-seconds", sc.CosTLSHandshakeTimeout, &transport.TLSHandshakeTimeout},
+		{"cos-response-header-timeout-seconds", sc.CosResponseTimeout, &transport.ResponseHeaderTimeout},
+		{"cos-keep-alive-seconds", sc.CosKeepAlive, &transport.KeepAlive},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		seconds, err := parser.ParseSeconds(setting.value)
+		if err != nil || seconds < 1 {
+			return transport, fmt.Errorf("value of %s should be an integer >= 1 or a duration of at least 1s, got: %s", setting.name, setting.value)
+		}
+		*setting.field = time.Duration(seconds) * time.Second
+	}
+	return transport, nil
+}
+
+// backendFailureState tells the controller whether a provisioning failed on an object storage call
+// may succeed later: an unreachable endpoint may come back, a denied access or a missing bucket will not
+func backendFailureState(err error) controller.ProvisioningState {
+	if backend.IsRetryable(err) {
+		return controller.ProvisioningInBackground
+	}
+	return controller.ProvisioningFinished
+}
+
+// checkBucketLocation checks that an existing bucket lives in the location of the storage class,
+// the endpoint of another location does not serve it once mounted. The check is skipped when
+// the credentials may not read the location of the bucket.
+func checkBucketLocation(ctx context.Context, sess backend.ObjectStorageSession, bucket, location string, logger *zap.Logger) error {
+	if location == "" {
+		return nil
+	}
+	actual, err := sess.GetBucketLocation(ctx, bucket)
+	if backend.ErrorKindOf(err) == backend.ErrorAccessDenied {
+		logger.Warn("cannot get the location of the bucket, skipping its check",
+			zap.String("bucket", bucket), zap.Error(err))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if actual != "" && !strings.EqualFold(actual, location) {
+		return fmt.Errorf("bucket %s is in location %s, the storage class expects %s", bucket, actual, location)
+	}
+	return nil
+}
 
 // IBMS3fsProvisioner is a dynamic provisioner of persistent volumes backed by Object Storage via s3fs
 type IBMS3fsProvisioner struct {
 	// Backend is the object store session factory
 	Backend backend.ObjectStorageSessionFactory
+	// Backends holds the session factories of the other backends storage classes may select
+	Backends *backend.Registry
 	// GRPCBackend is the grpc session factory
 	GRPCBackend grpcClient.GrpcSessionFactory
 	// AccessPolicy is the resource configuration session factory
@@ -129,9 +297,69 @@ type IBMS3fsProvisioner struct {
 	Client kubernetes.Interface
 	// UUIDGenerator is a UUID generator that will be used to generate bucket names
 	UUIDGenerator uuid.Generator
+	// GlobalDefaults holds the cluster-wide defaults of the storage class parameters, none when nil
+	GlobalDefaults *GlobalDefaultsWatcher
+	// TuningLimits holds the provisioner defaults and caps of the s3fs tuning parameters, none when nil
+	TuningLimits *TuningLimits
+	// NamespaceMetadata maps the labels and annotations of the namespaces of the claims to the labels
+	// of their volumes and the tags of their buckets
+	NamespaceMetadata []MetadataMapping
+	// RequesterAnnotation is the annotation of the claims naming the identity that created them, copied to the
+	// AnnotationRequestedBy of their volumes and the tags of their buckets, none when empty
+	RequesterAnnotation string
+	// NamespaceQuota holds the highest number of volumes of the namespaces, none when nil
+	NamespaceQuota *NamespaceQuotaWatcher
+	// ProgressInterval is the interval of the progress events of the bucket creations and deletions, none when 0
+	ProgressInterval time.Duration
+	// RetryBackoff is the backoff of the failed provisionings recorded on the claims, none when nil
+	RetryBackoff *RetryBackoff
+	// MissingSecretPolicy is the policy of the deletion of the buckets whose secret is gone, MissingSecretFail
+	// when empty
+	MissingSecretPolicy string
+	// CleanupSecretName and CleanupSecretNamespace name the secret deleting the buckets with MissingSecretFallback
+	CleanupSecretName      string
+	CleanupSecretNamespace string
 }
 
 var _ controller.Provisioner = &IBMS3fsProvisioner{}
+var _ controller.BlockProvisioner = &IBMS3fsProvisioner{}
+
+// ErrBlockVolumeMode is returned, wrapped, when a claim requests a raw block volume, s3fs mounts a file system
+var ErrBlockVolumeMode = errors.New("volumeMode Block is not supported, only Filesystem volumes can be provisioned")
+
+// UnsupportedVolumeModeReason is the reason of the warning events recorded on the claims in Block volume mode
+const UnsupportedVolumeModeReason = "UnsupportedVolumeMode"
+
+// SupportsBlock lets the claims in Block volume mode reach Provision, which rejects them with ErrBlockVolumeMode
+// rather than the generic message of the controller
+func (p *IBMS3fsProvisioner) SupportsBlock(ctx context.Context) bool {
+	return true
+}
+
+// checkVolumeMode rejects the claims in Block volume mode and records a warning event on them
+func (p *IBMS3fsProvisioner) checkVolumeMode(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string) error {
+	if pvc.Spec.VolumeMode == nil || *pvc.Spec.VolumeMode != v1.PersistentVolumeBlock {
+		return nil
+	}
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, UnsupportedVolumeModeReason,
+		ErrBlockVolumeMode.Error()); err != nil {
+		p.Logger.Error("Cannot record the unsupported volume mode event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+	return ErrBlockVolumeMode
+}
+
+// sessionFactory returns the session factory of the backend named by a storage class
+func (p *IBMS3fsProvisioner) sessionFactory(name string) (backend.ObjectStorageSessionFactory, error) {
+	if name == "" || name == backend.DefaultBackendName {
+		return p.Backend, nil
+	}
+	if p.Backends == nil {
+		return nil, fmt.Errorf("unknown object storage backend %q, expects %s", name, backend.DefaultBackendName)
+	}
+	return p.Backends.Factory(name)
+}
+
 var writeFile = ioutil.WriteFile
 
 func UnixConnect(addr string, t time.Duration) (net.Conn, error) {
@@ -176,7 +404,12 @@ func (p *IBMS3fsProvisioner) getCredentials(ctx context.Context, secretName, sec
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("cannot retrieve secret %s: %v", secretName, err)
 	}
+	return credentialsFromSecret(secrets)
+}
 
+// credentialsFromSecret reads the object storage credentials, the allowed namespaces and the resource
+// configuration API key of a secret
+func credentialsFromSecret(secrets *v1.Secret) (credentials *backend.ObjectStorageCredentials, allowedNamespace []string, resConfApiKey string, err error) {
 	if strings.TrimSpace(string(secrets.Type)) != driverName {
 		return nil, nil, "", fmt.Errorf("Wrong Secret Type.Provided secret of type %s.Expected type %s", string(secrets.Type), driverName)
 	}
@@ -214,30 +447,127 @@ func (p *IBMS3fsProvisioner) getCredentials(ctx context.Context, secretName, sec
 	}, allowedNamespace, resConfApiKey, nil
 }
 
+// getAhbeConf renders the additional header ConfigMap into the s3fs ahbe_conf format.
+// Every key of the ConfigMap is a file name suffix (e.g. ".gz") and its value holds
+// one "Header-Name: value" line per header to add to objects having that suffix.
+func (p *IBMS3fsProvisioner) getAhbeConf(ctx context.Context, configMapName, configMapNamespace string) (string, error) {
+	configMap, err := p.Client.CoreV1().ConfigMaps(configMapNamespace).Get(ctx, configMapName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("cannot retrieve configmap %s: %v", configMapName, err)
+	}
+
+	suffixes := make([]string, 0, len(configMap.Data))
+	for suffix := range configMap.Data {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Strings(suffixes)
+
+	var lines []string
+	for _, suffix := range suffixes {
+		for _, header := range strings.Split(configMap.Data[suffix], "\n") {
+			if strings.TrimSpace(header) == "" {
+				continue
+			}
+			kv := strings.SplitN(header, ":", 2)
+			name := strings.TrimSpace(kv[0])
+			if len(kv) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+				return "", fmt.Errorf("invalid header %q for suffix %s in configmap %s, expects \"Header-Name: value\"", header, suffix, configMapName)
+			}
+			lines = append(lines, suffix+" "+name+" "+strings.TrimSpace(kv[1]))
+		}
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("configmap %s does not define any header", configMapName)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
 func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options controller.ProvisionOptions) (pvcAnnotations, scOptions, string, error) {
 	var pvc pvcAnnotations
 	var sc scOptions
 	var pvcName = options.PVC.Name
 	var clusterID = os.Getenv("CLUSTER_ID")
 	var svcIp string
+	var errs []error
 	var err error
 
 	contextLogger, _ := logger.GetZapDefaultContextLogger()
 	contextLogger.Info(pvcName + ":" + clusterID + ":validate annotations and assign default values to annotations")
 
-	if err := parser.UnmarshalMap(&options.PVC.Annotations, &pvc); err != nil {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":cannot unmarshal PVC annotations: %v", err)
+	// the annotations and parameters of older versions are converted to the latest one before being read
+	annotations, err := convertAnnotations(options.PVC.Annotations)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot convert PVC annotations: %v", err))
+	}
+	parameters, err := convertAnnotations(options.StorageClass.Parameters)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot convert storage class parameters: %v", err))
+	}
+
+	// the profile of the storage class fills its unset parameters before the cluster-wide defaults
+	if parameters != nil {
+		if parameters, err = expandProfile(parameters); err != nil {
+			errs = append(errs, fmt.Errorf("invalid storage class parameters: %v", err))
+		}
+	}
+
+	// the cluster-wide defaults, overrides and caps are of the latest version too
+	globalDefaults := p.GlobalDefaults.Current()
+	if annotations != nil && parameters != nil {
+		annotations, parameters = globalDefaults.apply(annotations, parameters)
+	}
+	if parameters != nil {
+		p.TuningLimits.applyDefaults(parameters)
+	}
+	// the sizes given as quantities are converted to integers before being capped and unmarshalled
+	errs = append(errs, convertSizes(annotations)...)
+	errs = append(errs, convertSizes(parameters)...)
+	if annotations != nil && parameters != nil {
+		// the storage class may lock its endpoints and tuning before the values are capped
+		dropped, err := lockOverrides(annotations, parameters)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid storage class parameters: %v", err))
+		}
+		if len(dropped) > 0 {
+			p.warnLockedOverrides(ctx, options.PVC, options.StorageClass.Provisioner, dropped)
+		}
+		errs = append(errs, globalDefaults.checkCaps(annotations, parameters)...)
+		if isReadOnlyMany(options.PVC) {
+			applyReadOnlyDefaults(parameters)
+		}
+		errs = append(errs, p.enforceCaps(ctx, options.PVC, options.StorageClass.Provisioner, annotations, parameters)...)
+	}
+
+	if annotations != nil {
+		if err := parser.UnmarshalMap(&annotations, &pvc); err != nil {
+			errs = append(errs, fmt.Errorf("cannot unmarshal PVC annotations: %v", err))
+		}
+	}
+	if parameters != nil {
+		if err := parser.UnmarshalMap(&parameters, &sc); err != nil {
+			errs = append(errs, fmt.Errorf("cannot unmarshal storage class parameters: %v", err))
+		}
 	}
 
-	if err := parser.UnmarshalMap(&options.StorageClass.Parameters, &sc); err != nil {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":cannot unmarshal storage class parameters: %v", err)
+	if deprecated := deprecatedAnnotationsOf(options.PVC.Annotations); len(deprecated) > 0 {
+		p.warnDeprecatedAnnotations(ctx, options.PVC, options.StorageClass.Provisioner, deprecated)
+		if ConfigRejectDeprecatedAnnotations != nil && *ConfigRejectDeprecatedAnnotations {
+			for _, key := range deprecated {
+				errs = append(errs, fmt.Errorf("deprecated annotation %s is rejected by the provisioner configuration, use %s",
+					key, DeprecatedAnnotations[key]))
+			}
+		}
+	}
+
+	if unknown := UnknownAnnotations(options.PVC.Annotations); len(unknown) > 0 {
+		p.warnUnknownAnnotations(ctx, options.PVC, options.StorageClass.Provisioner, unknown)
 	}
 
 	if pvc.SecretName == "" {
 		if sc.SecretName != "" {
 			pvc.SecretName = sc.SecretName
 		} else {
-			return pvc, sc, svcIp, errors.New(pvcName + ":" + clusterID + ":secret-name not specified")
+			errs = append(errs, errors.New("secret-name not specified"))
 		}
 	}
 
@@ -250,23 +580,21 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 	}
 
 	if pvc.AutoCreateBucket == "" {
-		if sc.AutoCreateBucket != "" {
-			pvc.AutoCreateBucket = sc.AutoCreateBucket
-		} else {
-			pvc.AutoCreateBucket = "true"
-		}
-	} else if _, err := strconv.ParseBool(pvc.AutoCreateBucket); err != nil {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":invalid value for auto-create-bucket, expects true/false: %v", err)
+		pvc.AutoCreateBucket = sc.AutoCreateBucket
+	}
+	if value, err := parser.ParseBool(pvc.AutoCreateBucket); err != nil {
+		errs = append(errs, fmt.Errorf("invalid value for auto-create-bucket, expects true/false: %v", err))
+	} else {
+		pvc.AutoCreateBucket = strconv.FormatBool(value)
 	}
 
 	if pvc.AutoDeleteBucket == "" {
-		if sc.AutoDeleteBucket != "" {
-			pvc.AutoDeleteBucket = sc.AutoDeleteBucket
-		} else {
-			pvc.AutoDeleteBucket = "false"
-		}
-	} else if _, err := strconv.ParseBool(pvc.AutoDeleteBucket); err != nil {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":invalid value for auto-delete-bucket, expects true/false: %v", err)
+		pvc.AutoDeleteBucket = sc.AutoDeleteBucket
+	}
+	if value, err := parser.ParseBool(pvc.AutoDeleteBucket); err != nil {
+		errs = append(errs, fmt.Errorf("invalid value for auto-delete-bucket, expects true/false: %v", err))
+	} else {
+		pvc.AutoDeleteBucket = strconv.FormatBool(value)
 	}
 
 	if pvc.Bucket == "" && sc.Bucket != "" {
@@ -280,19 +608,23 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 	if pvc.AccessPolicyAllowedIps != "" {
 		validIps, wrongIpArr := parser.ParseIPs(pvc.AccessPolicyAllowedIps)
 		if !validIps {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":invalid value for access-policy-allowed-ips,  invalid ips are : %v", wrongIpArr)
+			errs = append(errs, fmt.Errorf("invalid value for access-policy-allowed-ips,  invalid ips are : %v", wrongIpArr))
 		}
 	}
 
 	if pvc.SetAccessPolicy != "" {
-		if _, err := strconv.ParseBool(pvc.SetAccessPolicy); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":invalid value for set-access-policy, expects true/false: %v", err)
+		if value, err := parser.ParseBool(pvc.SetAccessPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for set-access-policy, expects true/false: %v", err))
+		} else {
+			pvc.SetAccessPolicy = strconv.FormatBool(value)
 		}
 	}
 
 	if pvc.QuotaLimit != "" {
-		if _, err := strconv.ParseBool(pvc.QuotaLimit); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":invalid value for quota-limit, expects true/false: %v", err)
+		if value, err := parser.ParseBool(pvc.QuotaLimit); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for quota-limit, expects true/false: %v", err))
+		} else {
+			pvc.QuotaLimit = strconv.FormatBool(value)
 		}
 	}
 
@@ -302,36 +634,40 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 			// Generate the COS Service DNS name
 			svc, err := p.Client.CoreV1().Services(pvc.CosServiceNamespace).Get(ctx, pvc.CosServiceName, metav1.GetOptions{})
 			if err != nil {
-				return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":cannot retrieve service details: %v", err)
+				return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":%v", utilerrors.NewAggregate(
+					append(errs, fmt.Errorf("cannot retrieve service details: %v", err))))
 			}
 			port := svc.Spec.Ports[0].Port
 			svcIp = svc.Spec.ClusterIP
 			endPoint := "https://" + pvc.CosServiceName + "." + pvc.CosServiceNamespace + ".svc.cluster.local:" + strconv.Itoa(int(port))
-			pvc.Endpoint = endPoint
+			pvc.OSEndpoint = endPoint
 		}
 	}
-	// retrieve CA Cert if provided in secrets
-	if err := p.writeCrtFile(ctx, pvc.SecretName, pvc.SecretNamespace, pvc.CosServiceName); err != nil {
-		return pvc, sc, svcIp, fmt.Errorf("cannot retrieve secret: %v", err)
+	// retrieve CA Cert if provided in secrets, a missing secret-name is already reported
+	if pvc.SecretName != "" {
+		if err := p.writeCrtFile(ctx, pvc.SecretName, pvc.SecretNamespace, pvc.CosServiceName); err != nil {
+			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":%v", utilerrors.NewAggregate(
+				append(errs, fmt.Errorf("cannot retrieve secret: %v", err))))
+		}
 	}
 
 	//Override value of EndPoint defined in storageclass
 	// EndPoint should be defined in storage class.
-	if pvc.Endpoint != "" {
-		sc.OSEndpoint = pvc.Endpoint
+	if pvc.OSEndpoint != "" {
+		sc.OSEndpoint = pvc.OSEndpoint
 	}
 
 	//Override value of OSStorageClass defined in storageclass.
-	// pvc Region will be deprecated.
-	if pvc.Region != "" {
-		sc.OSStorageClass = pvc.Region
+	if pvc.OSStorageClass != "" {
+		sc.OSStorageClass = pvc.OSStorageClass
 	}
 
 	if !(strings.HasPrefix(sc.OSEndpoint, "https://") || strings.HasPrefix(sc.OSEndpoint, "http://")) {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+
-			":Bad value for ibm.io/object-store-endpoint \"%v\": scheme is missing. "+
+		errs = append(errs, fmt.Errorf("Bad value for ibm.io/object-store-endpoint \"%v\": scheme is missing. "+
 			"Must be of the form http://<hostname> or https://<hostname>",
-			sc.OSEndpoint)
+			sc.OSEndpoint))
+	} else if _, err := backend.LocationConstraint(sc.OSEndpoint, sc.OSStorageClass); err != nil {
+		errs = append(errs, err)
 	}
 
 	if pvc.IAMEndpoint != "" {
@@ -339,10 +675,9 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 	}
 
 	if !(strings.HasPrefix(sc.IAMEndpoint, "https://") || strings.HasPrefix(sc.IAMEndpoint, "http://")) {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+
-			":Bad value for ibm.io/iam-endpoint \"%v\":"+
+		errs = append(errs, fmt.Errorf("Bad value for ibm.io/iam-endpoint \"%v\":"+
 			" Must be of the form https://<hostname> or http://<hostname>",
-			sc.IAMEndpoint)
+			sc.IAMEndpoint))
 	}
 
 	//Override value of s3fs-fuse-retry-count defined in storageclass
@@ -351,68 +686,350 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 	}
 	if sc.S3FSFUSERetryCount != "" {
 		if retryCount, err := strconv.Atoi(sc.S3FSFUSERetryCount); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of s3fs-fuse-retry-count into integer: %v", err)
+			errs = append(errs, fmt.Errorf("Cannot convert value of s3fs-fuse-retry-count into integer: %v", err))
 		} else if retryCount < 1 {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName + ":" + clusterID + ":value of s3fs-fuse-retry-count should be >= 1")
+			errs = append(errs, errors.New("value of s3fs-fuse-retry-count should be >= 1"))
 		}
 	}
 
+	// ibm.io/stat-cache-expire takes precedence over ibm.io/stat-cache-expire-seconds
+	if pvc.StatCacheExpire != "" {
+		pvc.StatCacheExpireSeconds = pvc.StatCacheExpire
+	}
+	if sc.StatCacheExpire != "" {
+		sc.StatCacheExpireSeconds = sc.StatCacheExpire
+	}
 	//Override value of stat-cache-expire-seconds defined in storageclass
 	if pvc.StatCacheExpireSeconds != "" {
 		sc.StatCacheExpireSeconds = pvc.StatCacheExpireSeconds
 	}
 	if sc.StatCacheExpireSeconds != "" {
-		if cacheExpireSeconds, err := strconv.Atoi(sc.StatCacheExpireSeconds); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of stat-cache-expire-seconds into integer: %v", err)
+		if cacheExpireSeconds, err := parser.ParseSeconds(sc.StatCacheExpireSeconds); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of stat-cache-expire-seconds into integer: %v", err))
 		} else if cacheExpireSeconds < 0 {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName + ":" + clusterID + ":value of stat-cache-expire-seconds should be >= 0")
+			errs = append(errs, errors.New("value of stat-cache-expire-seconds should be >= 0"))
+		} else {
+			sc.StatCacheExpireSeconds = strconv.Itoa(cacheExpireSeconds)
 		}
 	}
 
 	//Override value of chunk-size-mb defined in storageclass
 	if pvc.ChunkSizeMB != "" {
 		if sc.ChunkSizeMB, err = strconv.Atoi(pvc.ChunkSizeMB); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of chunk-size-mb into integer: %v", err)
+			errs = append(errs, fmt.Errorf("Cannot convert value of chunk-size-mb into integer: %v", err))
 		}
 	}
 
 	//Override value of parallel-count defined in storageclass
 	if pvc.ParallelCount != "" {
 		if sc.ParallelCount, err = strconv.Atoi(pvc.ParallelCount); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of parallel-count into integer: %v", err)
+			errs = append(errs, fmt.Errorf("Cannot convert value of parallel-count into integer: %v", err))
 		}
 	}
 
 	//Override value of multireq-max defined in storageclass
 	if pvc.MultiReqMax != "" {
 		if sc.MultiReqMax, err = strconv.Atoi(pvc.MultiReqMax); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of multireq-max into integer: %v", err)
+			errs = append(errs, fmt.Errorf("Cannot convert value of multireq-max into integer: %v", err))
 		}
 	}
 
 	//Override value of stat-cache-size defined in storageclass
 	if pvc.StatCacheSize != "" {
 		if sc.StatCacheSize, err = strconv.Atoi(pvc.StatCacheSize); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of stat-cache-size into integer: %v", err)
+			errs = append(errs, fmt.Errorf("Cannot convert value of stat-cache-size into integer: %v", err))
 		}
 	}
 
+	//Override value of connect-timeout defined in storageclass
 	if pvc.ConnectTimeoutSeconds != "" {
-		if _, err := strconv.Atoi(pvc.ConnectTimeoutSeconds); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of connect-timeout-seconds into integer: %v", err)
-		}
 		sc.ConnectTimeoutSeconds = pvc.ConnectTimeoutSeconds
 	}
+	if sc.ConnectTimeoutSeconds != "" {
+		if connectTimeout, err := parser.ParseSeconds(sc.ConnectTimeoutSeconds); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of connect-timeout-seconds into integer: %v", err))
+		} else if connectTimeout < 1 {
+			errs = append(errs, errors.New("value of connect-timeout should be >= 1"))
+		} else {
+			sc.ConnectTimeoutSeconds = strconv.Itoa(connectTimeout)
+		}
+	}
 
+	//Override value of readwrite-timeout defined in storageclass
 	if pvc.ReadwriteTimeoutSeconds != "" {
-		if _, err := strconv.Atoi(pvc.ReadwriteTimeoutSeconds); err != nil {
-			return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":Cannot convert value of readwrite-timeout-seconds into integer: %v", err)
-		}
 		sc.ReadwriteTimeoutSeconds = pvc.ReadwriteTimeoutSeconds
 	}
+	if sc.ReadwriteTimeoutSeconds != "" {
+		if readwriteTimeout, err := parser.ParseSeconds(sc.ReadwriteTimeoutSeconds); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of readwrite-timeout-seconds into integer: %v", err))
+		} else if readwriteTimeout < 1 {
+			errs = append(errs, errors.New("value of readwrite-timeout should be >= 1"))
+		} else {
+			sc.ReadwriteTimeoutSeconds = strconv.Itoa(readwriteTimeout)
+		}
+	}
+
+	//Override value of tmpfs-cache-size-mb defined in storageclass
+	if pvc.TmpfsCacheSizeMB != "" {
+		sc.TmpfsCacheSizeMB = pvc.TmpfsCacheSizeMB
+	}
+	if sc.TmpfsCacheSizeMB != "" {
+		if cacheSizeMB, err := strconv.Atoi(sc.TmpfsCacheSizeMB); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of tmpfs-cache-size-mb into integer: %v", err))
+		} else if cacheSizeMB < 1 {
+			errs = append(errs, errors.New("value of tmpfs-cache-size-mb should be >= 1"))
+		}
+	}
+
+	if pvc.WriteBackCache {
+		sc.WriteBackCache = pvc.WriteBackCache
+	}
+	if sc.WriteBackCache && isReadOnlyMany(options.PVC) {
+		errs = append(errs, errors.New("write-back-cache is not supported by the ReadOnlyMany volumes, nothing is written through them"))
+	}
+
+	//Override value of write-back-delay-seconds defined in storageclass
+	if pvc.WriteBackDelaySeconds != "" {
+		sc.WriteBackDelaySeconds = pvc.WriteBackDelaySeconds
+	}
+	if sc.WriteBackDelaySeconds != "" {
+		if delaySeconds, err := parser.ParseSeconds(sc.WriteBackDelaySeconds); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of write-back-delay-seconds into integer: %v", err))
+		} else if delaySeconds < 0 {
+			errs = append(errs, errors.New("value of write-back-delay-seconds should be >= 0"))
+		} else {
+			sc.WriteBackDelaySeconds = strconv.Itoa(delaySeconds)
+		}
+	}
+
+	//Override value of read-ahead-kb defined in storageclass
+	if pvc.ReadAheadKB != "" {
+		sc.ReadAheadKB = pvc.ReadAheadKB
+	}
+	if sc.ReadAheadKB != "" {
+		if readAheadKB, err := strconv.Atoi(sc.ReadAheadKB); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of read-ahead-kb into integer: %v", err))
+		} else if readAheadKB < 0 {
+			errs = append(errs, errors.New("value of read-ahead-kb should be >= 0"))
+		}
+	}
+
+	if pvc.ReaddirOptimize {
+		sc.ReaddirOptimize = pvc.ReaddirOptimize
+	}
+
+	if pvc.NoObjCache {
+		sc.NoObjCache = pvc.NoObjCache
+	}
+
+	//Override value of multipart-size-mb defined in storageclass
+	if pvc.MultipartSizeMB != "" {
+		sc.MultipartSizeMB = pvc.MultipartSizeMB
+	}
+	if sc.MultipartSizeMB != "" {
+		if partSizeMB, err := strconv.Atoi(sc.MultipartSizeMB); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of multipart-size-mb into integer: %v", err))
+		} else if partSizeMB < 5 {
+			errs = append(errs, errors.New("value of multipart-size-mb should be >= 5"))
+		}
+	}
+
+	//Override value of singlepart-copy-limit-mb defined in storageclass
+	if pvc.SinglepartCopyLimitMB != "" {
+		sc.SinglepartCopyLimitMB = pvc.SinglepartCopyLimitMB
+	}
+	if sc.SinglepartCopyLimitMB != "" {
+		if copyLimitMB, err := strconv.Atoi(sc.SinglepartCopyLimitMB); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of singlepart-copy-limit-mb into integer: %v", err))
+		} else if copyLimitMB < 0 {
+			errs = append(errs, errors.New("value of singlepart-copy-limit-mb should be >= 0"))
+		}
+	}
+
+	//Override value of max-dirty-data-mb defined in storageclass
+	if pvc.MaxDirtyDataMB != "" {
+		sc.MaxDirtyDataMB = pvc.MaxDirtyDataMB
+	}
+	if sc.MaxDirtyDataMB != "" {
+		if dirtyDataMB, err := strconv.Atoi(sc.MaxDirtyDataMB); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of max-dirty-data-mb into integer: %v", err))
+		} else if dirtyDataMB != -1 && dirtyDataMB < 50 {
+			errs = append(errs, errors.New("value of max-dirty-data-mb should be -1 or >= 50"))
+		}
+	}
+
+	//Override value of list-object-max-keys defined in storageclass
+	if pvc.ListObjectMaxKeys != "" {
+		sc.ListObjectMaxKeys = pvc.ListObjectMaxKeys
+	}
+	if sc.ListObjectMaxKeys != "" {
+		if maxKeys, err := strconv.Atoi(sc.ListObjectMaxKeys); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot convert value of list-object-max-keys into integer: %v", err))
+		} else if maxKeys < 1 {
+			errs = append(errs, errors.New("value of list-object-max-keys should be >= 1"))
+		}
+	}
+
+	if pvc.CompatDir {
+		sc.CompatDir = pvc.CompatDir
+	}
+
+	if pvc.NotSupCompatDir {
+		sc.NotSupCompatDir = pvc.NotSupCompatDir
+	}
 
-	if pvc.AutoCreateBucket == "true" && pvc.ObjectPath != "" {
-		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":object-path cannot be set when auto-create is enabled, got: %s", pvc.ObjectPath)
+	if pvc.ComplementStat {
+		sc.ComplementStat = pvc.ComplementStat
+	}
+
+	if pvc.AhbeConfigMap != "" {
+		sc.AhbeConfigMap = pvc.AhbeConfigMap
+	}
+
+	if pvc.DetectContentType {
+		sc.DetectContentType = pvc.DetectContentType
+	}
+
+	if pvc.SELinuxContext != "" {
+		sc.SELinuxContext = pvc.SELinuxContext
+	}
+
+	if pvc.LogFile {
+		sc.LogFile = pvc.LogFile
+	}
+
+	// the passphrase comes from the encryption-key of the volume secret
+	if pvc.ClientSideEncryption {
+		sc.ClientSideEncryption = pvc.ClientSideEncryption
+	}
+
+	if pvc.Compression {
+		sc.Compression = pvc.Compression
+	}
+
+	if pvc.AuthMode != "" {
+		sc.AuthMode = pvc.AuthMode
+	}
+	if pvc.TrustedProfileID != "" {
+		sc.TrustedProfileID = pvc.TrustedProfileID
+	}
+	if sc.AuthMode != "" && sc.AuthMode != driver.AuthModeInstanceIdentity {
+		errs = append(errs, fmt.Errorf("invalid value for auth-mode, expects %s, got: %s",
+			driver.AuthModeInstanceIdentity, sc.AuthMode))
+	}
+	if sc.AuthMode == driver.AuthModeInstanceIdentity && sc.TrustedProfileID == "" {
+		errs = append(errs, errors.New("trusted-profile-id must be set when auth-mode is "+driver.AuthModeInstanceIdentity))
+	}
+
+	if pvc.ExtraMountOptions != "" {
+		sc.ExtraMountOptions = pvc.ExtraMountOptions
+	}
+	if sc.ExtraMountOptions != "" {
+		if err := validateExtraMountOptions(sc.ExtraMountOptions); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for extra-mount-options: %v", err))
+		}
+	}
+	// kubelet refuses the mountOptions of flex volumes, those of the storage class go to the driver options
+	if mountOptions := strings.Join(options.StorageClass.MountOptions, ","); mountOptions != "" {
+		if err := validateExtraMountOptions(mountOptions); err != nil {
+			errs = append(errs, fmt.Errorf("invalid storage class mountOptions: %v", err))
+		}
+	}
+
+	if sc.CompatProfile != "" && sc.CompatProfile != driver.CompatProfileOpenShift {
+		errs = append(errs, fmt.Errorf("invalid value for compat-profile, expects %s, got: %s",
+			driver.CompatProfileOpenShift, sc.CompatProfile))
+	}
+
+	if sc.MounterCPULimit != "" {
+		if cpu, err := resource.ParseQuantity(sc.MounterCPULimit); err != nil || cpu.MilliValue() < 10 {
+			errs = append(errs, fmt.Errorf("invalid value for mounter-cpu-limit, expects a CPU quantity of at least 10m, got: %s", sc.MounterCPULimit))
+		}
+	}
+
+	if sc.MounterMemoryLimit != "" {
+		if memory, err := resource.ParseQuantity(sc.MounterMemoryLimit); err != nil || memory.Value() <= 0 {
+			errs = append(errs, fmt.Errorf("invalid value for mounter-memory-limit, expects a positive memory quantity, got: %s", sc.MounterMemoryLimit))
+		}
+	}
+
+	if _, err := cosTransport(sc); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := backend.ValidateAccessCheck(sc.BucketAccessCheck); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := p.sessionFactory(sc.Backend); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := backend.ValidateBucketACL(sc.BucketACL); err != nil {
+		errs = append(errs, err)
+	}
+	if sc.BucketACL == backend.BucketACLPublicRead && ConfigForbidPublicBuckets != nil && *ConfigForbidPublicBuckets {
+		errs = append(errs, errors.New("public buckets are forbidden by the provisioner configuration, bucket-acl cannot be "+backend.BucketACLPublicRead))
+	}
+
+	if _, err := replicationRule(sc); err != nil {
+		errs = append(errs, err)
+	}
+
+	if sc.CompatDir && sc.NotSupCompatDir {
+		errs = append(errs, errors.New("compat-dir and notsup-compat-dir cannot be set together"))
+	}
+
+	if sc.PermissionMode != "" && sc.PermissionMode != driver.PermissionModePersistent &&
+		sc.PermissionMode != driver.PermissionModeStateless {
+		errs = append(errs, fmt.Errorf("invalid value for permission-mode, expects %s/%s: %s",
+			driver.PermissionModePersistent, driver.PermissionModeStateless, sc.PermissionMode))
+	}
+
+	if pvc.CreateObjectPath != "" {
+		if value, err := parser.ParseBool(pvc.CreateObjectPath); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for create-object-path, expects true/false: %v", err))
+		} else {
+			pvc.CreateObjectPath = strconv.FormatBool(value)
+		}
+	}
+
+	if pvc.ObjectPathAsPrefix != "" {
+		if value, err := parser.ParseBool(pvc.ObjectPathAsPrefix); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for object-path-as-prefix, expects true/false: %v", err))
+		} else {
+			pvc.ObjectPathAsPrefix = strconv.FormatBool(value)
+		}
+	}
+
+	if pvc.ObjectPathMarker != "" {
+		if value, err := parser.ParseBool(pvc.ObjectPathMarker); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for object-path-marker, expects true/false: %v", err))
+		} else {
+			pvc.ObjectPathMarker = strconv.FormatBool(value)
+		}
+	}
+	if pvc.ObjectPathMarker == "true" && pvc.ObjectPath == "" {
+		errs = append(errs, errors.New("object-path-marker needs an object-path"))
+	}
+
+	// a new bucket is empty, its object-path can only be there if we create it
+	if pvc.AutoCreateBucket == "true" && pvc.ObjectPath != "" && pvc.CreateObjectPath != "true" {
+		errs = append(errs, fmt.Errorf("object-path cannot be set when auto-create is enabled, got: %s", pvc.ObjectPath))
+	}
+
+	if pvc.Sources != "" {
+		sources, err := driver.ParseSources(pvc.Sources)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for sources: %v", err))
+		}
+		if pvc.AutoCreateBucket == "true" || pvc.AutoDeleteBucket == "true" {
+			errs = append(errs, errors.New("sources can only be set when bucket auto-create and auto-delete are disabled"))
+		}
+		// the first source stands for the volume in bucket checks and policies
+		if pvc.Bucket == "" && len(sources) > 0 {
+			pvc.Bucket = sources[0].Bucket
+		}
 	}
 
 	// Additional parameter should be of form "-o opt1 -o opt2=xxx -o opt3"
@@ -420,11 +1037,29 @@ func (p *IBMS3fsProvisioner) validateAnnotations(ctx context.Context, options co
 		sc.AddMountParam = pvc.AddMountParam
 	}
 
+	// every invalid annotation is reported at once, so that the claim is fixed in one iteration
+	if len(errs) > 0 {
+		return pvc, sc, svcIp, fmt.Errorf(pvcName+":"+clusterID+":%v", utilerrors.NewAggregate(errs))
+	}
 	return pvc, sc, svcIp, nil
 }
 
-// Provision provisions a new persistent volume
+// Provision provisions a new persistent volume, unless the backoff recorded on the claim by its previous
+// failures runs
 func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.ProvisionOptions) (*v1.PersistentVolume, controller.ProvisioningState, error) {
+	if err := p.checkRetryBackoff(options.PVC); err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(options.PVC.Name+":"+os.Getenv("CLUSTER_ID")+":%v", err)
+	}
+	pv, state, err := p.provision(ctx, options)
+	if err != nil {
+		p.NamespaceQuota.release(options.PVC)
+	}
+	p.recordRetryBackoff(ctx, options.PVC, err)
+	return pv, state, err
+}
+
+// provision provisions a new persistent volume
+func (p *IBMS3fsProvisioner) provision(ctx context.Context, options controller.ProvisionOptions) (*v1.PersistentVolume, controller.ProvisioningState, error) {
 	//var pvc pvcAnnotations
 	//var sc scOptions
 	var pvcName = options.PVC.Name
@@ -442,6 +1077,8 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 	var setBucketAccessPolicy = false
 	var setQuotaLimit = false
 	var quotaLimit int64
+	var bucketCreated = false
+	var replicationTarget string
 
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -449,10 +1086,27 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 	contextLogger.Info(pvcName + ":" + clusterID + ":Provisioning storage with these spec")
 	contextLogger.Info(pvcName+":"+clusterID+":PVC Details: ", zap.String("pvc", options.PVName))
 
+	if err := p.checkVolumeMode(ctx, options.PVC, options.StorageClass.Provisioner); err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":%w", err)
+	}
+
+	if err := p.checkNamespaceQuota(ctx, options.PVC, options.StorageClass.Provisioner); err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":%v", err)
+	}
+
 	pvc, sc, svcIp, err := p.validateAnnotations(ctx, options)
 	if err != nil {
 		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot validate annotations: %v", err)
 	}
+	backup, err := backupAnnotations(options.PVC.Annotations)
+	if err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot validate annotations: %v", err)
+	}
+
+	namespaceMetadata, err := p.namespaceMetadata(ctx, options.PVC.Namespace)
+	if err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot read the namespace metadata: %v", err)
+	}
 
 	//this handles the case where AutoDeleteBucket is set to true
 	if pvc.AutoDeleteBucket == "true" {
@@ -471,6 +1125,9 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 		pvc.Bucket = autoBucketNamePrefix + id
 	}
 
+	// validated with the annotations
+	locationConstraint, _ := backend.LocationConstraint(sc.OSEndpoint, sc.OSStorageClass)
+
 	if pvc.ValidateBucket == "no" && pvc.AutoCreateBucket == "false" {
 		valBucket = false
 	} else {
@@ -485,7 +1142,9 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 		}
 
 		creds.IAMEndpoint = sc.IAMEndpoint
-		sess = p.Backend.NewObjectStorageSession(sc.OSEndpoint, sc.OSStorageClass, creds, p.Logger)
+		transport, _ := cosTransport(sc)
+		factory, _ := p.sessionFactory(sc.Backend)
+		sess = factory.NewObjectStorageSession(sc.OSEndpoint, sc.OSStorageClass, creds, transport, p.Logger)
 	}
 
 	if len(allowedNamespace) > 0 {
@@ -602,7 +1261,14 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 		}
 
 		contextLogger.Info(pvcName + ":" + clusterID + " :creating bucket: " + pvc.Bucket)
-		msg, err = sess.CreateBucket(pvc.Bucket, sc.OSStorageClass)
+		if sc.BucketACL == backend.BucketACLPublicRead {
+			contextLogger.Warn(pvcName + ":" + clusterID + " :bucket '" + pvc.Bucket + "' is created public-read, anyone can read its objects")
+		}
+		createCtx, stopProgress := p.trackProgress(ctx, v1.ObjectReference{Kind: "PersistentVolumeClaim",
+			Namespace: options.PVC.Namespace, Name: options.PVC.Name, UID: options.PVC.UID},
+			options.StorageClass.Provisioner, "creating bucket "+pvc.Bucket)
+		msg, err = sess.CreateBucket(createCtx, pvc.Bucket, locationConstraint, sc.BucketACL)
+		stopProgress()
 		if msg != "" {
 			contextLogger.Info(pvcName + ":" + clusterID + " : " + msg)
 		}
@@ -613,8 +1279,28 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 				deleteBucket = false
 				contextLogger.Info(pvcName + ":" + clusterID + " :bucket '" + pvc.Bucket + "' already exists")
 			} else {
-				return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+" :cannot create bucket %s: %v", pvc.Bucket, err)
+				return nil, backendFailureState(err), fmt.Errorf(pvcName+":"+clusterID+" :cannot create bucket %s: %v", pvc.Bucket, err)
+			}
+		}
+		// the bucket is deleted on a later failure only when it did not exist
+		bucketCreated = deleteBucket
+		if bucketCreated {
+			p.tagBucket(ctx, sess, options.PVC, options.StorageClass.Provisioner, pvc.Bucket,
+				bucketTags(namespaceMetadata, p.requester(options.PVC)))
+		}
+
+		// validated with the annotations
+		replication, _ := replicationRule(sc)
+		if bucketCreated && replication != nil {
+			if err := sess.SetBucketReplication(ctx, pvc.Bucket, *replication); err != nil {
+				//revert bucket creation, the volume would have no DR copies
+				if err1 := sess.DeleteBucket(ctx, pvc.Bucket); err1 != nil {
+					return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+" : "+clusterID+" :cannot set replication of bucket %s: %v, and cannot delete it: %v", pvc.Bucket, err, err1)
+				}
+				return nil, backendFailureState(err), fmt.Errorf(pvcName+" : "+clusterID+" :failed to set replication for bucket %s : %v", pvc.Bucket, err)
 			}
+			replicationTarget = replication.TargetBucket + "@" + replication.TargetRegion
+			contextLogger.Info(pvcName + ":" + clusterID + " bucket :'" + pvc.Bucket + "' replicated to '" + replicationTarget + "'")
 		}
 
 		if setBucketAccessPolicy {
@@ -622,7 +1308,7 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 			if err != nil {
 				//revert bucket creation if updating bucket access policy fails
 				if deleteBucket {
-					err1 := sess.DeleteBucket(pvc.Bucket)
+					err1 := sess.DeleteBucket(ctx, pvc.Bucket)
 					if err1 != nil {
 						return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+" : "+clusterID+" :cannot set access policy %v", err1, " and cannot delete bucket %s :  %v", pvc.Bucket, err)
 					}
@@ -637,7 +1323,7 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 			if err != nil {
 				//revert bucket creation if updating bucket access policy fails
 				if deleteBucket {
-					err1 := sess.DeleteBucket(pvc.Bucket)
+					err1 := sess.DeleteBucket(ctx, pvc.Bucket)
 					if err1 != nil {
 						return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+" : "+clusterID+" :cannot set quota limit %v", err1, " and cannot delete bucket %s :  %v", pvc.Bucket, err)
 					}
@@ -670,18 +1356,54 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 	}
 
 	if valBucket {
-		if err := sess.CheckBucketAccess(pvc.Bucket); err != nil {
-			return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+" : "+clusterID+" :cannot access bucket %s: %v", pvc.Bucket, err)
+		if err := backend.CheckAccess(ctx, sess, sc.BucketAccessCheck, pvc.Bucket, pvc.ObjectPath); err != nil {
+			return nil, backendFailureState(err), fmt.Errorf(pvcName+" : "+clusterID+" :cannot access bucket %s: %v", pvc.Bucket, err)
+		}
+		if err := checkBucketLocation(ctx, sess, pvc.Bucket, locationConstraint, contextLogger); err != nil {
+			return nil, backendFailureState(err), fmt.Errorf(pvcName+" : "+clusterID+" :%v", err)
 		}
 	}
 
+	objectPathAsPrefix, _ := strconv.ParseBool(pvc.ObjectPathAsPrefix)
 	if pvc.ObjectPath != "" {
-		exist, err := sess.CheckObjectPathExistence(pvc.Bucket, pvc.ObjectPath)
+		exist, err := sess.CheckObjectPathExistence(ctx, pvc.Bucket, pvc.ObjectPath, objectPathAsPrefix)
 		if err != nil {
-			return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+" :cannot access object-path \"%s\" inside bucket %s: %v", pvc.ObjectPath, pvc.Bucket, err)
+			return nil, backendFailureState(err), fmt.Errorf(pvcName+":"+clusterID+" :cannot access object-path \"%s\" inside bucket %s: %v", pvc.ObjectPath, pvc.Bucket, err)
+		} else if !exist && pvc.CreateObjectPath == "true" {
+			contextLogger.Info(pvcName + ":" + clusterID + " :creating object-path '" + pvc.ObjectPath + "' inside bucket '" + pvc.Bucket + "'")
+			if err := sess.CreateObjectPath(ctx, pvc.Bucket, pvc.ObjectPath); err != nil {
+				return nil, backendFailureState(err), fmt.Errorf(pvcName+":"+clusterID+" :%v", err)
+			}
 		} else if !exist {
 			return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+" :object-path \"%s\" not found inside bucket %s", pvc.ObjectPath, pvc.Bucket)
 		}
+		if pvc.ObjectPathMarker == "true" {
+			if err := sess.CreateObjectPathMarker(ctx, pvc.Bucket, pvc.ObjectPath); err != nil {
+				return nil, backendFailureState(err), fmt.Errorf(pvcName+":"+clusterID+" :%v", err)
+			}
+		}
+	}
+
+	if pvc.Sources != "" {
+		sources, _ := driver.ParseSources(pvc.Sources)
+		for _, source := range sources {
+			if valBucket {
+				if err := backend.CheckAccess(ctx, sess, sc.BucketAccessCheck, source.Bucket, source.ObjectPath); err != nil {
+					return nil, backendFailureState(err), fmt.Errorf(pvcName+" : "+clusterID+" :cannot access bucket %s of source %s: %v", source.Bucket, source.Name, err)
+				}
+				if err := checkBucketLocation(ctx, sess, source.Bucket, locationConstraint, contextLogger); err != nil {
+					return nil, backendFailureState(err), fmt.Errorf(pvcName+" : "+clusterID+" :source %s: %v", source.Name, err)
+				}
+			}
+			if source.ObjectPath != "" {
+				exist, err := sess.CheckObjectPathExistence(ctx, source.Bucket, source.ObjectPath, objectPathAsPrefix)
+				if err != nil {
+					return nil, backendFailureState(err), fmt.Errorf(pvcName+":"+clusterID+" :cannot access object-path \"%s\" inside bucket %s: %v", source.ObjectPath, source.Bucket, err)
+				} else if !exist {
+					return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+" :object-path \"%s\" not found inside bucket %s", source.ObjectPath, source.Bucket)
+				}
+			}
+		}
 	}
 
 	if pvc.UseXattr {
@@ -702,16 +1424,26 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 	}
 
 	// Check AccessMode
-	accessMode := options.PVC.Spec.AccessModes
-	contextLogger.Info(pvcName+":"+clusterID+": acccess mode is.. ", zap.Any("access mode", accessMode))
-	if len(accessMode) > 1 {
-		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName + ":" + clusterID + ": More that one access mode is not supported.")
+	contextLogger.Info(pvcName+":"+clusterID+": acccess mode is.. ", zap.Any("access mode", options.PVC.Spec.AccessModes))
+	accessMode, err := validateAccessModes(options.PVC.Spec.AccessModes)
+	if err != nil {
+		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+": %v", err)
 	}
 
+	p.warnSharedBucketWriters(ctx, options.PVC, options.StorageClass.Provisioner, pvc.Bucket, accessMode)
+
 	if pvc.AutoCache {
 		sc.KernelCache = false
 	}
 
+	var ahbeConf string
+	if sc.AhbeConfigMap != "" {
+		ahbeConf, err = p.getAhbeConf(ctx, sc.AhbeConfigMap, options.PVC.Namespace)
+		if err != nil {
+			return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot get additional headers: %v", err)
+		}
+	}
+
 	driverOptions, err := parser.MarshalToMap(&driver.Options{
 		ChunkSizeMB:             sc.ChunkSizeMB,
 		ParallelCount:           sc.ParallelCount,
@@ -731,22 +1463,58 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 		ReadwriteTimeoutSeconds: sc.ReadwriteTimeoutSeconds,
 		ConnectTimeoutSeconds:   sc.ConnectTimeoutSeconds,
 		UseXattr:                sc.UseXattr,
-		AccessMode:              string(accessMode[0]),
+		AccessMode:              string(accessMode),
 		CosServiceIP:            svcIp,
 		AutoCache:               pvc.AutoCache,
 		AddMountParam:           sc.AddMountParam,
+		TmpfsCacheSizeMB:        sc.TmpfsCacheSizeMB,
+		WriteBackCache:          sc.WriteBackCache,
+		WriteBackDelaySeconds:   sc.WriteBackDelaySeconds,
+		ReadAheadKB:             sc.ReadAheadKB,
+		ReaddirOptimize:         sc.ReaddirOptimize,
+		NoObjCache:              sc.NoObjCache,
+		MultipartSizeMB:         sc.MultipartSizeMB,
+		SinglepartCopyLimitMB:   sc.SinglepartCopyLimitMB,
+		MaxDirtyDataMB:          sc.MaxDirtyDataMB,
+		ListObjectMaxKeys:       sc.ListObjectMaxKeys,
+		PermissionMode:          sc.PermissionMode,
+		CompatDir:               sc.CompatDir,
+		NotSupCompatDir:         sc.NotSupCompatDir,
+		ComplementStat:          sc.ComplementStat,
+		AhbeConf:                ahbeConf,
+		DetectContentType:       sc.DetectContentType,
+		SELinuxContext:          sc.SELinuxContext,
+		CompatProfile:           sc.CompatProfile,
+		LogFile:                 sc.LogFile,
+		MounterCPULimit:         sc.MounterCPULimit,
+		MounterMemoryLimit:      sc.MounterMemoryLimit,
+		MountOptions:            strings.Join(options.StorageClass.MountOptions, ","),
+		ExtraMountOptions:       sc.ExtraMountOptions,
+		Sources:                 pvc.Sources,
+		ClientSideEncryption:    sc.ClientSideEncryption,
+		Compression:             sc.Compression,
+		AuthMode:                sc.AuthMode,
+		TrustedProfileID:        sc.TrustedProfileID,
+		BucketAccessCheck:       sc.BucketAccessCheck,
+		ObjectPathAsPrefix:      objectPathAsPrefix,
+		Backend:                 sc.Backend,
 	})
 	if err != nil {
 		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot marshal driver options: %v", err)
 	}
 
 	pvcAnnots, err := parser.MarshalToMap(&pvcAnnotations{
+		APIVersion:              pvc.APIVersion,
 		AutoCreateBucket:        pvc.AutoCreateBucket,
 		AutoDeleteBucket:        pvc.AutoDeleteBucket,
 		Bucket:                  pvc.Bucket,
 		ObjectPath:              pvc.ObjectPath,
-		Endpoint:                pvc.Endpoint,
-		Region:                  pvc.Region,
+		Sources:                 pvc.Sources,
+		CreateObjectPath:        pvc.CreateObjectPath,
+		ObjectPathAsPrefix:      pvc.ObjectPathAsPrefix,
+		ObjectPathMarker:        pvc.ObjectPathMarker,
+		OSEndpoint:              pvc.OSEndpoint,
+		OSStorageClass:          pvc.OSStorageClass,
 		SecretName:              pvc.SecretName,
 		ChunkSizeMB:             pvc.ChunkSizeMB,
 		ParallelCount:           pvc.ParallelCount,
@@ -770,25 +1538,76 @@ func (p *IBMS3fsProvisioner) Provision(ctx context.Context, options controller.P
 	if err != nil {
 		return nil, controller.ProvisioningFinished, fmt.Errorf(pvcName+":"+clusterID+":cannot marshal pv options: %v", err)
 	}
+	pvcAnnots[AnnotationAccessSemantics] = accessModeSemantics[accessMode]
+	for key, value := range backup {
+		pvcAnnots[key] = value
+	}
+	if replicationTarget != "" {
+		pvcAnnots[AnnotationReplicationTarget] = replicationTarget
+	}
+	if requester := p.requester(options.PVC); requester != "" {
+		pvcAnnots[AnnotationRequestedBy] = requester
+	}
+
+	// the capacity is the quota enforced on the bucket, the request of the claim when there is none
+	capacity := options.PVC.Spec.Resources.Requests[v1.ResourceName(v1.ResourceStorage)]
+	if setQuotaLimit {
+		capacity = *resource.NewQuantity(quotaLimit, resource.BinarySI)
+	} else if !bucketCreated && resConfApiKey != "" && p.AccessPolicy != nil {
+		usage, err := p.AccessPolicy.NewAccessPolicy().GetBucketUsage(resConfApiKey, pvc.Bucket, sc.OSEndpoint, sc.IAMEndpoint, &backend.UpdateAPObj{})
+		if err != nil {
+			contextLogger.Warn(pvcName+":"+clusterID+" :cannot get the quota of bucket '"+pvc.Bucket+"', the capacity is the PVC request", zap.Error(err))
+		} else if usage.HardQuota > 0 {
+			capacity = *resource.NewQuantity(usage.HardQuota, resource.BinarySI)
+		}
+	}
 
 	reclaimPolicy := options.StorageClass.ReclaimPolicy
+	// the driver only runs on linux nodes
+	nodeRequirements := []v1.NodeSelectorRequirement{{
+		Key:      v1.LabelOSStable,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{"linux"},
+	}}
+	// only schedule pods on nodes whose preflight checks passed
+	if ConfigNodeReadinessAffinity != nil && *ConfigNodeReadinessAffinity {
+		nodeRequirements = append(nodeRequirements, v1.NodeSelectorRequirement{
+			Key:      driver.NodeReadyLabel,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{"true"},
+		})
+	}
+	nodeAffinity := &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: nodeRequirements}},
+		},
+	}
+
+	// Nodes get IAM tokens from their instance identity, the secret stays with the provisioner
+	secretRef := &v1.SecretReference{Name: pvc.SecretName, Namespace: pvc.SecretNamespace}
+	if sc.AuthMode == driver.AuthModeInstanceIdentity {
+		secretRef = nil
+	}
+
 	return &v1.PersistentVolume{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        options.PVName,
 			Annotations: pvcAnnots,
+			Labels:      p.withNamespaceLabels(pvLabels(pvc.Bucket, sc.OSEndpoint, bucketCreated), namespaceMetadata),
 		},
 		Spec: v1.PersistentVolumeSpec{
+			NodeAffinity:                  nodeAffinity,
 			PersistentVolumeReclaimPolicy: *reclaimPolicy,
-			AccessModes:                   options.PVC.Spec.AccessModes,
+			AccessModes:                   []v1.PersistentVolumeAccessMode{accessMode},
 			Capacity: v1.ResourceList{
-				v1.ResourceStorage: options.PVC.Spec.Resources.Requests[v1.ResourceName(v1.ResourceStorage)],
+				v1.ResourceStorage: capacity,
 			},
 			PersistentVolumeSource: v1.PersistentVolumeSource{
 				FlexVolume: &v1.FlexPersistentVolumeSource{
 					Driver:    driverName,
 					FSType:    fsType,
-					SecretRef: &v1.SecretReference{Name: pvc.SecretName, Namespace: pvc.SecretNamespace},
-					ReadOnly:  false,
+					SecretRef: secretRef,
+					ReadOnly:  accessMode == v1.ReadOnlyMany,
 					Options:   driverOptions,
 				},
 			},
@@ -806,36 +1625,75 @@ func (p *IBMS3fsProvisioner) Delete(ctx context.Context, pv *v1.PersistentVolume
 	endpointValue := pv.Spec.PersistentVolumeSource.FlexVolume.Options["object-store-endpoint"]
 	regionValue := pv.Spec.PersistentVolumeSource.FlexVolume.Options["object-store-storage-class"]
 	iamEndpoint := pv.Spec.PersistentVolumeSource.FlexVolume.Options["iam-endpoint"]
+	backendName := pv.Spec.PersistentVolumeSource.FlexVolume.Options["backend"]
 
 	err := parser.UnmarshalMap(&pv.Annotations, &pvcAnnots)
 	if err != nil {
 		return fmt.Errorf("cannot unmarshal PV annotations: %v", err)
 	}
 
-	if pvcAnnots.AutoDeleteBucket == "true" {
-		if err = p.deleteBucket(ctx, &pvcAnnots, endpointValue, regionValue, iamEndpoint); err != nil {
+	if autoDelete, _ := parser.ParseBool(pvcAnnots.AutoDeleteBucket); autoDelete {
+		// a bucket shared with other volumes, e.g. static ones, outlives the volume that created it
+		others, err := p.otherBucketReferences(ctx, pv, pvcAnnots.Bucket)
+		if err != nil {
+			return fmt.Errorf("cannot check the references of bucket %s: %v", pvcAnnots.Bucket, err)
+		}
+		if len(others) > 0 {
+			contextLogger.Warn("Keeping the bucket referred to by other PVs", zap.String("bucket", pvcAnnots.Bucket),
+				zap.Strings("pvs", others))
+			p.warnBucketInUse(ctx, pv, pvcAnnots.Bucket, others)
+			return nil
+		}
+		secretName, secretNamespace, skip, err := p.deletionSecret(ctx, pv, pvcAnnots.Bucket, pvcAnnots.SecretName,
+			pvcAnnots.SecretNamespace)
+		if err != nil {
+			return fmt.Errorf("cannot delete bucket: %v", err)
+		}
+		if skip {
+			return nil
+		}
+		pvcAnnots.SecretName, pvcAnnots.SecretNamespace = secretName, secretNamespace
+		deleteCtx, stopProgress := p.trackProgress(ctx, v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name,
+			UID: pv.UID}, driverName, "deleting bucket "+pvcAnnots.Bucket)
+		err = p.deleteBucket(deleteCtx, &pvcAnnots, backendName, endpointValue, regionValue, iamEndpoint)
+		stopProgress()
+		if err != nil {
 			return fmt.Errorf("cannot delete bucket: %v", err)
 		}
-	} else if _, err = strconv.ParseBool(pvcAnnots.AutoDeleteBucket); err != nil {
+	} else if _, err = parser.ParseBool(pvcAnnots.AutoDeleteBucket); err != nil {
 		return fmt.Errorf("invalid value for auto-delete-bucket, expects true/false: %v", err)
+	} else if pvcAnnots.ObjectPathMarker == "true" && pvcAnnots.ObjectPath != "" {
+		p.deleteObjectPathMarker(ctx, pv, &pvcAnnots, backendName, endpointValue, regionValue, iamEndpoint)
 	}
 	return nil
 }
 
-func (p *IBMS3fsProvisioner) deleteBucket(ctx context.Context, pvcAnnots *pvcAnnotations, endpointValue, regionValue, iamEndpoint string) error {
+func (p *IBMS3fsProvisioner) deleteBucket(ctx context.Context, pvcAnnots *pvcAnnotations, backendName, endpointValue, regionValue, iamEndpoint string) error {
 	contextLogger, _ := logger.GetZapDefaultContextLogger()
 	contextLogger.Info("Deleting the bucket..")
+	sess, err := p.deletionSession(ctx, pvcAnnots, backendName, endpointValue, regionValue, iamEndpoint)
+	if err != nil {
+		return err
+	}
+	return sess.DeleteBucket(ctx, pvcAnnots.Bucket)
+}
+
+// deletionSession returns a session with the credentials of the secret of a deleted volume
+func (p *IBMS3fsProvisioner) deletionSession(ctx context.Context, pvcAnnots *pvcAnnotations, backendName, endpointValue, regionValue, iamEndpoint string) (backend.ObjectStorageSession, error) {
 	// Retrieve CA Cert if provided in secert
 	if err := p.writeCrtFile(ctx, pvcAnnots.SecretName, pvcAnnots.SecretNamespace, pvcAnnots.CosServiceName); err != nil {
-		return fmt.Errorf("cannot retrieve secret: %v", err)
+		return nil, fmt.Errorf("cannot retrieve secret: %v", err)
 	}
 
 	creds, _, _, err := p.getCredentials(ctx, pvcAnnots.SecretName, pvcAnnots.SecretNamespace)
 	if err != nil {
-		return fmt.Errorf("cannot get credentials: %v", err)
+		return nil, fmt.Errorf("cannot get credentials: %v", err)
 	}
 	creds.IAMEndpoint = iamEndpoint
-	sess := p.Backend.NewObjectStorageSession(endpointValue, regionValue, creds, p.Logger)
-
-	return sess.DeleteBucket(pvcAnnots.Bucket)
+	factory, err := p.sessionFactory(backendName)
+	if err != nil {
+		return nil, err
+	}
+	// the storage class may be gone, the transport settings of the provisioner flags are used
+	return factory.NewObjectStorageSession(endpointValue, regionValue, creds, backend.TransportConfig{}, p.Logger), nil
 }
@@ -33,10 +33,12 @@ import (
 	//"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/api/core/v1"
 	//"k8s.io/client-go/pkg/runtime"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"strconv"
 	"testing"
+	"time"
 )
 
 const (
@@ -53,6 +55,7 @@ const (
 	testCAKey             = "cacrt-key"
 	testAllowedNamespace  = "test-allowed-namespace1 test-allowed-namespace2"
 	testResConAPIKey      = "test-resconfapikey"
+	testAhbeConfigMap     = "test-ahbe-configmap"
 
 	testChunkSizeMB            = 2
 	testParallelCount          = 3
@@ -74,6 +77,8 @@ const (
 	annotationAutoDeleteBucket        = "ibm.io/auto-delete-bucket"
 	annotationEndpoint                = "ibm.io/endpoint"
 	annotationRegion                  = "ibm.io/region"
+	annotationOSEndpoint              = "ibm.io/object-store-endpoint"
+	annotationOSStorageClass          = "ibm.io/object-store-storage-class"
 	annotationIAMEndpoint             = "ibm.io/iam-endpoint"
 	annotationSecretName              = "ibm.io/secret-name"
 	annotationSecretNamespace         = "ibm.io/secret-namespace"
@@ -87,6 +92,31 @@ const (
 	annotationAddMountParam           = "ibm.io/add-mount-param"
 	annotationAccessPolicyAllowedIps  = "ibm.io/access-policy-allowed-ips"
 	annotationQuotaLimit              = "ibm.io/quota-limit"
+	annotationTmpfsCacheSizeMB        = "ibm.io/tmpfs-cache-size-mb"
+	annotationWriteBackCache          = "ibm.io/write-back-cache"
+	annotationWriteBackDelaySeconds   = "ibm.io/write-back-delay-seconds"
+	annotationReadAheadKB             = "ibm.io/read-ahead-kb"
+	annotationReaddirOptimize         = "ibm.io/readdir-optimize"
+	annotationNoObjCache              = "ibm.io/noobj-cache"
+	annotationMultipartSizeMB         = "ibm.io/multipart-size-mb"
+	annotationSinglepartCopyLimitMB   = "ibm.io/singlepart-copy-limit-mb"
+	annotationMaxDirtyDataMB          = "ibm.io/max-dirty-data-mb"
+	annotationListObjectMaxKeys       = "ibm.io/list-object-max-keys"
+	annotationCompatDir               = "ibm.io/compat-dir"
+	annotationNotSupCompatDir         = "ibm.io/notsup-compat-dir"
+	annotationComplementStat          = "ibm.io/complement-stat"
+	annotationAhbeConfigMap           = "ibm.io/ahbe-configmap"
+	annotationDetectContentType       = "ibm.io/detect-content-type"
+	annotationSELinuxContext          = "ibm.io/selinux-context"
+	annotationLogFile                 = "ibm.io/log-file"
+	annotationExtraMountOptions       = "ibm.io/extra-mount-options"
+	annotationSources                 = "ibm.io/sources"
+	annotationCreateObjectPath        = "ibm.io/create-object-path"
+	annotationObjectPathAsPrefix      = "ibm.io/object-path-as-prefix"
+	annotationClientSideEncryption    = "ibm.io/client-side-encryption"
+	annotationCompression             = "ibm.io/compression"
+	annotationAuthMode                = "ibm.io/auth-mode"
+	annotationTrustedProfileID        = "ibm.io/trusted-profile-id"
 
 	parameterChunkSizeMB            = "ibm.io/chunk-size-mb"
 	parameterParallelCount          = "ibm.io/parallel-count"
@@ -102,6 +132,16 @@ const (
 	parameterStorageClass           = "ibm.io/object-store-storage-class"
 	parameterStatCacheExpireSeconds = "ibm.io/stat-cache-expire-seconds"
 	parameterAutoCache              = "ibm.io/auto_cache"
+	parameterPermissionMode         = "ibm.io/permission-mode"
+	parameterCompatProfile          = "ibm.io/compat-profile"
+	parameterMounterCPULimit        = "ibm.io/mounter-cpu-limit"
+	parameterMounterMemoryLimit     = "ibm.io/mounter-memory-limit"
+	parameterCosMaxIdleConns        = "ibm.io/cos-max-idle-conns"
+	parameterCosResponseTimeout     = "ibm.io/cos-response-header-timeout-seconds"
+	parameterCosKeepAlive           = "ibm.io/cos-keep-alive-seconds"
+	parameterBucketAccessCheck      = "ibm.io/bucket-access-check"
+	parameterBackend                = "ibm.io/backend"
+	parameterBucketACL              = "ibm.io/bucket-acl"
 
 	optionChunkSizeMB             = "chunk-size-mb"
 	optionParallelCount           = "parallel-count"
@@ -126,6 +166,33 @@ const (
 	optionServiceIP               = "service-ip"
 	optionAutoCache               = "auto_cache"
 	optionAddMountParam           = "add-mount-param"
+	optionTmpfsCacheSizeMB        = "tmpfs-cache-size-mb"
+	optionWriteBackCache          = "write-back-cache"
+	optionWriteBackDelaySeconds   = "write-back-delay-seconds"
+	optionReadAheadKB             = "read-ahead-kb"
+	optionReaddirOptimize         = "readdir-optimize"
+	optionNoObjCache              = "noobj-cache"
+	optionMultipartSizeMB         = "multipart-size-mb"
+	optionSinglepartCopyLimitMB   = "singlepart-copy-limit-mb"
+	optionMaxDirtyDataMB          = "max-dirty-data-mb"
+	optionListObjectMaxKeys       = "list-object-max-keys"
+	optionPermissionMode          = "permission-mode"
+	optionCompatDir               = "compat-dir"
+	optionComplementStat          = "complement-stat"
+	optionAhbeConf                = "ahbe-conf"
+	optionDetectContentType       = "detect-content-type"
+	optionSELinuxContext          = "selinux-context"
+	optionCompatProfile           = "compat-profile"
+	optionLogFile                 = "log-file"
+	optionMounterCPULimit         = "mounter-cpu-limit"
+	optionMounterMemoryLimit      = "mounter-memory-limit"
+	optionExtraMountOptions       = "extra-mount-options"
+	optionMountOptions            = "mount-options"
+	optionSources                 = "sources"
+	optionClientSideEncryption    = "client-side-encryption"
+	optionCompression             = "compression"
+	optionAuthMode                = "auth-mode"
+	optionTrustedProfileID        = "trusted-profile-id"
 )
 
 type clientGoConfig struct {
@@ -139,6 +206,8 @@ type clientGoConfig struct {
 	isTLS                 bool
 	withcaBundle          bool
 	withResConfAPIKey     bool
+	withAhbeConfigMap     bool
+	invalidAhbeConfigMap  bool
 }
 
 var (
@@ -152,8 +221,14 @@ func init() {
 	SockEndpoint = &endpt
 	accessPlcy := false
 	quotaLmt := false
+	nodeAffinity := false
+	forbidPublicBuckets := false
+	extraMountOptionsAllowlist := ""
+	ConfigForbidPublicBuckets = &forbidPublicBuckets
+	ConfigExtraMountOptionsAllowlist = &extraMountOptionsAllowlist
 	ConfigBucketAccessPolicy = &accessPlcy
 	ConfigQuotaLimit = &quotaLmt
+	ConfigNodeReadinessAffinity = &nodeAffinity
 }
 
 func getFakeClientGo(cfg *clientGoConfig) kubernetes.Interface {
@@ -212,6 +287,22 @@ func getFakeClientGo(cfg *clientGoConfig) kubernetes.Interface {
 		}
 		objects = append(objects, runtime.Object(secret))
 	}
+	if cfg.withAhbeConfigMap {
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      testAhbeConfigMap,
+				Namespace: testNamespace,
+			},
+			Data: map[string]string{
+				".html": "Cache-Control: max-age=300",
+				".gz":   "Content-Encoding: gzip\nCache-Control: no-transform",
+			},
+		}
+		if cfg.invalidAhbeConfigMap {
+			configMap.Data[".js"] = "Cache-Control"
+		}
+		objects = append(objects, runtime.Object(configMap))
+	}
 
 	return k8fake.NewSimpleClientset(objects...)
 }
@@ -401,6 +492,25 @@ func Test_Provision_PVCAnnotations_StorageClass_Positive(t *testing.T) {
 	assert.Equal(t, "test-storage-class-defined-in-pvc", pv.Spec.FlexVolume.Options[optionStorageClass])
 }
 
+func Test_Provision_PVCAnnotations_ObjectStore_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationOSEndpoint] = "https://test-object-store-endpoint-defined-in-pvc"
+	v.PVC.Annotations[annotationOSStorageClass] = "test-storage-class-defined-in-pvc"
+	// the deprecated annotations are overridden by their replacement
+	v.PVC.Annotations[annotationEndpoint] = "https://deprecated-endpoint"
+	v.PVC.Annotations[annotationRegion] = "deprecated-region"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://test-object-store-endpoint-defined-in-pvc", pv.Spec.FlexVolume.Options[optionOSEndpoint])
+		assert.Equal(t, "test-storage-class-defined-in-pvc", pv.Spec.FlexVolume.Options[optionStorageClass])
+		assert.Equal(t, "https://test-object-store-endpoint-defined-in-pvc", pv.Annotations[annotationOSEndpoint])
+		assert.NotContains(t, pv.Annotations, annotationEndpoint)
+		assert.NotContains(t, pv.Annotations, annotationRegion)
+	}
+}
+
 func Test_Provision_BadPVCIAMEndpoint(t *testing.T) {
 	p := getProvisioner()
 	v := getVolumeOptions()
@@ -434,6 +544,23 @@ func Test_Provision_PVCAnnotations_BadChunkSizeMB(t *testing.T) {
 	}
 }
 
+func Test_Provision_PVCAnnotations_AllErrorsReported(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationSecretName] = ""
+	v.PVC.Annotations["ibm.io/chunk-size-mb"] = "non-int-value"
+	v.PVC.Annotations["ibm.io/set-access-policy"] = "maybe"
+	v.StorageClass.Parameters[parameterParallelCount] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "secret-name not specified")
+		assert.Contains(t, err.Error(), "Cannot convert value of chunk-size-mb into integer")
+		assert.Contains(t, err.Error(), "invalid value for set-access-policy, expects true/false")
+		assert.Contains(t, err.Error(), "cannot unmarshal storage class parameters")
+	}
+}
+
 func Test_Provision_PVCAnnotations_ChunkSizeMB_Positive(t *testing.T) {
 	p := getProvisioner()
 	v := getVolumeOptions()
@@ -539,6 +666,30 @@ func Test_Provision_PVCAnnotations_StatCacheExpireSeconds_Positive(t *testing.T)
 	assert.Equal(t, "6", pv.Spec.FlexVolume.Options[optionStatCacheExpireSeconds])
 }
 
+func Test_Provision_PVCAnnotations_StatCacheExpire_Duration(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationStatCacheExpireSeconds] = "6"
+	v.PVC.Annotations["ibm.io/stat-cache-expire"] = "1m30s"
+	v.PVC.Annotations[annotationConnectTimeoutSeconds] = "2m"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "90", pv.Spec.FlexVolume.Options[optionStatCacheExpireSeconds])
+	assert.Equal(t, "120", pv.Spec.FlexVolume.Options[optionConnectTimeoutSeconds])
+}
+
+func Test_Provision_PVCAnnotations_BadStatCacheExpire_Duration(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations["ibm.io/stat-cache-expire"] = "1500ms"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects a whole number of seconds")
+	}
+}
+
 func Test_Provision_PVCAnnotations_BadS3FSFUSERetryCount(t *testing.T) {
 	p := getProvisioner()
 	v := getVolumeOptions()
@@ -780,6 +931,60 @@ func Test_Provision_ConfigQuotaLimit_AnnotationQuotaLimit_True(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func getQuotaProvisioner(t *testing.T, accessPolicy *fake.FakeAccessPolicyFactory, quotaLimit bool) *IBMS3fsProvisioner {
+	accessPolicyDisabled := false
+	previousAccessPolicy, previousQuotaLimit := ConfigBucketAccessPolicy, ConfigQuotaLimit
+	ConfigBucketAccessPolicy, ConfigQuotaLimit = &accessPolicyDisabled, &quotaLimit
+	t.Cleanup(func() { ConfigBucketAccessPolicy, ConfigQuotaLimit = previousAccessPolicy, previousQuotaLimit })
+	return getCustomProvisioner(
+		&clientGoConfig{withResConfAPIKey: true},
+		&fake.ObjectStorageSessionFactory{},
+		&fakeGrpcClient.FakeGrpcSessionFactory{},
+		accessPolicy,
+		&fakeProvider.FakeIBMProviderClientFactory{ClusterTypeVpcG2: true, TestSvcEndpoint: true},
+		uuid.NewCryptoGenerator(),
+	)
+}
+
+func Test_Provision_Capacity_QuotaLimit(t *testing.T) {
+	p := getQuotaProvisioner(t, &fake.FakeAccessPolicyFactory{}, true)
+	v := getVolumeOptions()
+	v.PVC.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1500M")}
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	assert.Equal(t, int64(1500000000), capacity.Value())
+}
+
+func Test_Provision_Capacity_ExistingBucketQuota(t *testing.T) {
+	p := getQuotaProvisioner(t, &fake.FakeAccessPolicyFactory{BucketUsage: backend.BucketUsage{HardQuota: 5 << 30}}, false)
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	assert.Equal(t, "5Gi", capacity.String())
+}
+
+func Test_Provision_Capacity_NoQuota(t *testing.T) {
+	for _, accessPolicy := range []*fake.FakeAccessPolicyFactory{{}, {FailGetBucketUsage: true}} {
+		p := getQuotaProvisioner(t, accessPolicy, false)
+		v := getVolumeOptions()
+		v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+		v.PVC.Annotations[annotationBucket] = testBucket
+		v.PVC.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}
+
+		pv, _, err := p.Provision(context.Background(), v)
+		assert.NoError(t, err)
+		capacity := pv.Spec.Capacity[v1.ResourceStorage]
+		assert.Equal(t, "1Gi", capacity.String())
+	}
+}
+
 func Test_Provision_BadPVCAnnotations_AccessPolicyAllowedIps(t *testing.T) {
 	p := getProvisioner()
 	v := getVolumeOptions()
@@ -1119,6 +1324,30 @@ func Test_Provision_FailCheckBucketAccess(t *testing.T) {
 	}
 }
 
+func Test_Provision_FailCheckBucketAccess_AccessDenied(t *testing.T) {
+	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{FailCheckBucketAccess: true, FailCheckBucketAccessKind: backend.ErrorAccessDenied},
+		&fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+
+	_, state, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ": access denied")
+	}
+	assert.Equal(t, controller.ProvisioningFinished, state)
+}
+
+func Test_Provision_FailCheckBucketAccess_EndpointUnreachable(t *testing.T) {
+	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{FailCheckBucketAccess: true, FailCheckBucketAccessKind: backend.ErrorUnreachable},
+		&fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+
+	_, state, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ": endpoint unreachable")
+	}
+	assert.Equal(t, controller.ProvisioningInBackground, state)
+}
+
 func Test_Provision_PVCAnnotations_ObjectPath_Positive(t *testing.T) {
 	factory := &fake.ObjectStorageSessionFactory{}
 	grpcFac := &fakeGrpcClient.FakeGrpcSessionFactory{}
@@ -1135,6 +1364,104 @@ func Test_Provision_PVCAnnotations_ObjectPath_Positive(t *testing.T) {
 	assert.Equal(t, testObjectPath, pv.Spec.FlexVolume.Options[optionObjectPath])
 }
 
+func Test_Provision_PVCAnnotations_ObjectPathAsPrefix_Positive(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationObjectPathAsPrefix] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.True(t, factory.LastObjectPathAsPrefix)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options["object-path-as-prefix"])
+}
+
+func Test_Provision_PVCAnnotations_BadObjectPathAsPrefix(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationObjectPathAsPrefix] = "maybe"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for object-path-as-prefix, expects true/false")
+	}
+}
+
+func Test_Provision_LocationConstraint_StorageClassOnly(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterOSEndpoint] = "https://s3.us-south.cloud-object-storage.appdomain.cloud"
+	v.StorageClass.Parameters[parameterStorageClass] = "Smart"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-south-smart", factory.LastLocationConstraint)
+	assert.Equal(t, "Smart", pv.Spec.FlexVolume.Options[optionStorageClass])
+}
+
+func Test_Provision_LocationConstraint_RegionMismatch(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterOSEndpoint] = "https://s3.us-south.cloud-object-storage.appdomain.cloud"
+	v.StorageClass.Parameters[parameterStorageClass] = "eu-de-smart"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "object-store-storage-class \"eu-de-smart\" is for location eu-de")
+	}
+}
+
+func getBucketLocationProvisioner(factory *fake.ObjectStorageSessionFactory) (*IBMS3fsProvisioner, controller.ProvisionOptions) {
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	return p, v
+}
+
+func Test_Provision_BucketLocation_Positive(t *testing.T) {
+	p, v := getBucketLocationProvisioner(&fake.ObjectStorageSessionFactory{BucketLocation: "TEST-STORAGE-CLASS"})
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+}
+
+func Test_Provision_BucketLocation_Mismatch(t *testing.T) {
+	p, v := getBucketLocationProvisioner(&fake.ObjectStorageSessionFactory{BucketLocation: "eu-de-smart"})
+
+	_, state, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bucket "+testBucket+" is in location eu-de-smart, the storage class expects "+testStorageClass)
+		assert.Equal(t, controller.ProvisioningFinished, state)
+	}
+}
+
+func Test_Provision_BucketLocation_AccessDenied(t *testing.T) {
+	p, v := getBucketLocationProvisioner(&fake.ObjectStorageSessionFactory{
+		FailGetBucketLocation: true, FailGetBucketLocationKind: backend.ErrorAccessDenied})
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+}
+
+func Test_Provision_BucketLocation_Error(t *testing.T) {
+	p, v := getBucketLocationProvisioner(&fake.ObjectStorageSessionFactory{
+		FailGetBucketLocation: true, FailGetBucketLocationKind: backend.ErrorUnreachable})
+
+	_, state, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot get location of bucket")
+		assert.Equal(t, controller.ProvisioningInBackground, state)
+	}
+}
+
 func Test_Provision_CheckObjectPathExistence_Error(t *testing.T) {
 	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{CheckObjectPathExistenceError: true}, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
 	v := getVolumeOptions()
@@ -1216,6 +1543,36 @@ func Test_Provision_KernelCache_Positive(t *testing.T) {
 	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionKernelCache])
 }
 
+func Test_Provision_SCParameters_Defaults(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	delete(v.StorageClass.Parameters, parameterChunkSizeMB)
+	delete(v.StorageClass.Parameters, parameterTLSCipherSuite)
+	v.StorageClass.Parameters[parameterParallelCount] = ""
+	v.StorageClass.Parameters[parameterDebugLevel] = ""
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", pv.Spec.FlexVolume.Options[optionChunkSizeMB])
+	assert.Equal(t, "5", pv.Spec.FlexVolume.Options[optionParallelCount])
+	assert.Equal(t, "AES", pv.Spec.FlexVolume.Options[optionTLSCipherSuite])
+	assert.Equal(t, "warn", pv.Spec.FlexVolume.Options[optionDebugLevel])
+}
+
+func Test_Provision_FlexibleBooleans_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterKernelCache] = "On"
+	v.PVC.Annotations[annotationAutoCreateBucket] = "Yes"
+	v.PVC.Annotations["ibm.io/auto-delete-bucket"] = "0"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionKernelCache])
+	assert.Equal(t, "true", pv.Annotations[annotationAutoCreateBucket])
+	assert.Equal(t, "false", pv.Annotations["ibm.io/auto-delete-bucket"])
+}
+
 func Test_Provision_AccessMode_Negative(t *testing.T) {
 	p := getProvisioner()
 	v := getVolumeOptions()
@@ -1244,6 +1601,39 @@ func Test_Provision_AccessMode_ReadOnly_Positive(t *testing.T) {
 	pv, _, err := p.Provision(context.Background(), v)
 	assert.NoError(t, err)
 	assert.Equal(t, "ReadOnlyMany", pv.Spec.FlexVolume.Options[optionAccessMode])
+	assert.True(t, pv.Spec.FlexVolume.ReadOnly)
+	assert.Equal(t, []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}, pv.Spec.AccessModes)
+	assert.Contains(t, pv.Annotations[AnnotationAccessSemantics], "read-only")
+}
+
+func Test_Provision_AccessMode_ReadWriteOncePod(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOncePod}
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "access mode ReadWriteOncePod is not enforced for flex volumes")
+	}
+
+	allow := true
+	ConfigAllowReadWriteOncePod = &allow
+	defer func() { ConfigAllowReadWriteOncePod = nil }()
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.False(t, pv.Spec.FlexVolume.ReadOnly)
+	assert.Equal(t, "ReadWriteOncePod", pv.Spec.FlexVolume.Options[optionAccessMode])
+}
+
+func Test_Provision_AccessMode_Missing(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = nil
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "access mode not specified")
+	}
 }
 
 func Test_Provision_AutoBucketCreate_Positive(t *testing.T) {
@@ -1310,6 +1700,30 @@ func Test_Provision_BucketAutoDelete_Positive(t *testing.T) {
 	assert.Equal(t, pv.Spec.FlexVolume.Options[optionBucket], factory.LastCreatedBucket)
 }
 
+func Test_Provision_Delete_COSServer(t *testing.T) {
+	server := fake.NewCOSServer()
+	defer server.Close()
+	factory := &backend.COSSessionFactory{Endpoint: server.URL, Retry: backend.RetryPolicy{MaxAttempts: 1}}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoDeleteBucket] = "true"
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+	delete(v.PVC.Annotations, annotationBucket)
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	bucket := pv.Spec.FlexVolume.Options[optionBucket]
+	assert.Equal(t, []string{"test/object-path/"}, server.Objects(bucket))
+
+	assert.NoError(t, p.Delete(context.Background(), pv))
+	assert.False(t, server.HasBucket(bucket))
+}
+
 func Test_Delete_BadPVAnnotations(t *testing.T) {
 	p := getProvisioner()
 	pv := getAutoDeletePersistentVolume()
@@ -1600,3 +2014,793 @@ func Test_Provision_PVCAnnotations_AddMountParam(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, testAddMountParam, pv.Spec.FlexVolume.Options[optionAddMountParam])
 }
+
+func Test_Provision_PVCAnnotations_BadTmpfsCacheSizeMB_NonInt(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationTmpfsCacheSizeMB] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot convert value of tmpfs-cache-size-mb into integer")
+	}
+}
+
+func Test_Provision_PVCAnnotations_BadTmpfsCacheSizeMB_Zero(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationTmpfsCacheSizeMB] = "0"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of tmpfs-cache-size-mb should be >= 1")
+	}
+}
+
+func Test_Provision_PVCAnnotations_TmpfsCacheSizeMB_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationTmpfsCacheSizeMB] = "64"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "64", pv.Spec.FlexVolume.Options[optionTmpfsCacheSizeMB])
+}
+
+func Test_Provision_PVCAnnotations_BadWriteBackDelaySeconds(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationWriteBackDelaySeconds] = "-1"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of write-back-delay-seconds should be >= 0")
+	}
+}
+
+func Test_Provision_PVCAnnotations_WriteBackCache_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationWriteBackCache] = "true"
+	v.PVC.Annotations[annotationWriteBackDelaySeconds] = "30"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionWriteBackCache])
+	assert.Equal(t, "30", pv.Spec.FlexVolume.Options[optionWriteBackDelaySeconds])
+}
+
+func Test_Provision_PVCAnnotations_BadReadAheadKB(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationReadAheadKB] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot convert value of read-ahead-kb into integer")
+	}
+}
+
+func Test_Provision_PVCAnnotations_ReadAhead_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationReadAheadKB] = "1024"
+	v.PVC.Annotations[annotationReaddirOptimize] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "1024", pv.Spec.FlexVolume.Options[optionReadAheadKB])
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionReaddirOptimize])
+}
+
+func Test_Provision_PVCAnnotations_NoObjCache_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationNoObjCache] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionNoObjCache])
+}
+
+func Test_Provision_SCParameters_NoObjCache_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[annotationNoObjCache] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionNoObjCache])
+}
+
+func Test_Provision_PVCAnnotations_BadMultipartSizeMB(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationMultipartSizeMB] = "1"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of multipart-size-mb should be >= 5")
+	}
+}
+
+func Test_Provision_PVCAnnotations_BadSinglepartCopyLimitMB(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationSinglepartCopyLimitMB] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot convert value of singlepart-copy-limit-mb into integer")
+	}
+}
+
+func Test_Provision_PVCAnnotations_MultipartSize_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationMultipartSizeMB] = "64"
+	v.PVC.Annotations[annotationSinglepartCopyLimitMB] = "128"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "64", pv.Spec.FlexVolume.Options[optionMultipartSizeMB])
+	assert.Equal(t, "128", pv.Spec.FlexVolume.Options[optionSinglepartCopyLimitMB])
+}
+
+func Test_Provision_PVCAnnotations_BadMaxDirtyDataMB(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationMaxDirtyDataMB] = "10"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of max-dirty-data-mb should be -1 or >= 50")
+	}
+}
+
+func Test_Provision_PVCAnnotations_MaxDirtyDataMB_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationMaxDirtyDataMB] = "-1"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "-1", pv.Spec.FlexVolume.Options[optionMaxDirtyDataMB])
+}
+
+func Test_Provision_PVCAnnotations_ConnectTimeoutSeconds_Zero(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationConnectTimeoutSeconds] = "0"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of connect-timeout should be >= 1")
+	}
+}
+
+func Test_Provision_SCParameters_BadReadwriteTimeoutSeconds(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[annotationReadwriteTimeoutSeconds] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot convert value of readwrite-timeout-seconds into integer")
+	}
+}
+
+func Test_Provision_SCParameters_Timeouts_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[annotationConnectTimeoutSeconds] = "10"
+	v.StorageClass.Parameters[annotationReadwriteTimeoutSeconds] = "120"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", pv.Spec.FlexVolume.Options[optionConnectTimeoutSeconds])
+	assert.Equal(t, "120", pv.Spec.FlexVolume.Options[optionReadwriteTimeoutSeconds])
+}
+
+func Test_Provision_PVCAnnotations_BadListObjectMaxKeys(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationListObjectMaxKeys] = "non-int-value"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Cannot convert value of list-object-max-keys into integer")
+	}
+}
+
+func Test_Provision_PVCAnnotations_ListObjectMaxKeys_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationListObjectMaxKeys] = "500"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "500", pv.Spec.FlexVolume.Options[optionListObjectMaxKeys])
+}
+
+func Test_Provision_SCParameters_BadPermissionMode(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterPermissionMode] = "bad-mode"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for permission-mode")
+	}
+}
+
+func Test_Provision_SCParameters_PermissionMode_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterPermissionMode] = driver.PermissionModeStateless
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, driver.PermissionModeStateless, pv.Spec.FlexVolume.Options[optionPermissionMode])
+}
+
+func Test_Provision_PVCAnnotations_CompatDirConflict(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationCompatDir] = "true"
+	v.StorageClass.Parameters[annotationNotSupCompatDir] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "compat-dir and notsup-compat-dir cannot be set together")
+	}
+}
+
+func Test_Provision_PVCAnnotations_CompatDir_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationCompatDir] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionCompatDir])
+}
+
+func Test_Provision_PVCAnnotations_ComplementStat_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationComplementStat] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionComplementStat])
+}
+
+func Test_Provision_PVCAnnotations_AhbeConfigMap_Positive(t *testing.T) {
+	p := getFakeClientGoProvisioner(&clientGoConfig{withAhbeConfigMap: true})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAhbeConfigMap] = testAhbeConfigMap
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ".gz Content-Encoding gzip\n.gz Cache-Control no-transform\n.html Cache-Control max-age=300\n",
+			pv.Spec.FlexVolume.Options[optionAhbeConf])
+	}
+}
+
+func Test_Provision_AhbeConfigMap_Missing(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAhbeConfigMap] = testAhbeConfigMap
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot retrieve configmap "+testAhbeConfigMap)
+	}
+}
+
+func Test_Provision_AhbeConfigMap_InvalidHeader(t *testing.T) {
+	p := getFakeClientGoProvisioner(&clientGoConfig{withAhbeConfigMap: true, invalidAhbeConfigMap: true})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAhbeConfigMap] = testAhbeConfigMap
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid header \"Cache-Control\" for suffix .js")
+	}
+}
+
+func Test_Provision_PVCAnnotations_DetectContentType_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationDetectContentType] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionDetectContentType])
+}
+
+func Test_Provision_PVCAnnotations_SELinuxContext_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationSELinuxContext] = "system_u:object_r:container_file_t:s0"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "system_u:object_r:container_file_t:s0", pv.Spec.FlexVolume.Options[optionSELinuxContext])
+}
+
+func Test_Provision_SCParameters_BadCompatProfile(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterCompatProfile] = "bad-profile"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for compat-profile")
+	}
+}
+
+func Test_Provision_SCParameters_CompatProfile_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterCompatProfile] = driver.CompatProfileOpenShift
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, driver.CompatProfileOpenShift, pv.Spec.FlexVolume.Options[optionCompatProfile])
+}
+
+func Test_Provision_NodeReadinessAffinity(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	*ConfigNodeReadinessAffinity = true
+	defer func() { *ConfigNodeReadinessAffinity = false }()
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) && assert.NotNil(t, pv.Spec.NodeAffinity) {
+		requirement := pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[1]
+		assert.Equal(t, driver.NodeReadyLabel, requirement.Key)
+		assert.Equal(t, []string{"true"}, requirement.Values)
+	}
+}
+
+func Test_Provision_NoNodeReadinessAffinity(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) && assert.NotNil(t, pv.Spec.NodeAffinity) {
+		assert.Equal(t, []v1.NodeSelectorRequirement{{
+			Key:      "kubernetes.io/os",
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{"linux"},
+		}}, pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions)
+	}
+}
+
+func Test_Provision_PVCAnnotations_LogFile_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationLogFile] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionLogFile])
+}
+
+func Test_Provision_SCParameters_MounterLimits_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterMounterCPULimit] = "500m"
+	v.StorageClass.Parameters[parameterMounterMemoryLimit] = "512Mi"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "500m", pv.Spec.FlexVolume.Options[optionMounterCPULimit])
+	assert.Equal(t, "512Mi", pv.Spec.FlexVolume.Options[optionMounterMemoryLimit])
+}
+
+func Test_Provision_SCParameters_BadMounterCPULimit(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterMounterCPULimit] = "1m"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for mounter-cpu-limit")
+	}
+}
+
+func Test_Provision_SCParameters_BadMounterMemoryLimit(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterMounterMemoryLimit] = "lots"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for mounter-memory-limit")
+	}
+}
+
+func Test_Provision_SCParameters_CosTransport_Positive(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterCosMaxIdleConns] = "200"
+	v.StorageClass.Parameters[parameterCosResponseTimeout] = "15"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, backend.TransportConfig{MaxIdleConns: 200, ResponseHeaderTimeout: 15 * time.Second}, factory.LastTransport)
+}
+
+func Test_Provision_SCParameters_BadCosTransport(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterCosKeepAlive] = "0"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of cos-keep-alive-seconds should be an integer >= 1")
+	}
+}
+
+func Test_Provision_SCParameters_BucketAccessCheck_HeadObject(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBucketAccessCheck] = "head-object"
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, testBucket, factory.LastCheckedBucket)
+	assert.Equal(t, "test/object-path/", factory.LastCheckedObject)
+	assert.Equal(t, "head-object", pv.Spec.FlexVolume.Options["bucket-access-check"])
+}
+
+func Test_Provision_Delete_RegisteredBackend(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	other := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	p.Backends = &backend.Registry{}
+	assert.NoError(t, p.Backends.Register("other", other))
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBackend] = "other"
+	v.PVC.Annotations[annotationAutoDeleteBucket] = "true"
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	delete(v.PVC.Annotations, annotationBucket)
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", pv.Spec.FlexVolume.Options["backend"])
+	assert.NotEmpty(t, other.LastCreatedBucket)
+	assert.Empty(t, factory.LastCreatedBucket)
+
+	err = p.Delete(context.Background(), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, other.LastCreatedBucket, other.LastDeletedBucket)
+	assert.Empty(t, factory.LastDeletedBucket)
+}
+
+func Test_Provision_SCParameters_UnknownBackend(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBackend] = "other"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown object storage backend \"other\"")
+	}
+}
+
+func Test_Provision_SCParameters_BucketACL(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBucketACL] = "public-read"
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "public-read", factory.LastBucketACL)
+}
+
+func Test_Provision_SCParameters_BadBucketACL(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBucketACL] = "public-read-write"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown bucket ACL \"public-read-write\"")
+	}
+}
+
+func Test_Provision_SCParameters_BucketACL_PublicForbidden(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBucketACL] = "public-read"
+	*ConfigForbidPublicBuckets = true
+	defer func() { *ConfigForbidPublicBuckets = false }()
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "public buckets are forbidden by the provisioner configuration")
+	}
+}
+
+func Test_Provision_SCParameters_BadBucketAccessCheck(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[parameterBucketAccessCheck] = "list-bucket"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown bucket access check \"list-bucket\"")
+	}
+}
+
+func Test_Provision_PVCAnnotations_ExtraMountOptions_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationExtraMountOptions] = "nocopyapi,stat_cache_interval_expire=60"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "nocopyapi,stat_cache_interval_expire=60", pv.Spec.FlexVolume.Options[optionExtraMountOptions])
+}
+
+func Test_Provision_PVCAnnotations_ExtraMountOptions_NotAllowed(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationExtraMountOptions] = "nocopyapi,use_cache=/etc"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for extra-mount-options: mount option \"use_cache\" is not allowed")
+	}
+}
+
+func Test_Provision_PVCAnnotations_ExtraMountOptions_Malformed(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationExtraMountOptions] = "nocopyapi,,retries=3"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "malformed mount option")
+	}
+}
+
+func Test_Provision_SCMountOptions_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.MountOptions = []string{"nocopyapi", "retries=3"}
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "nocopyapi,retries=3", pv.Spec.FlexVolume.Options[optionMountOptions])
+	assert.Empty(t, pv.Spec.MountOptions)
+}
+
+func Test_Provision_SCMountOptions_NotAllowed(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.MountOptions = []string{"passwd_file=/etc/passwd"}
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid storage class mountOptions: mount option \"passwd_file\" is not allowed")
+	}
+}
+
+func Test_Provision_ExtraMountOptions_ConfiguredAllowlist(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationExtraMountOptions] = "use_cache=/mnt/cache"
+	*ConfigExtraMountOptionsAllowlist = "use_cache, nocopyapi"
+	defer func() { *ConfigExtraMountOptionsAllowlist = "" }()
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "use_cache=/mnt/cache", pv.Spec.FlexVolume.Options[optionExtraMountOptions])
+}
+
+func Test_Provision_PVCAnnotations_Sources_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationSources] = "logs=bucket-a,images=bucket-b/prefix"
+	delete(v.PVC.Annotations, annotationBucket)
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "logs=bucket-a,images=bucket-b/prefix", pv.Spec.FlexVolume.Options[optionSources])
+		assert.Equal(t, "bucket-a", pv.Spec.FlexVolume.Options[optionBucket])
+	}
+}
+
+func Test_Provision_PVCAnnotations_Sources_Invalid(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationSources] = "logs=bucket-a,logs/2=bucket-b"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for sources")
+	}
+}
+
+func Test_Provision_PVCAnnotations_Sources_WithAutoCreateBucket(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationSources] = "logs=bucket-a"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "sources can only be set when bucket auto-create and auto-delete are disabled")
+	}
+}
+
+func Test_Provision_PVCAnnotations_Sources_ObjectPathNotFound(t *testing.T) {
+	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{CheckObjectPathExistencePathNotFound: true}, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationSources] = "logs=bucket-a,images=bucket-b/prefix"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "object-path \"prefix\" not found inside bucket bucket-b")
+	}
+}
+
+func Test_Provision_CreateObjectPath_PathNotFound(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{CheckObjectPathExistencePathNotFound: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, testObjectPath, factory.LastCreatedObjectPath)
+}
+
+func Test_Provision_CreateObjectPath_PathExists(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "", factory.LastCreatedObjectPath)
+}
+
+func Test_Provision_CreateObjectPath_WithAutoCreateBucket(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{CheckObjectPathExistencePathNotFound: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, testObjectPath, factory.LastCreatedObjectPath)
+}
+
+func Test_Provision_CreateObjectPath_Error(t *testing.T) {
+	p := getFakeBackendProvisioner(&fake.ObjectStorageSessionFactory{CheckObjectPathExistencePathNotFound: true, FailCreateObjectPath: true}, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.Error(t, err)
+}
+
+func Test_Provision_CreateObjectPath_Invalid(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationCreateObjectPath] = "maybe"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for create-object-path, expects true/false")
+	}
+}
+
+func Test_Provision_PVCAnnotations_ClientSideEncryption_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationClientSideEncryption] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionClientSideEncryption])
+}
+
+func Test_Provision_PVCAnnotations_Compression_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationCompression] = "true"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionCompression])
+}
+
+func Test_Provision_PVCAnnotations_InstanceIdentity_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAuthMode] = "instance-identity"
+	v.PVC.Annotations[annotationTrustedProfileID] = "Profile-9fd84246"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "instance-identity", pv.Spec.FlexVolume.Options[optionAuthMode])
+		assert.Equal(t, "Profile-9fd84246", pv.Spec.FlexVolume.Options[optionTrustedProfileID])
+		assert.Nil(t, pv.Spec.FlexVolume.SecretRef)
+	}
+}
+
+func Test_Provision_PVCAnnotations_InstanceIdentity_MissingProfile(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAuthMode] = "instance-identity"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "trusted-profile-id must be set when auth-mode is instance-identity")
+	}
+}
+
+func Test_Provision_PVCAnnotations_AuthMode_Invalid(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAuthMode] = "hmac"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value for auth-mode, expects instance-identity, got: hmac")
+	}
+}
+
+func Test_Provision_BlockVolumeMode(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	blockMode := v1.PersistentVolumeBlock
+	v.PVC.Spec.VolumeMode = &blockMode
+
+	_, state, err := p.Provision(context.Background(), v)
+	assert.True(t, errors.Is(err, ErrBlockVolumeMode))
+	assert.Equal(t, controller.ProvisioningFinished, state)
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, UnsupportedVolumeModeReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "only Filesystem volumes can be provisioned")
+	}
+
+	fileSystemMode := v1.PersistentVolumeFilesystem
+	v.PVC.Spec.VolumeMode = &fileSystemMode
+	_, _, err = p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+}
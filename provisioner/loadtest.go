@@ -0,0 +1,108 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"runtime"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoadTest tells how many volumes LoadTest provisions and how
+type LoadTest struct {
+	// Volumes is the number of volumes to provision
+	Volumes int
+	// Concurrency is the number of volumes provisioned at once
+	Concurrency int
+	// Options returns the options of the i-th volume
+	Options func(i int) controller.ProvisionOptions
+}
+
+// LoadTestReport is the outcome of a load test
+type LoadTestReport struct {
+	Volumes  int
+	Failures int
+	// FirstError is the error of the first failed volume
+	FirstError error
+	Duration   time.Duration
+	// Throughput is the number of volumes provisioned per second
+	Throughput float64
+	// Latency percentiles of the provisioning of a volume
+	P50, P90, P99, Max time.Duration
+	// AllocBytes and Allocs are the bytes and objects allocated per volume, by the whole process
+	AllocBytes uint64
+	Allocs     uint64
+}
+
+// LoadTest provisions volumes concurrently and reports the throughput, the latency percentiles
+// and the allocations of Provision. The volumes are not deleted.
+func (p *IBMS3fsProvisioner) LoadTest(ctx context.Context, test LoadTest) LoadTestReport {
+	if test.Concurrency < 1 {
+		test.Concurrency = 1
+	}
+	options := make([]controller.ProvisionOptions, test.Volumes)
+	for i := range options {
+		options[i] = test.Options(i)
+	}
+	latencies := make([]time.Duration, test.Volumes)
+	errs := make([]error, test.Volumes)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < test.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				volumeStart := time.Now()
+				_, _, errs[i] = p.Provision(ctx, options[i])
+				latencies[i] = time.Since(volumeStart)
+			}
+		}()
+	}
+	for i := 0; i < test.Volumes; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	report := LoadTestReport{Volumes: test.Volumes, Duration: time.Since(start)}
+	runtime.ReadMemStats(&after)
+	for _, err := range errs {
+		if err != nil {
+			if report.Failures == 0 {
+				report.FirstError = err
+			}
+			report.Failures++
+		}
+	}
+	if test.Volumes == 0 {
+		return report
+	}
+	report.Throughput = float64(test.Volumes) / report.Duration.Seconds()
+	report.AllocBytes = (after.TotalAlloc - before.TotalAlloc) / uint64(test.Volumes)
+	report.Allocs = (after.Mallocs - before.Mallocs) / uint64(test.Volumes)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+	report.P50, report.P90, report.P99, report.Max = percentile(50), percentile(90), percentile(99), percentile(100)
+	return report
+}
@@ -0,0 +1,62 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"strconv"
+	"testing"
+)
+
+func Test_LoadTest_Positive(t *testing.T) {
+	p := getProvisioner()
+	report := p.LoadTest(context.Background(), LoadTest{
+		Volumes:     20,
+		Concurrency: 4,
+		Options: func(i int) controller.ProvisionOptions {
+			v := getVolumeOptions()
+			v.PVName = "pv-" + strconv.Itoa(i)
+			v.PVC.Annotations[annotationBucket] = "bucket-" + strconv.Itoa(i)
+			return v
+		},
+	})
+	assert.Equal(t, 20, report.Volumes)
+	assert.Equal(t, 0, report.Failures)
+	assert.NoError(t, report.FirstError)
+	assert.True(t, report.Throughput > 0)
+	assert.True(t, report.P50 <= report.P90 && report.P90 <= report.P99 && report.P99 <= report.Max)
+	assert.NotZero(t, report.Allocs)
+}
+
+func Test_LoadTest_Failures(t *testing.T) {
+	p := getProvisioner()
+	report := p.LoadTest(context.Background(), LoadTest{
+		Volumes: 3,
+		Options: func(i int) controller.ProvisionOptions {
+			v := getVolumeOptions()
+			if i > 0 {
+				delete(v.PVC.Annotations, annotationSecretName)
+			}
+			v.PVC.Annotations[annotationBucket] = testBucket
+			return v
+		},
+	})
+	assert.Equal(t, 2, report.Failures)
+	assert.Error(t, report.FirstError)
+}
+
+func Test_LoadTest_NoVolumes(t *testing.T) {
+	report := getProvisioner().LoadTest(context.Background(), LoadTest{})
+	assert.Equal(t, 0, report.Volumes)
+	assert.Zero(t, report.Throughput)
+}
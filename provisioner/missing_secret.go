@@ -0,0 +1,71 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Policies of the deletion of the buckets whose volume refers to a secret that is gone
+const (
+	// MissingSecretFail fails the deletion, the volume is kept until the secret is created again
+	MissingSecretFail = "fail"
+	// MissingSecretSkip deletes the volume and keeps its bucket
+	MissingSecretSkip = "skip"
+	// MissingSecretFallback deletes the bucket with the credentials of the cleanup secret
+	MissingSecretFallback = "fallback"
+)
+
+// MissingSecretReason is the reason of the warning events recorded on the volumes deleted without their secret
+const MissingSecretReason = "MissingSecret"
+
+// deletionSecret returns the name and namespace of the secret whose credentials delete the bucket of a volume,
+// according to MissingSecretPolicy when the secret of the volume is gone. It returns skip when the bucket is
+// kept. The failures to read the secret other than its absence are left to the deletion.
+func (p *IBMS3fsProvisioner) deletionSecret(ctx context.Context, pv *v1.PersistentVolume, bucket, name,
+	namespace string) (string, string, bool, error) {
+	if p.MissingSecretPolicy == "" || p.MissingSecretPolicy == MissingSecretFail {
+		return name, namespace, false, nil
+	}
+	if _, err := p.Client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		return name, namespace, false, nil
+	}
+	var message string
+	switch p.MissingSecretPolicy {
+	case MissingSecretSkip:
+		message = fmt.Sprintf("secret %s/%s is gone, bucket %s is not deleted and must be deleted by hand",
+			namespace, name, bucket)
+	case MissingSecretFallback:
+		if p.CleanupSecretName == "" {
+			return "", "", false, errors.New("secret " + namespace + "/" + name + " is gone and no cleanup secret is set")
+		}
+		message = fmt.Sprintf("secret %s/%s is gone, bucket %s is deleted with the cleanup secret %s/%s",
+			namespace, name, bucket, p.CleanupSecretNamespace, p.CleanupSecretName)
+	default:
+		return "", "", false, fmt.Errorf("unknown missing secret policy %q", p.MissingSecretPolicy)
+	}
+	p.Logger.Warn("The secret of the volume is gone", zap.String("pv", pv.Name), zap.String("bucket", bucket),
+		zap.String("secret", namespace+"/"+name), zap.String("policy", p.MissingSecretPolicy))
+	ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+	if err := createEvent(ctx, p.Client, driverName, ref, v1.EventTypeWarning, MissingSecretReason, message); err != nil {
+		p.Logger.Error("Cannot record the missing secret event", zap.String("name", pv.Name), zap.Error(err))
+	}
+	if p.MissingSecretPolicy == MissingSecretSkip {
+		return "", "", true, nil
+	}
+	return p.CleanupSecretName, p.CleanupSecretNamespace, false, nil
+}
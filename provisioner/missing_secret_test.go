@@ -0,0 +1,90 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/driver"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func getMissingSecretProvisioner(factory *fake.ObjectStorageSessionFactory, policy string) *IBMS3fsProvisioner {
+	p := getCustomProvisioner(
+		&clientGoConfig{missingSecret: true},
+		factory,
+		&fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{},
+		uuid.NewCryptoGenerator(),
+	)
+	p.MissingSecretPolicy = policy
+	return p
+}
+
+func Test_Delete_MissingSecret_Skip(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getMissingSecretProvisioner(factory, MissingSecretSkip)
+	pv := getAutoDeletePersistentVolume()
+	pv.Name = "pv-dynamic"
+	pv.Annotations[annotationBucket] = testBucket
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Empty(t, factory.LastDeletedBucket)
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, MissingSecretReason, events.Items[0].Reason)
+		assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+		assert.Contains(t, events.Items[0].Message, "bucket test-bucket is not deleted and must be deleted by hand")
+	}
+}
+
+func Test_Delete_MissingSecret_Fallback(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getMissingSecretProvisioner(factory, MissingSecretFallback)
+	p.CleanupSecretName, p.CleanupSecretNamespace = "cleanup", "kube-system"
+	_, err := p.Client.CoreV1().Secrets("kube-system").Create(context.Background(), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cleanup", Namespace: "kube-system"},
+		Type:       driverName,
+		Data: map[string][]byte{
+			driver.SecretAccessKey: []byte("cleanup-access-key"),
+			driver.SecretSecretKey: []byte("cleanup-secret-key"),
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	pv := getAutoDeletePersistentVolume()
+	pv.Annotations[annotationBucket] = testBucket
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Equal(t, testBucket, factory.LastDeletedBucket)
+	assert.Equal(t, "cleanup-access-key", factory.LastCredentials.AccessKey)
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Contains(t, events.Items[0].Message, "is deleted with the cleanup secret kube-system/cleanup")
+	}
+}
+
+func Test_Delete_MissingSecret_FallbackUnset(t *testing.T) {
+	p := getMissingSecretProvisioner(&fake.ObjectStorageSessionFactory{}, MissingSecretFallback)
+	pv := getAutoDeletePersistentVolume()
+
+	err := p.Delete(context.Background(), pv)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no cleanup secret is set")
+	}
+}
@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+// BucketTagsFailedReason is the reason of the events of the claims whose bucket could not be tagged
+const BucketTagsFailedReason = "BucketTagsFailed"
+
+// MetadataMapping copies a label, or an annotation, of the namespace of the claims into a label of
+// their volumes and a tag of the buckets created for them, e.g. for the attribution of the costs
+type MetadataMapping struct {
+	// Annotation reads an annotation of the namespace instead of a label
+	Annotation bool
+	// Key is the key of the label or annotation of the namespace
+	Key string
+	// Target is the key of the label of the volumes and of the tag of the buckets
+	Target string
+}
+
+// ParseMetadataMappings reads comma separated mappings, label:<key>[=<target>] or annotation:<key>[=<target>],
+// the target being the key itself when omitted
+func ParseMetadataMappings(value string) ([]MetadataMapping, error) {
+	var mappings []MetadataMapping
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var mapping MetadataMapping
+		switch {
+		case strings.HasPrefix(item, "label:"):
+			item = strings.TrimPrefix(item, "label:")
+		case strings.HasPrefix(item, "annotation:"):
+			mapping.Annotation = true
+			item = strings.TrimPrefix(item, "annotation:")
+		default:
+			return nil, fmt.Errorf("invalid namespace metadata mapping %q, expects label:<key>[=<target>] or annotation:<key>[=<target>]", item)
+		}
+		parts := strings.SplitN(item, "=", 2)
+		mapping.Key, mapping.Target = parts[0], parts[0]
+		if len(parts) == 2 {
+			mapping.Target = parts[1]
+		}
+		if mapping.Key == "" {
+			return nil, fmt.Errorf("invalid namespace metadata mapping %q, the key is empty", item)
+		}
+		if errs := validation.IsQualifiedName(mapping.Target); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid target %q of namespace metadata mapping: %s", mapping.Target, strings.Join(errs, ", "))
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// namespaceMetadata returns the values of the NamespaceMetadata mappings of a namespace keyed by target,
+// the labels and annotations the namespace does not set are left out
+func (p *IBMS3fsProvisioner) namespaceMetadata(ctx context.Context, namespace string) (map[string]string, error) {
+	if len(p.NamespaceMetadata) == 0 {
+		return nil, nil
+	}
+	ns, err := p.Client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get namespace %s: %v", namespace, err)
+	}
+	metadata := map[string]string{}
+	for _, mapping := range p.NamespaceMetadata {
+		source := ns.Labels
+		if mapping.Annotation {
+			source = ns.Annotations
+		}
+		if value, ok := source[mapping.Key]; ok && value != "" {
+			metadata[mapping.Target] = value
+		}
+	}
+	return metadata, nil
+}
+
+// withNamespaceLabels adds the namespace metadata to the labels of a volume, without replacing them. The values
+// that are not valid label values, as annotations may hold, only tag the bucket.
+func (p *IBMS3fsProvisioner) withNamespaceLabels(labels, metadata map[string]string) map[string]string {
+	for key, value := range metadata {
+		if _, ok := labels[key]; ok {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			p.Logger.Warn("Namespace metadata is not a valid label value, the volume is not labeled with it",
+				zap.String("label", key), zap.Strings("errors", errs))
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// tagBucket tags a bucket created for a claim with the namespace metadata. A failure does not fail the
+// provisioning, the volume carries the labels still, it is recorded on the claim.
+func (p *IBMS3fsProvisioner) tagBucket(ctx context.Context, sess backend.ObjectStorageSession, pvc *v1.PersistentVolumeClaim,
+	component, bucket string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+	if err := sess.SetBucketTags(ctx, bucket, metadata); err != nil {
+		p.Logger.Warn("Cannot tag the bucket with the namespace metadata", zap.String("bucket", bucket), zap.Error(err))
+		ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+		if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, BucketTagsFailedReason,
+			fmt.Sprintf("cannot tag bucket %s: %v", bucket, err)); err != nil {
+			p.Logger.Error("Cannot record the bucket tags event", zap.String("name", pvc.Name), zap.Error(err))
+		}
+	}
+}
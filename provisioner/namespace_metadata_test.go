@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_ParseMetadataMappings(t *testing.T) {
+	mappings, err := ParseMetadataMappings("label:cost-center, annotation:example.com/owner=owner,")
+	assert.NoError(t, err)
+	assert.Equal(t, []MetadataMapping{
+		{Key: "cost-center", Target: "cost-center"},
+		{Annotation: true, Key: "example.com/owner", Target: "owner"},
+	}, mappings)
+
+	mappings, err = ParseMetadataMappings("")
+	assert.NoError(t, err)
+	assert.Empty(t, mappings)
+
+	_, err = ParseMetadataMappings("cost-center")
+	assert.Error(t, err)
+	_, err = ParseMetadataMappings("label:=team")
+	assert.Error(t, err)
+	_, err = ParseMetadataMappings("label:team=not a label")
+	assert.Error(t, err)
+}
+
+func getNamespaceMetadataProvisioner(t *testing.T, factory *fake.ObjectStorageSessionFactory) *IBMS3fsProvisioner {
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{})
+	p.NamespaceMetadata = []MetadataMapping{
+		{Key: "cost-center", Target: "cost-center"},
+		{Annotation: true, Key: "example.com/owner", Target: "owner"},
+		{Key: "unset", Target: "unset"},
+	}
+	_, err := p.Client.CoreV1().Namespaces().Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        testNamespace,
+		Labels:      map[string]string{"cost-center": "cc-42"},
+		Annotations: map[string]string{"example.com/owner": "Finance Team"},
+	}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return p
+}
+
+func Test_Provision_NamespaceMetadata(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getNamespaceMetadataProvisioner(t, factory)
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cost-center": "cc-42", "owner": "Finance Team"}, factory.LastBucketTags)
+	assert.Equal(t, "cc-42", pv.Labels["cost-center"])
+	// the annotation is not a valid label value, it only tags the bucket
+	assert.NotContains(t, pv.Labels, "owner")
+	assert.NotContains(t, pv.Labels, "unset")
+}
+
+func Test_Provision_NamespaceMetadata_ExistingBucket(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailCreateBucket: true, FailCreateBucketErrMsg: "BucketAlreadyExists"}
+	p := getNamespaceMetadataProvisioner(t, factory)
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Nil(t, factory.LastBucketTags)
+	assert.Equal(t, "cc-42", pv.Labels["cost-center"])
+}
+
+func Test_Provision_NamespaceMetadata_TagsFailed(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailSetBucketTags: true}
+	p := getNamespaceMetadataProvisioner(t, factory)
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, "cc-42", pv.Labels["cost-center"])
+	events, _ := p.Client.CoreV1().Events(testNamespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, BucketTagsFailedReason, events.Items[0].Reason)
+	}
+}
+
+func Test_Provision_NamespaceMetadata_MissingNamespace(t *testing.T) {
+	p := getProvisioner()
+	p.NamespaceMetadata = []MetadataMapping{{Key: "cost-center", Target: "cost-center"}}
+
+	_, _, err := p.Provision(context.Background(), getVolumeOptions())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot read the namespace metadata")
+	}
+}
@@ -0,0 +1,259 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys of the data of the namespace quota ConfigMap
+const (
+	// NamespaceQuotaDefaultKey is the highest number of volumes of a namespace not listed in NamespaceQuotaLimitsKey
+	NamespaceQuotaDefaultKey = "default"
+	// NamespaceQuotaLimitsKey holds a YAML map of the highest number of volumes keyed by namespace
+	NamespaceQuotaLimitsKey = "namespaces"
+)
+
+// NamespaceQuotaExceededReason is the reason of the warning events recorded on the claims refused by the quota
+const NamespaceQuotaExceededReason = "NamespaceQuotaExceeded"
+
+// NamespaceQuotaReservationTTL bounds the time a provisioned claim counts in the quota of its namespace before its
+// volume shows up in the cache of the watcher, in case the volume is never created
+const NamespaceQuotaReservationTTL = 5 * time.Minute
+
+// claimNamespaceIndex indexes the volumes of the driver by the namespace of their claim
+const claimNamespaceIndex = "claimNamespace"
+
+// NamespaceQuota limits the number of volumes of the claims of each namespace, so that a single tenant cannot
+// use up the bucket limit of the account
+type NamespaceQuota struct {
+	// Default applies to the namespaces without a limit, no limit when nil
+	Default *int
+	// Namespaces are the limits of the namespaces
+	Namespaces map[string]int
+}
+
+// ParseNamespaceQuota reads the namespace quota from the data of a ConfigMap
+func ParseNamespaceQuota(data map[string]string) (*NamespaceQuota, error) {
+	q := &NamespaceQuota{}
+	if value := strings.TrimSpace(data[NamespaceQuotaDefaultKey]); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q, expects a positive integer", NamespaceQuotaDefaultKey, value)
+		}
+		q.Default = &n
+	}
+	if err := yaml.Unmarshal([]byte(data[NamespaceQuotaLimitsKey]), &q.Namespaces); err != nil {
+		return nil, fmt.Errorf("invalid %s, expects integers: %v", NamespaceQuotaLimitsKey, err)
+	}
+	for namespace, n := range q.Namespaces {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid %s, the limit of %s must be positive, got %d", NamespaceQuotaLimitsKey, namespace, n)
+		}
+	}
+	return q, nil
+}
+
+// Limit returns the highest number of volumes of a namespace, false when it has no limit
+func (q *NamespaceQuota) Limit(namespace string) (int, bool) {
+	if q == nil {
+		return 0, false
+	}
+	if n, ok := q.Namespaces[namespace]; ok {
+		return n, true
+	}
+	if q.Default != nil {
+		return *q.Default, true
+	}
+	return 0, false
+}
+
+// NamespaceQuotaWatcher keeps the namespace quota of a ConfigMap up to date. A missing ConfigMap means no
+// quota, an invalid one keeps the previous quota. It counts the volumes of the namespaces from an informer
+// cache of the PVs, and the claims it let through whose volume is not in the cache yet.
+type NamespaceQuotaWatcher struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+	Logger    *zap.Logger
+
+	mutex sync.RWMutex
+	quota *NamespaceQuota
+
+	// reserveMutex serializes the checks, so that the claims provisioned concurrently see each other
+	reserveMutex sync.Mutex
+	volumes      cache.Indexer
+	// reservations are the expiries of the claims let through, by namespace and claim UID
+	reservations map[string]map[types.UID]time.Time
+}
+
+// Current returns the namespace quota in effect, nil when there is none
+func (w *NamespaceQuotaWatcher) Current() *NamespaceQuota {
+	if w == nil {
+		return nil
+	}
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.quota
+}
+
+// Start watches the ConfigMap and the PVs until ctx is done, and returns once their current content is loaded
+func (w *NamespaceQuotaWatcher) Start(ctx context.Context, resyncPeriod time.Duration) error {
+	if err := watchConfigMap(ctx, w.Client, w.Namespace, w.Name, resyncPeriod, w.load, func() { w.set(nil) }); err != nil {
+		return errors.New("cannot load the namespace quota ConfigMap " + w.Namespace + "/" + w.Name)
+	}
+	return w.watchVolumes(ctx, resyncPeriod)
+}
+
+// watchVolumes caches the PVs of the driver, indexed by the namespace of their claim
+func (w *NamespaceQuotaWatcher) watchVolumes(ctx context.Context, resyncPeriod time.Duration) error {
+	factory := informers.NewSharedInformerFactory(w.Client, resyncPeriod)
+	informer := factory.Core().V1().PersistentVolumes().Informer()
+	err := informer.AddIndexers(cache.Indexers{claimNamespaceIndex: func(obj interface{}) ([]string, error) {
+		pv, ok := obj.(*v1.PersistentVolume)
+		if !ok || pv.Spec.FlexVolume == nil || pv.Spec.FlexVolume.Driver != driverName || pv.Spec.ClaimRef == nil {
+			return nil, nil
+		}
+		return []string{pv.Spec.ClaimRef.Namespace}, nil
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot index the PVs: %v", err)
+	}
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("cannot load the PVs of the namespace quota")
+	}
+	w.volumes = informer.GetIndexer()
+	return nil
+}
+
+func (w *NamespaceQuotaWatcher) load(obj interface{}) {
+	configMap, ok := obj.(*v1.ConfigMap)
+	if !ok || configMap.Name != w.Name {
+		return
+	}
+	quota, err := ParseNamespaceQuota(configMap.Data)
+	if err != nil {
+		w.Logger.Error("Invalid namespace quota ConfigMap, keeping the previous quota",
+			zap.String("configmap", w.Namespace+"/"+w.Name), zap.Error(err))
+		return
+	}
+	w.set(quota)
+}
+
+func (w *NamespaceQuotaWatcher) set(quota *NamespaceQuota) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.quota = quota
+	w.Logger.Info("Loaded the namespace quota", zap.String("configmap", w.Namespace+"/"+w.Name),
+		zap.Bool("set", quota != nil))
+}
+
+// count counts the volumes of the namespace of a claim, from the cache, and the other claims let through whose
+// volume is not cached yet, dropping the expired reservations. The caller holds reserveMutex.
+func (w *NamespaceQuotaWatcher) count(pvc *v1.PersistentVolumeClaim) (int, error) {
+	objs, err := w.volumes.ByIndex(claimNamespaceIndex, pvc.Namespace)
+	if err != nil {
+		return 0, fmt.Errorf("cannot count the PVs: %v", err)
+	}
+	now := time.Now()
+	reservations := w.reservations[pvc.Namespace]
+	counted := map[types.UID]bool{}
+	count := 0
+	for _, obj := range objs {
+		pv := obj.(*v1.PersistentVolume)
+		counted[pv.Spec.ClaimRef.UID] = true
+		if (pvc.UID != "" && pv.Spec.ClaimRef.UID == pvc.UID) ||
+			pv.Status.Phase == v1.VolumeReleased || pv.Status.Phase == v1.VolumeFailed {
+			continue
+		}
+		count++
+	}
+	for uid, expiry := range reservations {
+		if counted[uid] || now.After(expiry) {
+			delete(reservations, uid)
+		} else if uid != pvc.UID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// reserve lets a claim through when the volumes of its namespace are under the limit, and counts it until its
+// volume shows up in the cache
+func (w *NamespaceQuotaWatcher) reserve(pvc *v1.PersistentVolumeClaim, limit int) (int, bool, error) {
+	w.reserveMutex.Lock()
+	defer w.reserveMutex.Unlock()
+	count, err := w.count(pvc)
+	if err != nil || count >= limit {
+		return count, false, err
+	}
+	if w.reservations[pvc.Namespace] == nil {
+		if w.reservations == nil {
+			w.reservations = map[string]map[types.UID]time.Time{}
+		}
+		w.reservations[pvc.Namespace] = map[types.UID]time.Time{}
+	}
+	w.reservations[pvc.Namespace][pvc.UID] = time.Now().Add(NamespaceQuotaReservationTTL)
+	return count, true, nil
+}
+
+// admits tells whether a new claim fits in the quota of its namespace, without reserving it
+func (w *NamespaceQuotaWatcher) admits(pvc *v1.PersistentVolumeClaim, limit int) (int, bool, error) {
+	w.reserveMutex.Lock()
+	defer w.reserveMutex.Unlock()
+	count, err := w.count(pvc)
+	return count, err == nil && count < limit, err
+}
+
+// release stops counting a claim let through whose provisioning failed
+func (w *NamespaceQuotaWatcher) release(pvc *v1.PersistentVolumeClaim) {
+	if w == nil {
+		return
+	}
+	w.reserveMutex.Lock()
+	defer w.reserveMutex.Unlock()
+	delete(w.reservations[pvc.Namespace], pvc.UID)
+}
+
+// checkNamespaceQuota refuses a claim when the volumes of the driver bound to the other claims of its namespace,
+// and the claims being provisioned, reach the limit of the namespace, and records a warning event on it.
+// The released volumes do not count.
+func (p *IBMS3fsProvisioner) checkNamespaceQuota(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string) error {
+	limit, ok := p.NamespaceQuota.Current().Limit(pvc.Namespace)
+	if !ok {
+		return nil
+	}
+	count, allowed, err := p.NamespaceQuota.reserve(pvc, limit)
+	if err != nil || allowed {
+		return err
+	}
+	message := fmt.Sprintf("namespace %s has %d object storage volumes, its quota allows %d", pvc.Namespace, count, limit)
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, NamespaceQuotaExceededReason,
+		message); err != nil {
+		p.Logger.Error("Cannot record the namespace quota event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+	return errors.New(message)
+}
@@ -0,0 +1,122 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"testing"
+)
+
+func getNamespaceQuota(t *testing.T, client kubernetes.Interface, data map[string]string) *NamespaceQuotaWatcher {
+	w := &NamespaceQuotaWatcher{Client: client, Logger: zap.NewNop()}
+	quota, err := ParseNamespaceQuota(data)
+	require.NoError(t, err)
+	w.set(quota)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, w.watchVolumes(ctx, 0))
+	return w
+}
+
+func getClaimedPV(name, namespace string, phase v1.PersistentVolumePhase) *v1.PersistentVolume {
+	pv := getOrphanPV(name, driverName, map[string]string{"bucket": name}, nil)
+	pv.Spec.ClaimRef = &v1.ObjectReference{Namespace: namespace, Name: name}
+	pv.Status.Phase = phase
+	return pv
+}
+
+func Test_ParseNamespaceQuota(t *testing.T) {
+	q, err := ParseNamespaceQuota(map[string]string{
+		NamespaceQuotaDefaultKey: "2",
+		NamespaceQuotaLimitsKey:  "team-a: 10\nsandbox: 0",
+	})
+	require.NoError(t, err)
+	limit, ok := q.Limit("team-a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, limit)
+	limit, ok = q.Limit("sandbox")
+	assert.True(t, ok)
+	assert.Equal(t, 0, limit)
+	limit, ok = q.Limit("other")
+	assert.True(t, ok)
+	assert.Equal(t, 2, limit)
+
+	q, err = ParseNamespaceQuota(map[string]string{NamespaceQuotaLimitsKey: "team-a: 10"})
+	require.NoError(t, err)
+	_, ok = q.Limit("other")
+	assert.False(t, ok)
+}
+
+func Test_ParseNamespaceQuota_Error(t *testing.T) {
+	_, err := ParseNamespaceQuota(map[string]string{NamespaceQuotaDefaultKey: "many"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid default \"many\"")
+	}
+	_, err = ParseNamespaceQuota(map[string]string{NamespaceQuotaLimitsKey: "team-a: -1"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the limit of team-a must be positive")
+	}
+}
+
+func Test_Provision_NamespaceQuota_Exceeded(t *testing.T) {
+	v := getVolumeOptions()
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{},
+		getClaimedPV("pv-1", v.PVC.Namespace, v1.VolumeBound), getClaimedPV("pv-2", v.PVC.Namespace, v1.VolumeBound))
+	p.NamespaceQuota = getNamespaceQuota(t, p.Client, map[string]string{NamespaceQuotaLimitsKey: v.PVC.Namespace + ": 2"})
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "namespace "+v.PVC.Namespace+" has 2 object storage volumes, its quota allows 2")
+	}
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, NamespaceQuotaExceededReason, events.Items[0].Reason)
+	}
+}
+
+func Test_Provision_NamespaceQuota_Positive(t *testing.T) {
+	v := getVolumeOptions()
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{},
+		getClaimedPV("pv-1", v.PVC.Namespace, v1.VolumeBound), getClaimedPV("pv-2", v.PVC.Namespace, v1.VolumeReleased),
+		getClaimedPV("pv-3", "other", v1.VolumeBound))
+	p.NamespaceQuota = getNamespaceQuota(t, p.Client, map[string]string{NamespaceQuotaDefaultKey: "2"})
+
+	_, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+}
+
+func Test_Provision_NamespaceQuota_Concurrent(t *testing.T) {
+	v := getVolumeOptions()
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{}, getClaimedPV("pv-1", v.PVC.Namespace, v1.VolumeBound))
+	p.NamespaceQuota = getNamespaceQuota(t, p.Client, map[string]string{NamespaceQuotaDefaultKey: "2"})
+	ctx := context.Background()
+
+	// the volume of the first claim is not created yet when the second one is provisioned
+	first, second := v.PVC.DeepCopy(), v.PVC.DeepCopy()
+	first.UID, second.UID = types.UID("uid-1"), types.UID("uid-2")
+	assert.NoError(t, p.checkNamespaceQuota(ctx, first, driverName))
+	assert.NoError(t, p.checkNamespaceQuota(ctx, first, driverName))
+	err := p.checkNamespaceQuota(ctx, second, driverName)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has 2 object storage volumes")
+	}
+
+	p.NamespaceQuota.release(first)
+	assert.NoError(t, p.checkNamespaceQuota(ctx, second, driverName))
+}
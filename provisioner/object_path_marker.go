@@ -0,0 +1,43 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ObjectPathMarkerNotDeletedReason is the reason of the warning events recorded on the deleted volumes whose
+// object-path marker is left in the bucket
+const ObjectPathMarkerNotDeletedReason = "ObjectPathMarkerNotDeleted"
+
+// deleteObjectPathMarker deletes the marker written under the object-path of a deleted volume. A marker left
+// behind is harmless, the failure records a warning event and does not keep the volume.
+func (p *IBMS3fsProvisioner) deleteObjectPathMarker(ctx context.Context, pv *v1.PersistentVolume, pvcAnnots *pvcAnnotations,
+	backendName, endpointValue, regionValue, iamEndpoint string) {
+	sess, err := p.deletionSession(ctx, pvcAnnots, backendName, endpointValue, regionValue, iamEndpoint)
+	if err == nil {
+		err = sess.DeleteObjectPathMarker(ctx, pvcAnnots.Bucket, pvcAnnots.ObjectPath)
+	}
+	if err == nil {
+		return
+	}
+	p.Logger.Warn("Cannot delete the object-path marker", zap.String("pv", pv.Name),
+		zap.String("bucket", pvcAnnots.Bucket), zap.String("object-path", pvcAnnots.ObjectPath), zap.Error(err))
+	message := fmt.Sprintf("marker of object-path %s is left in bucket %s: %v", pvcAnnots.ObjectPath, pvcAnnots.Bucket, err)
+	ref := v1.ObjectReference{Kind: "PersistentVolume", Name: pv.Name, UID: pv.UID}
+	if err := createEvent(ctx, p.Client, driverName, ref, v1.EventTypeWarning, ObjectPathMarkerNotDeletedReason,
+		message); err != nil {
+		p.Logger.Error("Cannot record the object-path marker event", zap.String("name", pv.Name), zap.Error(err))
+	}
+}
@@ -0,0 +1,98 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+const annotationObjectPathMarker = "ibm.io/object-path-marker"
+
+func Test_Provision_ObjectPathMarker(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{CheckObjectPathExistencePathNotFound: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationCreateObjectPath] = "true"
+	v.PVC.Annotations[annotationObjectPathMarker] = "yes"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, testObjectPath, factory.LastCreatedMarkerObjectPath)
+	assert.Equal(t, "true", pv.Annotations[annotationObjectPathMarker])
+}
+
+func Test_Provision_ObjectPathMarker_Error(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailCreateObjectPathMarker: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "false"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[annotationObjectPath] = testObjectPath
+	v.PVC.Annotations[annotationObjectPathAsPrefix] = "true"
+	v.PVC.Annotations[annotationObjectPathMarker] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot create marker")
+	}
+}
+
+func Test_Provision_ObjectPathMarker_NoObjectPath(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationObjectPathMarker] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "object-path-marker needs an object-path")
+	}
+}
+
+func Test_Delete_ObjectPathMarker(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	pv := getAutoDeletePersistentVolume()
+	pv.Annotations[annotationAutoDeleteBucket] = "false"
+	pv.Annotations[annotationBucket] = testBucket
+	pv.Annotations[annotationObjectPath] = testObjectPath
+	pv.Annotations[annotationObjectPathMarker] = "true"
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Equal(t, testObjectPath, factory.LastDeletedMarkerObjectPath)
+	assert.Empty(t, factory.LastDeletedBucket)
+}
+
+func Test_Delete_ObjectPathMarker_Error(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailDeleteObjectPathMarker: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	pv := getAutoDeletePersistentVolume()
+	pv.Name = "pv-marker"
+	pv.Annotations[annotationAutoDeleteBucket] = "false"
+	pv.Annotations[annotationBucket] = testBucket
+	pv.Annotations[annotationObjectPath] = testObjectPath
+	pv.Annotations[annotationObjectPathMarker] = "true"
+
+	// the marker left behind does not keep the volume
+	require.NoError(t, p.Delete(context.Background(), pv))
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, ObjectPathMarkerNotDeletedReason, events.Items[0].Reason)
+	}
+}
@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	"time"
+)
+
+// OrphanAudit tells which buckets ListOrphanBuckets looks at
+type OrphanAudit struct {
+	// SecretName and SecretNamespace name the secret of the credentials listing the buckets
+	SecretName      string
+	SecretNamespace string
+	// Endpoint, Region and IAMEndpoint are the object storage settings of the storage classes
+	Endpoint    string
+	Region      string
+	IAMEndpoint string
+	// Prefix selects the buckets, the prefix of the buckets created by the provisioner when empty
+	Prefix string
+	// MinAge leaves out the buckets created less than MinAge ago, 0 keeps them all
+	MinAge time.Duration
+	// WithSize lists the objects of every orphan bucket to tell its size
+	WithSize bool
+}
+
+// OrphanBucket is a bucket no persistent volume of the cluster refers to
+type OrphanBucket struct {
+	Name string
+	// Created is zero when the endpoint does not tell the creation date
+	Created time.Time
+	// Usage is nil when the size was not asked for or could not be read
+	Usage *backend.BucketUsage
+}
+
+// ListOrphanBuckets returns the buckets of the service instance that no persistent volume of the
+// cluster mounts, as bucket or as source. Buckets of volumes of other clusters sharing the service
+// instance are listed as well, the result is a list of candidates to review before any deletion.
+func (p *IBMS3fsProvisioner) ListOrphanBuckets(ctx context.Context, audit OrphanAudit) ([]OrphanBucket, error) {
+	used, err := p.volumeBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, _, _, err := p.getCredentials(ctx, audit.SecretName, audit.SecretNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get credentials: %v", err)
+	}
+	creds.IAMEndpoint = audit.IAMEndpoint
+	sess := p.Backend.NewObjectStorageSession(audit.Endpoint, audit.Region, creds, backend.TransportConfig{}, p.Logger)
+
+	prefix := audit.Prefix
+	if prefix == "" {
+		prefix = autoBucketNamePrefix
+	}
+	buckets, err := sess.ListBuckets(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []OrphanBucket
+	for _, bucket := range buckets {
+		if used[bucket.Name] {
+			continue
+		}
+		// buckets of unknown age are kept, their volume may still be provisioning
+		if audit.MinAge > 0 && (bucket.Created.IsZero() || time.Since(bucket.Created) < audit.MinAge) {
+			continue
+		}
+		orphan := OrphanBucket{Name: bucket.Name, Created: bucket.Created}
+		if audit.WithSize {
+			if orphan.Usage, err = sess.GetBucketUsage(ctx, bucket.Name, ""); err != nil {
+				p.Logger.Warn("cannot get the size of the bucket", zap.String("bucket", bucket.Name), zap.Error(err))
+			}
+		}
+		orphans = append(orphans, orphan)
+	}
+	return orphans, nil
+}
+
+// volumeBuckets returns the buckets mounted by the persistent volumes of the driver
+func (p *IBMS3fsProvisioner) volumeBuckets(ctx context.Context) (map[string]bool, error) {
+	references, err := p.bucketReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(references))
+	for bucket := range references {
+		used[bucket] = true
+	}
+	return used, nil
+}
+
+// OrphanDeletionPlan lists the buckets to delete from a service instance, in the JSON layout
+// printed by the list-orphan-buckets command for the bucket garbage collection to read.
+type OrphanDeletionPlan struct {
+	// Generated is when the buckets were found orphan
+	Generated time.Time `json:"generated"`
+	// SecretName and SecretNamespace name the secret of the credentials deleting the buckets
+	SecretName      string   `json:"secretName"`
+	SecretNamespace string   `json:"secretNamespace"`
+	Endpoint        string   `json:"endpoint"`
+	Region          string   `json:"region"`
+	IAMEndpoint     string   `json:"iamEndpoint,omitempty"`
+	Buckets         []string `json:"buckets"`
+}
+
+// NewOrphanDeletionPlan returns the plan deleting the orphan buckets of an audit
+func NewOrphanDeletionPlan(audit OrphanAudit, orphans []OrphanBucket) OrphanDeletionPlan {
+	plan := OrphanDeletionPlan{
+		Generated:       time.Now().UTC(),
+		SecretName:      audit.SecretName,
+		SecretNamespace: audit.SecretNamespace,
+		Endpoint:        audit.Endpoint,
+		Region:          audit.Region,
+		IAMEndpoint:     audit.IAMEndpoint,
+		Buckets:         []string{},
+	}
+	for _, orphan := range orphans {
+		plan.Buckets = append(plan.Buckets, orphan.Name)
+	}
+	return plan
+}
@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/uuid"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func getOrphanProvisioner(t *testing.T, factory *fake.ObjectStorageSessionFactory, pvs ...*v1.PersistentVolume) *IBMS3fsProvisioner {
+	p := getCustomProvisioner(
+		&clientGoConfig{},
+		factory,
+		&fakeGrpcClient.FakeGrpcSessionFactory{},
+		&fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{},
+		uuid.NewCryptoGenerator(),
+	)
+	for _, pv := range pvs {
+		_, err := p.Client.CoreV1().PersistentVolumes().Create(context.Background(), pv, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	return p
+}
+
+func getOrphanPV(name, driver string, options, annotations map[string]string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{Driver: driver, Options: options},
+			},
+		},
+	}
+}
+
+func orphanNames(orphans []OrphanBucket) []string {
+	var names []string
+	for _, orphan := range orphans {
+		names = append(names, orphan.Name)
+	}
+	return names
+}
+
+func getOrphanAudit() OrphanAudit {
+	return OrphanAudit{SecretName: testSecretName, SecretNamespace: testNamespace, Endpoint: testOSEndpoint, Region: "us-standard"}
+}
+
+func Test_ListOrphanBuckets_Positive(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{Buckets: []backend.BucketInfo{
+		{Name: "tmp-s3fs-used"}, {Name: "tmp-s3fs-source"}, {Name: "tmp-s3fs-annotated"},
+		{Name: "tmp-s3fs-other-driver"}, {Name: "tmp-s3fs-orphan"}, {Name: "kept"},
+	}}
+	p := getOrphanProvisioner(t, factory,
+		getOrphanPV("pv-1", driverName, map[string]string{"bucket": "tmp-s3fs-used", "sources": "logs=tmp-s3fs-source/prefix/"}, nil),
+		getOrphanPV("pv-2", driverName, map[string]string{"bucket": "x"}, map[string]string{"ibm.io/sources": "a=tmp-s3fs-annotated"}),
+		getOrphanPV("pv-3", "other/driver", map[string]string{"bucket": "tmp-s3fs-other-driver"}, nil),
+	)
+
+	orphans, err := p.ListOrphanBuckets(context.Background(), getOrphanAudit())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tmp-s3fs-other-driver", "tmp-s3fs-orphan"}, orphanNames(orphans))
+	assert.Nil(t, orphans[0].Usage)
+}
+
+func Test_ListOrphanBuckets_Prefix(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{Buckets: []backend.BucketInfo{{Name: "tmp-s3fs-orphan"}, {Name: "team-orphan"}}}
+	audit := getOrphanAudit()
+	audit.Prefix = "team-"
+
+	orphans, err := getOrphanProvisioner(t, factory).ListOrphanBuckets(context.Background(), audit)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-orphan"}, orphanNames(orphans))
+}
+
+func Test_ListOrphanBuckets_MinAge(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{Buckets: []backend.BucketInfo{
+		{Name: "tmp-s3fs-old", Created: time.Now().Add(-48 * time.Hour)},
+		{Name: "tmp-s3fs-new", Created: time.Now().Add(-time.Hour)},
+		{Name: "tmp-s3fs-unknown"},
+	}}
+	audit := getOrphanAudit()
+	audit.MinAge = 24 * time.Hour
+
+	orphans, err := getOrphanProvisioner(t, factory).ListOrphanBuckets(context.Background(), audit)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tmp-s3fs-old"}, orphanNames(orphans))
+}
+
+func Test_ListOrphanBuckets_WithSize(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{
+		Buckets:     []backend.BucketInfo{{Name: "tmp-s3fs-orphan"}},
+		BucketUsage: backend.BucketUsage{BytesUsed: 2048, ObjectCount: 2},
+	}
+	audit := getOrphanAudit()
+	audit.WithSize = true
+
+	orphans, err := getOrphanProvisioner(t, factory).ListOrphanBuckets(context.Background(), audit)
+	assert.NoError(t, err)
+	if assert.Len(t, orphans, 1) && assert.NotNil(t, orphans[0].Usage) {
+		assert.Equal(t, int64(2048), orphans[0].Usage.BytesUsed)
+	}
+
+	factory.FailGetBucketUsage = true
+	orphans, err = getOrphanProvisioner(t, factory).ListOrphanBuckets(context.Background(), audit)
+	assert.NoError(t, err)
+	if assert.Len(t, orphans, 1) {
+		assert.Nil(t, orphans[0].Usage)
+	}
+}
+
+func Test_ListOrphanBuckets_Negative(t *testing.T) {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{FailListBuckets: true})
+	_, err := p.ListOrphanBuckets(context.Background(), getOrphanAudit())
+	assert.Error(t, err)
+
+	p = getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{},
+		getOrphanPV("pv-1", driverName, map[string]string{"bucket": "b", "sources": "bad"}, nil))
+	_, err = p.ListOrphanBuckets(context.Background(), getOrphanAudit())
+	assert.Error(t, err)
+
+	audit := getOrphanAudit()
+	audit.SecretName = "missing"
+	_, err = getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{}).ListOrphanBuckets(context.Background(), audit)
+	assert.Error(t, err)
+}
+
+func Test_NewOrphanDeletionPlan(t *testing.T) {
+	audit := getOrphanAudit()
+	plan := NewOrphanDeletionPlan(audit, []OrphanBucket{{Name: "tmp-s3fs-1"}, {Name: "tmp-s3fs-2"}})
+	assert.Equal(t, []string{"tmp-s3fs-1", "tmp-s3fs-2"}, plan.Buckets)
+	assert.Equal(t, testSecretName, plan.SecretName)
+	assert.Equal(t, testOSEndpoint, plan.Endpoint)
+	assert.False(t, plan.Generated.IsZero())
+
+	assert.Equal(t, []string{}, NewOrphanDeletionPlan(audit, nil).Buckets)
+}
@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnnotationProfile is the parameter of the storage classes naming a preset of the s3fs tuning parameters
+const AnnotationProfile = "ibm.io/profile"
+
+// Profiles are the presets of AnnotationProfile. A parameter set by the storage class, or an annotation
+// set by the claim, wins over the value of its profile.
+var Profiles = map[string]map[string]string{
+	// large-files uploads and downloads big objects with large parts in parallel
+	"large-files": {
+		"ibm.io/chunk-size-mb":   "64",
+		"ibm.io/parallel-count":  "16",
+		"ibm.io/multireq-max":    "20",
+		"ibm.io/stat-cache-size": "10000",
+	},
+	// many-small-files caches the metadata of many objects and lists them with more concurrent requests
+	"many-small-files": {
+		"ibm.io/chunk-size-mb":   "5",
+		"ibm.io/parallel-count":  "5",
+		"ibm.io/multireq-max":    "50",
+		"ibm.io/stat-cache-size": "500000",
+	},
+	// write-heavy uploads the writes with medium parts in parallel
+	"write-heavy": {
+		"ibm.io/chunk-size-mb":   "32",
+		"ibm.io/parallel-count":  "10",
+		"ibm.io/multireq-max":    "20",
+		"ibm.io/stat-cache-size": "100000",
+	},
+}
+
+// profileNames returns the names of the profiles, sorted
+func profileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandProfile returns a copy of the parameters of a storage class with the values of its profile
+// set where the parameters leave them unset, and without the profile itself.
+func expandProfile(parameters map[string]string) (map[string]string, error) {
+	name, ok := parameters[AnnotationProfile]
+	if !ok {
+		return parameters, nil
+	}
+	expanded := make(map[string]string, len(parameters))
+	for key, value := range parameters {
+		expanded[key] = value
+	}
+	delete(expanded, AnnotationProfile)
+	profile, ok := Profiles[strings.TrimSpace(name)]
+	if !ok {
+		return expanded, fmt.Errorf("unknown %s %q, expects one of %s", AnnotationProfile, name,
+			strings.Join(profileNames(), ", "))
+	}
+	for key, value := range profile {
+		if expanded[key] == "" {
+			expanded[key] = value
+		}
+	}
+	return expanded, nil
+}
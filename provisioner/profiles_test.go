@@ -0,0 +1,84 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func Test_Profiles_Valid(t *testing.T) {
+	for name, profile := range Profiles {
+		m := make(map[string]string, len(profile))
+		for key, value := range profile {
+			m[key] = value
+		}
+		assert.Empty(t, convertSizes(m), name)
+		for key, value := range m {
+			n, err := strconv.Atoi(value)
+			assert.NoError(t, err, name+" "+key)
+			assert.True(t, n > 0, name+" "+key)
+		}
+	}
+}
+
+func Test_expandProfile(t *testing.T) {
+	expanded, err := expandProfile(map[string]string{
+		AnnotationProfile:       "large-files",
+		"ibm.io/parallel-count": "8",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ibm.io/chunk-size-mb":   "64",
+		"ibm.io/parallel-count":  "8",
+		"ibm.io/multireq-max":    "20",
+		"ibm.io/stat-cache-size": "10000",
+	}, expanded)
+}
+
+func Test_expandProfile_Unknown(t *testing.T) {
+	_, err := expandProfile(map[string]string{AnnotationProfile: "huge-files"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "unknown ibm.io/profile \"huge-files\", expects one of large-files, many-small-files, write-heavy",
+			err.Error())
+	}
+}
+
+func Test_Provision_Profile_Positive(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	delete(v.StorageClass.Parameters, parameterChunkSizeMB)
+	delete(v.StorageClass.Parameters, parameterParallelCount)
+	delete(v.StorageClass.Parameters, parameterMultiReqMax)
+	delete(v.StorageClass.Parameters, parameterStatCacheSize)
+	v.StorageClass.Parameters[AnnotationProfile] = "many-small-files"
+	v.PVC.Annotations["ibm.io/multireq-max"] = "30"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", pv.Spec.FlexVolume.Options[optionChunkSizeMB])
+	assert.Equal(t, "5", pv.Spec.FlexVolume.Options[optionParallelCount])
+	assert.Equal(t, "30", pv.Spec.FlexVolume.Options[optionMultiReqMax])
+	assert.Equal(t, "500000", pv.Spec.FlexVolume.Options[optionStatCacheSize])
+}
+
+func Test_Provision_Profile_Error(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[AnnotationProfile] = "huge-files"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid storage class parameters: unknown ibm.io/profile \"huge-files\"")
+	}
+}
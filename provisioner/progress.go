@@ -0,0 +1,77 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sync/atomic"
+	"time"
+)
+
+// OperationProgressReason is the reason of the events recorded while a long operation runs, so the users do
+// not take a slow bucket creation or deletion for a hung provisioner
+const OperationProgressReason = "OperationProgress"
+
+// operationProgress records the progress of an operation on an object until it is stopped
+type operationProgress struct {
+	objects int64
+	bytes   int64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// trackProgress records an event on ref every ProgressInterval until the returned function is called, with the
+// objects and bytes the session operations of the returned context report. Nothing is recorded when
+// ProgressInterval is 0.
+func (p *IBMS3fsProvisioner) trackProgress(ctx context.Context, ref v1.ObjectReference, component,
+	operation string) (context.Context, func()) {
+	if p.ProgressInterval <= 0 {
+		return ctx, func() {}
+	}
+	progress := &operationProgress{stop: make(chan struct{}), done: make(chan struct{})}
+	start := time.Now()
+	go func() {
+		defer close(progress.done)
+		ticker := time.NewTicker(p.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-progress.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				message := fmt.Sprintf("%s, running for %s", operation, time.Since(start).Round(time.Second))
+				if objects := atomic.LoadInt64(&progress.objects); objects > 0 {
+					bytes := resource.NewQuantity(atomic.LoadInt64(&progress.bytes), resource.BinarySI)
+					message += fmt.Sprintf(", %d objects (%s) done", objects, bytes.String())
+				}
+				if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeNormal, OperationProgressReason,
+					message); err != nil {
+					p.Logger.Error("Cannot record the progress event", zap.String("name", ref.Name), zap.Error(err))
+				}
+			}
+		}
+	}()
+	ctx = backend.WithProgress(ctx, func(objects, bytes int64) {
+		atomic.AddInt64(&progress.objects, objects)
+		atomic.AddInt64(&progress.bytes, bytes)
+	})
+	return ctx, func() {
+		close(progress.stop)
+		<-progress.done
+	}
+}
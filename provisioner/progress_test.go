@@ -0,0 +1,55 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func Test_Delete_Progress(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{
+		BucketUsage:       backend.BucketUsage{ObjectCount: 3, BytesUsed: 3 << 30},
+		DeleteBucketDelay: 100 * time.Millisecond,
+	}
+	pv := getAutoDeletePersistentVolume()
+	pv.Name = "pv-dynamic"
+	pv.Annotations[annotationBucket] = testBucket
+	p := getOrphanProvisioner(t, factory)
+	p.ProgressInterval = 20 * time.Millisecond
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	assert.Equal(t, testBucket, factory.LastDeletedBucket)
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if assert.NotEmpty(t, events.Items) {
+		assert.Equal(t, OperationProgressReason, events.Items[0].Reason)
+		assert.Equal(t, "PersistentVolume", events.Items[0].InvolvedObject.Kind)
+		assert.Contains(t, events.Items[0].Message, "deleting bucket test-bucket, running for ")
+		assert.Contains(t, events.Items[0].Message, ", 3 objects (3Gi) done")
+	}
+}
+
+func Test_Delete_Progress_Disabled(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{DeleteBucketDelay: 50 * time.Millisecond}
+	pv := getAutoDeletePersistentVolume()
+	pv.Annotations[annotationBucket] = testBucket
+	p := getOrphanProvisioner(t, factory)
+
+	require.NoError(t, p.Delete(context.Background(), pv))
+	events, _ := p.Client.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	assert.Empty(t, events.Items)
+}
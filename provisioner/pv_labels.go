@@ -0,0 +1,51 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"strconv"
+)
+
+// Labels of the provisioned volumes, to select them with kubectl get pv -l
+const (
+	// LabelBucketHash is the BucketLabelValue of the bucket of the volume
+	LabelBucketHash = "ibm.io/bucket-hash"
+	// LabelRegion is the location served by the object storage endpoint, e.g. us-south
+	LabelRegion = "ibm.io/cos-region"
+	// LabelEndpointType is public, private or direct
+	LabelEndpointType = "ibm.io/endpoint-type"
+	// LabelAutoCreated is true for the volumes whose bucket was created by the provisioner, false for the
+	// volumes of an existing bucket
+	LabelAutoCreated = "ibm.io/auto-created"
+)
+
+// BucketLabelValue returns the value of the bucket label of the volumes of a bucket. The bucket names
+// longer than a label value, or with dots, are hashed, so is every name for a single selector format.
+func BucketLabelValue(bucket string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(bucket)))[:32]
+}
+
+// pvLabels returns the labels of a volume, the region and endpoint type are only known for the
+// IBM Cloud Object Storage endpoints
+func pvLabels(bucket, endpoint string, bucketCreated bool) map[string]string {
+	labels := map[string]string{
+		LabelBucketHash:  BucketLabelValue(bucket),
+		LabelAutoCreated: strconv.FormatBool(bucketCreated),
+	}
+	if region := backend.EndpointRegion(endpoint); region != "" {
+		labels[LabelRegion] = region
+		labels[LabelEndpointType] = backend.EndpointType(endpoint)
+	}
+	return labels
+}
@@ -0,0 +1,56 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_pvLabels(t *testing.T) {
+	labels := pvLabels("a.bucket.with.dots", "https://s3.private.us-south.cloud-object-storage.appdomain.cloud", false)
+	assert.Equal(t, map[string]string{
+		LabelBucketHash:   BucketLabelValue("a.bucket.with.dots"),
+		LabelRegion:       "us-south",
+		LabelEndpointType: "private",
+		LabelAutoCreated:  "false",
+	}, labels)
+	assert.Len(t, labels[LabelBucketHash], 32)
+
+	labels = pvLabels(testBucket, "http://minio.example.com:9000", true)
+	assert.NotContains(t, labels, LabelRegion)
+	assert.NotContains(t, labels, LabelEndpointType)
+	assert.Equal(t, "true", labels[LabelAutoCreated])
+}
+
+func Test_Provision_PVLabels(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, BucketLabelValue(testBucket), pv.Labels[LabelBucketHash])
+	assert.Equal(t, "true", pv.Labels[LabelAutoCreated])
+
+	factory.FailCreateBucket = true
+	factory.FailCreateBucketErrMsg = "BucketAlreadyExists"
+	pv, _, err = p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", pv.Labels[LabelAutoCreated])
+}
@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AnnotationAllowPVCOverrides is the parameter of the storage classes which, set to false, ignores
+// the lockedAnnotations of their claims
+const AnnotationAllowPVCOverrides = "ibm.io/allow-pvc-overrides"
+
+// AnnotationOverridableAnnotations is the parameter of the storage classes listing, comma separated, the only
+// ibm.io annotations their claims may set, the others being ignored
+const AnnotationOverridableAnnotations = "ibm.io/overridable-annotations"
+
+// PVCOverrideIgnoredReason is the reason of the events of the claims whose annotations are locked by their storage class
+const PVCOverrideIgnoredReason = "PVCOverrideIgnored"
+
+// lockedAnnotations are the annotations of the claims overriding the endpoints and the tuning of their storage class.
+// The bucket, the credentials and the lifecycle of the bucket stay up to the claims.
+var lockedAnnotations = []string{
+	"ibm.io/object-store-endpoint",
+	"ibm.io/object-store-storage-class",
+	"ibm.io/iam-endpoint",
+	"ibm.io/tls-cipher-suite",
+	"ibm.io/chunk-size-mb",
+	"ibm.io/parallel-count",
+	"ibm.io/multireq-max",
+	"ibm.io/stat-cache-size",
+	"ibm.io/stat-cache-expire-seconds",
+	"ibm.io/stat-cache-expire",
+	"ibm.io/s3fs-fuse-retry-count",
+	"ibm.io/connect-timeout",
+	"ibm.io/readwrite-timeout",
+	"ibm.io/tmpfs-cache-size-mb",
+	"ibm.io/read-ahead-kb",
+	"ibm.io/multipart-size-mb",
+	"ibm.io/singlepart-copy-limit-mb",
+	"ibm.io/max-dirty-data-mb",
+	"ibm.io/list-object-max-keys",
+	"ibm.io/add-mount-param",
+	"ibm.io/extra-mount-options",
+}
+
+// IgnoredOverrides returns the annotations of a claim its storage class ignores, sorted: the lockedAnnotations when
+// the storage class forbids the overrides, and the ibm.io annotations it does not list as overridable.
+func IgnoredOverrides(annotations, parameters map[string]string) ([]string, error) {
+	ignored := map[string]bool{}
+	if value, ok := parameters[AnnotationAllowPVCOverrides]; ok {
+		allow, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, expects true or false", AnnotationAllowPVCOverrides, value)
+		}
+		if !allow {
+			for _, key := range lockedAnnotations {
+				if _, ok := annotations[key]; ok {
+					ignored[key] = true
+				}
+			}
+		}
+	}
+	if value, ok := parameters[AnnotationOverridableAnnotations]; ok {
+		overridable := map[string]bool{AnnotationAPIVersion: true, AnnotationRequestedBy: true,
+			AnnotationProvisioningFailures: true, AnnotationProvisioningRetryAfter: true,
+			AnnotationBackupBucket: true, AnnotationBackupInterval: true, AnnotationBackupPrefix: true}
+		for _, key := range strings.Split(value, ",") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if !strings.HasPrefix(key, "ibm.io/") {
+				key = "ibm.io/" + key
+			}
+			overridable[key] = true
+		}
+		// the quantity of chunk-size is converted to chunk-size-mb before the overrides are checked
+		if overridable[AnnotationChunkSize] {
+			overridable["ibm.io/chunk-size-mb"] = true
+		}
+		for key := range annotations {
+			if strings.HasPrefix(key, "ibm.io/") && !overridable[key] {
+				ignored[key] = true
+			}
+		}
+	}
+	var keys []string
+	for key := range ignored {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// lockOverrides drops the IgnoredOverrides from the annotations of a claim and returns them
+func lockOverrides(annotations, parameters map[string]string) ([]string, error) {
+	dropped, err := IgnoredOverrides(annotations, parameters)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range dropped {
+		delete(annotations, key)
+	}
+	return dropped, nil
+}
+
+// claimIgnoredOverrides returns the IgnoredOverrides of a claim once its annotations and the parameters of its
+// storage class are converted and completed the way Provision does
+func (p *IBMS3fsProvisioner) claimIgnoredOverrides(pvc *v1.PersistentVolumeClaim, sc *storagev1.StorageClass) ([]string, error) {
+	annotations, err := convertAnnotations(pvc.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert PVC annotations: %v", err)
+	}
+	parameters, err := convertAnnotations(sc.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert storage class parameters: %v", err)
+	}
+	if parameters, err = expandProfile(parameters); err != nil {
+		return nil, fmt.Errorf("invalid storage class parameters: %v", err)
+	}
+	annotations, parameters = p.GlobalDefaults.Current().apply(annotations, parameters)
+	return IgnoredOverrides(annotations, parameters)
+}
+
+// warnLockedOverrides records an event on a claim whose annotations are ignored
+func (p *IBMS3fsProvisioner) warnLockedOverrides(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string, dropped []string) {
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, PVCOverrideIgnoredReason,
+		fmt.Sprintf("annotations %s are ignored, the storage class does not allow the claims to override them",
+			strings.Join(dropped, ", "))); err != nil {
+		p.Logger.Error("Cannot record the ignored override event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+}
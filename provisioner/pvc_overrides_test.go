@@ -0,0 +1,116 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_lockOverrides(t *testing.T) {
+	annotations := map[string]string{
+		annotationIAMEndpoint:   "https://other-iam-endpoint",
+		"ibm.io/parallel-count": "30",
+		"ibm.io/bucket":         testBucket,
+	}
+	dropped, err := lockOverrides(annotations, map[string]string{AnnotationAllowPVCOverrides: "false"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{annotationIAMEndpoint, "ibm.io/parallel-count"}, dropped)
+	assert.Equal(t, map[string]string{"ibm.io/bucket": testBucket}, annotations)
+
+	dropped, err = lockOverrides(map[string]string{"ibm.io/parallel-count": "30"}, map[string]string{AnnotationAllowPVCOverrides: "true"})
+	assert.NoError(t, err)
+	assert.Empty(t, dropped)
+
+	_, err = lockOverrides(map[string]string{}, map[string]string{AnnotationAllowPVCOverrides: "no way"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "invalid ibm.io/allow-pvc-overrides \"no way\", expects true or false", err.Error())
+	}
+}
+
+func Test_Provision_LockedOverrides(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[AnnotationAllowPVCOverrides] = "false"
+	v.PVC.Annotations[annotationOSEndpoint] = "https://other-object-store-endpoint"
+	v.PVC.Annotations[annotationIAMEndpoint] = "https://other-iam-endpoint"
+	v.PVC.Annotations["ibm.io/parallel-count"] = "30"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, testOSEndpoint, pv.Spec.FlexVolume.Options[optionOSEndpoint])
+	assert.Equal(t, testIAMEndpoint, pv.Spec.FlexVolume.Options[optionIAMEndpoint])
+	assert.Equal(t, v.StorageClass.Parameters[parameterParallelCount], pv.Spec.FlexVolume.Options[optionParallelCount])
+
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, PVCOverrideIgnoredReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "ibm.io/iam-endpoint, ibm.io/object-store-endpoint, ibm.io/parallel-count")
+	}
+}
+
+func Test_Provision_LockedOverrides_Allowed(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Annotations["ibm.io/parallel-count"] = "30"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "30", pv.Spec.FlexVolume.Options[optionParallelCount])
+}
+
+func Test_IgnoredOverrides_Overridable(t *testing.T) {
+	annotations := map[string]string{
+		annotationBucket:        testBucket,
+		annotationObjectPath:    "/data",
+		annotationSecretName:    testSecretName,
+		"ibm.io/chunk-size-mb":  "64",
+		"ibm.io/parallel-count": "30",
+		AnnotationAPIVersion:    AnnotationsV2,
+		"example.com/owner":     "tenant",
+	}
+	ignored, err := IgnoredOverrides(annotations, map[string]string{
+		AnnotationOverridableAnnotations: "ibm.io/bucket, object-path,ibm.io/secret-name,ibm.io/chunk-size",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ibm.io/parallel-count"}, ignored)
+}
+
+func Test_IgnoredOverrides_LockedAndOverridable(t *testing.T) {
+	ignored, err := IgnoredOverrides(map[string]string{
+		annotationBucket:      testBucket,
+		annotationIAMEndpoint: "https://other-iam-endpoint",
+	}, map[string]string{
+		AnnotationAllowPVCOverrides:      "false",
+		AnnotationOverridableAnnotations: "ibm.io/bucket,ibm.io/iam-endpoint",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{annotationIAMEndpoint}, ignored)
+}
+
+func Test_Provision_OverridableAnnotations(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.StorageClass.Parameters[AnnotationOverridableAnnotations] = "ibm.io/secret-name"
+	v.PVC.Annotations["ibm.io/parallel-count"] = "30"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, v.StorageClass.Parameters[parameterParallelCount], pv.Spec.FlexVolume.Options[optionParallelCount])
+
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, PVCOverrideIgnoredReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "annotations ibm.io/parallel-count are ignored")
+	}
+}
@@ -0,0 +1,45 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"strconv"
+	"strings"
+)
+
+// AnnotationReplicationTarget is the annotation of the volumes whose created bucket is replicated, to
+// "<target bucket>@<target region>"
+const AnnotationReplicationTarget = "ibm.io/replication-target"
+
+// replicationRule returns the replication rule of the buckets created with a storage class, nil when its
+// buckets are not replicated
+func replicationRule(sc scOptions) (*backend.ReplicationRule, error) {
+	bucket := strings.TrimSpace(sc.ReplicationTargetBucket)
+	region := strings.TrimSpace(sc.ReplicationTargetRegion)
+	priority := strings.TrimSpace(sc.ReplicationPriority)
+	if bucket == "" && region == "" && priority == "" {
+		return nil, nil
+	}
+	rule := &backend.ReplicationRule{TargetBucket: bucket, TargetRegion: region}
+	if priority != "" {
+		value, err := strconv.ParseInt(priority, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replication-priority %q, expects a positive integer", priority)
+		}
+		rule.Priority = int32(value)
+	}
+	if err := backend.ValidateReplication(*rule, sc.OSEndpoint); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
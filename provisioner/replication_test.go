@@ -0,0 +1,92 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/controller"
+	"testing"
+)
+
+func getReplicatedVolumeOptions() controller.ProvisionOptions {
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.StorageClass.Parameters["ibm.io/replication-target-bucket"] = "dr-bucket"
+	v.StorageClass.Parameters["ibm.io/replication-target-region"] = "eu-de"
+	v.StorageClass.Parameters["ibm.io/replication-priority"] = "2"
+	return v
+}
+
+func Test_replicationRule(t *testing.T) {
+	rule, err := replicationRule(scOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	rule, err = replicationRule(scOptions{ReplicationTargetBucket: "dr", ReplicationTargetRegion: "eu-de"})
+	assert.NoError(t, err)
+	assert.Equal(t, &backend.ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de"}, rule)
+
+	_, err = replicationRule(scOptions{ReplicationTargetBucket: "dr", ReplicationTargetRegion: "eu-de", ReplicationPriority: "high"})
+	assert.EqualError(t, err, `invalid replication-priority "high", expects a positive integer`)
+	_, err = replicationRule(scOptions{ReplicationTargetRegion: "eu-de"})
+	assert.EqualError(t, err, "replication needs a replication-target-bucket")
+}
+
+func Test_Provision_Replication(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+
+	pv, _, err := p.Provision(context.Background(), getReplicatedVolumeOptions())
+	require.NoError(t, err)
+	assert.Equal(t, &backend.ReplicationRule{TargetBucket: "dr-bucket", TargetRegion: "eu-de", Priority: 2}, factory.LastReplicationRule)
+	assert.Equal(t, "dr-bucket@eu-de", pv.Annotations[AnnotationReplicationTarget])
+}
+
+func Test_Provision_Replication_ExistingBucket(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailCreateBucket: true, FailCreateBucketErrMsg: "BucketAlreadyExists"}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+
+	pv, _, err := p.Provision(context.Background(), getReplicatedVolumeOptions())
+	require.NoError(t, err)
+	assert.Nil(t, factory.LastReplicationRule)
+	assert.NotContains(t, pv.Annotations, AnnotationReplicationTarget)
+}
+
+func Test_Provision_Replication_Failed(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailSetBucketReplication: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+
+	_, _, err := p.Provision(context.Background(), getReplicatedVolumeOptions())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to set replication for bucket "+testBucket)
+	}
+	assert.Equal(t, testBucket, factory.LastDeletedBucket)
+}
+
+func Test_Provision_Replication_Invalid(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	v := getReplicatedVolumeOptions()
+	delete(v.StorageClass.Parameters, "ibm.io/replication-target-region")
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid replication-target-region")
+	}
+	assert.Empty(t, factory.LastCreatedBucket)
+}
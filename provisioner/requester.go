@@ -0,0 +1,41 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// AnnotationRequestedBy is the annotation of the volumes, and the tag of the buckets created for them, naming
+// the identity that created their claim. It is the default RequesterAnnotation of the claims as well.
+const AnnotationRequestedBy = "ibm.io/requested-by"
+
+// requester returns the identity that created a claim, as recorded on the claim by an admission webhook or
+// an audit tool in its RequesterAnnotation, empty when unknown
+func (p *IBMS3fsProvisioner) requester(pvc *v1.PersistentVolumeClaim) string {
+	if p.RequesterAnnotation == "" {
+		return ""
+	}
+	return strings.TrimSpace(pvc.Annotations[p.RequesterAnnotation])
+}
+
+// bucketTags returns the tags of a bucket created for a claim: the namespace metadata and the requester
+func bucketTags(metadata map[string]string, requester string) map[string]string {
+	tags := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		tags[key] = value
+	}
+	if requester != "" {
+		tags[AnnotationRequestedBy] = requester
+	}
+	return tags
+}
@@ -0,0 +1,71 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+const testRequester = "jane@example.com"
+
+func Test_Provision_RequestedBy(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{})
+	p.RequesterAnnotation = AnnotationRequestedBy
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[AnnotationRequestedBy] = testRequester
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, testRequester, pv.Annotations[AnnotationRequestedBy])
+	assert.Equal(t, map[string]string{AnnotationRequestedBy: testRequester}, factory.LastBucketTags)
+	// the annotation is read by the provisioner, it is not reported as unknown
+	events, _ := p.Client.CoreV1().Events(testNamespace).List(context.Background(), metav1.ListOptions{})
+	assert.Empty(t, events.Items)
+}
+
+func Test_Provision_RequestedBy_CustomAnnotation(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{})
+	p.RequesterAnnotation = "example.com/created-by"
+	v := getVolumeOptions()
+	v.PVC.Annotations["example.com/created-by"] = testRequester
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.Equal(t, testRequester, pv.Annotations[AnnotationRequestedBy])
+}
+
+func Test_Provision_RequestedBy_Disabled(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{},
+		&fakeProvider.FakeIBMProviderClientFactory{})
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	v.PVC.Annotations[annotationBucket] = testBucket
+	v.PVC.Annotations[AnnotationRequestedBy] = testRequester
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.NotContains(t, pv.Annotations, AnnotationRequestedBy)
+	assert.Nil(t, factory.LastBucketTags)
+}
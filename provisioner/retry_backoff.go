@@ -0,0 +1,102 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"time"
+)
+
+// Annotations of the claims holding their backoff, so that a restart of the provisioner does not retry
+// every failing claim at once
+const (
+	// AnnotationProvisioningFailures is the number of consecutive failed provisionings of a claim
+	AnnotationProvisioningFailures = "ibm.io/provisioning-failures"
+	// AnnotationProvisioningRetryAfter is the RFC 3339 time before which a claim is not provisioned again
+	AnnotationProvisioningRetryAfter = "ibm.io/provisioning-retry-after"
+)
+
+// RetryBackoff is the exponential backoff of the failed provisionings, from Base doubled on every failure
+// up to Max
+type RetryBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// delay returns the backoff after a number of consecutive failures
+func (b *RetryBackoff) delay(failures int) time.Duration {
+	delay := b.Base
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// checkRetryBackoff returns an error while the backoff of a claim recorded by a previous failure runs
+func (p *IBMS3fsProvisioner) checkRetryBackoff(pvc *v1.PersistentVolumeClaim) error {
+	if p.RetryBackoff == nil {
+		return nil
+	}
+	value := pvc.Annotations[AnnotationProvisioningRetryAfter]
+	if value == "" {
+		return nil
+	}
+	retryAfter, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// an edited annotation must not block the claim
+		return nil
+	}
+	if time.Now().Before(retryAfter) {
+		return fmt.Errorf("backing off after %s failed provisionings, retrying after %s",
+			pvc.Annotations[AnnotationProvisioningFailures], value)
+	}
+	return nil
+}
+
+// recordRetryBackoff records the backoff of a claim after a failed provisioning, and clears it after a
+// successful one. The annotations are patched, the claim of the controller may be stale.
+func (p *IBMS3fsProvisioner) recordRetryBackoff(ctx context.Context, pvc *v1.PersistentVolumeClaim, provisionErr error) {
+	if p.RetryBackoff == nil {
+		return
+	}
+	annotations := map[string]interface{}{}
+	if provisionErr != nil {
+		failures, _ := strconv.Atoi(pvc.Annotations[AnnotationProvisioningFailures])
+		failures++
+		retryAfter := time.Now().Add(p.RetryBackoff.delay(failures)).UTC().Format(time.RFC3339)
+		annotations[AnnotationProvisioningFailures] = strconv.Itoa(failures)
+		annotations[AnnotationProvisioningRetryAfter] = retryAfter
+	} else if _, ok := pvc.Annotations[AnnotationProvisioningFailures]; ok {
+		annotations[AnnotationProvisioningFailures] = nil
+		annotations[AnnotationProvisioningRetryAfter] = nil
+	} else {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		p.Logger.Error("Cannot encode the backoff of the claim", zap.String("name", pvc.Name), zap.Error(err))
+		return
+	}
+	if _, err := p.Client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType,
+		patch, metav1.PatchOptions{}); err != nil {
+		p.Logger.Error("Cannot record the backoff of the claim", zap.String("name", pvc.Name), zap.Error(err))
+	}
+}
@@ -0,0 +1,77 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	fakeProvider "github.com/IBM/ibmcloud-object-storage-plugin/ibm-provider/provider/fake-provider"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	fakeGrpcClient "github.com/IBM/ibmcloud-object-storage-plugin/utils/grpc-client/fake-grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func Test_RetryBackoff_delay(t *testing.T) {
+	b := &RetryBackoff{Base: 10 * time.Second, Max: time.Minute}
+	assert.Equal(t, 10*time.Second, b.delay(1))
+	assert.Equal(t, 20*time.Second, b.delay(2))
+	assert.Equal(t, 40*time.Second, b.delay(3))
+	assert.Equal(t, time.Minute, b.delay(4))
+	assert.Equal(t, time.Minute, b.delay(1000))
+}
+
+func Test_Provision_RetryBackoff(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{FailCreateBucket: true}
+	p := getFakeBackendProvisioner(factory, &fakeGrpcClient.FakeGrpcSessionFactory{}, &fake.FakeAccessPolicyFactory{}, &fakeProvider.FakeIBMProviderClientFactory{})
+	p.RetryBackoff = &RetryBackoff{Base: time.Minute, Max: time.Hour}
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationAutoCreateBucket] = "true"
+	_, err := p.Client.CoreV1().PersistentVolumeClaims(v.PVC.Namespace).Create(context.Background(), v.PVC, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, _, err = p.Provision(context.Background(), v)
+	assert.Error(t, err)
+	pvc, err := p.Client.CoreV1().PersistentVolumeClaims(v.PVC.Namespace).Get(context.Background(), v.PVC.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "1", pvc.Annotations[AnnotationProvisioningFailures])
+	retryAfter, err := time.Parse(time.RFC3339, pvc.Annotations[AnnotationProvisioningRetryAfter])
+	require.NoError(t, err)
+	assert.True(t, retryAfter.After(time.Now().Add(50*time.Second)))
+
+	// the backoff survives a restart, the object storage is not called before it ends
+	factory.ResetStats()
+	v.PVC = pvc
+	_, _, err = p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "backing off after 1 failed provisionings")
+	}
+	assert.Empty(t, factory.LastCreatedBucket)
+}
+
+func Test_Provision_RetryBackoff_Cleared(t *testing.T) {
+	p := getProvisioner()
+	p.RetryBackoff = &RetryBackoff{Base: time.Minute, Max: time.Hour}
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationProvisioningFailures] = "3"
+	v.PVC.Annotations[AnnotationProvisioningRetryAfter] = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+	_, err := p.Client.CoreV1().PersistentVolumeClaims(v.PVC.Namespace).Create(context.Background(), v.PVC, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, _, err = p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	pvc, err := p.Client.CoreV1().PersistentVolumeClaims(v.PVC.Namespace).Get(context.Background(), v.PVC.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, pvc.Annotations, AnnotationProvisioningFailures)
+	assert.NotContains(t, pvc.Annotations, AnnotationProvisioningRetryAfter)
+}
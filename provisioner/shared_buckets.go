@@ -0,0 +1,85 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// SharedBucketWriterReason is the reason of the events of the claims sharing their bucket between
+// read-only volumes and volumes writing to it
+const SharedBucketWriterReason = "SharedBucketWriter"
+
+// readOnlyDefaults are the parameters of the ReadOnlyMany claims their storage class leaves empty. Nothing
+// writes through the mount, the objects are cached in the kernel of each node.
+var readOnlyDefaults = map[string]string{
+	"ibm.io/kernel-cache": "true",
+}
+
+// isReadOnlyMany tells whether a claim requests the ReadOnlyMany access mode only
+func isReadOnlyMany(pvc *v1.PersistentVolumeClaim) bool {
+	modes := pvc.Spec.AccessModes
+	return len(modes) == 1 && modes[0] == v1.ReadOnlyMany
+}
+
+// applyReadOnlyDefaults sets the readOnlyDefaults of the parameters left empty
+func applyReadOnlyDefaults(parameters map[string]string) {
+	for key, value := range readOnlyDefaults {
+		if parameters[key] == "" {
+			parameters[key] = value
+		}
+	}
+}
+
+// warnSharedBucketWriters records an event on a claim whose bucket is mounted read-only by some volumes and
+// written by others: the read-only mounts cache the metadata, and with kernel-cache the data, of the objects
+// and may serve stale content while the writers update them.
+func (p *IBMS3fsProvisioner) warnSharedBucketWriters(ctx context.Context, pvc *v1.PersistentVolumeClaim, component, bucket string,
+	mode v1.PersistentVolumeAccessMode) {
+	references, err := p.bucketReferences(ctx)
+	if err != nil {
+		p.Logger.Warn("Cannot check the volumes sharing the bucket", zap.String("bucket", bucket), zap.Error(err))
+		return
+	}
+	var readers, writers []string
+	for _, name := range references[bucket] {
+		pv, err := p.Client.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		if len(pv.Spec.AccessModes) == 1 && pv.Spec.AccessModes[0] == v1.ReadOnlyMany {
+			readers = append(readers, name)
+		} else {
+			writers = append(writers, name)
+		}
+	}
+	var message string
+	switch {
+	case mode == v1.ReadOnlyMany && len(writers) > 0:
+		message = fmt.Sprintf("bucket %s is written by PVs %s, the read-only mounts cache the objects and may read "+
+			"stale data, set ibm.io/stat-cache-expire and ibm.io/kernel-cache: \"false\" for fresher reads",
+			bucket, strings.Join(writers, ", "))
+	case mode != v1.ReadOnlyMany && len(readers) > 0:
+		message = fmt.Sprintf("bucket %s is mounted read-only by PVs %s, which cache the objects and may read "+
+			"stale data after the writes of this volume", bucket, strings.Join(readers, ", "))
+	default:
+		return
+	}
+	ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+	if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, SharedBucketWriterReason, message); err != nil {
+		p.Logger.Error("Cannot record the shared bucket event", zap.String("name", pvc.Name), zap.Error(err))
+	}
+}
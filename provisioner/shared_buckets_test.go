@@ -0,0 +1,97 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func getSharedPV(name string, mode v1.PersistentVolumeAccessMode) *v1.PersistentVolume {
+	pv := getOrphanPV(name, driverName, map[string]string{"bucket": testBucket}, nil)
+	pv.Spec.AccessModes = []v1.PersistentVolumeAccessMode{mode}
+	return pv
+}
+
+func Test_Provision_ReadOnlyMany_Defaults(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+
+	pv, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.True(t, pv.Spec.FlexVolume.ReadOnly)
+	assert.Equal(t, "true", pv.Spec.FlexVolume.Options[optionKernelCache])
+
+	// the storage class wins over the default
+	v.StorageClass.Parameters[parameterKernelCache] = "false"
+	pv, _, err = p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	assert.NotEqual(t, "true", pv.Spec.FlexVolume.Options[optionKernelCache])
+}
+
+func Test_Provision_ReadOnlyMany_WriteBackCache(t *testing.T) {
+	p := getProvisioner()
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+	v.PVC.Annotations[annotationWriteBackCache] = "true"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "write-back-cache is not supported by the ReadOnlyMany volumes")
+	}
+}
+
+func Test_Provision_SharedBucket_ReaderOfWriter(t *testing.T) {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{}, getSharedPV("pv-writer", v1.ReadWriteMany))
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	_, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, SharedBucketWriterReason, events.Items[0].Reason)
+		assert.Contains(t, events.Items[0].Message, "bucket test-bucket is written by PVs pv-writer")
+	}
+}
+
+func Test_Provision_SharedBucket_WriterOfReaders(t *testing.T) {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{},
+		getSharedPV("pv-reader-1", v1.ReadOnlyMany), getSharedPV("pv-reader-2", v1.ReadOnlyMany))
+	v := getVolumeOptions()
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	_, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Contains(t, events.Items[0].Message, "mounted read-only by PVs pv-reader-1, pv-reader-2")
+	}
+}
+
+func Test_Provision_SharedBucket_ReadersOnly(t *testing.T) {
+	p := getOrphanProvisioner(t, &fake.ObjectStorageSessionFactory{}, getSharedPV("pv-reader", v1.ReadOnlyMany))
+	v := getVolumeOptions()
+	v.PVC.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+	v.PVC.Annotations[annotationBucket] = testBucket
+
+	_, _, err := p.Provision(context.Background(), v)
+	require.NoError(t, err)
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	assert.Empty(t, events.Items)
+}
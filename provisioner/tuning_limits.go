@@ -0,0 +1,109 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// TuningCapExceededReason is the reason of the events of the claims whose tuning parameters exceed a cap
+const TuningCapExceededReason = "TuningCapExceeded"
+
+// TuningParameters are the s3fs tuning parameters the provisioner sets defaults and caps for
+var TuningParameters = []string{
+	"ibm.io/chunk-size-mb",
+	"ibm.io/parallel-count",
+	"ibm.io/multireq-max",
+	"ibm.io/stat-cache-size",
+}
+
+// TuningLimits are the provisioner defaults and caps of the TuningParameters, set by its flags
+type TuningLimits struct {
+	// Defaults are the values of the parameters the storage classes leave empty, after the cluster-wide defaults
+	Defaults map[string]int
+	// Caps are the highest values of the parameters and annotations
+	Caps map[string]int
+	// Clamp lowers the values above their cap to the cap instead of rejecting the claim
+	Clamp bool
+}
+
+// Validate checks the defaults and caps are positive and the defaults do not exceed the caps
+func (l *TuningLimits) Validate() error {
+	if l == nil {
+		return nil
+	}
+	for _, key := range TuningParameters {
+		def, limit := l.Defaults[key], l.Caps[key]
+		if def < 0 || limit < 0 {
+			return fmt.Errorf("the default and the cap of %s must not be negative", key)
+		}
+		if def > 0 && limit > 0 && def > limit {
+			return fmt.Errorf("the default of %s %d exceeds its cap %d", key, def, limit)
+		}
+	}
+	return nil
+}
+
+// applyDefaults sets the defaults of the parameters left empty
+func (l *TuningLimits) applyDefaults(parameters map[string]string) {
+	if l == nil {
+		return
+	}
+	for _, key := range TuningParameters {
+		if def := l.Defaults[key]; def > 0 && parameters[key] == "" {
+			parameters[key] = strconv.Itoa(def)
+		}
+	}
+}
+
+// enforceCaps clamps, or reports, the integer annotations and parameters above their cap and records
+// an event on the claim. The values that are not integers are left to the validation of the annotations.
+func (p *IBMS3fsProvisioner) enforceCaps(ctx context.Context, pvc *v1.PersistentVolumeClaim, component string,
+	annotations, parameters map[string]string) []error {
+	l := p.TuningLimits
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	var exceeded []string
+	for _, key := range TuningParameters {
+		limit := l.Caps[key]
+		if limit <= 0 {
+			continue
+		}
+		for _, m := range []map[string]string{annotations, parameters} {
+			n, err := strconv.Atoi(m[key])
+			if err != nil || n <= limit {
+				continue
+			}
+			if l.Clamp {
+				m[key] = strconv.Itoa(limit)
+				exceeded = append(exceeded, fmt.Sprintf("value of %s %d clamped to the provisioner cap %d", key, n, limit))
+			} else {
+				errs = append(errs, fmt.Errorf("value of %s %d exceeds the provisioner cap %d", key, n, limit))
+				exceeded = append(exceeded, errs[len(errs)-1].Error())
+			}
+		}
+	}
+	if len(exceeded) > 0 {
+		ref := v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+		if err := createEvent(ctx, p.Client, component, ref, v1.EventTypeWarning, TuningCapExceededReason,
+			strings.Join(exceeded, "; ")); err != nil {
+			p.Logger.Error("Cannot record the tuning cap event", zap.String("name", pvc.Name), zap.Error(err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,80 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_TuningLimits_Validate(t *testing.T) {
+	assert.NoError(t, (*TuningLimits)(nil).Validate())
+	assert.NoError(t, (&TuningLimits{
+		Defaults: map[string]int{"ibm.io/chunk-size-mb": 16},
+		Caps:     map[string]int{"ibm.io/chunk-size-mb": 64, "ibm.io/parallel-count": 10},
+	}).Validate())
+
+	err := (&TuningLimits{
+		Defaults: map[string]int{"ibm.io/parallel-count": 20},
+		Caps:     map[string]int{"ibm.io/parallel-count": 10},
+	}).Validate()
+	if assert.Error(t, err) {
+		assert.Equal(t, "the default of ibm.io/parallel-count 20 exceeds its cap 10", err.Error())
+	}
+	assert.Error(t, (&TuningLimits{Caps: map[string]int{"ibm.io/multireq-max": -1}}).Validate())
+}
+
+func Test_Provision_TuningLimits_Defaults(t *testing.T) {
+	p := getProvisioner()
+	p.TuningLimits = &TuningLimits{Defaults: map[string]int{"ibm.io/chunk-size-mb": 16, "ibm.io/parallel-count": 8}}
+	v := getVolumeOptions()
+	delete(v.StorageClass.Parameters, parameterChunkSizeMB)
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "16", pv.Spec.FlexVolume.Options[optionChunkSizeMB])
+	// the parameter of the storage class wins over the default
+	assert.Equal(t, v.StorageClass.Parameters[parameterParallelCount], pv.Spec.FlexVolume.Options[optionParallelCount])
+}
+
+func Test_Provision_TuningLimits_Reject(t *testing.T) {
+	p := getProvisioner()
+	p.TuningLimits = &TuningLimits{Caps: map[string]int{"ibm.io/parallel-count": 10}}
+	v := getVolumeOptions()
+	v.PVC.Annotations["ibm.io/parallel-count"] = "50"
+
+	_, _, err := p.Provision(context.Background(), v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "value of ibm.io/parallel-count 50 exceeds the provisioner cap 10")
+	}
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, TuningCapExceededReason, events.Items[0].Reason)
+	}
+}
+
+func Test_Provision_TuningLimits_Clamp(t *testing.T) {
+	p := getProvisioner()
+	p.TuningLimits = &TuningLimits{Caps: map[string]int{"ibm.io/chunk-size-mb": 32}, Clamp: true}
+	v := getVolumeOptions()
+	v.PVC.Annotations[AnnotationChunkSize] = "1Gi"
+
+	pv, _, err := p.Provision(context.Background(), v)
+	assert.NoError(t, err)
+	assert.Equal(t, "32", pv.Spec.FlexVolume.Options[optionChunkSizeMB])
+	events, _ := p.Client.CoreV1().Events(v.PVC.Namespace).List(context.Background(), metav1.ListOptions{})
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, TuningCapExceededReason, events.Items[0].Reason)
+		assert.Equal(t, "value of ibm.io/chunk-size-mb 1024 clamped to the provisioner cap 32", events.Items[0].Message)
+	}
+}
@@ -0,0 +1,248 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	namespaceUsageBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ibmc_s3fs_namespace_usage_bytes",
+		Help: "Bytes stored in the buckets of the volumes of the claims of a namespace",
+	}, []string{"namespace"})
+	namespaceUsageObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ibmc_s3fs_namespace_usage_objects",
+		Help: "Objects stored in the buckets of the volumes of the claims of a namespace",
+	}, []string{"namespace"})
+	namespaceVolumes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ibmc_s3fs_namespace_volumes",
+		Help: "Volumes of the claims of a namespace whose usage is metered",
+	}, []string{"namespace"})
+	usageMeteringErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ibmc_s3fs_usage_metering_errors_total",
+		Help: "Volumes whose usage could not be read",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(namespaceUsageBytes, namespaceUsageObjects, namespaceVolumes, usageMeteringErrors)
+}
+
+// VolumeUsage is the usage of the bucket, under its object-path, of a volume of a claim
+type VolumeUsage struct {
+	Namespace  string `json:"namespace"`
+	Claim      string `json:"claim"`
+	Volume     string `json:"volume"`
+	Bucket     string `json:"bucket"`
+	ObjectPath string `json:"objectPath,omitempty"`
+	Bytes      int64  `json:"bytes"`
+	Objects    int64  `json:"objects"`
+	// Error is why the usage could not be read, the volume is left out of the namespace totals
+	Error string `json:"error,omitempty"`
+}
+
+// NamespaceUsage is the usage of the volumes of the claims of a namespace
+type NamespaceUsage struct {
+	Namespace string `json:"namespace"`
+	Volumes   int    `json:"volumes"`
+	Bytes     int64  `json:"bytes"`
+	Objects   int64  `json:"objects"`
+}
+
+// UsageReport is the usage of the volumes read at a time
+type UsageReport struct {
+	Time       time.Time        `json:"time"`
+	Namespaces []NamespaceUsage `json:"namespaces"`
+	Volumes    []VolumeUsage    `json:"volumes"`
+}
+
+// MeterUsage reads the usage of the volumes of the driver bound, or released, to a claim, with the
+// credentials of the secret of each volume. A volume whose usage cannot be read reports the error.
+func (p *IBMS3fsProvisioner) MeterUsage(ctx context.Context) (*UsageReport, error) {
+	pvs, err := p.Client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list PVs: %v", err)
+	}
+	report := &UsageReport{Time: time.Now().UTC()}
+	secrets := map[string]*backend.ObjectStorageCredentials{}
+	for _, pv := range pvs.Items {
+		flex := pv.Spec.FlexVolume
+		if flex == nil || flex.Driver != driverName || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		usage := VolumeUsage{Namespace: pv.Spec.ClaimRef.Namespace, Claim: pv.Spec.ClaimRef.Name, Volume: pv.Name,
+			Bucket: flex.Options["bucket"], ObjectPath: flex.Options["object-path"]}
+		if err := p.readVolumeUsage(ctx, flex, secrets, &usage); err != nil {
+			usage.Error = err.Error()
+		}
+		report.Volumes = append(report.Volumes, usage)
+	}
+	sort.Slice(report.Volumes, func(i, j int) bool {
+		a, b := report.Volumes[i], report.Volumes[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Volume < b.Volume
+	})
+	report.Namespaces = AggregateUsage(report.Volumes)
+	return report, nil
+}
+
+// readVolumeUsage sets the bytes and objects of a volume, the credentials are cached by secret
+func (p *IBMS3fsProvisioner) readVolumeUsage(ctx context.Context, flex *v1.FlexPersistentVolumeSource,
+	secrets map[string]*backend.ObjectStorageCredentials, usage *VolumeUsage) error {
+	if flex.SecretRef == nil {
+		return fmt.Errorf("no secret to read bucket %s with", usage.Bucket)
+	}
+	key := flex.SecretRef.Namespace + "/" + flex.SecretRef.Name
+	creds, ok := secrets[key]
+	if !ok {
+		secret, err := p.Client.CoreV1().Secrets(flex.SecretRef.Namespace).Get(ctx, flex.SecretRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot retrieve secret %s: %v", key, err)
+		}
+		if creds, _, _, err = credentialsFromSecret(secret); err != nil {
+			return fmt.Errorf("cannot get credentials: %v", err)
+		}
+		secrets[key] = creds
+	}
+	sess, err := p.flexSession(flex, creds)
+	if err != nil {
+		return err
+	}
+	bucketUsage, err := sess.GetBucketUsage(ctx, usage.Bucket, usage.ObjectPath)
+	if err != nil {
+		return fmt.Errorf("cannot read the usage of bucket %s: %v", usage.Bucket, err)
+	}
+	usage.Bytes, usage.Objects = bucketUsage.BytesUsed, bucketUsage.ObjectCount
+	return nil
+}
+
+// AggregateUsage sums the usage of the volumes by namespace, leaving out the volumes whose usage could
+// not be read, sorted by namespace
+func AggregateUsage(volumes []VolumeUsage) []NamespaceUsage {
+	byNamespace := map[string]*NamespaceUsage{}
+	var namespaces []string
+	for _, volume := range volumes {
+		if volume.Error != "" {
+			continue
+		}
+		ns, ok := byNamespace[volume.Namespace]
+		if !ok {
+			ns = &NamespaceUsage{Namespace: volume.Namespace}
+			byNamespace[volume.Namespace] = ns
+			namespaces = append(namespaces, volume.Namespace)
+		}
+		ns.Volumes++
+		ns.Bytes += volume.Bytes
+		ns.Objects += volume.Objects
+	}
+	sort.Strings(namespaces)
+	result := make([]NamespaceUsage, 0, len(namespaces))
+	for _, name := range namespaces {
+		result = append(result, *byNamespace[name])
+	}
+	return result
+}
+
+// UsageMeter meters the usage of the volumes periodically, sets the usage metrics of the namespaces and
+// serves the last report as JSON, or as CSV with the format=csv query parameter
+type UsageMeter struct {
+	Provisioner *IBMS3fsProvisioner
+	Logger      *zap.Logger
+
+	mutex  sync.RWMutex
+	report *UsageReport
+}
+
+// Run meters the usage every period until ctx is done
+func (m *UsageMeter) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		m.meter(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *UsageMeter) meter(ctx context.Context) {
+	report, err := m.Provisioner.MeterUsage(ctx)
+	if err != nil {
+		m.Logger.Error("Cannot meter the usage of the volumes", zap.Error(err))
+		return
+	}
+	for _, volume := range report.Volumes {
+		if volume.Error != "" {
+			usageMeteringErrors.Inc()
+			m.Logger.Warn("Cannot meter the usage of a volume", zap.String("volume", volume.Volume),
+				zap.String("error", volume.Error))
+		}
+	}
+	// the namespaces without volumes anymore are dropped from the metrics
+	namespaceUsageBytes.Reset()
+	namespaceUsageObjects.Reset()
+	namespaceVolumes.Reset()
+	for _, ns := range report.Namespaces {
+		namespaceUsageBytes.WithLabelValues(ns.Namespace).Set(float64(ns.Bytes))
+		namespaceUsageObjects.WithLabelValues(ns.Namespace).Set(float64(ns.Objects))
+		namespaceVolumes.WithLabelValues(ns.Namespace).Set(float64(ns.Volumes))
+	}
+	m.mutex.Lock()
+	m.report = report
+	m.mutex.Unlock()
+}
+
+// ServeHTTP writes the last report, 503 until the first one is done
+func (m *UsageMeter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.RLock()
+	report := m.report
+	m.mutex.RUnlock()
+	if report == nil {
+		http.Error(w, "the usage is not metered yet", http.StatusServiceUnavailable)
+		return
+	}
+	if r.URL.Query().Get("format") != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			m.Logger.Error("Cannot write the usage report", zap.Error(err))
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	out := csv.NewWriter(w)
+	rows := [][]string{{"time", "namespace", "claim", "volume", "bucket", "objectPath", "bytes", "objects", "error"}}
+	for _, volume := range report.Volumes {
+		rows = append(rows, []string{report.Time.Format(time.RFC3339), volume.Namespace, volume.Claim, volume.Volume,
+			volume.Bucket, volume.ObjectPath, strconv.FormatInt(volume.Bytes, 10), strconv.FormatInt(volume.Objects, 10),
+			volume.Error})
+	}
+	if err := out.WriteAll(rows); err != nil {
+		m.Logger.Error("Cannot write the usage report", zap.Error(err))
+	}
+}
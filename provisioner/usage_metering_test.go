@@ -0,0 +1,87 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getMeteredPV(name, namespace, bucket string) *v1.PersistentVolume {
+	pv := getOrphanPV(name, driverName, map[string]string{"bucket": bucket, "object-path": "data"}, nil)
+	pv.Spec.FlexVolume.SecretRef = &v1.SecretReference{Name: testSecretName, Namespace: testNamespace}
+	pv.Spec.ClaimRef = &v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: namespace, Name: "claim-" + name}
+	return pv
+}
+
+func Test_MeterUsage(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{BucketUsage: backend.BucketUsage{BytesUsed: 100, ObjectCount: 2}}
+	unbound := getMeteredPV("pv-unbound", "team-a", "bucket-unbound")
+	unbound.Spec.ClaimRef = nil
+	noSecret := getMeteredPV("pv-nosecret", "team-b", "bucket-nosecret")
+	noSecret.Spec.FlexVolume.SecretRef = nil
+	p := getOrphanProvisioner(t, factory,
+		getMeteredPV("pv-1", "team-a", "bucket-1"),
+		getMeteredPV("pv-2", "team-a", "bucket-2"),
+		getMeteredPV("pv-3", "team-b", "bucket-3"),
+		getOrphanPV("pv-other", "other/driver", map[string]string{"bucket": "other"}, nil),
+		unbound, noSecret,
+	)
+
+	report, err := p.MeterUsage(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "data", factory.LastUsagePrefix)
+	if assert.Len(t, report.Volumes, 4) {
+		assert.Equal(t, VolumeUsage{Namespace: "team-a", Claim: "claim-pv-1", Volume: "pv-1", Bucket: "bucket-1",
+			ObjectPath: "data", Bytes: 100, Objects: 2}, report.Volumes[0])
+		assert.Equal(t, "pv-nosecret", report.Volumes[3].Volume)
+		assert.Contains(t, report.Volumes[3].Error, "no secret")
+	}
+	assert.Equal(t, []NamespaceUsage{
+		{Namespace: "team-a", Volumes: 2, Bytes: 200, Objects: 4},
+		{Namespace: "team-b", Volumes: 1, Bytes: 100, Objects: 2},
+	}, report.Namespaces)
+}
+
+func Test_UsageMeter_ServeHTTP(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{BucketUsage: backend.BucketUsage{BytesUsed: 100, ObjectCount: 2}}
+	m := &UsageMeter{Provisioner: getOrphanProvisioner(t, factory, getMeteredPV("pv-1", "team-a", "bucket-1")), Logger: zap.NewNop()}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	m.meter(context.Background())
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report UsageReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, []NamespaceUsage{{Namespace: "team-a", Volumes: 1, Bytes: 100, Objects: 2}}, report.Namespaces)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage?format=csv", nil))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "time,namespace,claim,volume,bucket,objectPath,bytes,objects,error", lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], ",team-a,claim-pv-1,pv-1,bucket-1,data,100,2,"))
+	}
+}
@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/go-sdk-core/v3/core"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	"k8s.io/api/core/v1"
+	"net/http"
+	"time"
+)
+
+// Names of the checks of ValidateSecret, in the order they run
+const (
+	SecretCheckCredentials       = "credentials"
+	SecretCheckServiceInstanceID = "service instance id"
+	SecretCheckEndpoint          = "endpoint reachable"
+	SecretCheckIAMToken          = "IAM token"
+	SecretCheckListBuckets       = "list buckets"
+)
+
+// secretCheckTimeout bounds the network checks of ValidateSecret
+const secretCheckTimeout = 30 * time.Second
+
+var errServiceInstanceIDMissing = errors.New("service-instance-id secret missing, buckets cannot be listed nor created with the API key")
+
+// SecretCheck is the outcome of one check of a secret
+type SecretCheck struct {
+	Name string
+	// Err is nil when the check passed
+	Err error
+	// Skipped tells the check was not run because a check it depends on failed
+	Skipped bool
+}
+
+// SecretValidation holds what ValidateSecret needs besides the secret
+type SecretValidation struct {
+	// Endpoint is the object storage endpoint the secret is used with
+	Endpoint string
+	// Region signs the requests of HMAC credentials
+	Region string
+	// IAMEndpoint issues the IAM tokens of API keys
+	IAMEndpoint string
+	// Backend opens the object storage session listing the buckets
+	Backend backend.ObjectStorageSessionFactory
+	// HTTPClient checks that the endpoint is reachable, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// ValidateSecret checks that the provisioner can use a secret with an object storage endpoint:
+// its type and keys, the endpoint being reachable, the exchange of its API key for an IAM token,
+// and the permission to list the buckets of the service instance. A check is skipped when
+// one it depends on failed.
+func ValidateSecret(ctx context.Context, secret *v1.Secret, validation SecretValidation, logger *zap.Logger) []SecretCheck {
+	var checks []SecretCheck
+	add := func(name string, err error) bool {
+		checks = append(checks, SecretCheck{Name: name, Err: err})
+		return err == nil
+	}
+	skip := func(names ...string) []SecretCheck {
+		for _, name := range names {
+			checks = append(checks, SecretCheck{Name: name, Skipped: true})
+		}
+		return checks
+	}
+
+	creds, _, _, err := credentialsFromSecret(secret)
+	if !add(SecretCheckCredentials, err) {
+		return skip(SecretCheckEndpoint, SecretCheckListBuckets)
+	}
+	if creds.APIKey != "" {
+		var idErr error
+		if creds.ServiceInstanceID == "" {
+			idErr = errServiceInstanceIDMissing
+		}
+		add(SecretCheckServiceInstanceID, idErr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretCheckTimeout)
+	defer cancel()
+	if !add(SecretCheckEndpoint, checkEndpoint(ctx, validation.HTTPClient, validation.Endpoint)) {
+		if creds.APIKey != "" {
+			skip(SecretCheckIAMToken)
+		}
+		return skip(SecretCheckListBuckets)
+	}
+
+	if creds.APIKey != "" {
+		creds.IAMEndpoint = validation.IAMEndpoint
+		authenticator := &core.IamAuthenticator{ApiKey: creds.APIKey, URL: validation.IAMEndpoint + "/identity/token"}
+		request, _ := http.NewRequest(http.MethodGet, validation.Endpoint, nil)
+		if !add(SecretCheckIAMToken, authenticator.Authenticate(request)) {
+			return skip(SecretCheckListBuckets)
+		}
+	}
+
+	sess := validation.Backend.NewObjectStorageSession(validation.Endpoint, validation.Region, creds, backend.TransportConfig{}, logger)
+	_, err = sess.ListBuckets(ctx, "")
+	add(SecretCheckListBuckets, err)
+	return checks
+}
+
+// checkEndpoint checks that the endpoint answers HTTP requests, whatever their status
+func checkEndpoint(ctx context.Context, client *http.Client, endpoint string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
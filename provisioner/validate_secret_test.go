@@ -0,0 +1,136 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package provisioner
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"k8s.io/api/core/v1"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func getValidateSecret(data map[string]string) *v1.Secret {
+	secret := &v1.Secret{Type: driverName, Data: map[string][]byte{}}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+func getSecretValidation(t *testing.T, factory *fake.ObjectStorageSessionFactory) SecretValidation {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(endpoint.Close)
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("apikey") != "good-key" {
+			http.Error(w, `{"errorMessage":"Provided API key could not be found"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		expiration := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+		_, _ = w.Write([]byte(`{"access_token":"token","refresh_token":"refresh","token_type":"Bearer","expires_in":3600,"expiration":` + expiration + `}`))
+	}))
+	t.Cleanup(iam.Close)
+	return SecretValidation{Endpoint: endpoint.URL, Region: "us-standard", IAMEndpoint: iam.URL, Backend: factory}
+}
+
+func checkNames(checks []SecretCheck) (passed, failed, skipped []string) {
+	for _, check := range checks {
+		switch {
+		case check.Skipped:
+			skipped = append(skipped, check.Name)
+		case check.Err != nil:
+			failed = append(failed, check.Name)
+		default:
+			passed = append(passed, check.Name)
+		}
+	}
+	return passed, failed, skipped
+}
+
+func Test_ValidateSecret_HMAC_Positive(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{})
+	secret := getValidateSecret(map[string]string{"access-key": testAccessKey, "secret-key": testSecretKey})
+
+	passed, failed, skipped := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckCredentials, SecretCheckEndpoint, SecretCheckListBuckets}, passed)
+	assert.Empty(t, failed)
+	assert.Empty(t, skipped)
+}
+
+func Test_ValidateSecret_MissingKeys(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{})
+	checks := ValidateSecret(context.Background(), getValidateSecret(map[string]string{"access-key": testAccessKey}), validation, zap.NewNop())
+
+	passed, failed, skipped := checkNames(checks)
+	assert.Empty(t, passed)
+	assert.Equal(t, []string{SecretCheckCredentials}, failed)
+	assert.Equal(t, []string{SecretCheckEndpoint, SecretCheckListBuckets}, skipped)
+	assert.EqualError(t, checks[0].Err, "secret-key secret missing")
+}
+
+func Test_ValidateSecret_WrongType(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{})
+	secret := getValidateSecret(map[string]string{"api-key": "good-key", "service-instance-id": "id"})
+	secret.Type = v1.SecretTypeOpaque
+
+	_, failed, _ := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckCredentials}, failed)
+}
+
+func Test_ValidateSecret_APIKey_Positive(t *testing.T) {
+	factory := &fake.ObjectStorageSessionFactory{}
+	validation := getSecretValidation(t, factory)
+	secret := getValidateSecret(map[string]string{"api-key": "good-key", "service-instance-id": "id"})
+
+	passed, failed, _ := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckCredentials, SecretCheckServiceInstanceID, SecretCheckEndpoint,
+		SecretCheckIAMToken, SecretCheckListBuckets}, passed)
+	assert.Empty(t, failed)
+	assert.Equal(t, validation.IAMEndpoint, factory.LastCredentials.IAMEndpoint)
+}
+
+func Test_ValidateSecret_APIKey_IAMFailure(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{})
+	secret := getValidateSecret(map[string]string{"api-key": "bad-key"})
+
+	passed, failed, skipped := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckCredentials, SecretCheckEndpoint}, passed)
+	assert.Equal(t, []string{SecretCheckServiceInstanceID, SecretCheckIAMToken}, failed)
+	assert.Equal(t, []string{SecretCheckListBuckets}, skipped)
+}
+
+func Test_ValidateSecret_EndpointUnreachable(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{})
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	validation.Endpoint = unreachable.URL
+	secret := getValidateSecret(map[string]string{"api-key": "good-key", "service-instance-id": "id"})
+
+	_, failed, skipped := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckEndpoint}, failed)
+	assert.Equal(t, []string{SecretCheckIAMToken, SecretCheckListBuckets}, skipped)
+}
+
+func Test_ValidateSecret_ListBucketsFailure(t *testing.T) {
+	validation := getSecretValidation(t, &fake.ObjectStorageSessionFactory{FailListBuckets: true})
+	secret := getValidateSecret(map[string]string{"access-key": testAccessKey, "secret-key": testSecretKey})
+
+	_, failed, _ := checkNames(ValidateSecret(context.Background(), secret, validation, zap.NewNop()))
+	assert.Equal(t, []string{SecretCheckListBuckets}, failed)
+}
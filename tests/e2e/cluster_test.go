@@ -0,0 +1,221 @@
+//go:build e2e
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+// Package e2e provisions and mounts volumes of MinIO buckets with the provisioner and the driver
+// deployed in a kind cluster. Run it with:
+//
+//	E2E_PROVISIONER_IMAGE=<image> E2E_MOUNTER_IMAGE=<image> go test -tags e2e -timeout 30m ./tests/e2e/
+//
+// The images are loaded into the cluster from the local docker daemon. E2E_KEEP_CLUSTER=true keeps
+// the cluster after the run, and E2E_CLUSTER_NAME reuses an existing kind cluster instead of creating one.
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	defaultClusterName = "ibmc-s3fs-e2e"
+	minioNodePort      = "30900"
+	minioAccessKey     = "e2e-access-key"
+	minioSecretKey     = "e2e-secret-key"
+	minioRegion        = "us-east-1"
+	testNamespace      = "default"
+	testSecretName     = "e2e-cos-secret"
+	testStorageClass   = "ibmc-s3fs-e2e"
+	provisionerName    = "ibm.io/ibmc-s3fs"
+	setupTimeout       = 10 * time.Minute
+)
+
+var (
+	// client reaches the API server of the kind cluster
+	client kubernetes.Interface
+	// cos reaches MinIO from the host running the tests, to check the buckets
+	cos backend.ObjectStorageSession
+	// clusterEndpoint reaches MinIO from the pods and the node
+	clusterEndpoint string
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	for _, tool := range []string{"docker", "kind", "kubectl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: %s is required: %v\n", tool, err)
+			return 1
+		}
+	}
+	provisionerImage, mounterImage := os.Getenv("E2E_PROVISIONER_IMAGE"), os.Getenv("E2E_MOUNTER_IMAGE")
+	if provisionerImage == "" || mounterImage == "" {
+		fmt.Fprintln(os.Stderr, "e2e: E2E_PROVISIONER_IMAGE and E2E_MOUNTER_IMAGE must name the images to test")
+		return 1
+	}
+
+	dir, err := os.MkdirTemp("", "ibmc-s3fs-e2e")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+
+	clusterName := os.Getenv("E2E_CLUSTER_NAME")
+	if clusterName == "" {
+		clusterName = defaultClusterName
+		if err := command("kind", "create", "cluster", "--name", clusterName, "--kubeconfig", kubeconfig,
+			"--config", manifest("kind.yaml"), "--wait", "5m"); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: cannot create the cluster: %v\n", err)
+			return 1
+		}
+		if os.Getenv("E2E_KEEP_CLUSTER") != "true" {
+			defer command("kind", "delete", "cluster", "--name", clusterName)
+		}
+	} else if err := command("kind", "export", "kubeconfig", "--name", clusterName, "--kubeconfig", kubeconfig); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: cannot get the kubeconfig of cluster %s: %v\n", clusterName, err)
+		return 1
+	}
+
+	if err := deploy(clusterName, kubeconfig, provisionerImage, mounterImage); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: cannot deploy the plugin: %v\n", err)
+		return 1
+	}
+	if err := setUp(kubeconfig); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: cannot set up the storage class: %v\n", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// command runs a command, its output is shown when it fails
+func command(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v\n%s", name, strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// manifest returns the path of a manifest of the suite
+func manifest(name string) string {
+	return filepath.Join("manifests", name)
+}
+
+// deploy loads the images into the cluster and deploys MinIO, the provisioner and the mounter
+func deploy(clusterName, kubeconfig, provisionerImage, mounterImage string) error {
+	for _, image := range []string{provisionerImage, mounterImage} {
+		if err := command("kind", "load", "docker-image", image, "--name", clusterName); err != nil {
+			return err
+		}
+	}
+	kubectl := func(args ...string) error {
+		return command("kubectl", append([]string{"--kubeconfig", kubeconfig}, args...)...)
+	}
+	steps := [][]string{
+		{"apply", "-f", manifest("minio.yaml")},
+		{"apply", "-f", filepath.Join("..", "..", "deploy", "provisioner-sa.yaml")},
+		{"apply", "-f", manifest("provisioner.yaml")},
+		{"apply", "-f", filepath.Join("..", "..", "deploy", "mounter-daemonset.yaml")},
+		{"-n", "kube-system", "set", "image", "deployment/ibmcloud-object-storage-plugin",
+			"ibmcloud-object-storage-plugin-container=" + provisionerImage},
+		{"-n", "kube-system", "set", "image", "daemonset/ibmcloud-object-storage-mounter",
+			"ibmcloud-object-storage-mounter=" + mounterImage},
+		{"-n", "e2e-minio", "rollout", "status", "deployment/minio", "--timeout", "5m"},
+		{"-n", "kube-system", "rollout", "status", "deployment/ibmcloud-object-storage-plugin", "--timeout", "5m"},
+		// the mounter only rolls on delete, its pods are recreated with the image
+		{"-n", "kube-system", "delete", "pods", "-l", "app=ibmcloud-object-storage-mounter"},
+		{"-n", "kube-system", "rollout", "status", "daemonset/ibmcloud-object-storage-mounter", "--timeout", "5m"},
+	}
+	for _, step := range steps {
+		if err := kubectl(step...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUp creates the clients of the tests, the secret of MinIO and the storage class of the tests
+func setUp(kubeconfig string) error {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return err
+	}
+	if client, err = kubernetes.NewForConfig(config); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, address := range nodes.Items[0].Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			clusterEndpoint = "http://" + address.Address + ":" + minioNodePort
+		}
+	}
+	if clusterEndpoint == "" {
+		return fmt.Errorf("node %s has no internal IP", nodes.Items[0].Name)
+	}
+
+	creds := &backend.ObjectStorageCredentials{AccessKey: minioAccessKey, SecretKey: minioSecretKey}
+	cos = (&backend.COSSessionFactory{}).NewObjectStorageSession("http://127.0.0.1:"+minioNodePort, minioRegion,
+		creds, backend.TransportConfig{}, zap.NewNop())
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+		Type:       "ibm/ibmc-s3fs",
+		StringData: map[string]string{"access-key": minioAccessKey, "secret-key": minioSecretKey},
+	}
+	// a reused cluster already has them
+	if _, err := client.CoreV1().Secrets(testNamespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	reclaimPolicy := v1.PersistentVolumeReclaimDelete
+	class := &storagev1.StorageClass{
+		ObjectMeta:    metav1.ObjectMeta{Name: testStorageClass},
+		Provisioner:   provisionerName,
+		ReclaimPolicy: &reclaimPolicy,
+		Parameters: map[string]string{
+			"ibm.io/object-store-endpoint":      clusterEndpoint,
+			"ibm.io/object-store-storage-class": minioRegion,
+			"ibm.io/chunk-size-mb":              "16",
+			"ibm.io/parallel-count":             "2",
+			"ibm.io/multireq-max":               "4",
+			"ibm.io/stat-cache-size":            "1000",
+			"ibm.io/debug-level":                "info",
+			"ibm.io/curl-debug":                 "false",
+			"ibm.io/tls-cipher-suite":           "AESGCM",
+		},
+	}
+	if _, err := client.StorageV1().StorageClasses().Create(ctx, class, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
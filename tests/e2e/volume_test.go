@@ -0,0 +1,242 @@
+//go:build e2e
+
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package e2e
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	pollInterval = 2 * time.Second
+	pollTimeout  = 3 * time.Minute
+	// testFile is written into the volumes by the test pods
+	testFile = "e2e.txt"
+)
+
+// createPVC creates a claim of the storage class of the tests with the ibm.io annotations
+func createPVC(t *testing.T, name string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	className := testStorageClass
+	if annotations["ibm.io/secret-name"] == "" {
+		annotations["ibm.io/secret-name"] = testSecretName
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteMany},
+			StorageClassName: &className,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	pvc, err := client.CoreV1().PersistentVolumeClaims(testNamespace).Create(context.Background(), pvc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return pvc
+}
+
+// waitBound waits for a claim to be bound and returns the bucket of its volume
+func waitBound(t *testing.T, name string) string {
+	var bucket string
+	err := wait.PollImmediate(pollInterval, pollTimeout, func() (bool, error) {
+		pvc, err := client.CoreV1().PersistentVolumeClaims(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil || pvc.Status.Phase != v1.ClaimBound {
+			return false, err
+		}
+		pv, err := client.CoreV1().PersistentVolumes().Get(context.Background(), pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		bucket = pv.Spec.FlexVolume.Options["bucket"]
+		return true, nil
+	})
+	require.NoError(t, err, "claim %s not bound", name)
+	return bucket
+}
+
+// runPod runs a shell script in a pod mounting a claim on /mnt/s3fs and waits for it to succeed
+func runPod(t *testing.T, name, claim, script string) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{{
+				Name:         "test",
+				Image:        "busybox",
+				Command:      []string{"sh", "-c", script},
+				VolumeMounts: []v1.VolumeMount{{Name: "volume", MountPath: "/mnt/s3fs"}},
+			}},
+			Volumes: []v1.Volume{{
+				Name: "volume",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+				},
+			}},
+		},
+	}
+	_, err := client.CoreV1().Pods(testNamespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	defer func() {
+		zero := int64(0)
+		_ = client.CoreV1().Pods(testNamespace).Delete(context.Background(), name, metav1.DeleteOptions{GracePeriodSeconds: &zero})
+		waitGone(t, func() error {
+			_, err := client.CoreV1().Pods(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+			return err
+		})
+	}()
+
+	err = wait.PollImmediate(pollInterval, pollTimeout, func() (bool, error) {
+		pod, err := client.CoreV1().Pods(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == v1.PodFailed {
+			t.Fatalf("pod %s failed: %s", name, podLogs(name))
+		}
+		return pod.Status.Phase == v1.PodSucceeded, nil
+	})
+	require.NoError(t, err, "pod %s did not succeed: %s", name, podLogs(name))
+}
+
+func podLogs(name string) string {
+	logs, err := client.CoreV1().Pods(testNamespace).GetLogs(name, &v1.PodLogOptions{}).DoRaw(context.Background())
+	if err != nil {
+		return err.Error()
+	}
+	return string(logs)
+}
+
+// deletePVC deletes a claim and waits for its volume to be deleted
+func deletePVC(t *testing.T, name string) {
+	pvc, err := client.CoreV1().PersistentVolumeClaims(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, client.CoreV1().PersistentVolumeClaims(testNamespace).Delete(context.Background(), name, metav1.DeleteOptions{}))
+	waitGone(t, func() error {
+		_, err := client.CoreV1().PersistentVolumes().Get(context.Background(), pvc.Spec.VolumeName, metav1.GetOptions{})
+		return err
+	})
+}
+
+// waitGone waits for get to report that an object does not exist anymore
+func waitGone(t *testing.T, get func() error) {
+	err := wait.PollImmediate(pollInterval, pollTimeout, func() (bool, error) {
+		err := get()
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	require.NoError(t, err)
+}
+
+// bucketExists tells whether MinIO has a bucket
+func bucketExists(t *testing.T, bucket string) bool {
+	err := cos.CheckBucketAccess(context.Background(), bucket)
+	if backend.ErrorKindOf(err) == backend.ErrorNotFound {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+// objectCount returns the number of objects of a bucket under a prefix
+func objectCount(t *testing.T, bucket, prefix string) int64 {
+	usage, err := cos.GetBucketUsage(context.Background(), bucket, prefix)
+	require.NoError(t, err)
+	return usage.ObjectCount
+}
+
+func Test_AutoCreateAutoDelete(t *testing.T) {
+	createPVC(t, "auto-delete", map[string]string{
+		"ibm.io/auto-create-bucket": "true",
+		"ibm.io/auto-delete-bucket": "true",
+	})
+	bucket := waitBound(t, "auto-delete")
+	assert.True(t, strings.HasPrefix(bucket, "tmp-s3fs-"), "bucket %s not named by the provisioner", bucket)
+	require.True(t, bucketExists(t, bucket), "bucket %s not created", bucket)
+
+	runPod(t, "auto-delete-writer", "auto-delete", "echo hello > /mnt/s3fs/"+testFile+" && ls /mnt/s3fs && sync")
+	assert.Equal(t, int64(1), objectCount(t, bucket, testFile))
+
+	deletePVC(t, "auto-delete")
+	assert.False(t, bucketExists(t, bucket), "bucket %s not deleted with its volume", bucket)
+}
+
+func Test_AutoCreateKeep(t *testing.T) {
+	bucket := "e2e-auto-create-keep"
+	defer func() { _ = cos.DeleteBucket(context.Background(), bucket) }()
+	createPVC(t, "auto-create-keep", map[string]string{
+		"ibm.io/auto-create-bucket": "true",
+		"ibm.io/auto-delete-bucket": "false",
+		"ibm.io/bucket":             bucket,
+	})
+	assert.Equal(t, bucket, waitBound(t, "auto-create-keep"))
+	require.True(t, bucketExists(t, bucket), "bucket %s not created", bucket)
+
+	runPod(t, "auto-create-keep-writer", "auto-create-keep", "echo hello > /mnt/s3fs/"+testFile+" && sync")
+
+	deletePVC(t, "auto-create-keep")
+	assert.True(t, bucketExists(t, bucket), "bucket %s deleted with its volume", bucket)
+	assert.Equal(t, int64(1), objectCount(t, bucket, testFile))
+}
+
+func Test_ExistingBucket(t *testing.T) {
+	bucket := "e2e-existing"
+	_, err := cos.CreateBucket(context.Background(), bucket, minioRegion, "")
+	require.NoError(t, err)
+	defer func() { _ = cos.DeleteBucket(context.Background(), bucket) }()
+	require.NoError(t, cos.CreateObjectPath(context.Background(), bucket, "data"))
+
+	createPVC(t, "existing", map[string]string{
+		"ibm.io/auto-create-bucket": "false",
+		"ibm.io/auto-delete-bucket": "false",
+		"ibm.io/bucket":             bucket,
+		"ibm.io/object-path":        "data",
+	})
+	assert.Equal(t, bucket, waitBound(t, "existing"))
+
+	runPod(t, "existing-writer", "existing", "echo hello > /mnt/s3fs/"+testFile+" && cat /mnt/s3fs/"+testFile)
+	// the volume mounts the object path, the file is written under it
+	assert.Equal(t, int64(1), objectCount(t, bucket, "data/"+testFile))
+
+	deletePVC(t, "existing")
+	assert.True(t, bucketExists(t, bucket), "existing bucket %s deleted with its volume", bucket)
+}
+
+func Test_MissingBucket(t *testing.T) {
+	createPVC(t, "missing", map[string]string{
+		"ibm.io/auto-create-bucket": "false",
+		"ibm.io/auto-delete-bucket": "false",
+		"ibm.io/bucket":             "e2e-missing",
+	})
+	defer func() {
+		_ = client.CoreV1().PersistentVolumeClaims(testNamespace).Delete(context.Background(), "missing", metav1.DeleteOptions{})
+	}()
+
+	// the provisioner checks the bucket and leaves the claim pending
+	time.Sleep(30 * time.Second)
+	pvc, err := client.CoreV1().PersistentVolumeClaims(testNamespace).Get(context.Background(), "missing", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1.ClaimPending, pvc.Status.Phase)
+	assert.False(t, bucketExists(t, "e2e-missing"))
+}
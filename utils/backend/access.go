@@ -0,0 +1,68 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Modes of the check that the credentials can access a bucket
+const (
+	// AccessCheckHeadBucket checks the bucket with a HEAD on it, which needs the permission to list it
+	AccessCheckHeadBucket = "head-bucket"
+	// AccessCheckHeadObject checks the bucket with a HEAD on the placeholder of the object-path, or on
+	// accessProbeKey, for credentials limited to reading and writing objects
+	AccessCheckHeadObject = "head-object"
+)
+
+// Canned ACLs a bucket may be created with
+const (
+	// BucketACLPrivate grants access to the bucket to the service instance only
+	BucketACLPrivate = "private"
+	// BucketACLPublicRead lets anyone read the objects of the bucket, for public static content
+	BucketACLPublicRead = "public-read"
+)
+
+// accessProbeKey is the object looked up by the head-object check when there is no object-path
+const accessProbeKey = ".ibm-s3fs-access-check"
+
+// ValidateAccessCheck checks the mode of the bucket access check, empty being AccessCheckHeadBucket
+func ValidateAccessCheck(mode string) error {
+	switch mode {
+	case "", AccessCheckHeadBucket, AccessCheckHeadObject:
+		return nil
+	}
+	return fmt.Errorf("unknown bucket access check %q, should be %q or %q", mode, AccessCheckHeadBucket, AccessCheckHeadObject)
+}
+
+// ValidateBucketACL checks the canned ACL of the buckets to create, empty leaving the default ACL
+func ValidateBucketACL(acl string) error {
+	switch acl {
+	case "", BucketACLPrivate, BucketACLPublicRead:
+		return nil
+	}
+	return fmt.Errorf("unknown bucket ACL %q, should be %q or %q", acl, BucketACLPrivate, BucketACLPublicRead)
+}
+
+// CheckAccess checks that the credentials of sess can access bucket and the objects of object-path
+// with the given mode of access check
+func CheckAccess(ctx context.Context, sess ObjectStorageSession, mode, bucket, objectpath string) error {
+	if mode != AccessCheckHeadObject {
+		return sess.CheckBucketAccess(ctx, bucket)
+	}
+	key := accessProbeKey
+	if objectpath = strings.Trim(objectpath, "/"); objectpath != "" {
+		key = objectpath + "/"
+	}
+	return sess.CheckObjectAccess(ctx, bucket, key)
+}
@@ -30,6 +30,15 @@ type AccessPolicyFactory interface {
 type AccessPolicy interface {
 	UpdateAccessPolicy(allowedIps, apiKey, bucketName string, rcc ResourceConfigurationV1) error
 	UpdateQuotaLimit(quota int64, apiKey, bucketName, osEndpoint, iamEndpoint string, rcc ResourceConfigurationV1) error
+	GetBucketUsage(apiKey, bucketName, osEndpoint, iamEndpoint string, rcc ResourceConfigurationV1) (*BucketUsage, error)
+}
+
+// BucketUsage is the storage used by a bucket and its quota, as metered by the object storage
+type BucketUsage struct {
+	BytesUsed   int64
+	ObjectCount int64
+	// HardQuota is 0 when the bucket has no quota
+	HardQuota int64
 }
 
 type UpdateAPFactory struct{}
@@ -37,6 +46,8 @@ type UpdateAPFactory struct{}
 type ResourceConfigurationV1 interface {
 	// UpdateBucketConfig updates the bucket access policy configuration with given ips
 	UpdateBucketConfig(*rc.ResourceConfigurationV1, *rc.UpdateBucketConfigOptions) (*core.DetailedResponse, error)
+	// GetBucketConfig returns the bucket configuration and usage
+	GetBucketConfig(*rc.ResourceConfigurationV1, *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error)
 }
 
 type UpdateAPObj struct {
@@ -47,6 +58,10 @@ func (uc *UpdateAPObj) UpdateBucketConfig(service *rc.ResourceConfigurationV1, o
 	return service.UpdateBucketConfig(options)
 }
 
+func (uc *UpdateAPObj) GetBucketConfig(service *rc.ResourceConfigurationV1, options *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error) {
+	return service.GetBucketConfig(options)
+}
+
 func (c *UpdateAPFactory) NewAccessPolicy() AccessPolicy {
 
 	return &UpdateAPObj{}
@@ -86,9 +101,8 @@ func (c *UpdateAPObj) UpdateAccessPolicy(allowedIps, apiKey, bucketName string,
 	return err
 }
 
-// UpdateQuotaLimit updates the bucket quota limits
-func (c *UpdateAPObj) UpdateQuotaLimit(quota int64, apiKey, bucketName, osEndpoint, iamEndpoint string, rcc ResourceConfigurationV1) error {
-
+// resourceConfigService returns a client of the resource configuration API matching the object storage endpoint
+func resourceConfigService(apiKey, osEndpoint, iamEndpoint string) *rc.ResourceConfigurationV1 {
 	ConfigEP := ""
 	IAMEP := iamEndpoint + "/identity/token"
 
@@ -110,6 +124,13 @@ func (c *UpdateAPObj) UpdateQuotaLimit(quota int64, apiKey, bucketName, osEndpoi
 		Authenticator: authenticator,
 		URL:           ConfigEP,
 	})
+	return service
+}
+
+// UpdateQuotaLimit updates the bucket quota limits
+func (c *UpdateAPObj) UpdateQuotaLimit(quota int64, apiKey, bucketName, osEndpoint, iamEndpoint string, rcc ResourceConfigurationV1) error {
+
+	service := resourceConfigService(apiKey, osEndpoint, iamEndpoint)
 
 	updateConfigOptions := &rc.UpdateBucketConfigOptions{
 		Bucket:    core.StringPtr(bucketName),
@@ -122,3 +143,28 @@ func (c *UpdateAPObj) UpdateQuotaLimit(quota int64, apiKey, bucketName, osEndpoi
 	}
 	return err
 }
+
+// GetBucketUsage returns the bytes and objects stored in the bucket and its quota
+func (c *UpdateAPObj) GetBucketUsage(apiKey, bucketName, osEndpoint, iamEndpoint string, rcc ResourceConfigurationV1) (*BucketUsage, error) {
+
+	service := resourceConfigService(apiKey, osEndpoint, iamEndpoint)
+
+	bucket, _, err := rcc.GetBucketConfig(service, &rc.GetBucketConfigOptions{
+		Bucket: core.StringPtr(bucketName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &BucketUsage{}
+	if bucket.BytesUsed != nil {
+		usage.BytesUsed = *bucket.BytesUsed
+	}
+	if bucket.ObjectCount != nil {
+		usage.ObjectCount = *bucket.ObjectCount
+	}
+	if bucket.HardQuota != nil {
+		usage.HardQuota = *bucket.HardQuota
+	}
+	return usage, nil
+}
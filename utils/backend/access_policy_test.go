@@ -46,6 +46,7 @@ type fakeResourceConfigurationV1 struct {
 
 type fakeRCV1 interface {
 	UpdateBucketConfig(*rc.ResourceConfigurationV1, *rc.UpdateBucketConfigOptions) (*core.DetailedResponse, error)
+	GetBucketConfig(*rc.ResourceConfigurationV1, *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error)
 }
 
 func (rc *fakeResourceConfigurationV1) UpdateBucketConfig(service *rc.ResourceConfigurationV1, options *rc.UpdateBucketConfigOptions) (*core.DetailedResponse, error) {
@@ -61,12 +62,22 @@ type fakeResourceConfigurationV1Fail struct {
 
 type fakeRCV2 interface {
 	UpdateBucketConfig(*rc.ResourceConfigurationV1, *rc.UpdateBucketConfigOptions) (*core.DetailedResponse, error)
+	GetBucketConfig(*rc.ResourceConfigurationV1, *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error)
+}
+
+func (f *fakeResourceConfigurationV1) GetBucketConfig(service *rc.ResourceConfigurationV1, options *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error) {
+	dresponse = core.DetailedResponse{StatusCode: statusCode, Headers: httpHeader, Result: result, RawResult: byteArray}
+	return &rc.Bucket{BytesUsed: core.Int64Ptr(1000), ObjectCount: core.Int64Ptr(3), HardQuota: core.Int64Ptr(quota)}, &dresponse, nil
 }
 
 func (rc *fakeResourceConfigurationV1Fail) UpdateBucketConfig(service *rc.ResourceConfigurationV1, options *rc.UpdateBucketConfigOptions) (*core.DetailedResponse, error) {
 	return nil, errTest
 }
 
+func (f *fakeResourceConfigurationV1Fail) GetBucketConfig(service *rc.ResourceConfigurationV1, options *rc.GetBucketConfigOptions) (*rc.Bucket, *core.DetailedResponse, error) {
+	return nil, nil, errTest
+}
+
 func getFakeAccessPolicySession(r ResourceConfigurationV1) AccessPolicy {
 	return &UpdateAPObj{rcv1: r}
 }
@@ -108,3 +119,19 @@ func Test_UpdateQuotaLimit_Error(t *testing.T) {
 		assert.Contains(t, err.Error(), errTestMsg)
 	}
 }
+
+func Test_GetBucketUsage_Positive(t *testing.T) {
+	rcSess := getFakeAccessPolicySession(&fakeResourceConfigurationV1{frc1: rc1})
+	usage, err := rcSess.GetBucketUsage(resConfApiKey, testBucket, osEndpoint, iamEndpoint, rc1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &BucketUsage{BytesUsed: 1000, ObjectCount: 3, HardQuota: quota}, usage)
+	}
+}
+
+func Test_GetBucketUsage_Error(t *testing.T) {
+	rcSess := getFakeAccessPolicySession(&fakeResourceConfigurationV1Fail{frc2: rc2})
+	_, err := rcSess.GetBucketUsage(resConfApiKey, testBucket, osEndpoint, iamEndpoint, rc2)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), errTestMsg)
+	}
+}
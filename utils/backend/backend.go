@@ -11,15 +11,28 @@
 package backend
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/IBM/ibm-cos-sdk-go/aws"
-	"github.com/IBM/ibm-cos-sdk-go/aws/awserr"
-	"github.com/IBM/ibm-cos-sdk-go/aws/credentials"
-	"github.com/IBM/ibm-cos-sdk-go/aws/credentials/ibmiam"
-	"github.com/IBM/ibm-cos-sdk-go/aws/session"
-	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/IBM/go-sdk-core/v3/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"go.uber.org/zap"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultCallTimeout bounds the object storage calls made with a context without deadline
+	DefaultCallTimeout = 2 * time.Minute
 )
 
 // ObjectStorageCredentials holds credentials for accessing an object storage service
@@ -36,39 +49,113 @@ type ObjectStorageCredentials struct {
 	IAMEndpoint string
 }
 
+// BucketInfo describes a bucket of a listing
+type BucketInfo struct {
+	Name string
+	// Created is zero when the endpoint does not tell the creation date
+	Created time.Time
+}
+
 // ObjectStorageSessionFactory is an interface of an object store session factory
 type ObjectStorageSessionFactory interface {
 
-	// NewObjectStorageBackend method creates a new object store session
-	NewObjectStorageSession(endpoint, region string, creds *ObjectStorageCredentials, logger *zap.Logger) ObjectStorageSession
+	// NewObjectStorageBackend method creates a new object store session,
+	// the non-zero transport settings override the ones of the factory
+	NewObjectStorageSession(endpoint, region string, creds *ObjectStorageCredentials, transport TransportConfig, logger *zap.Logger) ObjectStorageSession
 }
 
-// ObjectStorageSession is an interface of an object store session
+// ObjectStorageSession is an interface of an object store session.
+// Calls are bounded by the deadline of their context, or DefaultCallTimeout when it has none.
+// Failures are returned as *Error, ErrorKindOf tells them apart.
 type ObjectStorageSession interface {
 
 	// CheckBucketAccess method check that a bucket can be accessed
-	CheckBucketAccess(bucket string) error
+	CheckBucketAccess(ctx context.Context, bucket string) error
+
+	// CheckObjectAccess method check that the objects of a bucket can be accessed,
+	// without needing the permission to list the bucket
+	CheckObjectAccess(ctx context.Context, bucket, key string) error
+
+	// CheckObjectPathExistence method checks that object-path exists inside bucket, as its placeholder
+	// object or, when asPrefix is set, as the prefix of any object
+	CheckObjectPathExistence(ctx context.Context, bucket, objectpath string, asPrefix bool) (bool, error)
+
+	// CreateObjectPath method creates the placeholder object of object-path inside bucket
+	CreateObjectPath(ctx context.Context, bucket, objectpath string) error
+
+	// CreateObjectPathMarker method creates the empty ObjectPathMarker object under object-path inside bucket
+	CreateObjectPathMarker(ctx context.Context, bucket, objectpath string) error
+
+	// DeleteObjectPathMarker method deletes the ObjectPathMarker object of object-path inside bucket, if any
+	DeleteObjectPathMarker(ctx context.Context, bucket, objectpath string) error
+
+	// GetBucketUsage method returns the bytes and objects stored under prefix inside bucket,
+	// counted by listing them
+	GetBucketUsage(ctx context.Context, bucket, prefix string) (*BucketUsage, error)
+
+	// GetBucketLocation method returns the location constraint of a bucket
+	GetBucketLocation(ctx context.Context, bucket string) (string, error)
 
-	// CheckObjectPathExistence method checks that object-path exists inside bucket
-	CheckObjectPathExistence(bucket, objectpath string) (bool, error)
+	// SetBucketTags method replaces the tags of a bucket
+	SetBucketTags(ctx context.Context, bucket string, tags map[string]string) error
 
-	// CreateBucket methods creates a new bucket
-	CreateBucket(bucket, locationConstraint string) (string, error)
+	// SetBucketReplication method enables the versioning of a bucket and replicates its objects to the target of rule
+	SetBucketReplication(ctx context.Context, bucket string, rule ReplicationRule) error
 
-	// DeleteBucket methods deletes a bucket (with all of its objects)
-	DeleteBucket(bucket string) error
+	// IsBucketEmpty method checks that a bucket holds no object outside of excludePrefixes
+	IsBucketEmpty(ctx context.Context, bucket string, excludePrefixes []string) (bool, error)
+
+	// ListBuckets method returns the buckets starting with prefix
+	// of the service instance of the credentials
+	ListBuckets(ctx context.Context, prefix string) ([]BucketInfo, error)
+
+	// CreateBucket methods creates a new bucket, with the canned acl when not empty
+	CreateBucket(ctx context.Context, bucket, locationConstraint, acl string) (string, error)
+
+	// SyncPrefix method copies the objects under srcPrefix inside srcBucket to dstPrefix inside dstBucket,
+	// server-side, skipping the up to date copies
+	SyncPrefix(ctx context.Context, srcBucket, srcPrefix, dstBucket, dstPrefix string) (*SyncResult, error)
+
+	// DeleteBucket methods deletes a bucket (with all of its objects and their versions),
+	// reporting the deleted objects to the ProgressFunc of ctx
+	DeleteBucket(ctx context.Context, bucket string) error
 }
 
-// COSSessionFactory represents a COS (S3) session factory
-type COSSessionFactory struct{}
+// COSSessionFactory represents a COS (S3) session factory.
+// Sessions with the same transport settings share their HTTP client and its connections.
+type COSSessionFactory struct {
+	// Transport overrides DefaultTransportConfig for the sessions of the factory
+	Transport TransportConfig
+	// Retry overrides DefaultRetryPolicy for the sessions of the factory
+	Retry RetryPolicy
+	// Breaker overrides DefaultCircuitBreakerConfig for the endpoints of the factory
+	Breaker CircuitBreakerConfig
+	// Endpoint, when set, replaces the endpoint of every session, to simulate the object storage
+	Endpoint string
+	// RateLimit bounds the rate of the requests of all the sessions of the factory
+	RateLimit RateLimitConfig
+
+	mutex    sync.Mutex
+	clients  map[transportKey]*http.Client
+	breakers map[string]*circuitBreaker
+	limiter  *rateLimiter
+}
 
 type s3API interface {
-	HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error)
-	CreateBucket(input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error)
-	ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsOutput, error)
-	//ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
-	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
-	DeleteBucket(input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error)
+	HeadBucket(ctx context.Context, input *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetBucketLocation(ctx context.Context, input *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error)
+	ListBuckets(ctx context.Context, input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	CreateBucket(ctx context.Context, input *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	ListObjects(ctx context.Context, input *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error)
+	ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	CopyObject(ctx context.Context, input *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteBucket(ctx context.Context, input *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
+	PutBucketTagging(ctx context.Context, input *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error)
+	PutBucketVersioning(ctx context.Context, input *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error)
+	PutBucketReplication(ctx context.Context, input *s3.PutBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.PutBucketReplicationOutput, error)
 }
 
 // COSSession represents a COS (S3) session
@@ -77,120 +164,515 @@ type COSSession struct {
 	logger *zap.Logger
 }
 
+// iamAuth authenticates requests with an IBM IAM bearer token instead of an AWS signature
+type iamAuth struct {
+	authenticator     *core.IamAuthenticator
+	serviceInstanceID string
+}
+
+func (a *iamAuth) ID() string {
+	return "IBMIAMAuth"
+}
+
+func (a *iamAuth) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	req, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("unexpected request type %T", in.Request)
+	}
+	if err := a.authenticator.Authenticate(req.Request); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("cannot get IAM token: %v", err)
+	}
+	if a.serviceInstanceID != "" {
+		req.Header.Set("ibm-service-instance-id", a.serviceInstanceID)
+	}
+	return next.HandleFinalize(ctx, in)
+}
+
 // NewObjectStorageSession method creates a new object store session
-func (s *COSSessionFactory) NewObjectStorageSession(endpoint, region string, creds *ObjectStorageCredentials, logger *zap.Logger) ObjectStorageSession {
-	var sdkCreds *credentials.Credentials
+func (s *COSSessionFactory) NewObjectStorageSession(endpoint, region string, creds *ObjectStorageCredentials, transport TransportConfig, logger *zap.Logger) ObjectStorageSession {
+	if s.Endpoint != "" {
+		endpoint = s.Endpoint
+	}
+	options := s3.Options{
+		BaseEndpoint: aws.String(endpoint),
+		Region:       region,
+		UsePathStyle: true,
+		Retryer:      DefaultRetryPolicy.Merge(s.Retry).newRetryer(),
+		// COS does not support the checksums the SDK adds by default
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	}
+	if client, err := s.httpClient(transport); err != nil {
+		logger.Warn("Cannot configure HTTP transport, using the default one", zap.Error(err))
+	} else {
+		options.HTTPClient = client
+	}
+	if breaker := s.circuitBreaker(endpoint); breaker != nil {
+		options.APIOptions = append(options.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(breaker, middleware.Before)
+		})
+	}
+	if limiter := s.rateLimiter(); limiter != nil {
+		// added after the retry middleware, retries wait for a token as well
+		options.APIOptions = append(options.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(limiter, middleware.After)
+		})
+	}
 	if creds.APIKey != "" {
-		sdkCreds = ibmiam.NewStaticCredentials(aws.NewConfig(), creds.IAMEndpoint+"/identity/token", creds.APIKey, creds.ServiceInstanceID)
+		auth := &iamAuth{
+			authenticator:     &core.IamAuthenticator{ApiKey: creds.APIKey, URL: creds.IAMEndpoint + "/identity/token"},
+			serviceInstanceID: creds.ServiceInstanceID,
+		}
+		// requests are left unsigned, the IAM token is added after each retry
+		options.Credentials = aws.AnonymousCredentials{}
+		options.APIOptions = append(options.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(auth, middleware.After)
+		})
 	} else {
-		sdkCreds = credentials.NewStaticCredentials(creds.AccessKey, creds.SecretKey, "")
+		options.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: creds.AccessKey, SecretAccessKey: creds.SecretKey}, nil
+		})
 	}
-	sess, _ := session.NewSession(&aws.Config{
-		S3ForcePathStyle: aws.Bool(true),
-		Endpoint:         aws.String(endpoint),
-		Credentials:      sdkCreds,
-		Region:           aws.String(region),
-	})
 
 	return &COSSession{
-		svc:    s3.New(sess),
+		svc:    s3.New(options),
 		logger: logger,
 	}
 }
 
-// CheckBucketAccess method check that a bucket can be accessed
-func (s *COSSession) CheckBucketAccess(bucket string) error {
-	_, err := s.svc.HeadBucket(&s3.HeadBucketInput{
+// callContext bounds a call with DefaultCallTimeout when ctx has no deadline
+func callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultCallTimeout)
+}
+
+// errorCode returns the code of the object storage error answered to a call, if any
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// wrongCredentials tells whether the object storage rejected the HMAC credentials
+func wrongCredentials(err error) bool {
+	code := errorCode(err)
+	return code == "SignatureDoesNotMatch" || code == "InvalidAccessKeyId"
+}
+
+// CheckBucketAccess method check that a bucket can be accessed, failures are returned as *Error
+func (s *COSSession) CheckBucketAccess(ctx context.Context, bucket string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	_, err := s.svc.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(bucket),
 	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RequestError" && strings.Contains(err.Error(), "Credential=") {
+		if wrongCredentials(err) {
 			s.logger.Warn(fmt.Sprintf("Check your secret access key for bucket %s", bucket))
-			return fmt.Errorf("AccessKey/SecretKey is wrong")
+			return &Error{Kind: ErrorAccessDenied, Err: errors.New("AccessKey/SecretKey is wrong")}
 		}
 	}
 
-	return err
+	return newError(err)
 }
 
-// CheckObjectPathExistence method checks that object-path exists inside bucket
-func (s *COSSession) CheckObjectPathExistence(bucket, objectpath string) (bool, error) {
-	if strings.HasPrefix(objectpath, "/") {
-		objectpath = strings.TrimPrefix(objectpath, "/")
+// CheckObjectAccess method check that the objects of a bucket can be accessed with a HEAD on key,
+// a missing key still proving the access. Failures are returned as *Error.
+func (s *COSSession) CheckObjectAccess(ctx context.Context, bucket, key string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	_, err := s.svc.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && ErrorKindOf(err) == ErrorNotFound {
+		return nil
 	}
+	return newError(err)
+}
+
+// CheckObjectPathExistence method checks that object-path exists inside bucket, with a HEAD on its
+// placeholder object or, when asPrefix is set, with a listing of at most one key under the prefix
+func (s *COSSession) CheckObjectPathExistence(ctx context.Context, bucket, objectpath string, asPrefix bool) (bool, error) {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	objectpath = strings.TrimPrefix(objectpath, "/")
 	if !strings.HasSuffix(objectpath, "/") {
 		objectpath = objectpath + "/"
 	}
 
-	resp, err := s.svc.ListObjects(&s3.ListObjectsInput{
-		Bucket:  aws.String(bucket),
-		MaxKeys: aws.Int64(1),
-		Prefix:  aws.String(objectpath),
+	if !asPrefix {
+		_, err := s.svc.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(objectpath),
+		})
+		if err != nil {
+			if ErrorKindOf(err) == ErrorNotFound {
+				return false, nil
+			}
+			return false, newError(fmt.Errorf("cannot access object-path '%s' in bucket '%s': %w", objectpath, bucket, err))
+		}
+		return true, nil
+	}
+
+	resp, err := s.svc.ListObjects(ctx, &s3.ListObjectsInput{
+		Bucket:    aws.String(bucket),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(1),
+		Prefix:    aws.String(objectpath),
+	})
+	if err != nil {
+		return false, newError(fmt.Errorf("cannot list bucket '%s': %w", bucket, err))
+	}
+	return len(resp.Contents) > 0 || len(resp.CommonPrefixes) > 0, nil
+}
+
+// CreateObjectPath method creates the placeholder object of object-path inside bucket,
+// the empty "<object-path>/" object s3fs shows as a directory
+func (s *COSSession) CreateObjectPath(ctx context.Context, bucket, objectpath string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	objectpath = strings.TrimPrefix(objectpath, "/")
+	if !strings.HasSuffix(objectpath, "/") {
+		objectpath = objectpath + "/"
+	}
+
+	_, err := s.svc.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(objectpath),
+		Body:   strings.NewReader(""),
 	})
+	if err != nil {
+		return newError(fmt.Errorf("cannot create object-path '%s' in bucket '%s': %w", objectpath, bucket, err))
+	}
+	return nil
+}
+
+// ObjectPathMarker is the name of the object kept under an object-path, so that an empty object-path
+// used as a prefix survives and its existence is checked with a single request
+const ObjectPathMarker = ".keep"
 
+// objectPathMarkerKey returns the key of the ObjectPathMarker of object-path
+func objectPathMarkerKey(objectpath string) string {
+	objectpath = strings.TrimPrefix(objectpath, "/")
+	if !strings.HasSuffix(objectpath, "/") {
+		objectpath = objectpath + "/"
+	}
+	return objectpath + ObjectPathMarker
+}
+
+// CreateObjectPathMarker method creates the empty ObjectPathMarker object under object-path inside bucket
+func (s *COSSession) CreateObjectPathMarker(ctx context.Context, bucket, objectpath string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	key := objectPathMarkerKey(objectpath)
+	_, err := s.svc.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(""),
+	})
 	if err != nil {
-		return false, fmt.Errorf("cannot list bucket '%s': %v", bucket, err)
+		return newError(fmt.Errorf("cannot create marker '%s' in bucket '%s': %w", key, bucket, err))
 	}
+	return nil
+}
+
+// DeleteObjectPathMarker method deletes the ObjectPathMarker object of object-path inside bucket,
+// a missing marker or bucket is not an error
+func (s *COSSession) DeleteObjectPathMarker(ctx context.Context, bucket, objectpath string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
 
-	if len(resp.Contents) == 1 {
-		object := *(resp.Contents[0].Key)
-		if (object == objectpath) || (strings.TrimSuffix(object, "/") == objectpath) {
+	key := objectPathMarkerKey(objectpath)
+	_, err := s.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && ErrorKindOf(err) != ErrorNotFound {
+		return newError(fmt.Errorf("cannot delete marker '%s' in bucket '%s': %w", key, bucket, err))
+	}
+	return nil
+}
+
+// GetBucketUsage method returns the bytes and objects stored under prefix inside bucket, the whole
+// bucket for an empty prefix. It lists every object, each page of the listing bounded by its own
+// call timeout, and leaves HardQuota to 0. Failures are returned as *Error.
+func (s *COSSession) GetBucketUsage(ctx context.Context, bucket, prefix string) (*BucketUsage, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	usage := &BucketUsage{}
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListObjects(listCtx, input)
+		cancel()
+		if err != nil {
+			return nil, newError(fmt.Errorf("cannot list bucket '%s': %w", bucket, err))
+		}
+
+		for _, object := range resp.Contents {
+			usage.BytesUsed += aws.ToInt64(object.Size)
+			usage.ObjectCount++
+		}
+		if !aws.ToBool(resp.IsTruncated) || len(resp.Contents) == 0 {
+			return usage, nil
+		}
+		// v1 listings without a delimiter carry no NextMarker, the last key is the marker
+		input.Marker = resp.NextMarker
+		if input.Marker == nil {
+			input.Marker = resp.Contents[len(resp.Contents)-1].Key
+		}
+	}
+}
+
+// GetBucketLocation method returns the location constraint of a bucket, e.g. us-south-standard,
+// failures are returned as *Error
+func (s *COSSession) GetBucketLocation(ctx context.Context, bucket string) (string, error) {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	resp, err := s.svc.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return "", newError(fmt.Errorf("cannot get location of bucket '%s': %w", bucket, err))
+	}
+	return string(resp.LocationConstraint), nil
+}
+
+// SetBucketTags method replaces the tags of a bucket, sorted by key, failures are returned as *Error
+func (s *COSSession) SetBucketTags(ctx context.Context, bucket string, tags map[string]string) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tagSet := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	_, err := s.svc.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	if err != nil {
+		return newError(fmt.Errorf("cannot set tags of bucket '%s': %w", bucket, err))
+	}
+	return nil
+}
+
+// IsBucketEmpty method checks that a bucket holds no object outside of excludePrefixes. It lists one
+// key at a time, skipping past the whole excluded prefix of each listed key, instead of listing the
+// bucket. Failures are returned as *Error.
+func (s *COSSession) IsBucketEmpty(ctx context.Context, bucket string, excludePrefixes []string) (bool, error) {
+	input := &s3.ListObjectsInput{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int32(1),
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListObjects(listCtx, input)
+		cancel()
+		if err != nil {
+			return false, newError(fmt.Errorf("cannot list bucket '%s': %w", bucket, err))
+		}
+		if len(resp.Contents) == 0 {
 			return true, nil
 		}
+
+		key := aws.ToString(resp.Contents[0].Key)
+		excluded := ""
+		for _, prefix := range excludePrefixes {
+			// the shortest matching prefix skips the most keys
+			if prefix != "" && strings.HasPrefix(key, prefix) && (excluded == "" || len(prefix) < len(excluded)) {
+				excluded = prefix
+			}
+		}
+		if excluded == "" {
+			return false, nil
+		}
+		// no key of the prefix sorts after the prefix followed by the highest code point
+		input.Marker = aws.String(excluded + string(utf8.MaxRune))
+	}
+}
+
+// ListBuckets method returns the names of the buckets starting with prefix. The buckets are the ones
+// of the service instance of the HMAC keys, or of the ServiceInstanceID of the IAM credentials.
+// Failures are returned as *Error.
+func (s *COSSession) ListBuckets(ctx context.Context, prefix string) ([]BucketInfo, error) {
+	var buckets []BucketInfo
+	input := &s3.ListBucketsInput{}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListBuckets(listCtx, input)
+		cancel()
+		if err != nil {
+			return nil, newError(fmt.Errorf("cannot list buckets: %w", err))
+		}
+
+		// the prefix is matched here too, not every endpoint filters the listing
+		for _, bucket := range resp.Buckets {
+			if name := aws.ToString(bucket.Name); strings.HasPrefix(name, prefix) {
+				buckets = append(buckets, BucketInfo{Name: name, Created: aws.ToTime(bucket.CreationDate)})
+			}
+		}
+		if aws.ToString(resp.ContinuationToken) == "" {
+			return buckets, nil
+		}
+		input.ContinuationToken = resp.ContinuationToken
 	}
-	return false, nil
 }
 
-// CreateBucket methods creates a new bucket
-func (s *COSSession) CreateBucket(bucket, locationConstraint string) (string, error) {
-	_, err := s.svc.CreateBucket(&s3.CreateBucketInput{
+// CreateBucket methods creates a new bucket, failures are returned as *Error
+func (s *COSSession) CreateBucket(ctx context.Context, bucket, locationConstraint, acl string) (string, error) {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	input := &s3.CreateBucketInput{
 		Bucket: aws.String(bucket),
-		CreateBucketConfiguration: &s3.CreateBucketConfiguration{
-			LocationConstraint: aws.String(locationConstraint),
+		CreateBucketConfiguration: &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(locationConstraint),
 		},
-	})
+	}
+	if acl != "" {
+		input.ACL = types.BucketCannedACL(acl)
+	}
+	_, err := s.svc.CreateBucket(ctx, input)
 
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "BucketAlreadyOwnedByYou" {
+		if errorCode(err) == "BucketAlreadyOwnedByYou" {
 			s.logger.Warn(fmt.Sprintf("bucket '%s' already exists", bucket))
 			return fmt.Sprintf("bucket '%s' already exists", bucket), nil
-		} else if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RequestError" && strings.Contains(err.Error(), "Credential=") {
+		} else if wrongCredentials(err) {
 			s.logger.Warn(fmt.Sprintf("Check your secret access key for bucket %s", bucket))
-			return fmt.Sprintf("Check your secret access key for bucket %s", bucket), fmt.Errorf("AccessKey/SecretKey is wrong")
+			return fmt.Sprintf("Check your secret access key for bucket %s", bucket),
+				&Error{Kind: ErrorAccessDenied, Err: errors.New("AccessKey/SecretKey is wrong")}
 		}
-		return "", err
+		return "", newError(err)
 	}
 	return "", nil
 }
 
-// DeleteBucket methods deletes a bucket (with all of its objects)
-func (s *COSSession) DeleteBucket(bucket string) error {
-	resp, err := s.svc.ListObjects(&s3.ListObjectsInput{
+// DeleteBucket methods deletes a bucket (with all of its objects and their versions), failures are returned as *Error
+func (s *COSSession) DeleteBucket(ctx context.Context, bucket string) error {
+	input := &s3.ListObjectsInput{
 		Bucket: aws.String(bucket),
-	})
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListObjects(listCtx, input)
+		cancel()
 
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchBucket" {
-			s.logger.Warn(fmt.Sprintf("bucket %s is already deleted", bucket))
-			return nil
+		if err != nil {
+			if errorCode(err) == "NoSuchBucket" {
+				s.logger.Warn(fmt.Sprintf("bucket %s is already deleted", bucket))
+				return nil
+			}
+
+			return newError(fmt.Errorf("cannot list bucket '%s': %w", bucket, err))
 		}
 
-		return fmt.Errorf("cannot list bucket '%s': %v", bucket, err)
-	}
+		for _, key := range resp.Contents {
+			deleteCtx, cancel := callContext(ctx)
+			_, err = s.svc.DeleteObject(deleteCtx, &s3.DeleteObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    key.Key,
+			})
+			cancel()
 
-	for _, key := range resp.Contents {
-		_, err = s.svc.DeleteObject(&s3.DeleteObjectInput{
-			Bucket: aws.String(bucket),
-			Key:    key.Key,
-		})
+			if err != nil {
+				return newError(fmt.Errorf("cannot delete object %s/%s: %w", bucket, aws.ToString(key.Key), err))
+			}
+			ReportProgress(ctx, 1, aws.ToInt64(key.Size))
+		}
 
-		if err != nil {
-			return fmt.Errorf("cannot delete object %s/%s: %v", bucket, *key.Key, err)
+		if !aws.ToBool(resp.IsTruncated) || len(resp.Contents) == 0 {
+			break
+		}
+		// the listings of a bucket being emptied go on after the last deleted key
+		input.Marker = resp.NextMarker
+		if input.Marker == nil {
+			input.Marker = resp.Contents[len(resp.Contents)-1].Key
 		}
 	}
 
-	_, err = s.svc.DeleteBucket(&s3.DeleteBucketInput{
+	if err := s.deleteVersions(ctx, bucket); err != nil {
+		return err
+	}
+
+	deleteCtx, cancel := callContext(ctx)
+	defer cancel()
+	_, err := s.svc.DeleteBucket(deleteCtx, &s3.DeleteBucketInput{
 		Bucket: aws.String(bucket),
 	})
-	return err
+	return newError(err)
+}
+
+// deleteVersions deletes by version id the noncurrent versions and the delete markers of a versioned bucket,
+// the deletion of its objects leaves them and the bucket cannot be deleted before they are gone
+func (s *COSSession) deleteVersions(ctx context.Context, bucket string) error {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListObjectVersions(listCtx, input)
+		cancel()
+
+		if err != nil {
+			if errorCode(err) == "NotImplemented" {
+				// the endpoint does not version the buckets
+				return nil
+			}
+			return newError(fmt.Errorf("cannot list the versions of bucket '%s': %w", bucket, err))
+		}
+
+		versions := make([]types.ObjectIdentifier, 0, len(resp.Versions)+len(resp.DeleteMarkers))
+		for _, version := range resp.Versions {
+			versions = append(versions, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range resp.DeleteMarkers {
+			versions = append(versions, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		for _, version := range versions {
+			deleteCtx, cancel := callContext(ctx)
+			_, err = s.svc.DeleteObject(deleteCtx, &s3.DeleteObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       version.Key,
+				VersionId: version.VersionId,
+			})
+			cancel()
+
+			if err != nil {
+				return newError(fmt.Errorf("cannot delete version %s of object %s/%s: %w",
+					aws.ToString(version.VersionId), bucket, aws.ToString(version.Key), err))
+			}
+		}
+
+		if !aws.ToBool(resp.IsTruncated) {
+			return nil
+		}
+		input.KeyMarker, input.VersionIdMarker = resp.NextKeyMarker, resp.NextVersionIdMarker
+	}
 }
@@ -11,22 +11,86 @@
 package backend
 
 import (
+	"context"
 	"errors"
-	"github.com/IBM/ibm-cos-sdk-go/aws/awserr"
-	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
-	"strings"
+	"net/http"
 	"testing"
+	"time"
 )
 
 type fakeS3API struct {
 	ErrHeadBucket   error
+	ErrHeadObject   error
+	HeadObjectKey   string
 	ErrCreateBucket error
+	CreateInput     *s3.CreateBucketInput
+	ErrGetLocation  error
+	ErrListBuckets  error
+	BucketLocation  string
 	ErrListObjects  error
 	ErrDeleteObject error
 	ErrDeleteBucket error
-	ObjectPath      string
+	ErrCopyObject   error
+	// CopyInputs are the inputs of the CopyObject calls
+	CopyInputs []*s3.CopyObjectInput
+	// DeletedKeys are the keys of the DeleteObject calls
+	DeletedKeys  []string
+	ErrPutObject error
+	PutObjectKey string
+	// ListObjectsInput is the input of the last ListObjects call
+	ListObjectsInput *s3.ListObjectsInput
+	// ListCommonPrefixes makes ListObjects return a common prefix instead of testObject
+	ListCommonPrefixes bool
+	// EmptyList makes ListObjects return no object
+	EmptyList bool
+	// BucketPages are returned in turn by ListBuckets
+	BucketPages []s3.ListBucketsOutput
+	// BucketsInputs are the inputs of the ListBuckets calls
+	BucketsInputs []s3.ListBucketsInput
+	// ListPages are returned in turn by ListObjects when set
+	ListPages []s3.ListObjectsOutput
+	// ListMarkers are the markers of the ListObjects calls returning ListPages
+	ListMarkers []string
+	// Deadline is the deadline of the context of the last call
+	Deadline time.Time
+	// ErrPutBucketTagging is returned by PutBucketTagging
+	ErrPutBucketTagging error
+	// TaggingInput is the input of the last PutBucketTagging call
+	TaggingInput *s3.PutBucketTaggingInput
+	// ErrPutBucketVersioning and ErrPutBucketReplication are returned by PutBucketVersioning and PutBucketReplication
+	ErrPutBucketVersioning  error
+	ErrPutBucketReplication error
+	// VersioningInput and ReplicationInput are the inputs of the last PutBucketVersioning and PutBucketReplication calls
+	VersioningInput  *s3.PutBucketVersioningInput
+	ReplicationInput *s3.PutBucketReplicationInput
+	// DeletedVersions are the "<key>@<version id>" of the DeleteObject calls with a version id
+	DeletedVersions []string
+	// VersionPages are returned in turn by ListObjectVersions, which lists no version when empty
+	VersionPages          []s3.ListObjectVersionsOutput
+	ErrListObjectVersions error
+	// VersionMarkers are the "<key marker>@<version id marker>" of the ListObjectVersions calls
+	VersionMarkers []string
+}
+
+func (a *fakeS3API) PutBucketVersioning(ctx context.Context, input *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	a.VersioningInput = input
+	return nil, a.ErrPutBucketVersioning
+}
+
+func (a *fakeS3API) PutBucketReplication(ctx context.Context, input *s3.PutBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.PutBucketReplicationOutput, error) {
+	a.ReplicationInput = input
+	return nil, a.ErrPutBucketReplication
+}
+
+func (a *fakeS3API) PutBucketTagging(ctx context.Context, input *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	a.TaggingInput = input
+	return nil, a.ErrPutBucketTagging
 }
 
 const (
@@ -48,25 +112,89 @@ var (
 	errFoo     = errors.New(errFooMsg)
 )
 
-func (a *fakeS3API) HeadBucket(input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+func (a *fakeS3API) HeadBucket(ctx context.Context, input *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	a.Deadline, _ = ctx.Deadline()
 	return nil, a.ErrHeadBucket
 }
 
-func (a *fakeS3API) CreateBucket(input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error) {
+func (a *fakeS3API) HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	a.HeadObjectKey = *input.Key
+	return nil, a.ErrHeadObject
+}
+
+func (a *fakeS3API) GetBucketLocation(ctx context.Context, input *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	if a.ErrGetLocation != nil {
+		return nil, a.ErrGetLocation
+	}
+	return &s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraint(a.BucketLocation)}, nil
+}
+
+func (a *fakeS3API) ListBuckets(ctx context.Context, input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	a.BucketsInputs = append(a.BucketsInputs, *input)
+	if a.ErrListBuckets != nil || len(a.BucketPages) == 0 {
+		return nil, a.ErrListBuckets
+	}
+	page := a.BucketPages[0]
+	a.BucketPages = a.BucketPages[1:]
+	return &page, nil
+}
+
+func (a *fakeS3API) CreateBucket(ctx context.Context, input *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	a.CreateInput = input
 	return nil, a.ErrCreateBucket
 }
 
-func (a *fakeS3API) ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsOutput, error) {
+func (a *fakeS3API) ListObjects(ctx context.Context, input *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	a.ListObjectsInput = input
+	if len(a.ListPages) > 0 {
+		a.ListMarkers = append(a.ListMarkers, aws.ToString(input.Marker))
+		page := a.ListPages[0]
+		a.ListPages = a.ListPages[1:]
+		return &page, a.ErrListObjects
+	}
+	if a.ListCommonPrefixes {
+		return &s3.ListObjectsOutput{
+			CommonPrefixes: []types.CommonPrefix{{Prefix: &testObject}},
+		}, a.ErrListObjects
+	}
+	if a.EmptyList {
+		return &s3.ListObjectsOutput{}, a.ErrListObjects
+	}
 	return &s3.ListObjectsOutput{
-		Contents: []*s3.Object{{Key: &testObject}},
+		Contents: []types.Object{{Key: &testObject}},
 	}, a.ErrListObjects
 }
 
-func (a *fakeS3API) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+func (a *fakeS3API) ListObjectVersions(ctx context.Context, input *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	a.VersionMarkers = append(a.VersionMarkers, aws.ToString(input.KeyMarker)+"@"+aws.ToString(input.VersionIdMarker))
+	if a.ErrListObjectVersions != nil || len(a.VersionPages) == 0 {
+		return &s3.ListObjectVersionsOutput{}, a.ErrListObjectVersions
+	}
+	page := a.VersionPages[0]
+	a.VersionPages = a.VersionPages[1:]
+	return &page, nil
+}
+
+func (a *fakeS3API) PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	a.PutObjectKey = *input.Key
+	return nil, a.ErrPutObject
+}
+
+func (a *fakeS3API) DeleteObject(ctx context.Context, input *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if input.VersionId != nil {
+		a.DeletedVersions = append(a.DeletedVersions, aws.ToString(input.Key)+"@"+aws.ToString(input.VersionId))
+	} else {
+		a.DeletedKeys = append(a.DeletedKeys, aws.ToString(input.Key))
+	}
 	return nil, a.ErrDeleteObject
 }
 
-func (a *fakeS3API) DeleteBucket(input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error) {
+func (a *fakeS3API) CopyObject(ctx context.Context, input *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	a.CopyInputs = append(a.CopyInputs, input)
+	return nil, a.ErrCopyObject
+}
+
+func (a *fakeS3API) DeleteBucket(ctx context.Context, input *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
 	return nil, a.ErrDeleteBucket
 }
 
@@ -79,93 +207,373 @@ func getSession(svc s3API) ObjectStorageSession {
 
 func Test_NewObjectStorageSession_Positive(t *testing.T) {
 	f := &COSSessionFactory{}
-	sess := f.NewObjectStorageSession(testEndpoint, testRegion, &ObjectStorageCredentials{AccessKey: testAccessKey, SecretKey: testSecretKey}, zap.NewNop())
+	sess := f.NewObjectStorageSession(testEndpoint, testRegion, &ObjectStorageCredentials{AccessKey: testAccessKey, SecretKey: testSecretKey}, TransportConfig{}, zap.NewNop())
 	assert.NotNil(t, sess)
 }
 
 func Test_NewObjectStorageIAMSession_Positive(t *testing.T) {
 	f := &COSSessionFactory{}
 	sess := f.NewObjectStorageSession(testEndpoint, testRegion,
-		&ObjectStorageCredentials{ServiceInstanceID: testServiceInstanceID, APIKey: testAPIKey, IAMEndpoint: testIAMEndpoint}, zap.NewNop())
+		&ObjectStorageCredentials{ServiceInstanceID: testServiceInstanceID, APIKey: testAPIKey, IAMEndpoint: testIAMEndpoint}, TransportConfig{}, zap.NewNop())
 	assert.NotNil(t, sess)
 }
 
 func Test_CheckBucketAccess_Error(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrHeadBucket: errFoo})
-	err := sess.CheckBucketAccess(testBucket)
+	err := sess.CheckBucketAccess(context.Background(), testBucket)
 	if assert.Error(t, err) {
 		assert.EqualError(t, err, errFooMsg)
 	}
 }
 
+func Test_CheckBucketAccess_WrongCredentials(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrHeadBucket: &smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}})
+	err := sess.CheckBucketAccess(context.Background(), testBucket)
+	if assert.Error(t, err) {
+		assert.EqualError(t, err, "access denied: AccessKey/SecretKey is wrong")
+		assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+	}
+}
+
+func Test_CheckBucketAccess_DefaultTimeout(t *testing.T) {
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	err := sess.CheckBucketAccess(context.Background(), testBucket)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(DefaultCallTimeout), svc.Deadline, 5*time.Second)
+}
+
+func Test_CheckBucketAccess_CallerDeadline(t *testing.T) {
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	deadline := time.Now().Add(10 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	err := sess.CheckBucketAccess(ctx, testBucket)
+	assert.NoError(t, err)
+	assert.Equal(t, deadline, svc.Deadline)
+}
+
 func Test_CheckBucketAccess_Positive(t *testing.T) {
 	sess := getSession(&fakeS3API{})
-	err := sess.CheckBucketAccess(testBucket)
+	err := sess.CheckBucketAccess(context.Background(), testBucket)
+	assert.NoError(t, err)
+}
+
+func Test_CheckObjectAccess_Positive(t *testing.T) {
+	svc := &fakeS3API{}
+	err := getSession(svc).CheckObjectAccess(context.Background(), testBucket, testObject)
+	assert.NoError(t, err)
+	assert.Equal(t, testObject, svc.HeadObjectKey)
+}
+
+func Test_CheckObjectAccess_ObjectNotFound(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrHeadObject: responseError(http.StatusNotFound)})
+	err := sess.CheckObjectAccess(context.Background(), testBucket, testObject)
 	assert.NoError(t, err)
 }
 
+func Test_CheckObjectAccess_AccessDenied(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrHeadObject: responseError(http.StatusForbidden)})
+	err := sess.CheckObjectAccess(context.Background(), testBucket, testObject)
+	assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+}
+
+func Test_CheckAccess(t *testing.T) {
+	svc := &fakeS3API{ErrHeadBucket: errFoo}
+	sess := getSession(svc)
+	assert.Error(t, CheckAccess(context.Background(), sess, "", testBucket, testObjectPath))
+	assert.Error(t, CheckAccess(context.Background(), sess, AccessCheckHeadBucket, testBucket, testObjectPath))
+
+	assert.NoError(t, CheckAccess(context.Background(), sess, AccessCheckHeadObject, testBucket, testObjectPath))
+	assert.Equal(t, "test/object-path/", svc.HeadObjectKey)
+	assert.NoError(t, CheckAccess(context.Background(), sess, AccessCheckHeadObject, testBucket, ""))
+	assert.Equal(t, accessProbeKey, svc.HeadObjectKey)
+}
+
+func Test_ValidateAccessCheck(t *testing.T) {
+	assert.NoError(t, ValidateAccessCheck(""))
+	assert.NoError(t, ValidateAccessCheck(AccessCheckHeadObject))
+	assert.Error(t, ValidateAccessCheck("list-bucket"))
+}
+
 func Test_CheckObjectPathExistence_Positive(t *testing.T) {
-	testObject = strings.TrimPrefix(testObjectPath, "/")
-	testObject = testObject + "/"
-	sess := getSession(&fakeS3API{ObjectPath: testObject})
-	exist, err := sess.CheckObjectPathExistence(testBucket, testObjectPath)
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	exist, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, false)
 	assert.NoError(t, err)
 	assert.Equal(t, exist, true)
+	assert.Equal(t, "test/object-path/", svc.HeadObjectKey)
+	assert.Nil(t, svc.ListObjectsInput)
 }
 
-func Test_CheckObjectPathExistence_WithoutSuffix(t *testing.T) {
-	testObject = strings.TrimPrefix(testObjectPath, "/")
-	sess := getSession(&fakeS3API{ObjectPath: testObject})
-	exist, err := sess.CheckObjectPathExistence(testBucket, testObjectPath)
+func Test_CheckObjectPathExistence_PathNotFound(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrHeadObject: responseError(http.StatusNotFound)})
+	exist, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, false)
 	assert.NoError(t, err)
 	assert.Equal(t, exist, false)
 }
 
-func Test_CheckObjectPathExistence_PathNotFound(t *testing.T) {
-	sess := getSession(&fakeS3API{ObjectPath: "test/object-path-xxxx"})
-	testObject = "test/object-path-xxxx"
-	exist, err := sess.CheckObjectPathExistence(testBucket, testObjectPath)
+func Test_CheckObjectPathExistence_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrHeadObject: responseError(http.StatusForbidden)})
+	_, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot access object-path 'test/object-path/' in bucket 'test-bucket'")
+		assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+	}
+}
+
+func Test_CheckObjectPathExistence_AsPrefix_Positive(t *testing.T) {
+	testObject = "test/object-path/file"
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	exist, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, true)
+	assert.NoError(t, err)
+	assert.Equal(t, exist, true)
+	if assert.NotNil(t, svc.ListObjectsInput) {
+		assert.Equal(t, "test/object-path/", *svc.ListObjectsInput.Prefix)
+		assert.Equal(t, "/", *svc.ListObjectsInput.Delimiter)
+		assert.Equal(t, int32(1), *svc.ListObjectsInput.MaxKeys)
+	}
+	assert.Equal(t, "", svc.HeadObjectKey)
+}
+
+func Test_CheckObjectPathExistence_AsPrefix_CommonPrefix(t *testing.T) {
+	testObject = "test/object-path/dir/"
+	sess := getSession(&fakeS3API{ListCommonPrefixes: true})
+	exist, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, true)
+	assert.NoError(t, err)
+	assert.Equal(t, exist, true)
+}
+
+func Test_CheckObjectPathExistence_AsPrefix_PathNotFound(t *testing.T) {
+	sess := getSession(&fakeS3API{EmptyList: true})
+	exist, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, true)
 	assert.NoError(t, err)
 	assert.Equal(t, exist, false)
 }
 
-func Test_CheckObjectPathExistence_Error(t *testing.T) {
+func Test_CheckObjectPathExistence_AsPrefix_Error(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrListObjects: errFoo})
-	_, err := sess.CheckObjectPathExistence(testBucket, testObjectPath)
+	_, err := sess.CheckObjectPathExistence(context.Background(), testBucket, testObjectPath, true)
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "cannot list bucket")
 	}
 }
 
+func Test_CreateObjectPath_Positive(t *testing.T) {
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	err := sess.CreateObjectPath(context.Background(), testBucket, testObjectPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "test/object-path/", svc.PutObjectKey)
+}
+
+func Test_CreateObjectPathMarker_Positive(t *testing.T) {
+	svc := &fakeS3API{}
+	err := getSession(svc).CreateObjectPathMarker(context.Background(), testBucket, testObjectPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "test/object-path/.keep", svc.PutObjectKey)
+}
+
+func Test_CreateObjectPathMarker_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrPutObject: errFoo})
+	err := sess.CreateObjectPathMarker(context.Background(), testBucket, testObjectPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot create marker 'test/object-path/.keep' in bucket 'test-bucket'")
+	}
+}
+
+func Test_DeleteObjectPathMarker(t *testing.T) {
+	svc := &fakeS3API{}
+	assert.NoError(t, getSession(svc).DeleteObjectPathMarker(context.Background(), testBucket, testObjectPath))
+	assert.Equal(t, []string{"test/object-path/.keep"}, svc.DeletedKeys)
+
+	svc = &fakeS3API{ErrDeleteObject: &smithy.GenericAPIError{Code: "NoSuchKey"}}
+	assert.NoError(t, getSession(svc).DeleteObjectPathMarker(context.Background(), testBucket, testObjectPath))
+
+	svc = &fakeS3API{ErrDeleteObject: errFoo}
+	err := getSession(svc).DeleteObjectPathMarker(context.Background(), testBucket, testObjectPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot delete marker")
+	}
+}
+
+func Test_CreateObjectPath_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrPutObject: errFoo})
+	err := sess.CreateObjectPath(context.Background(), testBucket, testObjectPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot create object-path 'test/object-path/' in bucket 'test-bucket'")
+	}
+}
+
+func Test_Session_GetBucketUsage_Pages(t *testing.T) {
+	svc := &fakeS3API{ListPages: []s3.ListObjectsOutput{
+		{IsTruncated: aws.Bool(true), Contents: []types.Object{
+			{Key: aws.String("test/object-path/a"), Size: aws.Int64(100)},
+			{Key: aws.String("test/object-path/b"), Size: aws.Int64(200)},
+		}},
+		{IsTruncated: aws.Bool(false), Contents: []types.Object{
+			{Key: aws.String("test/object-path/c"), Size: aws.Int64(5)},
+		}},
+	}}
+	usage, err := getSession(svc).GetBucketUsage(context.Background(), testBucket, testObjectPath)
+	assert.NoError(t, err)
+	assert.Equal(t, &BucketUsage{BytesUsed: 305, ObjectCount: 3}, usage)
+	assert.Equal(t, []string{"", "test/object-path/b"}, svc.ListMarkers)
+	assert.Equal(t, "test/object-path/", *svc.ListObjectsInput.Prefix)
+}
+
+func Test_Session_GetBucketUsage_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrListObjects: errFoo})
+	_, err := sess.GetBucketUsage(context.Background(), testBucket, "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot list bucket 'test-bucket'")
+	}
+}
+
+func Test_IsBucketEmpty_Empty(t *testing.T) {
+	svc := &fakeS3API{EmptyList: true}
+	empty, err := getSession(svc).IsBucketEmpty(context.Background(), testBucket, nil)
+	assert.NoError(t, err)
+	assert.True(t, empty)
+	assert.Equal(t, int32(1), aws.ToInt32(svc.ListObjectsInput.MaxKeys))
+}
+
+func Test_IsBucketEmpty_NotEmpty(t *testing.T) {
+	testObject = "test-object"
+	empty, err := getSession(&fakeS3API{}).IsBucketEmpty(context.Background(), testBucket, []string{"other/"})
+	assert.NoError(t, err)
+	assert.False(t, empty)
+}
+
+func Test_IsBucketEmpty_ExcludedPrefixes(t *testing.T) {
+	svc := &fakeS3API{ListPages: []s3.ListObjectsOutput{
+		{Contents: []types.Object{{Key: aws.String(".trash/a/b")}}},
+		{Contents: []types.Object{{Key: aws.String("keep/placeholder")}}},
+		{},
+	}}
+	empty, err := getSession(svc).IsBucketEmpty(context.Background(), testBucket, []string{".trash/a/", ".trash/", "keep/"})
+	assert.NoError(t, err)
+	assert.True(t, empty)
+	assert.Equal(t, []string{"", ".trash/\U0010FFFF", "keep/\U0010FFFF"}, svc.ListMarkers)
+}
+
+func Test_IsBucketEmpty_Error(t *testing.T) {
+	_, err := getSession(&fakeS3API{ErrListObjects: errFoo}).IsBucketEmpty(context.Background(), testBucket, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot list bucket 'test-bucket'")
+	}
+}
+
+func Test_ListBuckets_Positive(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := &fakeS3API{BucketPages: []s3.ListBucketsOutput{
+		{ContinuationToken: aws.String("next"), Buckets: []types.Bucket{{Name: aws.String("tmp-s3fs-1"), CreationDate: aws.Time(created)}, {Name: aws.String("other")}}},
+		{Buckets: []types.Bucket{{Name: aws.String("tmp-s3fs-2")}}},
+	}}
+	buckets, err := getSession(svc).ListBuckets(context.Background(), "tmp-s3fs-")
+	assert.NoError(t, err)
+	assert.Equal(t, []BucketInfo{{Name: "tmp-s3fs-1", Created: created}, {Name: "tmp-s3fs-2"}}, buckets)
+	if assert.Len(t, svc.BucketsInputs, 2) {
+		assert.Equal(t, "tmp-s3fs-", aws.ToString(svc.BucketsInputs[0].Prefix))
+		assert.Nil(t, svc.BucketsInputs[0].ContinuationToken)
+		assert.Equal(t, "next", aws.ToString(svc.BucketsInputs[1].ContinuationToken))
+	}
+}
+
+func Test_ListBuckets_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrListBuckets: responseError(http.StatusForbidden)})
+	_, err := sess.ListBuckets(context.Background(), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot list buckets")
+		assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+	}
+}
+
+func Test_GetBucketLocation_Positive(t *testing.T) {
+	sess := getSession(&fakeS3API{BucketLocation: "us-south-standard"})
+	location, err := sess.GetBucketLocation(context.Background(), testBucket)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-south-standard", location)
+}
+
+func Test_GetBucketLocation_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrGetLocation: responseError(http.StatusNotFound)})
+	_, err := sess.GetBucketLocation(context.Background(), testBucket)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot get location of bucket 'test-bucket'")
+		assert.Equal(t, ErrorNotFound, ErrorKindOf(err))
+	}
+}
+
+func Test_SetBucketTags_Positive(t *testing.T) {
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	err := sess.SetBucketTags(context.Background(), testBucket, map[string]string{"team": "a", "cost-center": "42"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, svc.TaggingInput) {
+		assert.Equal(t, testBucket, *svc.TaggingInput.Bucket)
+		assert.Equal(t, []types.Tag{{Key: aws.String("cost-center"), Value: aws.String("42")},
+			{Key: aws.String("team"), Value: aws.String("a")}}, svc.TaggingInput.Tagging.TagSet)
+	}
+}
+
+func Test_SetBucketTags_Error(t *testing.T) {
+	sess := getSession(&fakeS3API{ErrPutBucketTagging: responseError(http.StatusForbidden)})
+	err := sess.SetBucketTags(context.Background(), testBucket, map[string]string{"team": "a"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot set tags of bucket 'test-bucket'")
+		assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+	}
+}
+
 func Test_CreateBucketAccess_Error(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrCreateBucket: errFoo})
-	_, err := sess.CreateBucket(testBucket, testLocationConstraint)
+	_, err := sess.CreateBucket(context.Background(), testBucket, testLocationConstraint, "")
 	if assert.Error(t, err) {
 		assert.EqualError(t, err, errFooMsg)
 	}
 }
 
 func Test_CreateBucketAccess_BucketAlreadyExists_Positive(t *testing.T) {
-	sess := getSession(&fakeS3API{ErrCreateBucket: awserr.New("BucketAlreadyOwnedByYou", "", errFoo)})
-	_, err := sess.CreateBucket(testBucket, testLocationConstraint)
+	sess := getSession(&fakeS3API{ErrCreateBucket: &smithy.GenericAPIError{Code: "BucketAlreadyOwnedByYou"}})
+	_, err := sess.CreateBucket(context.Background(), testBucket, testLocationConstraint, "")
 	assert.NoError(t, err)
 }
 
 func Test_CreateBucket_Positive(t *testing.T) {
-	sess := getSession(&fakeS3API{})
-	_, err := sess.CreateBucket(testBucket, testLocationConstraint)
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	_, err := sess.CreateBucket(context.Background(), testBucket, testLocationConstraint, "")
 	assert.NoError(t, err)
+	assert.Empty(t, svc.CreateInput.ACL)
+}
+
+func Test_CreateBucket_ACL(t *testing.T) {
+	svc := &fakeS3API{}
+	sess := getSession(svc)
+	_, err := sess.CreateBucket(context.Background(), testBucket, testLocationConstraint, BucketACLPublicRead)
+	assert.NoError(t, err)
+	assert.Equal(t, types.BucketCannedACLPublicRead, svc.CreateInput.ACL)
+}
+
+func Test_ValidateBucketACL(t *testing.T) {
+	assert.NoError(t, ValidateBucketACL(""))
+	assert.NoError(t, ValidateBucketACL(BucketACLPrivate))
+	assert.NoError(t, ValidateBucketACL(BucketACLPublicRead))
+	assert.Error(t, ValidateBucketACL("public-read-write"))
 }
 
 func Test_DeleteBucket_BucketAlreadyDeleted_Positive(t *testing.T) {
-	sess := getSession(&fakeS3API{ErrListObjects: awserr.New("NoSuchBucket", "", errFoo)})
-	err := sess.DeleteBucket(testBucket)
+	sess := getSession(&fakeS3API{ErrListObjects: &smithy.GenericAPIError{Code: "NoSuchBucket"}})
+	err := sess.DeleteBucket(context.Background(), testBucket)
 	assert.NoError(t, err)
 }
 
 func Test_DeleteBucket_ListObjectsError(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrListObjects: errFoo})
-	err := sess.DeleteBucket(testBucket)
+	err := sess.DeleteBucket(context.Background(), testBucket)
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "cannot list bucket")
 	}
@@ -173,7 +581,7 @@ func Test_DeleteBucket_ListObjectsError(t *testing.T) {
 
 func Test_DeleteBucket_DeleteObjectError(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrDeleteObject: errFoo})
-	err := sess.DeleteBucket(testBucket)
+	err := sess.DeleteBucket(context.Background(), testBucket)
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "cannot delete object")
 	}
@@ -181,14 +589,128 @@ func Test_DeleteBucket_DeleteObjectError(t *testing.T) {
 
 func Test_DeleteBucket_Error(t *testing.T) {
 	sess := getSession(&fakeS3API{ErrDeleteBucket: errFoo})
-	err := sess.DeleteBucket(testBucket)
+	err := sess.DeleteBucket(context.Background(), testBucket)
 	if assert.Error(t, err) {
 		assert.EqualError(t, err, errFooMsg)
 	}
 }
 
+func Test_DeleteBucket_Pages(t *testing.T) {
+	svc := &fakeS3API{ListPages: []s3.ListObjectsOutput{
+		{IsTruncated: aws.Bool(true), Contents: []types.Object{
+			{Key: aws.String("a"), Size: aws.Int64(100)},
+			{Key: aws.String("b"), Size: aws.Int64(200)},
+		}},
+		{IsTruncated: aws.Bool(false), Contents: []types.Object{
+			{Key: aws.String("c"), Size: aws.Int64(5)},
+		}},
+	}}
+	var objects, bytes int64
+	ctx := WithProgress(context.Background(), func(o, b int64) {
+		objects += o
+		bytes += b
+	})
+	err := getSession(svc).DeleteBucket(ctx, testBucket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, svc.DeletedKeys)
+	assert.Equal(t, []string{"", "b"}, svc.ListMarkers)
+	assert.Equal(t, int64(3), objects)
+	assert.Equal(t, int64(305), bytes)
+}
+
+func Test_DeleteBucket_Versions(t *testing.T) {
+	svc := &fakeS3API{EmptyList: true, VersionPages: []s3.ListObjectVersionsOutput{
+		{
+			IsTruncated:         aws.Bool(true),
+			NextKeyMarker:       aws.String("a"),
+			NextVersionIdMarker: aws.String("1"),
+			Versions:            []types.ObjectVersion{{Key: aws.String("a"), VersionId: aws.String("2")}, {Key: aws.String("a"), VersionId: aws.String("1")}},
+			DeleteMarkers:       []types.DeleteMarkerEntry{{Key: aws.String("a"), VersionId: aws.String("3")}},
+		},
+		{
+			IsTruncated:   aws.Bool(false),
+			DeleteMarkers: []types.DeleteMarkerEntry{{Key: aws.String("b"), VersionId: aws.String("4")}},
+		},
+	}}
+	err := getSession(svc).DeleteBucket(context.Background(), testBucket)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a@2", "a@1", "a@3", "b@4"}, svc.DeletedVersions)
+	assert.Equal(t, []string{"@", "a@1"}, svc.VersionMarkers)
+}
+
+func Test_DeleteBucket_VersionsError(t *testing.T) {
+	sess := getSession(&fakeS3API{EmptyList: true, ErrListObjectVersions: errFoo})
+	err := sess.DeleteBucket(context.Background(), testBucket)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot list the versions")
+	}
+
+	sess = getSession(&fakeS3API{EmptyList: true, ErrListObjectVersions: &smithy.GenericAPIError{Code: "NotImplemented"}})
+	assert.NoError(t, sess.DeleteBucket(context.Background(), testBucket))
+}
+
+func Test_SyncPrefix(t *testing.T) {
+	before, after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	svc := &fakeS3API{ListPages: []s3.ListObjectsOutput{
+		// the copies, then the sources
+		{IsTruncated: aws.Bool(false), Contents: []types.Object{
+			{Key: aws.String("backup/a"), Size: aws.Int64(1), ETag: aws.String(`"x-2"`), LastModified: aws.Time(after)},
+			{Key: aws.String("backup/b"), Size: aws.Int64(1), ETag: aws.String(`"old"`), LastModified: aws.Time(after)},
+			{Key: aws.String("backup/d"), Size: aws.Int64(4), ETag: aws.String(`"old"`), LastModified: aws.Time(before)},
+		}},
+		{IsTruncated: aws.Bool(false), Contents: []types.Object{
+			// copied in parts, the ETag of the copy differs
+			{Key: aws.String("data/a"), Size: aws.Int64(1), ETag: aws.String(`"x"`), LastModified: aws.Time(before)},
+			{Key: aws.String("data/b"), Size: aws.Int64(2), ETag: aws.String(`"y"`), LastModified: aws.Time(before)},
+			{Key: aws.String("data/dir/c"), Size: aws.Int64(3), ETag: aws.String(`"z"`), LastModified: aws.Time(before)},
+			// rewritten with the same size after its copy
+			{Key: aws.String("data/d"), Size: aws.Int64(4), ETag: aws.String(`"w"`), LastModified: aws.Time(after)},
+		}},
+	}}
+	var objects int64
+	ctx := WithProgress(context.Background(), func(o, b int64) { objects += o })
+	result, err := getSession(svc).SyncPrefix(ctx, testBucket, "/data", "backup-bucket", "backup/")
+	assert.NoError(t, err)
+	assert.Equal(t, &SyncResult{Copied: 3, BytesCopied: 9, Unchanged: 1}, result)
+	assert.Equal(t, int64(3), objects)
+	if assert.Len(t, svc.CopyInputs, 3) {
+		assert.Equal(t, "backup/b", aws.ToString(svc.CopyInputs[0].Key))
+		assert.Equal(t, "backup-bucket", aws.ToString(svc.CopyInputs[0].Bucket))
+		assert.Equal(t, "test-bucket%2Fdata%2Fb", aws.ToString(svc.CopyInputs[0].CopySource))
+		assert.Equal(t, "backup/dir/c", aws.ToString(svc.CopyInputs[1].Key))
+		assert.Equal(t, "backup/d", aws.ToString(svc.CopyInputs[2].Key))
+	}
+}
+
+func Test_SyncPrefix_Skipped(t *testing.T) {
+	svc := &fakeS3API{ListPages: []s3.ListObjectsOutput{
+		{},
+		{Contents: []types.Object{
+			{Key: aws.String("big"), Size: aws.Int64(MaxCopyObjectSize + 1)},
+			{Key: aws.String("small"), Size: aws.Int64(1)},
+		}},
+	}}
+	result, err := getSession(svc).SyncPrefix(context.Background(), testBucket, "", "backup-bucket", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &SyncResult{Copied: 1, BytesCopied: 1, Skipped: []string{"big"}}, result)
+	if assert.Len(t, svc.CopyInputs, 1) {
+		assert.Equal(t, "small", aws.ToString(svc.CopyInputs[0].Key))
+	}
+}
+
+func Test_SyncPrefix_Errors(t *testing.T) {
+	svc := &fakeS3API{ErrCopyObject: errFoo, ListPages: []s3.ListObjectsOutput{
+		{},
+		{Contents: []types.Object{{Key: aws.String("a"), Size: aws.Int64(1)}}},
+	}}
+	_, err := getSession(svc).SyncPrefix(context.Background(), testBucket, "", "backup-bucket", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot copy object test-bucket/")
+	}
+}
+
 func Test_DeleteBucket_Positive(t *testing.T) {
 	sess := getSession(&fakeS3API{})
-	err := sess.DeleteBucket(testBucket)
+	err := sess.DeleteBucket(context.Background(), testBucket)
 	assert.NoError(t, err)
 }
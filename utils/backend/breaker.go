@@ -0,0 +1,193 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+// ErrEndpointUnavailable is returned, wrapped, by the calls to an object storage endpoint
+// that failed too many times in a row, without trying to reach it
+var ErrEndpointUnavailable = errors.New("endpoint unavailable")
+
+// States of the circuit breaker of an endpoint, as reported by the endpoint circuit state metric
+const (
+	circuitClosed   = 0
+	circuitOpen     = 1
+	circuitHalfOpen = 2
+)
+
+var (
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ibmc_s3fs_cos_endpoint_circuit_state",
+		Help: "State of the circuit breaker of the object storage endpoint, 0 closed, 1 open, 2 half-open",
+	}, []string{"endpoint"})
+	circuitRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ibmc_s3fs_cos_endpoint_circuit_rejected_total",
+		Help: "Calls to the object storage endpoint failed fast because its circuit breaker was open",
+	}, []string{"endpoint"})
+	circuitOpened = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ibmc_s3fs_cos_endpoint_circuit_opened_total",
+		Help: "Times the circuit breaker of the object storage endpoint opened",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(circuitState, circuitRejected, circuitOpened)
+}
+
+// CircuitBreakerConfig tells when the calls to an endpoint fail fast.
+// Zero fields keep the value of DefaultCircuitBreakerConfig.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many calls in a row must fail to reach the endpoint to open
+	// its circuit, negative to never open it
+	FailureThreshold int
+	// OpenDuration is how long calls fail fast before one call is let through to probe the endpoint
+	OpenDuration time.Duration
+}
+
+// DefaultCircuitBreakerConfig is the circuit breaker configuration when the session factory sets none
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+}
+
+// Merge returns c with the non-zero fields of override
+func (c CircuitBreakerConfig) Merge(override CircuitBreakerConfig) CircuitBreakerConfig {
+	if override.FailureThreshold != 0 {
+		c.FailureThreshold = override.FailureThreshold
+	}
+	if override.OpenDuration != 0 {
+		c.OpenDuration = override.OpenDuration
+	}
+	return c
+}
+
+// Validate checks the settings of the circuit breaker
+func (c CircuitBreakerConfig) Validate() error {
+	if c.OpenDuration < 0 {
+		return fmt.Errorf("circuit open duration should be >= 0, got %v", c.OpenDuration)
+	}
+	return nil
+}
+
+// circuitBreaker fails the calls to an endpoint fast once it is down, instead of letting
+// every call wait for its timeouts and retries
+type circuitBreaker struct {
+	endpoint string
+	config   CircuitBreakerConfig
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(endpoint string, config CircuitBreakerConfig) *circuitBreaker {
+	circuitState.WithLabelValues(endpoint).Set(circuitClosed)
+	return &circuitBreaker{endpoint: endpoint, config: config, now: time.Now}
+}
+
+// allow tells whether a call may go to the endpoint. Once the circuit has been open for
+// OpenDuration, a single call is let through, its outcome closes or opens the circuit again.
+func (b *circuitBreaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == circuitOpen && b.now().Sub(b.openedAt) >= b.config.OpenDuration {
+		b.setState(circuitHalfOpen)
+	}
+	if b.state == circuitClosed || (b.state == circuitHalfOpen && !b.probing) {
+		b.probing = b.state == circuitHalfOpen
+		return nil
+	}
+
+	circuitRejected.WithLabelValues(b.endpoint).Inc()
+	retryIn := b.config.OpenDuration - b.now().Sub(b.openedAt)
+	if retryIn < 0 {
+		retryIn = 0
+	}
+	return fmt.Errorf("%w: %s failed %d calls in a row, next attempt in %v",
+		ErrEndpointUnavailable, b.endpoint, b.failures, retryIn.Round(time.Second))
+}
+
+// record accounts the outcome of a call let through by allow
+func (b *circuitBreaker) record(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		// the caller gave up, the call tells nothing about the endpoint
+		return
+	}
+	if !IsRetryable(err) {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		if b.state != circuitOpen {
+			circuitOpened.WithLabelValues(b.endpoint).Inc()
+		}
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state int) {
+	b.state = state
+	circuitState.WithLabelValues(b.endpoint).Set(float64(state))
+}
+
+// HandleInitialize runs the call, retries included, when the circuit lets it through
+func (b *circuitBreaker) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	if err := b.allow(); err != nil {
+		return middleware.InitializeOutput{}, middleware.Metadata{}, err
+	}
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	b.record(err)
+	return out, metadata, err
+}
+
+func (b *circuitBreaker) ID() string {
+	return "IBMCircuitBreaker"
+}
+
+// circuitBreaker returns the circuit breaker of an endpoint, shared by the sessions of the factory,
+// nil when circuits never open
+func (s *COSSessionFactory) circuitBreaker(endpoint string) *circuitBreaker {
+	config := DefaultCircuitBreakerConfig.Merge(s.Breaker)
+	if config.FailureThreshold < 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if breaker, ok := s.breakers[endpoint]; ok {
+		return breaker
+	}
+	if s.breakers == nil {
+		s.breakers = map[string]*circuitBreaker{}
+	}
+	breaker := newCircuitBreaker(endpoint, config)
+	s.breakers[endpoint] = breaker
+	return breaker
+}
@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func responseError(status int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      errFoo,
+	}
+}
+
+func getCircuitBreaker(now *time.Time) *circuitBreaker {
+	breaker := newCircuitBreaker(testEndpoint, CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute})
+	breaker.now = func() time.Time { return *now }
+	return breaker
+}
+
+func Test_CircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	now := time.Now()
+	breaker := getCircuitBreaker(&now)
+
+	assert.NoError(t, breaker.allow())
+	breaker.record(responseError(http.StatusServiceUnavailable))
+	assert.NoError(t, breaker.allow())
+	breaker.record(context.DeadlineExceeded)
+
+	err := breaker.allow()
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, ErrEndpointUnavailable))
+		assert.Contains(t, err.Error(), "endpoint unavailable: test-endpoint failed 2 calls in a row")
+	}
+}
+
+func Test_CircuitBreaker_AnswersKeepItClosed(t *testing.T) {
+	now := time.Now()
+	breaker := getCircuitBreaker(&now)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, breaker.allow())
+		breaker.record(responseError(http.StatusForbidden))
+		assert.NoError(t, breaker.allow())
+		breaker.record(context.Canceled)
+	}
+	assert.Equal(t, circuitClosed, breaker.state)
+}
+
+func Test_CircuitBreaker_HalfOpen(t *testing.T) {
+	now := time.Now()
+	breaker := getCircuitBreaker(&now)
+	breaker.record(errFoo)
+	assert.Equal(t, circuitClosed, breaker.state)
+	breaker.record(&smithyhttp.RequestSendError{Err: errFoo})
+	breaker.record(&smithyhttp.RequestSendError{Err: errFoo})
+	assert.Equal(t, circuitOpen, breaker.state)
+
+	// a single probe once the circuit was open long enough, failing opens it again
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.allow())
+	assert.Error(t, breaker.allow())
+	breaker.record(&smithyhttp.RequestSendError{Err: errFoo})
+	assert.Equal(t, circuitOpen, breaker.state)
+	assert.Error(t, breaker.allow())
+
+	// a successful probe closes it
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.allow())
+	breaker.record(nil)
+	assert.Equal(t, circuitClosed, breaker.state)
+	assert.NoError(t, breaker.allow())
+}
+
+func Test_CircuitBreaker_PerEndpoint(t *testing.T) {
+	f := &COSSessionFactory{}
+	assert.True(t, f.circuitBreaker(testEndpoint) == f.circuitBreaker(testEndpoint))
+	assert.False(t, f.circuitBreaker(testEndpoint) == f.circuitBreaker("other-endpoint"))
+
+	f = &COSSessionFactory{Breaker: CircuitBreakerConfig{FailureThreshold: -1}}
+	assert.Nil(t, f.circuitBreaker(testEndpoint))
+}
+
+func Test_CircuitBreaker_FailsFast(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	f := &COSSessionFactory{
+		Retry:   RetryPolicy{MaxAttempts: 1},
+		Breaker: CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute},
+	}
+	sess := f.NewObjectStorageSession(server.URL, testRegion,
+		&ObjectStorageCredentials{AccessKey: testAccessKey, SecretKey: testSecretKey}, TransportConfig{}, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		err := sess.CheckBucketAccess(context.Background(), testBucket)
+		if assert.Error(t, err) {
+			assert.False(t, errors.Is(err, ErrEndpointUnavailable))
+		}
+	}
+	err := sess.CheckBucketAccess(context.Background(), testBucket)
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, ErrEndpointUnavailable))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,149 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestIDHeaders are the response headers carrying the id of a request, the one IBM support
+// looks requests up with first
+var requestIDHeaders = []string{"x-clv-request-id", "x-amz-request-id"}
+
+// ErrorKind tells apart the failures of the object storage calls
+type ErrorKind string
+
+// Kinds of the errors of the object storage calls
+const (
+	// ErrorAccessDenied is returned when the credentials are wrong or lack the permission, 401 or 403
+	ErrorAccessDenied ErrorKind = "access denied"
+	// ErrorNotFound is returned when the bucket or object does not exist, 404
+	ErrorNotFound ErrorKind = "not found"
+	// ErrorConflict is returned when the bucket is in a state preventing the call, 409
+	ErrorConflict ErrorKind = "conflict"
+	// ErrorUnreachable is returned when the endpoint could not serve the call: network failures,
+	// timeouts, server errors, or the endpoint circuit being open
+	ErrorUnreachable ErrorKind = "endpoint unreachable"
+	// ErrorOther is returned for the other failures
+	ErrorOther ErrorKind = ""
+)
+
+// Error is the error of an object storage call
+type Error struct {
+	Kind ErrorKind
+	Err  error
+	// RequestID is the id of the failed request, empty when no response was received
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	msg := e.Err.Error()
+	if e.Kind != ErrorOther {
+		msg = string(e.Kind) + ": " + msg
+	}
+	if e.RequestID != "" && !strings.Contains(msg, e.RequestID) {
+		msg += " (request id: " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable tells whether the call may succeed when tried again later
+func (e *Error) Retryable() bool {
+	return e.Kind == ErrorUnreachable
+}
+
+// newError returns err as an *Error of its kind
+func newError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var backendErr *Error
+	if errors.As(err, &backendErr) {
+		return err
+	}
+	return &Error{Kind: ErrorKindOf(err), Err: err, RequestID: requestIDOf(err)}
+}
+
+// RequestID returns the id of the request an object storage call failed on, empty if unknown
+func RequestID(err error) string {
+	var backendErr *Error
+	if errors.As(err, &backendErr) && backendErr.RequestID != "" {
+		return backendErr.RequestID
+	}
+	return requestIDOf(err)
+}
+
+func requestIDOf(err error) string {
+	var respErr *smithyhttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.Response == nil || respErr.Response.Response == nil {
+		return ""
+	}
+	for _, header := range requestIDHeaders {
+		if id := respErr.Response.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// ErrorKindOf returns the kind of an error of an object storage call
+func ErrorKindOf(err error) ErrorKind {
+	var backendErr *Error
+	if errors.As(err, &backendErr) {
+		return backendErr.Kind
+	}
+
+	if errors.Is(err, ErrEndpointUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorUnreachable
+	}
+	var netErr net.Error
+	var sendErr *smithyhttp.RequestSendError
+	if errors.As(err, &netErr) || errors.As(err, &sendErr) {
+		return ErrorUnreachable
+	}
+
+	switch errorCode(err) {
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "Forbidden":
+		return ErrorAccessDenied
+	case "NoSuchBucket", "NoSuchKey", "NotFound":
+		return ErrorNotFound
+	case "BucketAlreadyExists", "BucketAlreadyOwnedByYou", "BucketNotEmpty", "OperationAborted":
+		return ErrorConflict
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch status := respErr.HTTPStatusCode(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return ErrorAccessDenied
+		case status == http.StatusNotFound:
+			return ErrorNotFound
+		case status == http.StatusConflict:
+			return ErrorConflict
+		case status >= http.StatusInternalServerError:
+			return ErrorUnreachable
+		}
+	}
+	return ErrorOther
+}
+
+// IsRetryable tells whether a failed object storage call may succeed when tried again later
+func IsRetryable(err error) bool {
+	return ErrorKindOf(err) == ErrorUnreachable
+}
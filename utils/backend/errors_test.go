@@ -0,0 +1,82 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func Test_ErrorKindOf(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{&smithy.GenericAPIError{Code: "AccessDenied"}, ErrorAccessDenied},
+		{&smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}, ErrorAccessDenied},
+		{responseError(http.StatusUnauthorized), ErrorAccessDenied},
+		{&smithy.GenericAPIError{Code: "NoSuchBucket"}, ErrorNotFound},
+		{responseError(http.StatusNotFound), ErrorNotFound},
+		{&smithy.GenericAPIError{Code: "BucketNotEmpty"}, ErrorConflict},
+		{responseError(http.StatusConflict), ErrorConflict},
+		{responseError(http.StatusServiceUnavailable), ErrorUnreachable},
+		{&net.OpError{Op: "dial", Err: errFoo}, ErrorUnreachable},
+		{&smithyhttp.RequestSendError{Err: errFoo}, ErrorUnreachable},
+		{fmt.Errorf("cannot list bucket: %w", context.DeadlineExceeded), ErrorUnreachable},
+		{fmt.Errorf("%w: test-endpoint", ErrEndpointUnavailable), ErrorUnreachable},
+		{responseError(http.StatusBadRequest), ErrorOther},
+		{errFoo, ErrorOther},
+	} {
+		assert.Equal(t, test.kind, ErrorKindOf(test.err), "%v", test.err)
+	}
+}
+
+func Test_Error(t *testing.T) {
+	err := newError(fmt.Errorf("cannot list bucket 'b': %w", &smithy.GenericAPIError{Code: "NoSuchBucket", Message: "missing"}))
+	var backendErr *Error
+	if assert.True(t, errors.As(err, &backendErr)) {
+		assert.Equal(t, ErrorNotFound, backendErr.Kind)
+		assert.False(t, backendErr.Retryable())
+	}
+	assert.Contains(t, err.Error(), "not found: cannot list bucket 'b'")
+	assert.Equal(t, err, newError(err))
+	assert.Nil(t, newError(nil))
+
+	err = newError(responseError(http.StatusBadGateway))
+	assert.True(t, IsRetryable(err))
+	assert.EqualError(t, newError(errFoo), errFooMsg)
+}
+
+func Test_Error_RequestID(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-amz-request-id", "amz-id")
+	header.Set("x-clv-request-id", "clv-id")
+	respErr := &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden, Header: header}},
+		Err:      errFoo,
+	}
+
+	err := newError(fmt.Errorf("cannot create bucket 'b': %w", respErr))
+	assert.Equal(t, "clv-id", RequestID(err))
+	assert.Contains(t, err.Error(), "(request id: clv-id)")
+
+	header.Del("x-clv-request-id")
+	assert.Equal(t, "amz-id", RequestID(respErr))
+	assert.Empty(t, RequestID(newError(errFoo)))
+	assert.Empty(t, RequestID(responseError(http.StatusNotFound)))
+}
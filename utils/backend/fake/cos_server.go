@@ -0,0 +1,682 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package fake
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// COSServer is an in-memory object storage serving the S3 calls of the COS sessions, path-style:
+// buckets can be created, listed, located, tagged, versioned, replicated and deleted, objects put, copied, read, listed and deleted.
+// Requests are not authenticated, each response carries a request id as COS responses do.
+type COSServer struct {
+	// URL is the endpoint of the server
+	URL string
+
+	server   *httptest.Server
+	mutex    sync.Mutex
+	buckets  map[string]*cosBucket
+	requests int
+}
+
+type cosBucket struct {
+	location string
+	created  time.Time
+	objects  map[string][]byte
+	// modified are the times the objects were written
+	modified map[string]time.Time
+	tags     map[string]string
+	// versioning is the status of the versioning of the bucket, empty when never set
+	versioning string
+	// replicationTarget is the bucket of the replication rule of the bucket
+	replicationTarget string
+	// versions are the versions and delete markers of the objects of a versioned bucket, oldest first
+	versions    []cosVersion
+	nextVersion int
+}
+
+// cosVersion is a version of an object, or the delete marker left by its deletion
+type cosVersion struct {
+	key          string
+	id           string
+	data         []byte
+	deleteMarker bool
+}
+
+// NewCOSServer starts an empty COSServer, to be closed by the caller
+func NewCOSServer() *COSServer {
+	s := &COSServer{buckets: map[string]*cosBucket{}}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close stops the server
+func (s *COSServer) Close() {
+	s.server.Close()
+}
+
+// CreateBucket adds an empty bucket to the server
+func (s *COSServer) CreateBucket(bucket, location string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets[bucket] = &cosBucket{location: location, created: time.Now(), objects: map[string][]byte{}}
+}
+
+// PutObject adds an object to a bucket of the server, creating the bucket when missing
+func (s *COSServer) PutObject(bucket, key string, data []byte) {
+	if !s.HasBucket(bucket) {
+		s.CreateBucket(bucket, "")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.buckets[bucket].putData(key, data)
+}
+
+// HasBucket tells whether the server holds a bucket
+func (s *COSServer) HasBucket(bucket string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.buckets[bucket]
+	return ok
+}
+
+// Objects returns the keys of the objects of a bucket, sorted
+func (s *COSServer) Objects(bucket string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	return b.keys()
+}
+
+// Tags returns the tags of a bucket, nil when it has none
+func (s *COSServer) Tags(bucket string) map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	return b.tags
+}
+
+// Versioning returns the status of the versioning of a bucket, empty when never set
+func (s *COSServer) Versioning(bucket string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return ""
+	}
+	return b.versioning
+}
+
+// ReplicationTarget returns the bucket the objects of a bucket are replicated to, empty when not replicated
+func (s *COSServer) ReplicationTarget(bucket string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return ""
+	}
+	return b.replicationTarget
+}
+
+// Versions returns the number of versions and delete markers of a bucket, 0 when it is not versioned
+func (s *COSServer) Versions(bucket string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return 0
+	}
+	return len(b.versions)
+}
+
+// putData stores data as the current version of key, keeping the previous versions of a versioned bucket
+func (b *cosBucket) putData(key string, data []byte) {
+	b.objects[key] = data
+	if b.modified == nil {
+		b.modified = map[string]time.Time{}
+	}
+	b.modified[key] = time.Now()
+	if b.versioning == "Enabled" {
+		b.nextVersion++
+		b.versions = append(b.versions, cosVersion{key: key, id: strconv.Itoa(b.nextVersion), data: data})
+	}
+}
+
+// deleteKey deletes the current version of key, leaving a delete marker in a versioned bucket
+func (b *cosBucket) deleteKey(key string) {
+	delete(b.objects, key)
+	if b.versioning == "Enabled" {
+		b.nextVersion++
+		b.versions = append(b.versions, cosVersion{key: key, id: strconv.Itoa(b.nextVersion), deleteMarker: true})
+	}
+}
+
+// deleteVersion deletes a version or delete marker of key, the newest remaining version becomes current.
+// The objects of a bucket never versioned have the "null" version.
+func (b *cosBucket) deleteVersion(key, id string) {
+	if b.versioning == "" {
+		if id == "null" {
+			delete(b.objects, key)
+		}
+		return
+	}
+	var current *cosVersion
+	versions := b.versions[:0]
+	for _, version := range b.versions {
+		if version.key == key && version.id == id {
+			continue
+		}
+		versions = append(versions, version)
+		if version.key == key {
+			current = &versions[len(versions)-1]
+		}
+	}
+	b.versions = versions
+	if current == nil || current.deleteMarker {
+		delete(b.objects, key)
+	} else {
+		b.objects[key] = current.data
+	}
+}
+
+// versionsOf returns the versions and delete markers of the objects, by key then newest first
+func (b *cosBucket) versionsOf() []cosVersion {
+	if b.versioning == "" {
+		versions := make([]cosVersion, 0, len(b.objects))
+		for _, key := range b.keys() {
+			versions = append(versions, cosVersion{key: key, id: "null", data: b.objects[key]})
+		}
+		return versions
+	}
+	versions := make([]cosVersion, 0, len(b.versions))
+	for i := len(b.versions) - 1; i >= 0; i-- {
+		versions = append(versions, b.versions[i])
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].key < versions[j].key
+	})
+	return versions
+}
+
+func (b *cosBucket) keys() []string {
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type cosError struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	// HEAD responses have no body, the SDK tells the errors apart by their status
+	if r.Method != http.MethodHead {
+		writeXML(w, cosError{Code: code, Message: code})
+	}
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func (s *COSServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+	w.Header().Set("x-clv-request-id", fmt.Sprintf("%016x", s.requests))
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucketName := parts[0]
+	if bucketName == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+			return
+		}
+		s.listBuckets(w)
+		return
+	}
+	bucket, exists := s.buckets[bucketName]
+
+	if len(parts) == 1 || parts[1] == "" {
+		if _, ok := r.URL.Query()["tagging"]; ok && r.Method == http.MethodPut {
+			if !exists {
+				writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			putTagging(w, r, bucket)
+			return
+		}
+		if _, ok := r.URL.Query()["versioning"]; ok && r.Method == http.MethodPut {
+			if !exists {
+				writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			putVersioning(w, r, bucket)
+			return
+		}
+		if _, ok := r.URL.Query()["replication"]; ok && r.Method == http.MethodPut {
+			if !exists {
+				writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			s.putReplication(w, r, bucket)
+			return
+		}
+		if r.Method == http.MethodPut {
+			s.createBucket(w, r, bucketName, exists)
+			return
+		}
+		if !exists {
+			writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			if len(bucket.objects) > 0 || len(bucket.versions) > 0 {
+				writeError(w, r, http.StatusConflict, "BucketNotEmpty")
+				return
+			}
+			delete(s.buckets, bucketName)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			if _, ok := r.URL.Query()["location"]; ok {
+				writeXML(w, struct {
+					XMLName  xml.Name `xml:"LocationConstraint"`
+					Xmlns    string   `xml:"xmlns,attr"`
+					Location string   `xml:",chardata"`
+				}{Xmlns: s3Namespace, Location: bucket.location})
+				return
+			}
+			if _, ok := r.URL.Query()["versions"]; ok {
+				listVersions(w, r, bucketName, bucket)
+				return
+			}
+			listObjects(w, r, bucketName, bucket)
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+		}
+		return
+	}
+
+	if !exists {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := parts[1]
+	data, found := bucket.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("x-amz-copy-source"); source != "" {
+			s.copyObject(w, r, source, bucket, key)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		bucket.putData(key, body)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+			bucket.deleteVersion(key, versionID)
+		} else {
+			bucket.deleteKey(key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead, http.MethodGet:
+		if !found {
+			writeError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// copyObject copies the object of a "<bucket>/<key>" copy source, URL encoded, to key inside bucket
+func (s *COSServer) copyObject(w http.ResponseWriter, r *http.Request, source string, bucket *cosBucket, key string) {
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	parts := strings.SplitN(source, "/", 2)
+	sourceBucket, ok := s.buckets[parts[0]]
+	if !ok || len(parts) != 2 {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	data, ok := sourceBucket.objects[parts[1]]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	bucket.putData(key, data)
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{Xmlns: s3Namespace, ETag: etag(data), LastModified: time.Now().UTC().Format(time.RFC3339)})
+}
+
+// etag returns the ETag of an object stored in a single part, the quoted MD5 of its data
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *COSServer) listBuckets(w http.ResponseWriter) {
+	type bucketEntry struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	result := struct {
+		XMLName xml.Name      `xml:"ListAllMyBucketsResult"`
+		Xmlns   string        `xml:"xmlns,attr"`
+		Buckets []bucketEntry `xml:"Buckets>Bucket"`
+	}{Xmlns: s3Namespace}
+
+	names := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result.Buckets = append(result.Buckets, bucketEntry{
+			Name:         name,
+			CreationDate: s.buckets[name].created.UTC().Format(time.RFC3339),
+		})
+	}
+	writeXML(w, result)
+}
+
+func (s *COSServer) createBucket(w http.ResponseWriter, r *http.Request, bucketName string, exists bool) {
+	if exists {
+		writeError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou")
+		return
+	}
+	var config struct {
+		LocationConstraint string `xml:"LocationConstraint"`
+	}
+	if body, err := ioutil.ReadAll(r.Body); err == nil && len(body) > 0 {
+		if err := xml.Unmarshal(body, &config); err != nil {
+			writeError(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+	}
+	s.buckets[bucketName] = &cosBucket{location: config.LocationConstraint, created: time.Now(), objects: map[string][]byte{}}
+	w.WriteHeader(http.StatusOK)
+}
+
+// putTagging replaces the tags of a bucket
+func putTagging(w http.ResponseWriter, r *http.Request, bucket *cosBucket) {
+	var tagging struct {
+		Tags []struct {
+			Key   string `xml:"Key"`
+			Value string `xml:"Value"`
+		} `xml:"TagSet>Tag"`
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = xml.Unmarshal(body, &tagging)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	bucket.tags = map[string]string{}
+	for _, tag := range tagging.Tags {
+		bucket.tags[tag.Key] = tag.Value
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// putVersioning sets the status of the versioning of a bucket
+func putVersioning(w http.ResponseWriter, r *http.Request, bucket *cosBucket) {
+	var versioning struct {
+		Status string `xml:"Status"`
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = xml.Unmarshal(body, &versioning)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	if bucket.versioning == "" && versioning.Status == "Enabled" {
+		// the objects stored before keep the "null" version
+		for _, key := range bucket.keys() {
+			bucket.versions = append(bucket.versions, cosVersion{key: key, id: "null", data: bucket.objects[key]})
+		}
+	}
+	bucket.versioning = versioning.Status
+	w.WriteHeader(http.StatusOK)
+}
+
+// putReplication sets the replication of a bucket to the destination of its single rule, both buckets must be
+// versioned
+func (s *COSServer) putReplication(w http.ResponseWriter, r *http.Request, bucket *cosBucket) {
+	var replication struct {
+		Destinations []string `xml:"Rule>Destination>Bucket"`
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = xml.Unmarshal(body, &replication)
+	}
+	if err != nil || len(replication.Destinations) != 1 {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	target := strings.TrimPrefix(replication.Destinations[0], "arn:aws:s3:::")
+	if bucket.versioning != "Enabled" || s.buckets[target] == nil || s.buckets[target].versioning != "Enabled" {
+		writeError(w, r, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+	bucket.replicationTarget = target
+	w.WriteHeader(http.StatusOK)
+}
+
+// listObjects answers a ListObjects (v1) call, grouping the keys by delimiter
+func listObjects(w http.ResponseWriter, r *http.Request, bucketName string, bucket *cosBucket) {
+	query := r.URL.Query()
+	prefix, delimiter, marker := query.Get("prefix"), query.Get("delimiter"), query.Get("marker")
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		var err error
+		if maxKeys, err = strconv.Atoi(value); err != nil || maxKeys < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+	}
+
+	type objectEntry struct {
+		Key          string `xml:"Key"`
+		Size         int    `xml:"Size"`
+		ETag         string `xml:"ETag"`
+		LastModified string `xml:"LastModified"`
+	}
+	type prefixEntry struct {
+		Prefix string `xml:"Prefix"`
+	}
+	result := struct {
+		XMLName        xml.Name      `xml:"ListBucketResult"`
+		Xmlns          string        `xml:"xmlns,attr"`
+		Name           string        `xml:"Name"`
+		Prefix         string        `xml:"Prefix"`
+		Marker         string        `xml:"Marker"`
+		MaxKeys        int           `xml:"MaxKeys"`
+		Delimiter      string        `xml:"Delimiter,omitempty"`
+		IsTruncated    bool          `xml:"IsTruncated"`
+		NextMarker     string        `xml:"NextMarker,omitempty"`
+		Contents       []objectEntry `xml:"Contents"`
+		CommonPrefixes []prefixEntry `xml:"CommonPrefixes"`
+	}{Xmlns: s3Namespace, Name: bucketName, Prefix: prefix, Marker: marker, MaxKeys: maxKeys, Delimiter: delimiter}
+
+	last := ""
+	for _, key := range bucket.keys() {
+		if !strings.HasPrefix(key, prefix) || key <= marker {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry = key[:len(prefix)+i+len(delimiter)]
+				if entry == last || entry <= marker {
+					continue
+				}
+			}
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if entry != key {
+			result.CommonPrefixes = append(result.CommonPrefixes, prefixEntry{Prefix: entry})
+		} else {
+			result.Contents = append(result.Contents, objectEntry{
+				Key:          key,
+				Size:         len(bucket.objects[key]),
+				ETag:         etag(bucket.objects[key]),
+				LastModified: bucket.modified[key].UTC().Format("2006-01-02T15:04:05.000Z"),
+			})
+		}
+		last = entry
+	}
+	if result.IsTruncated && delimiter != "" {
+		result.NextMarker = last
+	}
+	writeXML(w, result)
+}
+
+// listVersions answers a ListObjectVersions call, listing the versions after the key and version id markers
+func listVersions(w http.ResponseWriter, r *http.Request, bucketName string, bucket *cosBucket) {
+	query := r.URL.Query()
+	prefix, keyMarker, versionIDMarker := query.Get("prefix"), query.Get("key-marker"), query.Get("version-id-marker")
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		var err error
+		if maxKeys, err = strconv.Atoi(value); err != nil || maxKeys < 1 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+	}
+
+	type versionEntry struct {
+		Key          string `xml:"Key"`
+		VersionID    string `xml:"VersionId"`
+		IsLatest     bool   `xml:"IsLatest"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag,omitempty"`
+		Size         int    `xml:"Size"`
+	}
+	type markerEntry struct {
+		Key          string `xml:"Key"`
+		VersionID    string `xml:"VersionId"`
+		IsLatest     bool   `xml:"IsLatest"`
+		LastModified string `xml:"LastModified"`
+	}
+	result := struct {
+		XMLName             xml.Name       `xml:"ListVersionsResult"`
+		Xmlns               string         `xml:"xmlns,attr"`
+		Name                string         `xml:"Name"`
+		Prefix              string         `xml:"Prefix"`
+		KeyMarker           string         `xml:"KeyMarker"`
+		VersionIDMarker     string         `xml:"VersionIdMarker"`
+		MaxKeys             int            `xml:"MaxKeys"`
+		IsTruncated         bool           `xml:"IsTruncated"`
+		NextKeyMarker       string         `xml:"NextKeyMarker,omitempty"`
+		NextVersionIDMarker string         `xml:"NextVersionIdMarker,omitempty"`
+		Versions            []versionEntry `xml:"Version"`
+		DeleteMarkers       []markerEntry  `xml:"DeleteMarker"`
+	}{Xmlns: s3Namespace, Name: bucketName, Prefix: prefix, KeyMarker: keyMarker, VersionIDMarker: versionIDMarker, MaxKeys: maxKeys}
+
+	// without version id marker, the listing goes on after all the versions of the key marker
+	started := keyMarker == ""
+	last, count := "", 0
+	for _, version := range bucket.versionsOf() {
+		isLatest := version.key != last
+		last = version.key
+		if !started {
+			if version.key < keyMarker || (version.key == keyMarker && versionIDMarker == "") {
+				continue
+			}
+			if version.key == keyMarker {
+				started = version.id == versionIDMarker
+				continue
+			}
+			started = true
+		}
+		if !strings.HasPrefix(version.key, prefix) {
+			continue
+		}
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		result.NextKeyMarker, result.NextVersionIDMarker = version.key, version.id
+		modified := time.Now().UTC().Format(time.RFC3339)
+		if version.deleteMarker {
+			result.DeleteMarkers = append(result.DeleteMarkers, markerEntry{
+				Key: version.key, VersionID: version.id, IsLatest: isLatest, LastModified: modified,
+			})
+		} else {
+			result.Versions = append(result.Versions, versionEntry{
+				Key: version.key, VersionID: version.id, IsLatest: isLatest, LastModified: modified,
+				ETag: etag(version.data), Size: len(version.data),
+			})
+		}
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+	writeXML(w, result)
+}
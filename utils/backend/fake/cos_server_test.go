@@ -0,0 +1,174 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package fake
+
+import (
+	"context"
+	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func getServerSession(server *COSServer) backend.ObjectStorageSession {
+	factory := &backend.COSSessionFactory{Endpoint: server.URL}
+	return factory.NewObjectStorageSession("https://s3.us-south.cloud-object-storage.appdomain.cloud", "us-south",
+		&backend.ObjectStorageCredentials{AccessKey: "akey", SecretKey: "skey"}, backend.TransportConfig{}, zap.NewNop())
+}
+
+func Test_COSServer_Buckets(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+
+	_, err := sess.CreateBucket(ctx, "tmp-s3fs-1", "us-south-smart", "")
+	assert.NoError(t, err)
+	msg, err := sess.CreateBucket(ctx, "tmp-s3fs-1", "us-south-smart", "")
+	assert.NoError(t, err)
+	assert.Contains(t, msg, "already exists")
+	server.CreateBucket("other", "")
+
+	assert.NoError(t, sess.CheckBucketAccess(ctx, "tmp-s3fs-1"))
+	err = sess.CheckBucketAccess(ctx, "missing")
+	assert.Equal(t, backend.ErrorNotFound, backend.ErrorKindOf(err))
+	assert.Len(t, backend.RequestID(err), 16)
+
+	location, err := sess.GetBucketLocation(ctx, "tmp-s3fs-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-south-smart", location)
+
+	buckets, err := sess.ListBuckets(ctx, "tmp-s3fs-")
+	assert.NoError(t, err)
+	if assert.Len(t, buckets, 1) {
+		assert.Equal(t, "tmp-s3fs-1", buckets[0].Name)
+		assert.WithinDuration(t, time.Now(), buckets[0].Created, time.Minute)
+	}
+
+	server.PutObject("tmp-s3fs-1", "a", []byte("data"))
+	assert.NoError(t, sess.DeleteBucket(ctx, "tmp-s3fs-1"))
+	assert.False(t, server.HasBucket("tmp-s3fs-1"))
+	assert.NoError(t, sess.DeleteBucket(ctx, "tmp-s3fs-1"))
+}
+
+func Test_COSServer_Objects(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+	server.PutObject("bucket", "data/file", []byte("12345"))
+	server.PutObject("bucket", "data/dir/file", []byte("123"))
+	server.PutObject("bucket", ".trash/file", []byte("1"))
+
+	assert.NoError(t, sess.CreateObjectPath(ctx, "bucket", "/volume"))
+	assert.Equal(t, []string{".trash/file", "data/dir/file", "data/file", "volume/"}, server.Objects("bucket"))
+
+	exist, err := sess.CheckObjectPathExistence(ctx, "bucket", "volume", false)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = sess.CheckObjectPathExistence(ctx, "bucket", "data", false)
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	exist, err = sess.CheckObjectPathExistence(ctx, "bucket", "data", true)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	assert.NoError(t, sess.CheckObjectAccess(ctx, "bucket", "missing"))
+
+	usage, err := sess.GetBucketUsage(ctx, "bucket", "data")
+	assert.NoError(t, err)
+	assert.Equal(t, &backend.BucketUsage{BytesUsed: 8, ObjectCount: 2}, usage)
+
+	empty, err := sess.IsBucketEmpty(ctx, "bucket", []string{".trash/", "data/"})
+	assert.NoError(t, err)
+	assert.False(t, empty)
+	empty, err = sess.IsBucketEmpty(ctx, "bucket", []string{".trash/", "data/", "volume/"})
+	assert.NoError(t, err)
+	assert.True(t, empty)
+}
+
+func Test_COSServer_Tags(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+	server.CreateBucket("bucket", "")
+
+	assert.NoError(t, sess.SetBucketTags(ctx, "bucket", map[string]string{"cost-center": "42", "team": "a"}))
+	assert.Equal(t, map[string]string{"cost-center": "42", "team": "a"}, server.Tags("bucket"))
+	assert.Error(t, sess.SetBucketTags(ctx, "missing", map[string]string{"team": "a"}))
+}
+
+func Test_COSServer_Replication(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+	server.CreateBucket("bucket", "")
+	server.CreateBucket("dr", "")
+	rule := backend.ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de", Priority: 1}
+
+	// the target must be versioned too
+	assert.Error(t, sess.SetBucketReplication(ctx, "bucket", rule))
+	assert.Equal(t, "Enabled", server.Versioning("bucket"))
+	assert.Empty(t, server.ReplicationTarget("bucket"))
+
+	server.buckets["dr"].versioning = "Enabled"
+	assert.NoError(t, sess.SetBucketReplication(ctx, "bucket", rule))
+	assert.Equal(t, "dr", server.ReplicationTarget("bucket"))
+	assert.Equal(t, backend.ErrorNotFound, backend.ErrorKindOf(sess.SetBucketReplication(ctx, "missing", rule)))
+}
+
+func Test_COSServer_DeleteVersionedBucket(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+	server.PutObject("bucket", "a", []byte("1"))
+	server.CreateBucket("dr", "")
+	server.buckets["dr"].versioning = "Enabled"
+	assert.NoError(t, sess.SetBucketReplication(ctx, "bucket",
+		backend.ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de", Priority: 1}))
+	server.PutObject("bucket", "a", []byte("12"))
+	server.PutObject("bucket", "b", []byte("123"))
+	assert.Equal(t, 3, server.Versions("bucket"))
+
+	assert.NoError(t, sess.DeleteBucket(ctx, "bucket"))
+	assert.False(t, server.HasBucket("bucket"))
+}
+
+func Test_COSServer_SyncPrefix(t *testing.T) {
+	server := NewCOSServer()
+	defer server.Close()
+	sess := getServerSession(server)
+	ctx := context.Background()
+	server.PutObject("bucket", "data/a", []byte("12345"))
+	server.PutObject("bucket", "data/dir/b", []byte("123"))
+	server.PutObject("bucket", "other/c", []byte("1"))
+	server.PutObject("backup", "pv/a", []byte("12345"))
+	server.PutObject("backup", "pv/old", []byte("1"))
+
+	result, err := sess.SyncPrefix(ctx, "bucket", "/data", "backup", "pv")
+	assert.NoError(t, err)
+	assert.Equal(t, &backend.SyncResult{Copied: 1, BytesCopied: 3, Unchanged: 1}, result)
+	assert.Equal(t, []string{"pv/a", "pv/dir/b", "pv/old"}, server.Objects("backup"))
+
+	// an object rewritten with the same size is copied again
+	time.Sleep(10 * time.Millisecond)
+	server.PutObject("bucket", "data/a", []byte("54321"))
+	result, err = sess.SyncPrefix(ctx, "bucket", "/data", "backup", "pv")
+	assert.NoError(t, err)
+	assert.Equal(t, &backend.SyncResult{Copied: 1, BytesCopied: 5, Unchanged: 1}, result)
+
+	_, err = sess.SyncPrefix(ctx, "bucket", "data", "missing", "pv")
+	assert.Equal(t, backend.ErrorNotFound, backend.ErrorKindOf(err))
+}
@@ -29,6 +29,12 @@ type FakeAccessPolicyFactory struct {
 	FailUpdateQuotaLimitErrMsg string
 	//PassUpdateAccessPolicy ...
 	PassUpdateQuotaLimit bool
+	//FailGetBucketUsage ...
+	FailGetBucketUsage bool
+	//BucketUsage returned by GetBucketUsage
+	BucketUsage backend.BucketUsage
+	//LastUsageAPIKey is the API key of the last GetBucketUsage call
+	LastUsageAPIKey string
 }
 
 var _ backend.AccessPolicyFactory = (*FakeAccessPolicyFactory)(nil)
@@ -60,3 +66,13 @@ func (c *fakeAccessPolicy) UpdateQuotaLimit(quota int64, apiKey, bucketName, osE
 	}
 	return nil
 }
+
+// GetBucketUsage method creates a fake getBucketConfig call
+func (c *fakeAccessPolicy) GetBucketUsage(apiKey, bucketName, osEndpoint, iamEndpoint string, rcc backend.ResourceConfigurationV1) (*backend.BucketUsage, error) {
+	c.rcv1.LastUsageAPIKey = apiKey
+	if c.rcv1.FailGetBucketUsage {
+		return nil, errors.New("cannot get bucket configuration")
+	}
+	usage := c.rcv1.BucketUsage
+	return &usage, nil
+}
@@ -11,25 +11,62 @@
 package fake
 
 import (
+	"context"
 	"errors"
 	"github.com/IBM/ibmcloud-object-storage-plugin/utils/backend"
 	"go.uber.org/zap"
+	"strings"
+	"time"
 )
 
 //ObjectStorageSessionFactory is a factory for mocked object storage sessions
 type ObjectStorageSessionFactory struct {
 	//FailCheckBucketAccess ...
 	FailCheckBucketAccess bool
+	// FailCheckBucketAccessKind is the kind of the error of CheckBucketAccess when it fails
+	FailCheckBucketAccessKind backend.ErrorKind
+	// BucketUsage is the usage returned by GetBucketUsage
+	BucketUsage backend.BucketUsage
+	// FailGetBucketUsage ...
+	FailGetBucketUsage bool
+	// NotEmptyBucket makes IsBucketEmpty report the buckets as holding objects
+	NotEmptyBucket bool
+	// FailIsBucketEmpty ...
+	FailIsBucketEmpty bool
+	// LastExcludePrefixes stores the excluded prefixes of the last IsBucketEmpty call
+	LastExcludePrefixes []string
+	// Buckets are the buckets returned by ListBuckets when they start with its prefix
+	Buckets []backend.BucketInfo
+	// FailListBuckets ...
+	FailListBuckets bool
+	// BucketLocation is the location constraint returned by GetBucketLocation
+	BucketLocation string
+	// FailGetBucketLocation ...
+	FailGetBucketLocation bool
+	// FailGetBucketLocationKind is the kind of the error of GetBucketLocation when it fails
+	FailGetBucketLocationKind backend.ErrorKind
 	//FailCreateBucket ...
 	FailCreateBucket bool
 	//FailCreateBucket with specific error msg...
 	FailCreateBucketErrMsg string
 	//FailDeleteBucket ...
 	FailDeleteBucket bool
+	// DeleteBucketDelay is the time DeleteBucket takes, after reporting the objects of BucketUsage as deleted
+	DeleteBucketDelay time.Duration
+	// SyncResult is the result of SyncPrefix, whose copies are reported as progress
+	SyncResult backend.SyncResult
+	// FailSyncPrefix ...
+	FailSyncPrefix bool
 	//CheckObjectPathExistenceError ...
 	CheckObjectPathExistenceError bool
 	//CheckObjectPathExistencePathNotFound ...
 	CheckObjectPathExistencePathNotFound bool
+	//FailCreateObjectPath ...
+	FailCreateObjectPath bool
+	// FailCreateObjectPathMarker ...
+	FailCreateObjectPathMarker bool
+	// FailDeleteObjectPathMarker ...
+	FailDeleteObjectPathMarker bool
 
 	// LastEndpoint holds the endpoint of the last created session
 	LastEndpoint string
@@ -37,14 +74,43 @@ type ObjectStorageSessionFactory struct {
 	LastRegion string
 	// LastCredentials holds the credentials of the last created session
 	LastCredentials *backend.ObjectStorageCredentials
+	// LastTransport holds the transport settings of the last created session
+	LastTransport backend.TransportConfig
 	// LastCheckedBucket stores the name of the last bucket that was checked
 	LastCheckedBucket string
+	// LastCheckedObject stores the key of the last object checked by CheckObjectAccess
+	LastCheckedObject string
 	// LastCreatedBucket stores the name of the last bucket that was created
 	LastCreatedBucket string
+	// LastLocationConstraint stores the location constraint of the last bucket that was created
+	LastLocationConstraint string
+	// LastBucketACL stores the canned ACL of the last bucket that was created
+	LastBucketACL string
 	// LastDeletedBucket stores the name of the last bucket that was deleted
 	LastDeletedBucket string
 	//LastUpdatedBucket
 	LastUpdatedBucket string
+	// LastCreatedObjectPath stores the last object-path that was created
+	LastCreatedObjectPath string
+	// LastCreatedMarkerObjectPath stores the object-path of the last marker that was created
+	LastCreatedMarkerObjectPath string
+	// LastDeletedMarkerObjectPath stores the object-path of the last marker that was deleted
+	LastDeletedMarkerObjectPath string
+	// LastUsagePrefix stores the prefix of the last GetBucketUsage call
+	LastUsagePrefix string
+	// LastObjectPathAsPrefix stores whether the last object-path was checked as a prefix
+	LastObjectPathAsPrefix bool
+	// LastSyncSource and LastSyncTarget store the "<bucket>/<prefix>" of the last SyncPrefix call
+	LastSyncSource string
+	LastSyncTarget string
+	// LastBucketTags stores the tags of the last SetBucketTags call
+	LastBucketTags map[string]string
+	// FailSetBucketTags ...
+	FailSetBucketTags bool
+	// LastReplicationRule stores the rule of the last SetBucketReplication call
+	LastReplicationRule *backend.ReplicationRule
+	// FailSetBucketReplication ...
+	FailSetBucketReplication bool
 }
 
 type fakeObjectStorageSession struct {
@@ -52,10 +118,11 @@ type fakeObjectStorageSession struct {
 }
 
 // NewObjectStorageSession method creates a new fake object store session
-func (f *ObjectStorageSessionFactory) NewObjectStorageSession(endpoint, region string, creds *backend.ObjectStorageCredentials, logger *zap.Logger) backend.ObjectStorageSession {
+func (f *ObjectStorageSessionFactory) NewObjectStorageSession(endpoint, region string, creds *backend.ObjectStorageCredentials, transport backend.TransportConfig, logger *zap.Logger) backend.ObjectStorageSession {
 	f.LastEndpoint = endpoint
 	f.LastRegion = region
 	f.LastCredentials = creds
+	f.LastTransport = transport
 	return &fakeObjectStorageSession{
 		factory: f,
 	}
@@ -66,21 +133,45 @@ func (f *ObjectStorageSessionFactory) ResetStats() {
 	f.LastEndpoint = ""
 	f.LastRegion = ""
 	f.LastCredentials = &backend.ObjectStorageCredentials{}
+	f.LastTransport = backend.TransportConfig{}
 	f.LastCheckedBucket = ""
+	f.LastCheckedObject = ""
 	f.LastCreatedBucket = ""
+	f.LastLocationConstraint = ""
+	f.LastBucketACL = ""
 	f.LastDeletedBucket = ""
 	f.LastUpdatedBucket = ""
+	f.LastCreatedObjectPath = ""
+	f.LastCreatedMarkerObjectPath = ""
+	f.LastDeletedMarkerObjectPath = ""
+	f.LastObjectPathAsPrefix = false
+	f.LastUsagePrefix = ""
+	f.LastExcludePrefixes = nil
+	f.LastBucketTags = nil
+	f.LastReplicationRule = nil
+	f.LastSyncSource = ""
+	f.LastSyncTarget = ""
 }
 
-func (s *fakeObjectStorageSession) CheckBucketAccess(bucket string) error {
+func (s *fakeObjectStorageSession) CheckBucketAccess(ctx context.Context, bucket string) error {
 	s.factory.LastCheckedBucket = bucket
 	if s.factory.FailCheckBucketAccess {
-		return errors.New("")
+		return &backend.Error{Kind: s.factory.FailCheckBucketAccessKind, Err: errors.New("")}
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) CheckObjectAccess(ctx context.Context, bucket, key string) error {
+	s.factory.LastCheckedBucket = bucket
+	s.factory.LastCheckedObject = key
+	if s.factory.FailCheckBucketAccess {
+		return &backend.Error{Kind: s.factory.FailCheckBucketAccessKind, Err: errors.New("")}
 	}
 	return nil
 }
 
-func (s *fakeObjectStorageSession) CheckObjectPathExistence(bucket, objectpath string) (bool, error) {
+func (s *fakeObjectStorageSession) CheckObjectPathExistence(ctx context.Context, bucket, objectpath string, asPrefix bool) (bool, error) {
+	s.factory.LastObjectPathAsPrefix = asPrefix
 	if s.factory.CheckObjectPathExistenceError {
 		return false, errors.New("")
 	} else if s.factory.CheckObjectPathExistencePathNotFound {
@@ -89,18 +180,112 @@ func (s *fakeObjectStorageSession) CheckObjectPathExistence(bucket, objectpath s
 	return true, nil
 }
 
-func (s *fakeObjectStorageSession) CreateBucket(bucket, locationConstraint string) (string, error) {
+func (s *fakeObjectStorageSession) CreateObjectPath(ctx context.Context, bucket, objectpath string) error {
+	s.factory.LastCreatedObjectPath = objectpath
+	if s.factory.FailCreateObjectPath {
+		return errors.New("")
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) CreateObjectPathMarker(ctx context.Context, bucket, objectpath string) error {
+	s.factory.LastCreatedMarkerObjectPath = objectpath
+	if s.factory.FailCreateObjectPathMarker {
+		return errors.New("cannot create marker")
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) DeleteObjectPathMarker(ctx context.Context, bucket, objectpath string) error {
+	s.factory.LastDeletedMarkerObjectPath = objectpath
+	if s.factory.FailDeleteObjectPathMarker {
+		return errors.New("cannot delete marker")
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) GetBucketUsage(ctx context.Context, bucket, prefix string) (*backend.BucketUsage, error) {
+	s.factory.LastCheckedBucket = bucket
+	s.factory.LastUsagePrefix = prefix
+	if s.factory.FailGetBucketUsage {
+		return nil, &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot list bucket")}
+	}
+	usage := s.factory.BucketUsage
+	return &usage, nil
+}
+
+func (s *fakeObjectStorageSession) GetBucketLocation(ctx context.Context, bucket string) (string, error) {
+	if s.factory.FailGetBucketLocation {
+		return "", &backend.Error{Kind: s.factory.FailGetBucketLocationKind, Err: errors.New("cannot get location of bucket")}
+	}
+	return s.factory.BucketLocation, nil
+}
+
+func (s *fakeObjectStorageSession) SetBucketTags(ctx context.Context, bucket string, tags map[string]string) error {
+	s.factory.LastBucketTags = tags
+	if s.factory.FailSetBucketTags {
+		return &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot set tags of bucket")}
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) SetBucketReplication(ctx context.Context, bucket string, rule backend.ReplicationRule) error {
+	s.factory.LastReplicationRule = &rule
+	if s.factory.FailSetBucketReplication {
+		return &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot set replication of bucket")}
+	}
+	return nil
+}
+
+func (s *fakeObjectStorageSession) IsBucketEmpty(ctx context.Context, bucket string, excludePrefixes []string) (bool, error) {
+	s.factory.LastCheckedBucket = bucket
+	s.factory.LastExcludePrefixes = excludePrefixes
+	if s.factory.FailIsBucketEmpty {
+		return false, &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot list bucket")}
+	}
+	return !s.factory.NotEmptyBucket, nil
+}
+
+func (s *fakeObjectStorageSession) ListBuckets(ctx context.Context, prefix string) ([]backend.BucketInfo, error) {
+	if s.factory.FailListBuckets {
+		return nil, &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot list buckets")}
+	}
+	var buckets []backend.BucketInfo
+	for _, bucket := range s.factory.Buckets {
+		if strings.HasPrefix(bucket.Name, prefix) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets, nil
+}
+
+func (s *fakeObjectStorageSession) CreateBucket(ctx context.Context, bucket, locationConstraint, acl string) (string, error) {
 	s.factory.LastCreatedBucket = bucket
+	s.factory.LastLocationConstraint = locationConstraint
+	s.factory.LastBucketACL = acl
 	if s.factory.FailCreateBucket {
 		return "", errors.New(s.factory.FailCreateBucketErrMsg)
 	}
 	return "", nil
 }
 
-func (s *fakeObjectStorageSession) DeleteBucket(bucket string) error {
+func (s *fakeObjectStorageSession) SyncPrefix(ctx context.Context, srcBucket, srcPrefix, dstBucket, dstPrefix string) (*backend.SyncResult, error) {
+	s.factory.LastSyncSource = srcBucket + "/" + srcPrefix
+	s.factory.LastSyncTarget = dstBucket + "/" + dstPrefix
+	if s.factory.FailSyncPrefix {
+		return nil, &backend.Error{Kind: backend.ErrorOther, Err: errors.New("cannot copy object")}
+	}
+	result := s.factory.SyncResult
+	backend.ReportProgress(ctx, result.Copied, result.BytesCopied)
+	return &result, nil
+}
+
+func (s *fakeObjectStorageSession) DeleteBucket(ctx context.Context, bucket string) error {
 	s.factory.LastDeletedBucket = bucket
 	if s.factory.FailDeleteBucket {
 		return errors.New("")
 	}
+	backend.ReportProgress(ctx, s.factory.BucketUsage.ObjectCount, s.factory.BucketUsage.BytesUsed)
+	time.Sleep(s.factory.DeleteBucketDelay)
 	return nil
 }
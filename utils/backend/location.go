@@ -0,0 +1,103 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// cosStorageClasses are the storage classes of the buckets of IBM Cloud Object Storage
+var cosStorageClasses = []string{"standard", "vault", "cold", "smart", "flex", "onerate_active"}
+
+// cosRegion matches the regional (us-south), cross-region (us) and single-site (ams03) locations
+var cosRegion = regexp.MustCompile(`^[a-z]+(-[a-z]+)?[0-9]*$`)
+
+// EndpointRegion returns the location served by an IBM Cloud Object Storage endpoint, e.g. us-south
+// for s3.private.us-south.cloud-object-storage.appdomain.cloud, or "" for the other endpoints
+func EndpointRegion(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	labels := strings.Split(u.Hostname(), ".")
+	for i := 1; i < len(labels); i++ {
+		if labels[i] == "cloud-object-storage" || labels[i] == "objectstorage" {
+			// legacy cross-region endpoints are named us-geo, eu-geo and ap-geo
+			return strings.TrimSuffix(labels[i-1], "-geo")
+		}
+	}
+	return ""
+}
+
+// Types of the IBM Cloud Object Storage endpoints, by the network they are reached through
+const (
+	EndpointPublic  = "public"
+	EndpointPrivate = "private"
+	EndpointDirect  = "direct"
+)
+
+// EndpointType returns the type of an IBM Cloud Object Storage endpoint, e.g. private for
+// s3.private.us-south.cloud-object-storage.appdomain.cloud, or "" for the other endpoints
+func EndpointType(endpoint string) string {
+	if EndpointRegion(endpoint) == "" {
+		return ""
+	}
+	u, _ := url.Parse(endpoint)
+	hostname := u.Hostname()
+	for _, label := range strings.Split(hostname, ".") {
+		if label == EndpointPrivate || label == EndpointDirect {
+			return label
+		}
+	}
+	// the legacy private endpoints are in the service network, e.g. s3.us-south.objectstorage.service.networklayer.com
+	if strings.HasSuffix(hostname, ".service.networklayer.com") {
+		return EndpointPrivate
+	}
+	return EndpointPublic
+}
+
+// LocationConstraint returns the location constraint of the buckets created through an endpoint of
+// IBM Cloud Object Storage for an object-store-storage-class, <location>-<class> e.g. us-south-smart.
+// A class alone gets the location of the endpoint, a location alone the standard class. The
+// values for other endpoints are kept as is, their servers have their own location constraints.
+func LocationConstraint(endpoint, storageClass string) (string, error) {
+	region := EndpointRegion(endpoint)
+	if region == "" {
+		return storageClass, nil
+	}
+
+	value := strings.ToLower(strings.TrimSpace(storageClass))
+	location, class := value, "standard"
+	for _, c := range cosStorageClasses {
+		if value == c {
+			location, class = "", c
+		} else if strings.HasSuffix(value, "-"+c) {
+			location, class = strings.TrimSuffix(value, "-"+c), c
+		}
+	}
+	location = strings.TrimSuffix(location, "-geo")
+	if location == "" {
+		location = region
+	}
+
+	if !cosRegion.MatchString(location) {
+		return "", fmt.Errorf("invalid object-store-storage-class %q, expects <location>-<class> with class one of %s",
+			storageClass, strings.Join(cosStorageClasses, ", "))
+	}
+	if location != region {
+		return "", fmt.Errorf("object-store-storage-class %q is for location %s, endpoint %s serves %s",
+			storageClass, location, endpoint, region)
+	}
+	return location + "-" + class, nil
+}
@@ -0,0 +1,65 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	testCOSEndpoint      = "https://s3.us-south.cloud-object-storage.appdomain.cloud"
+	testPrivateEndpoint  = "https://s3.private.eu-de.cloud-object-storage.appdomain.cloud:443"
+	testCrossRegEndpoint = "https://s3.eu-geo.objectstorage.softlayer.net"
+)
+
+func Test_LocationConstraint(t *testing.T) {
+	for _, test := range []struct {
+		endpoint, storageClass, location string
+	}{
+		{testCOSEndpoint, "us-south-smart", "us-south-smart"},
+		{testCOSEndpoint, " US-South-Vault ", "us-south-vault"},
+		{testCOSEndpoint, "cold", "us-south-cold"},
+		{testCOSEndpoint, "us-south", "us-south-standard"},
+		{testCOSEndpoint, "", "us-south-standard"},
+		{testCOSEndpoint, "us-south-onerate_active", "us-south-onerate_active"},
+		{testPrivateEndpoint, "eu-de-flex", "eu-de-flex"},
+		{testCrossRegEndpoint, "eu-geo-standard", "eu-standard"},
+		{"https://s3.ams03.cloud-object-storage.appdomain.cloud", "smart", "ams03-smart"},
+		{"http://minio.example.com:9000", "us-east-1", "us-east-1"},
+	} {
+		location, err := LocationConstraint(test.endpoint, test.storageClass)
+		if assert.NoError(t, err, "%s %s", test.endpoint, test.storageClass) {
+			assert.Equal(t, test.location, location)
+		}
+	}
+}
+
+func Test_EndpointType(t *testing.T) {
+	assert.Equal(t, EndpointPublic, EndpointType(testCOSEndpoint))
+	assert.Equal(t, EndpointPrivate, EndpointType(testPrivateEndpoint))
+	assert.Equal(t, EndpointPublic, EndpointType(testCrossRegEndpoint))
+	assert.Equal(t, EndpointDirect, EndpointType("https://s3.direct.us-south.cloud-object-storage.appdomain.cloud"))
+	assert.Equal(t, EndpointPrivate, EndpointType("https://s3.us-south.objectstorage.service.networklayer.com"))
+	assert.Equal(t, "", EndpointType("http://minio.example.com:9000"))
+}
+
+func Test_LocationConstraint_Invalid(t *testing.T) {
+	_, err := LocationConstraint(testCOSEndpoint, "eu-de-smart")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is for location eu-de, endpoint "+testCOSEndpoint+" serves us-south")
+	}
+
+	_, err = LocationConstraint(testCOSEndpoint, "us-south-premium!")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid object-store-storage-class \"us-south-premium!\"")
+	}
+}
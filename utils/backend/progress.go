@@ -0,0 +1,33 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+)
+
+// ProgressFunc is called by the long operations of a session, such as DeleteBucket, with the number of
+// objects and bytes processed since the previous call
+type ProgressFunc func(objects, bytes int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context reporting the progress of the session operations it is passed to
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress calls the ProgressFunc of ctx, if any, for the sessions of the other packages and the fakes
+func ReportProgress(ctx context.Context, objects, bytes int64) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(objects, bytes)
+	}
+}
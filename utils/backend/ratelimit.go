@@ -0,0 +1,91 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+var rateLimitWait = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ibmc_s3fs_cos_rate_limit_wait_seconds_total",
+	Help: "Time the object storage requests waited for the rate limit of the provisioner",
+})
+
+func init() {
+	prometheus.MustRegister(rateLimitWait)
+}
+
+// RateLimitConfig bounds the rate of the requests of the sessions of a factory, so that
+// provisioning many volumes at once does not get the service instance throttled.
+// A zero RequestsPerSecond leaves the requests unlimited.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate of the requests, retries included
+	RequestsPerSecond float64
+	// Burst is how many requests may be sent at once after an idle period, 1 when not set
+	Burst int
+}
+
+// Validate checks the settings of the rate limit
+func (c RateLimitConfig) Validate() error {
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("requests per second should be >= 0, got %v", c.RequestsPerSecond)
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("rate limit burst should be >= 0, got %d", c.Burst)
+	}
+	return nil
+}
+
+// rateLimiter delays each request until the token bucket shared by the sessions has a token
+type rateLimiter struct {
+	limiter *rate.Limiter
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	burst := config.Burst
+	if burst == 0 {
+		burst = 1
+	}
+	return &rateLimiter{limiter: rate.NewLimiter(rate.Limit(config.RequestsPerSecond), burst)}
+}
+
+// HandleFinalize waits for a token before each attempt of a call
+func (l *rateLimiter) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	if err := l.limiter.Wait(ctx); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("cannot wait for the rate limit of the object storage requests: %w", err)
+	}
+	rateLimitWait.Add(time.Since(start).Seconds())
+	return next.HandleFinalize(ctx, in)
+}
+
+func (l *rateLimiter) ID() string {
+	return "IBMRateLimiter"
+}
+
+// rateLimiter returns the rate limiter shared by the sessions of the factory, nil when unlimited
+func (s *COSSessionFactory) rateLimiter() *rateLimiter {
+	if s.RateLimit.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.limiter == nil {
+		s.limiter = newRateLimiter(s.RateLimit)
+	}
+	return s.limiter
+}
@@ -0,0 +1,80 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getRateLimitedSession(factory *COSSessionFactory) ObjectStorageSession {
+	return factory.NewObjectStorageSession(testEndpoint, testRegion,
+		&ObjectStorageCredentials{AccessKey: "akey", SecretKey: "skey"}, TransportConfig{}, zap.NewNop())
+}
+
+func Test_RateLimitConfig_Validate(t *testing.T) {
+	assert.NoError(t, RateLimitConfig{}.Validate())
+	assert.NoError(t, RateLimitConfig{RequestsPerSecond: 2.5, Burst: 5}.Validate())
+	assert.Error(t, RateLimitConfig{RequestsPerSecond: -1}.Validate())
+	assert.Error(t, RateLimitConfig{RequestsPerSecond: 1, Burst: -1}.Validate())
+}
+
+func Test_RateLimiter_Shared(t *testing.T) {
+	factory := &COSSessionFactory{}
+	assert.Nil(t, factory.rateLimiter())
+
+	factory.RateLimit = RateLimitConfig{RequestsPerSecond: 1}
+	limiter := factory.rateLimiter()
+	if assert.NotNil(t, limiter) {
+		assert.Equal(t, 1, limiter.limiter.Burst())
+		assert.Same(t, limiter, factory.rateLimiter())
+	}
+}
+
+func Test_RateLimiter_DelaysRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	factory := &COSSessionFactory{Endpoint: server.URL, RateLimit: RateLimitConfig{RequestsPerSecond: 20, Burst: 1}}
+	sess := getRateLimitedSession(factory)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sess.CheckBucketAccess(context.Background(), testBucket))
+	}
+	assert.Equal(t, 3, requests)
+	assert.True(t, time.Since(start) >= 90*time.Millisecond, "requests were not delayed")
+}
+
+func Test_RateLimiter_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	factory := &COSSessionFactory{Endpoint: server.URL, RateLimit: RateLimitConfig{RequestsPerSecond: 0.1}}
+	sess := getRateLimitedSession(factory)
+	assert.NoError(t, sess.CheckBucketAccess(context.Background(), testBucket))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := sess.CheckBucketAccess(ctx, testBucket)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot wait for the rate limit")
+	}
+}
@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBackendName is the name of the IBM Cloud Object Storage backend, the one of the storage
+// classes naming no backend
+const DefaultBackendName = "cos"
+
+// S3BackendName is the name of the backend of the S3 compatible object storages, authenticated with HMAC keys
+const S3BackendName = "s3"
+
+// Registry holds the session factories of the object storage backends other than the default one,
+// by the name storage classes select them with
+type Registry struct {
+	mutex     sync.RWMutex
+	factories map[string]ObjectStorageSessionFactory
+}
+
+// Register adds the session factory of a backend
+func (r *Registry) Register(name string, factory ObjectStorageSessionFactory) error {
+	if name == "" || name == DefaultBackendName {
+		return fmt.Errorf("backend name %q is reserved", name)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("backend %q is already registered", name)
+	}
+	if r.factories == nil {
+		r.factories = map[string]ObjectStorageSessionFactory{}
+	}
+	r.factories[name] = factory
+	return nil
+}
+
+// Factory returns the session factory of a registered backend
+func (r *Registry) Factory(name string) (ObjectStorageSessionFactory, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if factory, ok := r.factories[name]; ok {
+		return factory, nil
+	}
+	return nil, fmt.Errorf("unknown object storage backend %q, expects one of %s", name, strings.Join(r.names(), ", "))
+}
+
+// names returns the default backend and the registered ones, sorted
+func (r *Registry) names() []string {
+	names := []string{DefaultBackendName}
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,34 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	registry := &Registry{}
+	s3 := &COSSessionFactory{}
+	assert.NoError(t, registry.Register("s3", s3))
+	assert.Error(t, registry.Register("s3", &COSSessionFactory{}))
+	assert.Error(t, registry.Register(DefaultBackendName, &COSSessionFactory{}))
+	assert.Error(t, registry.Register("", &COSSessionFactory{}))
+
+	factory, err := registry.Factory("s3")
+	assert.NoError(t, err)
+	assert.True(t, factory == s3)
+
+	_, err = registry.Factory("gcs")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown object storage backend \"gcs\", expects one of cos, s3")
+	}
+}
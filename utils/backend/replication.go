@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ReplicationRule replicates the objects written to a bucket to a target bucket in another region
+type ReplicationRule struct {
+	// TargetBucket is the bucket the objects are replicated to, versioned, writable by the service instance
+	TargetBucket string
+	// TargetRegion is the region of TargetBucket, e.g. eu-de
+	TargetRegion string
+	// Priority orders the rules of a bucket, the highest wins when several rules match an object
+	Priority int32
+}
+
+// ID returns the identifier of the rule on the bucket
+func (r ReplicationRule) ID() string {
+	return "ibm-s3fs-replication-" + r.TargetRegion
+}
+
+// ValidateReplication checks a replication rule of the buckets created through endpoint, the region of the
+// target must be another one than the region of the endpoint when it is known
+func ValidateReplication(rule ReplicationRule, endpoint string) error {
+	if rule.TargetBucket == "" {
+		return errors.New("replication needs a replication-target-bucket")
+	}
+	if !cosRegion.MatchString(rule.TargetRegion) {
+		return fmt.Errorf("invalid replication-target-region %q, expects a region e.g. eu-de", rule.TargetRegion)
+	}
+	if region := EndpointRegion(endpoint); region == rule.TargetRegion {
+		return fmt.Errorf("replication-target-region %s is the region of endpoint %s, expects another region", region, endpoint)
+	}
+	if rule.Priority < 0 {
+		return fmt.Errorf("invalid replication-priority %d, expects a positive integer", rule.Priority)
+	}
+	return nil
+}
+
+// ReplicationTargetARN returns the resource name of the target bucket of a replication rule
+func ReplicationTargetARN(bucket string) string {
+	return "arn:aws:s3:::" + bucket
+}
+
+// SetBucketReplication method enables the versioning of a bucket, which replication needs, and replaces its
+// replication configuration with rule. The new objects and versions are replicated, the delete markers are
+// not. Failures are returned as *Error.
+func (s *COSSession) SetBucketReplication(ctx context.Context, bucket string, rule ReplicationRule) error {
+	ctx, cancel := callContext(ctx)
+	defer cancel()
+
+	_, err := s.svc.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	if err != nil {
+		return newError(fmt.Errorf("cannot enable versioning of bucket '%s': %w", bucket, err))
+	}
+	_, err = s.svc.PutBucketReplication(ctx, &s3.PutBucketReplicationInput{
+		Bucket: aws.String(bucket),
+		ReplicationConfiguration: &types.ReplicationConfiguration{
+			// IBM Cloud Object Storage authorizes the replication between service instances, without a role
+			Role: aws.String(""),
+			Rules: []types.ReplicationRule{{
+				ID:                      aws.String(rule.ID()),
+				Status:                  types.ReplicationRuleStatusEnabled,
+				Priority:                aws.Int32(rule.Priority),
+				Filter:                  &types.ReplicationRuleFilter{Prefix: aws.String("")},
+				DeleteMarkerReplication: &types.DeleteMarkerReplication{Status: types.DeleteMarkerReplicationStatusDisabled},
+				Destination:             &types.Destination{Bucket: aws.String(ReplicationTargetARN(rule.TargetBucket))},
+			}},
+		},
+	})
+	if err != nil {
+		return newError(fmt.Errorf("cannot set replication of bucket '%s' to '%s': %w", bucket, rule.TargetBucket, err))
+	}
+	return nil
+}
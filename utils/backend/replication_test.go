@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+const testUSSouthEndpoint = "https://s3.private.us-south.cloud-object-storage.appdomain.cloud"
+
+func Test_ValidateReplication(t *testing.T) {
+	assert.NoError(t, ValidateReplication(ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de", Priority: 1}, testUSSouthEndpoint))
+	assert.NoError(t, ValidateReplication(ReplicationRule{TargetBucket: "dr", TargetRegion: "us-south"}, "https://minio.example.com"))
+
+	assert.EqualError(t, ValidateReplication(ReplicationRule{TargetRegion: "eu-de"}, testUSSouthEndpoint),
+		"replication needs a replication-target-bucket")
+	assert.EqualError(t, ValidateReplication(ReplicationRule{TargetBucket: "dr"}, testUSSouthEndpoint),
+		`invalid replication-target-region "", expects a region e.g. eu-de`)
+	assert.EqualError(t, ValidateReplication(ReplicationRule{TargetBucket: "dr", TargetRegion: "us-south"}, testUSSouthEndpoint),
+		"replication-target-region us-south is the region of endpoint "+testUSSouthEndpoint+", expects another region")
+	assert.Error(t, ValidateReplication(ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de", Priority: -1}, testUSSouthEndpoint))
+}
+
+func Test_SetBucketReplication_Positive(t *testing.T) {
+	svc := &fakeS3API{}
+	err := getSession(svc).SetBucketReplication(context.Background(), testBucket,
+		ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de", Priority: 2})
+	assert.NoError(t, err)
+	if assert.NotNil(t, svc.VersioningInput) {
+		assert.Equal(t, testBucket, aws.ToString(svc.VersioningInput.Bucket))
+		assert.Equal(t, types.BucketVersioningStatusEnabled, svc.VersioningInput.VersioningConfiguration.Status)
+	}
+	if assert.NotNil(t, svc.ReplicationInput) && assert.Len(t, svc.ReplicationInput.ReplicationConfiguration.Rules, 1) {
+		rule := svc.ReplicationInput.ReplicationConfiguration.Rules[0]
+		assert.Equal(t, "ibm-s3fs-replication-eu-de", aws.ToString(rule.ID))
+		assert.Equal(t, types.ReplicationRuleStatusEnabled, rule.Status)
+		assert.Equal(t, int32(2), aws.ToInt32(rule.Priority))
+		assert.Equal(t, "arn:aws:s3:::dr", aws.ToString(rule.Destination.Bucket))
+	}
+}
+
+func Test_SetBucketReplication_Error(t *testing.T) {
+	svc := &fakeS3API{ErrPutBucketVersioning: responseError(http.StatusForbidden)}
+	err := getSession(svc).SetBucketReplication(context.Background(), testBucket, ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot enable versioning of bucket 'test-bucket'")
+		assert.Equal(t, ErrorAccessDenied, ErrorKindOf(err))
+	}
+	assert.Nil(t, svc.ReplicationInput)
+
+	svc = &fakeS3API{ErrPutBucketReplication: errFoo}
+	err = getSession(svc).SetBucketReplication(context.Background(), testBucket, ReplicationRule{TargetBucket: "dr", TargetRegion: "eu-de"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot set replication of bucket 'test-bucket' to 'dr'")
+	}
+}
@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Classes of the errors a RetryPolicy may retry
+const (
+	// RetryThrottling retries the calls the object storage throttled, like SlowDown answers
+	RetryThrottling = "throttling"
+	// RetryServer retries the calls failing with a 500, 502, 503 or 504 status
+	RetryServer = "server"
+	// RetryConnection retries the calls that could not reach the object storage
+	RetryConnection = "connection"
+	// RetryTimeout retries the calls the object storage timed out, like RequestTimeout answers
+	RetryTimeout = "timeout"
+)
+
+// RetryPolicy tells how the calls to the object storage are retried. Retries wait a random
+// delay between zero and BaseDelay doubled at each attempt, capped at MaxBackoff.
+// Zero fields keep the value of DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a call is tried, retries included
+	MaxAttempts int
+	// BaseDelay is the delay cap of the first retry
+	BaseDelay time.Duration
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+	// RetryableClasses are the classes of the errors that are retried
+	RetryableClasses []string
+}
+
+// DefaultRetryPolicy is the retry policy of the object storage calls
+// when the session factory sets none
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:      5,
+	BaseDelay:        200 * time.Millisecond,
+	MaxBackoff:       20 * time.Second,
+	RetryableClasses: []string{RetryThrottling, RetryServer, RetryConnection, RetryTimeout},
+}
+
+// ParseRetryableClasses parses a comma-separated list of retryable error classes
+func ParseRetryableClasses(value string) ([]string, error) {
+	var classes []string
+	for _, class := range strings.Split(value, ",") {
+		class = strings.TrimSpace(class)
+		if class == "" {
+			continue
+		}
+		if _, ok := retryables(class); !ok {
+			return nil, fmt.Errorf("unknown retryable error class %q, expects %s, %s, %s or %s",
+				class, RetryThrottling, RetryServer, RetryConnection, RetryTimeout)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+// Merge returns r with the non-zero fields of override
+func (r RetryPolicy) Merge(override RetryPolicy) RetryPolicy {
+	if override.MaxAttempts != 0 {
+		r.MaxAttempts = override.MaxAttempts
+	}
+	if override.BaseDelay != 0 {
+		r.BaseDelay = override.BaseDelay
+	}
+	if override.MaxBackoff != 0 {
+		r.MaxBackoff = override.MaxBackoff
+	}
+	if override.RetryableClasses != nil {
+		r.RetryableClasses = override.RetryableClasses
+	}
+	return r
+}
+
+// Validate checks the settings of the policy
+func (r RetryPolicy) Validate() error {
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts should be >= 1, got %d", r.MaxAttempts)
+	}
+	if r.BaseDelay < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("retry delays should be >= 0, got base delay %v and max backoff %v", r.BaseDelay, r.MaxBackoff)
+	}
+	if r.BaseDelay > 0 && r.MaxBackoff > 0 && r.BaseDelay > r.MaxBackoff {
+		return fmt.Errorf("retry base delay %v should not exceed max backoff %v", r.BaseDelay, r.MaxBackoff)
+	}
+	for _, class := range r.RetryableClasses {
+		if _, ok := retryables(class); !ok {
+			return fmt.Errorf("unknown retryable error class %q", class)
+		}
+	}
+	return nil
+}
+
+// retryables returns the checks of the errors of a class
+func retryables(class string) ([]retry.IsErrorRetryable, bool) {
+	switch class {
+	case RetryThrottling:
+		return []retry.IsErrorRetryable{
+			retry.RetryableErrorCode{Codes: retry.DefaultThrottleErrorCodes},
+			retry.RetryableHTTPStatusCode{Codes: map[int]struct{}{http.StatusTooManyRequests: {}}},
+		}, true
+	case RetryServer:
+		return []retry.IsErrorRetryable{retry.RetryableHTTPStatusCode{Codes: retry.DefaultRetryableHTTPStatusCodes}}, true
+	case RetryConnection:
+		return []retry.IsErrorRetryable{retry.RetryableConnectionError{}}, true
+	case RetryTimeout:
+		return []retry.IsErrorRetryable{retry.RetryableErrorCode{Codes: retry.DefaultRetryableErrorCodes}}, true
+	}
+	return nil, false
+}
+
+// backoff returns a random delay before the retry following attempt, the full jitter
+// keeping the retries of many volumes from hitting the object storage at once
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	limit := r.BaseDelay
+	for i := 0; i < attempt && limit < r.MaxBackoff; i++ {
+		limit *= 2
+	}
+	if limit > r.MaxBackoff {
+		limit = r.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// newRetryer returns the SDK retryer applying the policy
+func (r RetryPolicy) newRetryer() aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = r.MaxAttempts
+		o.MaxBackoff = r.MaxBackoff
+		o.Backoff = retry.BackoffDelayerFunc(func(attempt int, err error) (time.Duration, error) {
+			return r.backoff(attempt - 1), nil
+		})
+		// canceled calls are never retried, errors marked retryable by the SDK always are
+		o.Retryables = []retry.IsErrorRetryable{retry.NoRetryCanceledError{}, retry.RetryableError{}}
+		for _, class := range r.RetryableClasses {
+			checks, _ := retryables(class)
+			o.Retryables = append(o.Retryables, checks...)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_ParseRetryableClasses(t *testing.T) {
+	classes, err := ParseRetryableClasses(" throttling,server ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{RetryThrottling, RetryServer}, classes)
+
+	classes, err = ParseRetryableClasses("")
+	assert.NoError(t, err)
+	assert.Nil(t, classes)
+
+	_, err = ParseRetryableClasses("throttling,everything")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown retryable error class \"everything\"")
+	}
+}
+
+func Test_RetryPolicy_Merge(t *testing.T) {
+	policy := DefaultRetryPolicy.Merge(RetryPolicy{MaxAttempts: 10, RetryableClasses: []string{RetryServer}})
+	assert.Equal(t, 10, policy.MaxAttempts)
+	assert.Equal(t, DefaultRetryPolicy.BaseDelay, policy.BaseDelay)
+	assert.Equal(t, DefaultRetryPolicy.MaxBackoff, policy.MaxBackoff)
+	assert.Equal(t, []string{RetryServer}, policy.RetryableClasses)
+}
+
+func Test_RetryPolicy_Validate(t *testing.T) {
+	assert.NoError(t, DefaultRetryPolicy.Validate())
+	assert.NoError(t, RetryPolicy{}.Validate())
+	assert.Error(t, RetryPolicy{MaxAttempts: -1}.Validate())
+	assert.Error(t, RetryPolicy{BaseDelay: time.Minute, MaxBackoff: time.Second}.Validate())
+	assert.Error(t, RetryPolicy{RetryableClasses: []string{"everything"}}.Validate())
+}
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for i := 0; i < 100; i++ {
+		assert.True(t, policy.backoff(0) <= 100*time.Millisecond)
+		assert.True(t, policy.backoff(2) <= 400*time.Millisecond)
+		assert.True(t, policy.backoff(40) <= time.Second)
+		assert.True(t, policy.backoff(40) >= 0)
+	}
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(3))
+}
+
+func Test_RetryPolicy_RetryableClasses(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "SlowDown"}
+	connection := &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsTemporary: true}}
+
+	retryer := DefaultRetryPolicy.Merge(RetryPolicy{RetryableClasses: []string{RetryThrottling}}).newRetryer()
+	assert.True(t, retryer.IsErrorRetryable(throttled))
+	assert.False(t, retryer.IsErrorRetryable(connection))
+	assert.False(t, retryer.IsErrorRetryable(&smithy.GenericAPIError{Code: "AccessDenied"}))
+	assert.False(t, retryer.IsErrorRetryable(context.Canceled))
+	assert.Equal(t, DefaultRetryPolicy.MaxAttempts, retryer.MaxAttempts())
+
+	retryer = DefaultRetryPolicy.Merge(RetryPolicy{RetryableClasses: []string{RetryConnection}}).newRetryer()
+	assert.False(t, retryer.IsErrorRetryable(throttled))
+	assert.True(t, retryer.IsErrorRetryable(connection))
+}
@@ -0,0 +1,128 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MaxCopyObjectSize is the largest object a single server-side copy can copy, 5 GiB
+const MaxCopyObjectSize = 5 << 30
+
+// SyncResult counts the objects of a SyncPrefix call
+type SyncResult struct {
+	// Copied is the number of objects copied, BytesCopied their size
+	Copied      int64
+	BytesCopied int64
+	// Unchanged is the number of objects whose copy was already up to date
+	Unchanged int64
+	// Skipped are the keys of the objects larger than MaxCopyObjectSize, which are not copied
+	Skipped []string
+}
+
+// syncPrefix normalizes a prefix of SyncPrefix: no leading slash and a trailing one unless empty
+func syncPrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	return prefix
+}
+
+// walkObjects calls fn with the objects under prefix inside bucket, each page of the listing bounded
+// by its own call timeout
+func (s *COSSession) walkObjects(ctx context.Context, bucket, prefix string, fn func(types.Object) error) error {
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		listCtx, cancel := callContext(ctx)
+		resp, err := s.svc.ListObjects(listCtx, input)
+		cancel()
+		if err != nil {
+			return newError(fmt.Errorf("cannot list bucket '%s': %w", bucket, err))
+		}
+		for _, object := range resp.Contents {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+		if !aws.ToBool(resp.IsTruncated) || len(resp.Contents) == 0 {
+			return nil
+		}
+		input.Marker = resp.NextMarker
+		if input.Marker == nil {
+			input.Marker = resp.Contents[len(resp.Contents)-1].Key
+		}
+	}
+}
+
+// SyncPrefix method copies the objects under srcPrefix inside srcBucket to dstPrefix inside dstBucket with
+// server-side copies, skipping the objects whose copy has the same size and was written after them. The ETags
+// are not compared, those of the objects uploaded in parts differ from those of their copies. The objects of the
+// destination missing from the source are kept, the objects larger than MaxCopyObjectSize are not copied and
+// reported in the result. The copied objects are reported to the ProgressFunc of ctx, failures are returned as *Error.
+func (s *COSSession) SyncPrefix(ctx context.Context, srcBucket, srcPrefix, dstBucket, dstPrefix string) (*SyncResult, error) {
+	srcPrefix, dstPrefix = syncPrefix(srcPrefix), syncPrefix(dstPrefix)
+	type copyState struct {
+		size     int64
+		modified time.Time
+	}
+	copies := map[string]copyState{}
+	if err := s.walkObjects(ctx, dstBucket, dstPrefix, func(object types.Object) error {
+		copies[strings.TrimPrefix(aws.ToString(object.Key), dstPrefix)] = copyState{
+			size: aws.ToInt64(object.Size), modified: aws.ToTime(object.LastModified)}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	err := s.walkObjects(ctx, srcBucket, srcPrefix, func(object types.Object) error {
+		key := aws.ToString(object.Key)
+		size := aws.ToInt64(object.Size)
+		name := strings.TrimPrefix(key, srcPrefix)
+		if c, ok := copies[name]; ok && c.size == size && !c.modified.Before(aws.ToTime(object.LastModified)) {
+			result.Unchanged++
+			return nil
+		}
+		if size > MaxCopyObjectSize {
+			result.Skipped = append(result.Skipped, key)
+			return nil
+		}
+		copyCtx, cancel := callContext(ctx)
+		_, err := s.svc.CopyObject(copyCtx, &s3.CopyObjectInput{
+			Bucket:     aws.String(dstBucket),
+			Key:        aws.String(dstPrefix + name),
+			CopySource: aws.String(url.PathEscape(srcBucket + "/" + key)),
+		})
+		cancel()
+		if err != nil {
+			return newError(fmt.Errorf("cannot copy object %s/%s to bucket '%s': %w", srcBucket, key, dstBucket, err))
+		}
+		result.Copied++
+		result.BytesCopied += size
+		ReportProgress(ctx, 1, size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
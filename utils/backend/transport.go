@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// caBundleEnv names the CA bundle file trusted in addition to the system CAs
+const caBundleEnv = "AWS_CA_BUNDLE"
+
+// TransportConfig holds the HTTP transport settings of the object storage clients.
+// Zero fields keep the value of the configuration they are merged into.
+type TransportConfig struct {
+	// MaxIdleConns is how many idle connections are kept open to the object storage endpoint
+	MaxIdleConns int
+	// IdleConnTimeout is how long an idle connection is kept open
+	IdleConnTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake of new connections
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for the response headers once a request is sent
+	ResponseHeaderTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes of the connections
+	KeepAlive time.Duration
+}
+
+// DefaultTransportConfig is the transport configuration of the object storage clients
+// when neither flags nor the storage class set one
+var DefaultTransportConfig = TransportConfig{
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: time.Minute,
+	KeepAlive:             30 * time.Second,
+}
+
+// Merge returns c with the non-zero fields of override
+func (c TransportConfig) Merge(override TransportConfig) TransportConfig {
+	if override.MaxIdleConns != 0 {
+		c.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.IdleConnTimeout != 0 {
+		c.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.TLSHandshakeTimeout != 0 {
+		c.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+	if override.ResponseHeaderTimeout != 0 {
+		c.ResponseHeaderTimeout = override.ResponseHeaderTimeout
+	}
+	if override.KeepAlive != 0 {
+		c.KeepAlive = override.KeepAlive
+	}
+	return c
+}
+
+// Validate checks that the settings are not negative
+func (c TransportConfig) Validate() error {
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("max idle connections should be >= 0, got %d", c.MaxIdleConns)
+	}
+	for name, value := range map[string]time.Duration{
+		"idle connection timeout": c.IdleConnTimeout,
+		"TLS handshake timeout":   c.TLSHandshakeTimeout,
+		"response header timeout": c.ResponseHeaderTimeout,
+		"keep-alive":              c.KeepAlive,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s should be >= 0, got %v", name, value)
+		}
+	}
+	return nil
+}
+
+// transportKey identifies the HTTP clients that can share their connections
+type transportKey struct {
+	config TransportConfig
+	// caPEM is the content of the CA bundle, the bundle file is rewritten when its secret changes
+	caPEM string
+}
+
+// newHTTPClient returns a client using the transport settings of c, trusting the CAs of caPEM if any.
+// All the connections go to the same object storage endpoint, so the idle connections are per host.
+func (c TransportConfig) newHTTPClient(caPEM string) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: c.KeepAlive,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConns,
+		IdleConnTimeout:       c.IdleConnTimeout,
+		TLSHandshakeTimeout:   c.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: c.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if caPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+			return nil, fmt.Errorf("no certificate found in CA bundle")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// httpClient returns the client of the transport configuration, shared by the sessions using it
+func (s *COSSessionFactory) httpClient(override TransportConfig) (*http.Client, error) {
+	key := transportKey{config: DefaultTransportConfig.Merge(s.Transport).Merge(override)}
+	if caBundle := os.Getenv(caBundleEnv); caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle: %v", err)
+		}
+		key.caPEM = string(pem)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if client, ok := s.clients[key]; ok {
+		return client, nil
+	}
+	client, err := key.config.newHTTPClient(key.caPEM)
+	if err != nil {
+		return nil, err
+	}
+	if s.clients == nil {
+		s.clients = map[transportKey]*http.Client{}
+	}
+	s.clients[key] = client
+	return client, nil
+}
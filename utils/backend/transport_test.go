@@ -0,0 +1,78 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_TransportConfig_Merge(t *testing.T) {
+	config := DefaultTransportConfig.Merge(TransportConfig{MaxIdleConns: 10, KeepAlive: time.Minute})
+	assert.Equal(t, 10, config.MaxIdleConns)
+	assert.Equal(t, time.Minute, config.KeepAlive)
+	assert.Equal(t, DefaultTransportConfig.IdleConnTimeout, config.IdleConnTimeout)
+	assert.Equal(t, DefaultTransportConfig.TLSHandshakeTimeout, config.TLSHandshakeTimeout)
+	assert.Equal(t, DefaultTransportConfig.ResponseHeaderTimeout, config.ResponseHeaderTimeout)
+}
+
+func Test_TransportConfig_Validate(t *testing.T) {
+	assert.NoError(t, DefaultTransportConfig.Validate())
+	assert.NoError(t, TransportConfig{}.Validate())
+	assert.Error(t, TransportConfig{MaxIdleConns: -1}.Validate())
+	assert.Error(t, TransportConfig{ResponseHeaderTimeout: -time.Second}.Validate())
+}
+
+func Test_HTTPClient_Settings(t *testing.T) {
+	f := &COSSessionFactory{Transport: TransportConfig{MaxIdleConns: 20}}
+	client, err := f.httpClient(TransportConfig{ResponseHeaderTimeout: 5 * time.Second})
+	if assert.NoError(t, err) {
+		transport := client.Transport.(*http.Transport)
+		assert.Equal(t, 20, transport.MaxIdleConns)
+		assert.Equal(t, 20, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 5*time.Second, transport.ResponseHeaderTimeout)
+		assert.Equal(t, DefaultTransportConfig.TLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	}
+}
+
+func Test_HTTPClient_Shared(t *testing.T) {
+	f := &COSSessionFactory{}
+	client1, err := f.httpClient(TransportConfig{})
+	assert.NoError(t, err)
+	client2, err := f.httpClient(DefaultTransportConfig)
+	assert.NoError(t, err)
+	client3, err := f.httpClient(TransportConfig{MaxIdleConns: 1})
+	assert.NoError(t, err)
+	assert.True(t, client1 == client2)
+	assert.False(t, client1 == client3)
+}
+
+func Test_HTTPClient_BadCABundle(t *testing.T) {
+	file, err := ioutil.TempFile("", "ca-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("not a certificate")
+	file.Close()
+	os.Setenv(caBundleEnv, file.Name())
+	defer os.Unsetenv(caBundleEnv)
+
+	f := &COSSessionFactory{}
+	_, err = f.httpClient(TransportConfig{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no certificate found in CA bundle")
+	}
+}
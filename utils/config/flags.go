@@ -0,0 +1,156 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Sources of the value of a flag, from the highest precedence to the lowest
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// FlagLoader completes the flags of a command from the environment and a config file.
+// A flag set on the command line wins over its environment variable, which wins over the
+// config file, which wins over the default value of the flag.
+type FlagLoader struct {
+	// EnvPrefix is prepended to the name of a flag in upper snake case to get its environment
+	// variable, e.g. IBMC_S3FS_METRICS_PORT for metricsPort with the IBMC_S3FS_ prefix
+	EnvPrefix string
+	// EnvAliases are the environment variables of flags read before the prefixed ones existed,
+	// the prefixed variable wins when both are set
+	EnvAliases map[string]string
+	// ConfigFlag is the name of the flag holding the path of the TOML config file, whose keys are the
+	// names of the flags. A missing file is only an error when the path is not the default one.
+	ConfigFlag string
+}
+
+// EnvName returns the environment variable of a flag
+func (l FlagLoader) EnvName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		// a word starts on an upper case letter after a lower case one, or before one for acronyms, e.g. cosTLSHandshake
+		if i > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return l.EnvPrefix + b.String()
+}
+
+// Load sets the flags of fs left unset by the command line, fs being parsed, and returns the source
+// of the value of each flag
+func (l FlagLoader) Load(fs *flag.FlagSet) (map[string]string, error) {
+	sources := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) { sources[f.Name] = SourceDefault })
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = SourceFlag })
+
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if sources[f.Name] != SourceDefault {
+			return
+		}
+		name := l.EnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok && l.EnvAliases[f.Name] != "" {
+			name = l.EnvAliases[f.Name]
+			value, ok = os.LookupEnv(name)
+		}
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value %q of %s: %v", value, name, err))
+			return
+		}
+		sources[f.Name] = SourceEnv
+	})
+	if len(errs) > 0 {
+		return sources, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	configFlag := fs.Lookup(l.ConfigFlag)
+	if configFlag == nil || configFlag.Value.String() == "" {
+		return sources, nil
+	}
+	var values map[string]interface{}
+	if _, err := toml.DecodeFile(configFlag.Value.String(), &values); err != nil {
+		if os.IsNotExist(err) && sources[l.ConfigFlag] == SourceDefault {
+			return sources, nil
+		}
+		return sources, fmt.Errorf("cannot read config file %s: %v", configFlag.Value.String(), err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		source, ok := sources[name]
+		if !ok || name == l.ConfigFlag {
+			errs = append(errs, fmt.Sprintf("unknown setting %s", name))
+			continue
+		}
+		if source != SourceDefault {
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(values[name])); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value %v of %s: %v", values[name], name, err))
+			continue
+		}
+		sources[name] = SourceFile
+	}
+	if len(errs) > 0 {
+		return sources, fmt.Errorf("invalid config file %s: %s", configFlag.Value.String(), strings.Join(errs, "; "))
+	}
+	return sources, nil
+}
+
+// isSecretSetting tells whether the value of a setting must not be logged
+func isSecretSetting(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range []string{"key", "token", "password", "secret", "credential"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveConfig describes the value and source of each flag of fs, for a startup dump.
+// The values of the settings named like secrets are masked.
+func EffectiveConfig(fs *flag.FlagSet, sources map[string]string) map[string]string {
+	effective := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if isSecretSetting(f.Name) && value != "" {
+			value = "XXX"
+		}
+		source := sources[f.Name]
+		if source == "" {
+			source = SourceDefault
+		}
+		effective[f.Name] = value + " (" + source + ")"
+	})
+	return effective
+}
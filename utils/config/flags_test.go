@@ -0,0 +1,122 @@
+/*******************************************************************************
+ * IBM Confidential
+ * OCO Source Materials
+ * IBM Cloud Container Service, 5737-D43
+ * (C) Copyright IBM Corp. 2017, 2018 All Rights Reserved.
+ * The source code for this program is not  published or otherwise divested of
+ * its trade secrets, irrespective of what has been deposited with
+ * the U.S. Copyright Office.
+ ******************************************************************************/
+
+package config
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testLoader = FlagLoader{
+	EnvPrefix:  "TEST_",
+	EnvAliases: map[string]string{"clusterID": "TEST_LEGACY_CLUSTER_ID"},
+	ConfigFlag: "config",
+}
+
+func getTestFlags(configFile string) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config", configFile, "")
+	fs.Int("metricsPort", 0, "")
+	fs.String("clusterID", "", "")
+	fs.Duration("leaseDuration", 15*time.Second, "")
+	fs.String("apiKey", "", "")
+	fs.Bool("debugTrace", false, "")
+	return fs
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestFlagLoader_EnvName(t *testing.T) {
+	assert.Equal(t, "TEST_METRICS_PORT", testLoader.EnvName("metricsPort"))
+	assert.Equal(t, "TEST_CLUSTER_ID", testLoader.EnvName("clusterID"))
+	assert.Equal(t, "TEST_COS_TLS_HANDSHAKE_TIMEOUT", testLoader.EnvName("cosTLSHandshakeTimeout"))
+}
+
+func TestFlagLoader_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "metricsPort = 9090\nclusterID = \"file\"\nleaseDuration = \"1m\"\ndebugTrace = true\n")
+	fs := getTestFlags(path)
+	require.NoError(t, fs.Parse([]string{"-metricsPort=8080"}))
+	t.Setenv("TEST_METRICS_PORT", "7070")
+	t.Setenv("TEST_CLUSTER_ID", "env")
+	t.Setenv("TEST_LEGACY_CLUSTER_ID", "legacy")
+
+	sources, err := testLoader.Load(fs)
+	require.NoError(t, err)
+	assert.Equal(t, "8080", fs.Lookup("metricsPort").Value.String())
+	assert.Equal(t, SourceFlag, sources["metricsPort"])
+	assert.Equal(t, "env", fs.Lookup("clusterID").Value.String())
+	assert.Equal(t, SourceEnv, sources["clusterID"])
+	assert.Equal(t, "1m0s", fs.Lookup("leaseDuration").Value.String())
+	assert.Equal(t, SourceFile, sources["leaseDuration"])
+	assert.Equal(t, "true", fs.Lookup("debugTrace").Value.String())
+	assert.Equal(t, SourceDefault, sources["apiKey"])
+}
+
+func TestFlagLoader_EnvAlias(t *testing.T) {
+	fs := getTestFlags("")
+	require.NoError(t, fs.Parse(nil))
+	t.Setenv("TEST_LEGACY_CLUSTER_ID", "legacy")
+
+	sources, err := testLoader.Load(fs)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", fs.Lookup("clusterID").Value.String())
+	assert.Equal(t, SourceEnv, sources["clusterID"])
+}
+
+func TestFlagLoader_Errors(t *testing.T) {
+	fs := getTestFlags("")
+	require.NoError(t, fs.Parse(nil))
+	t.Setenv("TEST_METRICS_PORT", "many")
+	_, err := testLoader.Load(fs)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid value \"many\" of TEST_METRICS_PORT")
+	}
+
+	fs = getTestFlags(writeConfigFile(t, "unknown = 1\n"))
+	require.NoError(t, fs.Parse(nil))
+	t.Setenv("TEST_METRICS_PORT", "1")
+	_, err = testLoader.Load(fs)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown setting unknown")
+	}
+
+	// a missing config file is only an error when it is configured
+	fs = getTestFlags("/nonexistent/config.toml")
+	require.NoError(t, fs.Parse(nil))
+	_, err = testLoader.Load(fs)
+	assert.NoError(t, err)
+	require.NoError(t, fs.Parse([]string{"-config=/nonexistent/config.toml"}))
+	_, err = testLoader.Load(fs)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot read config file /nonexistent/config.toml")
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	fs := getTestFlags("")
+	require.NoError(t, fs.Parse([]string{"-apiKey=secret-value", "-metricsPort=8080"}))
+	sources, err := testLoader.Load(fs)
+	require.NoError(t, err)
+
+	effective := EffectiveConfig(fs, sources)
+	assert.Equal(t, "XXX (flag)", effective["apiKey"])
+	assert.Equal(t, "8080 (flag)", effective["metricsPort"])
+	assert.Equal(t, "15s (default)", effective["leaseDuration"])
+}
@@ -14,8 +14,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"net"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // DecodeBase64 decodes a base64 string
@@ -28,17 +34,130 @@ func DecodeBase64(encoded string) (string, error) {
 	return string(bytes), nil
 }
 
-// UnmarshalMap unmarshal a map[string]string to an interface (via JSON decoding)
+// ParseBool parses a boolean written as true/false, yes/no, on/off or 1/0, case-insensitive
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "t", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "f", "no", "n", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q, expects true/false, yes/no, on/off or 1/0", value)
+}
+
+// mapField is a field of the struct decoded by UnmarshalMap
+type mapField struct {
+	isBool       bool
+	hasDefault   bool
+	defaultValue string
+}
+
+// mapFields returns the fields of the struct pointed by v by their JSON name
+func mapFields(v interface{}) map[string]mapField {
+	fields := map[string]mapField{}
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fields
+	}
+	t = t.Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		defaultValue, hasDefault := t.Field(i).Tag.Lookup("default")
+		fields[name] = mapField{
+			isBool:       t.Field(i).Type.Kind() == reflect.Bool,
+			hasDefault:   hasDefault,
+			defaultValue: defaultValue,
+		}
+	}
+	return fields
+}
+
+// UnmarshalMap unmarshal a map[string]string to an interface (via JSON decoding).
+// The values of the boolean fields are read with ParseBool, and the fields with a
+// `default:"..."` tag get that value when their entry is missing or empty.
+// The entries that cannot be unmarshaled are all reported in the returned error,
+// the valid ones are still set on v.
 func UnmarshalMap(m *map[string]string, v interface{}) error {
+	if fields := mapFields(v); len(fields) > 0 {
+		normalized := make(map[string]string, len(*m))
+		for name, field := range fields {
+			if field.hasDefault && (*m)[name] == "" {
+				normalized[name] = field.defaultValue
+			}
+		}
+		for key, value := range *m {
+			if _, ok := normalized[key]; ok {
+				continue
+			}
+			if b, err := ParseBool(value); err == nil && fields[key].isBool {
+				value = strconv.FormatBool(b)
+			}
+			normalized[key] = value
+		}
+		m = &normalized
+	}
 	jsonBytes, err := json.Marshal(*m)
 	if err != nil {
 		return fmt.Errorf("cannot marshal map: %v", err)
 	}
-	err = json.Unmarshal(jsonBytes, v)
-	if err != nil {
+	if err = json.Unmarshal(jsonBytes, v); err == nil {
+		return nil
+	}
+
+	// the JSON decoding stops on the first error, decode the entries one by one to find them all
+	keys := make([]string, 0, len(*m))
+	for key := range *m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		entryBytes, _ := json.Marshal(map[string]string{key: (*m)[key]})
+		if err := json.Unmarshal(entryBytes, v); err != nil {
+			errs = append(errs, fmt.Errorf("cannot unmarshal %s %q: %v", key, (*m)[key], err))
+		}
+	}
+	if len(errs) == 0 {
 		return fmt.Errorf("cannot unmarshal '%s': %v", string(jsonBytes), err)
 	}
-	return nil
+	return utilerrors.NewAggregate(errs)
+}
+
+// ParseSeconds parses a number of seconds given as an integer or as a Go duration, e.g. "90s" or "1m30s".
+// A duration must be a whole number of seconds.
+func ParseSeconds(value string) (int, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expects a number of seconds or a duration such as 90s", value)
+	}
+	if duration%time.Second != 0 {
+		return 0, fmt.Errorf("invalid duration %q, expects a whole number of seconds", value)
+	}
+	return int(duration / time.Second), nil
+}
+
+// ParseSize parses a size given as an integer number of units or as a quantity, e.g. "64Mi" or "100k", and
+// returns it in units of the given number of bytes, 1 for counts. A quantity must be a whole number of units.
+func ParseSize(value string, unit int64) (int, error) {
+	if size, err := strconv.Atoi(value); err == nil {
+		return size, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, expects an integer or a quantity such as 64Mi or 100k", value)
+	}
+	size, ok := quantity.AsInt64()
+	if !ok || size%unit != 0 {
+		return 0, fmt.Errorf("invalid size %q, expects a whole multiple of %s", value,
+			resource.NewQuantity(unit, resource.BinarySI).String())
+	}
+	return int(size / unit), nil
 }
 
 // MarshalToMap converts an interface to map[string]string (via JSON encoding)
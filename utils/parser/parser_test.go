@@ -39,6 +39,70 @@ func Test_UnmarshalMap_Error(t *testing.T) {
 	}
 }
 
+func Test_UnmarshalMap_AllErrors(t *testing.T) {
+	var v struct {
+		A int  `json:"a,string"`
+		B bool `json:"b,string"`
+		C int  `json:"c,string"`
+	}
+	err := UnmarshalMap(&map[string]string{"a": "one", "b": "maybe", "c": "3"}, &v)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot unmarshal a \"one\"")
+		assert.Contains(t, err.Error(), "cannot unmarshal b \"maybe\"")
+	}
+	assert.Equal(t, 3, v.C)
+}
+
+func Test_UnmarshalMap_Bool(t *testing.T) {
+	var v struct {
+		A bool   `json:"a,string"`
+		B bool   `json:"b,string"`
+		C string `json:"c"`
+	}
+	err := UnmarshalMap(&map[string]string{"a": "Yes", "b": "off", "c": "on"}, &v)
+	if assert.NoError(t, err) {
+		assert.True(t, v.A)
+		assert.False(t, v.B)
+		assert.Equal(t, "on", v.C)
+	}
+}
+
+func Test_UnmarshalMap_Defaults(t *testing.T) {
+	var v struct {
+		A int    `json:"a,string" default:"10"`
+		B string `json:"b" default:"warn"`
+		C int    `json:"c,string" default:"5"`
+		D string `json:"d"`
+	}
+	m := map[string]string{"b": "", "c": "7"}
+	err := UnmarshalMap(&m, &v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 10, v.A)
+		assert.Equal(t, "warn", v.B)
+		assert.Equal(t, 7, v.C)
+		assert.Equal(t, "", v.D)
+	}
+	// the defaults are not written to the map
+	assert.Equal(t, map[string]string{"b": "", "c": "7"}, m)
+}
+
+func Test_ParseBool(t *testing.T) {
+	for _, value := range []string{"true", "TRUE", "yes", "Y", "on", "1", " On "} {
+		b, err := ParseBool(value)
+		assert.NoError(t, err, value)
+		assert.True(t, b, value)
+	}
+	for _, value := range []string{"false", "No", "n", "OFF", "0"} {
+		b, err := ParseBool(value)
+		assert.NoError(t, err, value)
+		assert.False(t, b, value)
+	}
+	_, err := ParseBool("maybe")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects true/false, yes/no, on/off or 1/0")
+	}
+}
+
 func Test_UnmarshalMap_Positive(t *testing.T) {
 	var v testType
 	err := UnmarshalMap(&map[string]string{"t": "5"}, &v)
@@ -47,6 +111,52 @@ func Test_UnmarshalMap_Positive(t *testing.T) {
 	}
 }
 
+func Test_ParseSeconds_Positive(t *testing.T) {
+	for value, expected := range map[string]int{"90": 90, "90s": 90, "1m30s": 90, "2h": 7200, "-5": -5} {
+		seconds, err := ParseSeconds(value)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, seconds, value)
+		}
+	}
+}
+
+func Test_ParseSeconds_Error(t *testing.T) {
+	_, err := ParseSeconds("ninety")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects a number of seconds or a duration")
+	}
+	_, err = ParseSeconds("1500ms")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects a whole number of seconds")
+	}
+}
+
+func Test_ParseSize_Positive(t *testing.T) {
+	for value, expected := range map[string]int{"64": 64, "64Mi": 64, "1Gi": 1024, "-1": -1} {
+		size, err := ParseSize(value, 1<<20)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, size, value)
+		}
+	}
+	for value, expected := range map[string]int{"100000": 100000, "100k": 100000, "1M": 1000000, "2Ki": 2048} {
+		size, err := ParseSize(value, 1)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, size, value)
+		}
+	}
+}
+
+func Test_ParseSize_Error(t *testing.T) {
+	_, err := ParseSize("big", 1<<20)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expects an integer or a quantity such as 64Mi or 100k")
+	}
+	_, err = ParseSize("64M", 1<<20)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid size \"64M\", expects a whole multiple of 1Mi")
+	}
+}
+
 func Test_MarshalToMap_MarshalError(t *testing.T) {
 	type badType struct {
 		F func()